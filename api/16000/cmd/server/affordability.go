@@ -0,0 +1,31 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/itmtjewelry/land-booking-kpr/internal/domain"
+)
+
+const defaultMaxDSR = 40
+
+// dsrPolicyFromEnv reads KPR_MAX_DSR (percent of monthly income, in (0, 100])
+// and KPR_DSR_MODE (block or flag).
+func dsrPolicyFromEnv() (domain.DSRPolicy, error) {
+	p := domain.DSRPolicy{MaxDSR: defaultMaxDSR, Mode: domain.DSRBlock}
+	if v := os.Getenv("KPR_MAX_DSR"); v != "" {
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil || !(f > 0 && f <= 100) {
+			return p, fmt.Errorf("invalid KPR_MAX_DSR: %q", v)
+		}
+		p.MaxDSR = f
+	}
+	if v := os.Getenv("KPR_DSR_MODE"); v != "" {
+		if v != domain.DSRBlock && v != domain.DSRFlag {
+			return p, fmt.Errorf("invalid KPR_DSR_MODE: %q", v)
+		}
+		p.Mode = v
+	}
+	return p, nil
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/itmtjewelry/land-booking-kpr/internal/logging"
+	"github.com/itmtjewelry/land-booking-kpr/internal/migrate"
+	"github.com/itmtjewelry/land-booking-kpr/internal/storage"
+)
+
+const backupPruneInterval = 6 * time.Hour
+
+// backupRetentionFromEnv reads BACKUP_RETENTION_DAYS and BACKUP_KEEP_PER_FILE
+// (0 disables a limit), defaulting to storage.DefaultBackupRetention.
+func backupRetentionFromEnv() (storage.RetentionPolicy, error) {
+	p := storage.DefaultBackupRetention
+	if v := os.Getenv("BACKUP_RETENTION_DAYS"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return p, fmt.Errorf("invalid BACKUP_RETENTION_DAYS: %q", v)
+		}
+		p.MaxAge = time.Duration(n) * 24 * time.Hour
+	}
+	if v := os.Getenv("BACKUP_KEEP_PER_FILE"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return p, fmt.Errorf("invalid BACKUP_KEEP_PER_FILE: %q", v)
+		}
+		p.KeepPerFile = n
+	}
+	return p, nil
+}
+
+// runBackupPruner prunes once at startup and then every backupPruneInterval.
+func runBackupPruner(logger *logging.CSVLogger, dir string, policy storage.RetentionPolicy, stop <-chan struct{}) {
+	prune := func() {
+		removed, err := storage.PruneBackups(dir, policy, time.Now())
+		if err != nil {
+			logger.Log("ERROR", "backup_prune_failed", "", "storage", dir, err.Error())
+			return
+		}
+		if len(removed) > 0 {
+			logger.Log("INFO", "backup_pruned", "", "storage", dir, fmt.Sprintf("removed=%d", len(removed)))
+		}
+	}
+
+	prune()
+	t := time.NewTicker(backupPruneInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C:
+			prune()
+		case <-stop:
+			return
+		}
+	}
+}
+
+// runBackupsCLI implements `server backups list|restore|prune` against STORAGE_DIR.
+// A restore holds the storage directory exclusively; a running server reloads
+// the restored file afterwards.
+func runBackupsCLI(args []string) int {
+	usage := func() int {
+		_, _ = fmt.Fprintln(os.Stderr, "usage: server backups list <file> | restore <file> <backup> | prune")
+		return 2
+	}
+	if len(args) == 0 {
+		return usage()
+	}
+
+	dir := os.Getenv("STORAGE_DIR")
+	if dir == "" {
+		_, _ = fmt.Fprintln(os.Stderr, "STORAGE_DIR is required")
+		return 1
+	}
+
+	switch args[0] {
+	case "list":
+		if len(args) != 2 {
+			return usage()
+		}
+		backups, err := storage.ListBackups(dir, args[1])
+		if err != nil {
+			_, _ = fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		for _, b := range backups {
+			fmt.Printf("%s\t%s\t%d\n", b.Name, b.CreatedAt.Format(time.RFC3339), b.Size)
+		}
+	case "restore":
+		if len(args) != 3 {
+			return usage()
+		}
+		lock, ok := lockStorageDirCLI(dir, true)
+		if !ok {
+			return 1
+		}
+		defer func() { _ = lock.Unlock() }()
+		if err := storage.RestoreBackup(dir, args[1], args[2]); err != nil {
+			_, _ = fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		fmt.Printf("restored %s from %s\n", args[1], args[2])
+		rep, err := migrate.File(dir, args[1])
+		if err != nil {
+			_, _ = fmt.Fprintln(os.Stderr, "migration failed:", err)
+			return 1
+		}
+		printMigrateReport(rep)
+	case "prune":
+		policy, err := backupRetentionFromEnv()
+		if err != nil {
+			_, _ = fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		removed, err := storage.PruneBackups(dir, policy, time.Now())
+		if err != nil {
+			_, _ = fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		for _, b := range removed {
+			fmt.Println("removed", b.Name)
+		}
+		fmt.Printf("pruned %d backup(s)\n", len(removed))
+	default:
+		return usage()
+	}
+	return 0
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"sort"
+
+	"github.com/itmtjewelry/land-booking-kpr/internal/domain"
+)
+
+// index returns the ids of filename's records grouped by their string field
+// (blank keys are left out), sorted. It is built from the raw decoded items,
+// not the typed records, so a record that fails typed decoding is still
+// found, as by a scan of GetItems. It is built once per snapshot, so a
+// reload of the file rebuilds it on next use.
+func index(rs *runtimeState, filename, field string) map[string][]string {
+	snap := rs.snapshot(filename)
+	if snap == nil {
+		return nil
+	}
+	snap.idxMu.Lock()
+	defer snap.idxMu.Unlock()
+	if m, ok := snap.idx[field]; ok {
+		return m
+	}
+
+	m := map[string][]string{}
+	for id, v := range snap.items {
+		rec, _ := v.(map[string]any)
+		if k, _ := rec[field].(string); k != "" {
+			m[k] = append(m[k], id)
+		}
+	}
+	for _, ids := range m {
+		sort.Strings(ids)
+	}
+	if snap.idx == nil {
+		snap.idx = map[string]map[string][]string{}
+	}
+	snap.idx[field] = m
+	return m
+}
+
+func (rs *runtimeState) ZonesBySubsite(subsiteID string) []string {
+	return index(rs, domain.ZonesFile, "subsite_id")[subsiteID]
+}
+
+func (rs *runtimeState) BookingsByZone(zoneID string) []string {
+	return index(rs, domain.BookingsFile, "zone_id")[zoneID]
+}
+
+func (rs *runtimeState) KPRsByBooking(bookingID string) []string {
+	return index(rs, domain.KPRApplicationsFile, "booking_id")[bookingID]
+}
+
+func (rs *runtimeState) PlansByKPR(kprID string) []string {
+	return index(rs, domain.InstallmentPlansFile, "kpr_id")[kprID]
+}
+
+func (rs *runtimeState) PaymentsByKPR(kprID string) []string {
+	return index(rs, domain.PaymentsFile, "kpr_id")[kprID]
+}
+
+func (rs *runtimeState) PaymentsByBooking(bookingID string) []string {
+	return index(rs, domain.PaymentsFile, "booking_id")[bookingID]
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/itmtjewelry/land-booking-kpr/internal/domain"
+)
+
+// indexCase is one index checked against a linear scan of its file.
+type indexCase struct {
+	name  string
+	file  string
+	field string
+	get   func(*runtimeState, string) []string
+}
+
+var indexCases = []indexCase{
+	{"ZonesBySubsite", domain.ZonesFile, "subsite_id", (*runtimeState).ZonesBySubsite},
+	{"BookingsByZone", domain.BookingsFile, "zone_id", (*runtimeState).BookingsByZone},
+	{"KPRsByBooking", domain.KPRApplicationsFile, "booking_id", (*runtimeState).KPRsByBooking},
+	{"PlansByKPR", domain.InstallmentPlansFile, "kpr_id", (*runtimeState).PlansByKPR},
+	{"PaymentsByKPR", domain.PaymentsFile, "kpr_id", (*runtimeState).PaymentsByKPR},
+	{"PaymentsByBooking", domain.PaymentsFile, "booking_id", (*runtimeState).PaymentsByBooking},
+}
+
+// linearScan is what the index replaces: every id of file whose field is key.
+func linearScan(rs *runtimeState, file, field, key string) []string {
+	var out []string
+	for id, v := range rs.GetItems(file) {
+		if m, _ := v.(map[string]any); m != nil && m[field] == key {
+			out = append(out, id)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+// indexSeed spreads n records of each indexed file over three keys, with
+// every fourth record left without a key.
+func indexSeed(n int) map[string]map[string]any {
+	seed := map[string]map[string]any{}
+	for _, tc := range indexCases {
+		if seed[tc.file] == nil {
+			seed[tc.file] = map[string]any{}
+		}
+		for i := 0; i < n; i++ {
+			id := fmt.Sprintf("%s_%d", tc.file[:3], i)
+			rec, _ := seed[tc.file][id].(map[string]any)
+			if rec == nil {
+				rec = map[string]any{"id": id}
+				seed[tc.file][id] = rec
+			}
+			if i%4 != 3 {
+				rec[tc.field] = fmt.Sprintf("%s_k%d", tc.field, i%3)
+			}
+		}
+	}
+	return seed
+}
+
+func checkIndexes(t *testing.T, rs *runtimeState) {
+	t.Helper()
+	for _, tc := range indexCases {
+		for _, key := range []string{tc.field + "_k0", tc.field + "_k1", tc.field + "_k2", "missing"} {
+			got, want := tc.get(rs, key), linearScan(rs, tc.file, tc.field, key)
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("%s(%s) = %v, want %v", tc.name, key, got, want)
+			}
+		}
+		if got := tc.get(rs, ""); got != nil {
+			t.Errorf("%s indexes records without a key: %v", tc.name, got)
+		}
+	}
+}
+
+func TestIndexesMatchLinearScan(t *testing.T) {
+	rs := newTestState(t, indexSeed(10))
+	checkIndexes(t, rs)
+
+	// Rewrite every indexed file with other records; the indexes follow.
+	seed := indexSeed(7)
+	for _, tc := range indexCases {
+		for _, v := range seed[tc.file] {
+			if rec := v.(map[string]any); rec[tc.field] == tc.field+"_k0" {
+				rec[tc.field] = tc.field + "_k2"
+			}
+		}
+	}
+	var files []string
+	for name, items := range seed {
+		writeTestFile(t, rs.StorageDir(), name, items)
+		files = append(files, name)
+	}
+	if err := rs.ReloadFiles(files...); err != nil {
+		t.Fatal(err)
+	}
+	checkIndexes(t, rs)
+	if got := rs.ZonesBySubsite("subsite_id_k0"); got != nil {
+		t.Fatalf("index kept ids from before the reload: %v", got)
+	}
+}
+
+func TestIndexRebuiltOnlyForReloadedFile(t *testing.T) {
+	rs := newTestState(t, indexSeed(4))
+	zones := rs.ZonesBySubsite("subsite_id_k0")
+	bookings := rs.BookingsByZone("zone_id_k0")
+
+	writeTestFile(t, rs.StorageDir(), domain.BookingsFile, map[string]any{
+		"b_new": map[string]any{"id": "b_new", "zone_id": "zone_id_k0"},
+	})
+	if err := rs.ReloadFiles(domain.BookingsFile); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := rs.BookingsByZone("zone_id_k0"); !reflect.DeepEqual(got, []string{"b_new"}) {
+		t.Fatalf("BookingsByZone after reload = %v (was %v)", got, bookings)
+	}
+	// The zones index belongs to the untouched zones snapshot and is reused.
+	if got := rs.ZonesBySubsite("subsite_id_k0"); len(got) == 0 || &got[0] != &zones[0] {
+		t.Fatalf("ZonesBySubsite rebuilt: %v", got)
+	}
+}
+
+// A record the typed decoding drops (created_at is not a string) is still
+// indexed, as a linear scan finds it.
+func TestIndexesKeepUndecodableRecords(t *testing.T) {
+	seed := indexSeed(4)
+	for _, tc := range indexCases {
+		id := tc.file[:3] + "_bad"
+		rec, _ := seed[tc.file][id].(map[string]any)
+		if rec == nil {
+			rec = map[string]any{"id": id, "created_at": 1}
+			seed[tc.file][id] = rec
+		}
+		rec[tc.field] = tc.field + "_k0"
+	}
+	rs := newTestState(t, seed)
+	if _, ok := rs.Zones()["zon_bad"]; ok {
+		t.Fatal("zon_bad decoded; the test needs a record typed decoding drops")
+	}
+
+	checkIndexes(t, rs)
+	if got := rs.BookingsByZone("zone_id_k0"); len(got) == 0 || got[len(got)-1] != "boo_bad" {
+		t.Fatalf("BookingsByZone misses boo_bad: %v", got)
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/itmtjewelry/land-booking-kpr/internal/integrity"
+	"github.com/itmtjewelry/land-booking-kpr/internal/storage"
+)
+
+// runIntegrityCLI implements `server integrity [-repair]` against STORAGE_DIR.
+// It exits 0 when clean and 4 when issues remain. -repair holds the storage
+// directory exclusively, so it is safe next to a running server.
+func runIntegrityCLI(args []string) int {
+	fs := flag.NewFlagSet("integrity", flag.ContinueOnError)
+	repair := fs.Bool("repair", false, "recompute dp_paid and schedule paid_amount from the payments ledger")
+	if err := fs.Parse(args); err != nil || fs.NArg() != 0 {
+		_, _ = fmt.Fprintln(os.Stderr, "usage: server integrity [-repair]")
+		return 2
+	}
+
+	dir := os.Getenv("STORAGE_DIR")
+	if dir == "" {
+		_, _ = fmt.Fprintln(os.Stderr, "STORAGE_DIR is required")
+		return 1
+	}
+	lock, ok := lockStorageDirCLI(dir, *repair)
+	if !ok {
+		return 1
+	}
+	defer func() { _ = lock.Unlock() }()
+
+	lr, err := storage.ReloadCore(dir)
+	if err != nil {
+		_, _ = fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	if *repair {
+		changed, fixed, err := integrity.Repair(lr.Loaded)
+		if err != nil {
+			_, _ = fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		names := make([]string, 0, len(changed))
+		for name := range changed {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		if len(names) > 0 {
+			tx := storage.BeginTx(dir)
+			for _, name := range names {
+				tx.Stage(name, changed[name])
+				lr.Loaded[name] = changed[name]
+			}
+			if err := tx.Commit(); err != nil {
+				_, _ = fmt.Fprintln(os.Stderr, err)
+				return 1
+			}
+		}
+		for _, is := range fixed {
+			fmt.Printf("repaired\t%s\t%s\t%s\t%s\n", is.Kind, is.File, is.ID, is.Message)
+		}
+	}
+
+	rep := integrity.Check(lr.Loaded)
+	for _, is := range rep.Issues {
+		fmt.Printf("%s\t%s\t%s\t%s\n", is.Kind, is.File, is.ID, is.Message)
+	}
+	if rep.OK() {
+		fmt.Println("no integrity issues")
+		return 0
+	}
+	fmt.Printf("%d issue(s)\n", len(rep.Issues))
+	return 4
+}
@@ -2,145 +2,133 @@ package main
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"net/http"
 	"os"
 	"os/signal"
-	"sync"
+	"strings"
 	"syscall"
 	"time"
 
-	"github.com/itmtjewelry/land-booking-kpr/internal/app"
+	"github.com/itmtjewelry/land-booking-kpr/internal/auth"
 	corehttp "github.com/itmtjewelry/land-booking-kpr/internal/http"
 	"github.com/itmtjewelry/land-booking-kpr/internal/httpapi"
 	"github.com/itmtjewelry/land-booking-kpr/internal/logging"
 	"github.com/itmtjewelry/land-booking-kpr/internal/storage"
 )
 
-type runtimeState struct {
-	mu sync.RWMutex
-
-	storageDir string
-	ready      bool
-	loaded     map[string]storage.JSONFile
-	loadedList []string
-
-	fileMu map[string]*sync.Mutex
-}
-
-func newRuntimeState(storageDir string, lr *storage.LoadResult) *runtimeState {
-	return &runtimeState{
-		storageDir: storageDir,
-		ready:      true,
-		loaded:     lr.Loaded,
-		loadedList: lr.LoadedList,
-		fileMu: map[string]*sync.Mutex{
-			"sites.json":    &sync.Mutex{},
-			"subsites.json": &sync.Mutex{},
-			"zones.json":    &sync.Mutex{},
-			"users.json":    &sync.Mutex{},
-			"bookings.json": &sync.Mutex{},
-			"domains.json":  &sync.Mutex{},
-		},
+func main() {
+	// Every loader and writer, CLIs included, needs the PII keys.
+	piiKeys, err := piiKeyringFromEnv()
+	if err != nil {
+		_, _ = fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
 	}
-}
+	storage.SetKeyring(piiKeys)
 
-func (rs *runtimeState) StorageReady() bool {
-	rs.mu.RLock()
-	defer rs.mu.RUnlock()
-	return rs.ready
-}
+	if len(os.Args) > 1 && os.Args[1] == "backups" {
+		os.Exit(runBackupsCLI(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		os.Exit(runMigrateCLI(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "integrity" {
+		os.Exit(runIntegrityCLI(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "snapshot" {
+		os.Exit(runSnapshotCLI(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "pii" {
+		os.Exit(runPIICLI(os.Args[2:]))
+	}
 
-func (rs *runtimeState) StorageDir() string {
-	rs.mu.RLock()
-	defer rs.mu.RUnlock()
-	return rs.storageDir
-}
+	addr := ":16000"
+	logDir := "/var/api/16000/logs"
+	service := "go-core"
 
-func (rs *runtimeState) Loaded() map[string]storage.JSONFile {
-	rs.mu.RLock()
-	defer rs.mu.RUnlock()
-	out := make(map[string]storage.JSONFile, len(rs.loaded))
-	for k, v := range rs.loaded {
-		out[k] = v
+	logger := logging.NewCSVLogger(logDir, service)
+	storageDir := os.Getenv("STORAGE_DIR")
+	if piiKeys == nil {
+		logger.Log("WARN", "pii_encryption_disabled", "", "storage", storageDir, "PII_KEYS/PII_KEY_FILE not set; customer PII is stored in plaintext")
+	} else {
+		logger.Log("INFO", "pii_encryption_enabled", "", "storage", storageDir, "active_key="+piiKeys.ActiveID())
 	}
-	return out
-}
 
-func (rs *runtimeState) LockForFile(filename string) *sync.Mutex {
-	rs.mu.RLock()
-	defer rs.mu.RUnlock()
-	if m, ok := rs.fileMu[filename]; ok {
-		return m
+	// Recovery and migrations rewrite files: hold the directory exclusively
+	// until the snapshot is taken.
+	dirLock, err := storage.LockDir(storageDir, true)
+	if err != nil {
+		logger.Log("ERROR", "storage_load_failed", "", "storage", storageDir, err.Error())
+		_, _ = fmt.Fprintln(os.Stderr, "storage load failed:", err)
+		os.Exit(1)
 	}
-	return &sync.Mutex{}
-}
 
-func (rs *runtimeState) ReloadCore() error {
-	rs.mu.RLock()
-	dir := rs.storageDir
-	rs.mu.RUnlock()
-
-	lr, err := storage.LoadCore(dir)
+	loadRes, err := storage.LoadCore(storageDir)
 	if err != nil {
-		return err
+		logger.Log("ERROR", "storage_load_failed", "", "storage", storageDir, err.Error())
+		_, _ = fmt.Fprintln(os.Stderr, "storage load failed:", err)
+		os.Exit(1)
 	}
 
-	rs.mu.Lock()
-	rs.ready = true
-	rs.loaded = lr.Loaded
-	rs.loadedList = lr.LoadedList
-	rs.mu.Unlock()
-	return nil
-}
-
-func (rs *runtimeState) GetItems(filename string) map[string]any {
-	rs.mu.RLock()
-	jf, ok := rs.loaded[filename]
-	rs.mu.RUnlock()
-	if !ok || jf.Items == nil {
-		return nil
+	loadRes, err = migrateOnStartup(logger, loadRes)
+	if err != nil {
+		logger.Log("ERROR", "storage_migrate_failed", "", "storage", storageDir, err.Error())
+		_, _ = fmt.Fprintln(os.Stderr, "storage migration failed:", err)
+		os.Exit(1)
 	}
-
-	out := make(map[string]any, len(jf.Items))
-	for id, raw := range jf.Items {
-		var v any
-		if err := json.Unmarshal(raw, &v); err != nil {
-			continue
+	loadStats := statFiles(loadRes.Dir, loadRes.LoadedList)
+	_ = dirLock.Unlock()
+
+	sessionTTL := auth.DefaultSessionTTL
+	if v := os.Getenv("SESSION_TTL"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			_, _ = fmt.Fprintln(os.Stderr, "invalid SESSION_TTL:", err)
+			os.Exit(1)
 		}
-		out[id] = v
+		sessionTTL = d
 	}
-	return out
-}
 
-func (rs *runtimeState) CurrentAppState() app.State {
-	rs.mu.RLock()
-	defer rs.mu.RUnlock()
-	return app.State{
-		StorageReady: rs.ready,
-		StorageDir:   rs.storageDir,
-		LoadedFiles:  rs.loadedList,
+	retention, err := backupRetentionFromEnv()
+	if err != nil {
+		_, _ = fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
 	}
-}
 
-func main() {
-	addr := ":16000"
-	logDir := "/var/api/16000/logs"
-	service := "go-core"
+	watchInterval, err := storageWatchIntervalFromEnv()
+	if err != nil {
+		_, _ = fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
 
-	logger := logging.NewCSVLogger(logDir, service)
-	storageDir := os.Getenv("STORAGE_DIR")
+	simulateLimit, err := simulateRateLimitFromEnv()
+	if err != nil {
+		_, _ = fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
 
-	loadRes, err := storage.LoadCore(storageDir)
+	dsrPolicy, err := dsrPolicyFromEnv()
 	if err != nil {
-		logger.Log("ERROR", "storage_load_failed", "", "storage", storageDir, err.Error())
-		_, _ = fmt.Fprintln(os.Stderr, "storage load failed:", err)
+		_, _ = fmt.Fprintln(os.Stderr, err)
 		os.Exit(1)
 	}
 
-	rs := newRuntimeState(loadRes.Dir, loadRes)
+	rs := newRuntimeState(logger, loadRes.Dir, loadRes, loadStats, sessionTTL, retention)
+	rs.simulateRateLimit = simulateLimit
+	rs.dsrPolicy = dsrPolicy
 
+	stopWorkers := make(chan struct{})
+	go runBackupPruner(logger, loadRes.Dir, retention, stopWorkers)
+	if watchInterval > 0 {
+		go runStorageWatcher(rs, watchInterval, stopWorkers)
+	}
+
+	for _, rec := range loadRes.Recovered {
+		logger.Log("WARN", "storage_tx_recovered", "", "storage", rec.ID, rec.Action+" "+strings.Join(rec.Files, ","))
+	}
+	for name, err := range loadRes.Skipped {
+		logger.Log("WARN", "storage_optional_skipped", "", "storage", name, err.Error())
+	}
 	logger.Log("INFO", "storage_loaded", "", "storage", storageDir, fmt.Sprintf("loaded=%d", len(loadRes.LoadedList)))
 	logger.Log("INFO", "startup", "", "service", service, "starting server")
 
@@ -176,6 +164,7 @@ func main() {
 	signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
 
 	<-stop
+	close(stopWorkers)
 	logger.Log("INFO", "shutdown", "", "service", service, "shutdown signal received")
 
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/itmtjewelry/land-booking-kpr/internal/logging"
+	"github.com/itmtjewelry/land-booking-kpr/internal/migrate"
+	"github.com/itmtjewelry/land-booking-kpr/internal/storage"
+)
+
+// migrateOnStartup upgrades outdated files before the server starts and
+// returns the load result to serve from. A file newer than this binary is fatal.
+func migrateOnStartup(logger *logging.CSVLogger, lr *storage.LoadResult) (*storage.LoadResult, error) {
+	rep, err := migrate.Run(lr.Dir, lr.Loaded, false)
+	if err != nil {
+		return nil, err
+	}
+	if len(rep.Files) == 0 {
+		return lr, nil
+	}
+	for _, f := range rep.Files {
+		logger.Log("WARN", "storage_migrated", "", "storage", f.File, fmt.Sprintf("v%d->v%d %s", f.From, f.To, migratedCounts(f)))
+	}
+	reloaded, err := storage.ReloadCore(lr.Dir)
+	if err != nil {
+		return nil, err
+	}
+	reloaded.Recovered = lr.Recovered
+	return reloaded, nil
+}
+
+// runMigrateCLI implements `server migrate [-dry-run]` against STORAGE_DIR.
+func runMigrateCLI(args []string) int {
+	fs := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	dryRun := fs.Bool("dry-run", false, "print what would change without writing")
+	if err := fs.Parse(args); err != nil || fs.NArg() != 0 {
+		_, _ = fmt.Fprintln(os.Stderr, "usage: server migrate [-dry-run]")
+		return 2
+	}
+
+	dir := os.Getenv("STORAGE_DIR")
+	if dir == "" {
+		_, _ = fmt.Fprintln(os.Stderr, "STORAGE_DIR is required")
+		return 1
+	}
+
+	// A dry run must not touch the directory, so it skips journal recovery.
+	load := storage.LoadCore
+	if *dryRun {
+		load = storage.ReloadCore
+	}
+	lock, ok := lockStorageDirCLI(dir, !*dryRun)
+	if !ok {
+		return 1
+	}
+	defer func() { _ = lock.Unlock() }()
+
+	lr, err := load(dir)
+	if err != nil {
+		_, _ = fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	rep, err := migrate.Run(dir, lr.Loaded, *dryRun)
+	if err != nil {
+		_, _ = fmt.Fprintln(os.Stderr, err)
+		if errors.Is(err, migrate.ErrNewerVersion) {
+			return 3
+		}
+		return 1
+	}
+	printMigrateReport(rep)
+	return 0
+}
+
+func printMigrateReport(rep *migrate.Report) {
+	verb := "migrated"
+	if rep.DryRun {
+		verb = "would migrate"
+	}
+	if len(rep.Files) == 0 {
+		fmt.Println("all files are at the current schema version")
+		return
+	}
+	for _, f := range rep.Files {
+		fmt.Printf("%s %s v%d -> v%d\n", verb, f.File, f.From, f.To)
+		for _, s := range f.Steps {
+			fmt.Printf("  v%d -> v%d: %s (%d record(s))\n", s.From, s.To, s.Description, len(s.Changed))
+			for _, id := range s.Changed {
+				fmt.Println("    ", id)
+			}
+		}
+	}
+}
+
+func migratedCounts(f migrate.FileResult) string {
+	parts := make([]string, 0, len(f.Steps))
+	for _, s := range f.Steps {
+		parts = append(parts, fmt.Sprintf("v%d:%d", s.To, len(s.Changed)))
+	}
+	return "changed=" + strings.Join(parts, ",")
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/itmtjewelry/land-booking-kpr/internal/storage"
+)
+
+// piiKeyringFromEnv reads the PII keys from PII_KEYS or, when unset, from the
+// file named by PII_KEY_FILE (see storage.ParseKeyring for the format; the
+// first key is active). Neither set returns nil: PII is stored in plaintext.
+func piiKeyringFromEnv() (*storage.Keyring, error) {
+	spec := os.Getenv("PII_KEYS")
+	if spec == "" {
+		path := os.Getenv("PII_KEY_FILE")
+		if path == "" {
+			return nil, nil
+		}
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("invalid PII_KEY_FILE: %w", err)
+		}
+		spec = string(b)
+	}
+	k, err := storage.ParseKeyring(spec)
+	if err != nil {
+		return nil, fmt.Errorf("invalid PII keys: %w", err)
+	}
+	return k, nil
+}
+
+// runPIICLI implements `server pii status | rekey` against STORAGE_DIR.
+// status counts stored values per key id; rekey re-encrypts every value with
+// the active key (run it after adding a new first key, before dropping the old
+// one). rekey holds the storage directory exclusively.
+func runPIICLI(args []string) int {
+	if len(args) != 1 || (args[0] != "status" && args[0] != "rekey") {
+		_, _ = fmt.Fprintln(os.Stderr, "usage: server pii status | rekey")
+		return 2
+	}
+
+	dir := os.Getenv("STORAGE_DIR")
+	if dir == "" {
+		_, _ = fmt.Fprintln(os.Stderr, "STORAGE_DIR is required")
+		return 1
+	}
+	lock, ok := lockStorageDirCLI(dir, args[0] == "rekey")
+	if !ok {
+		return 1
+	}
+	defer func() { _ = lock.Unlock() }()
+
+	if args[0] == "rekey" {
+		names, err := storage.RekeyPII(dir)
+		if err != nil {
+			_, _ = fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		for _, name := range names {
+			fmt.Printf("rekeyed\t%s\n", name)
+		}
+	}
+
+	usage, err := storage.PIIKeyUsage(dir)
+	if err != nil {
+		_, _ = fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	ids := make([]string, 0, len(usage))
+	for id := range usage {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		fmt.Printf("%s\t%d\n", id, usage[id])
+	}
+	return 0
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"github.com/itmtjewelry/land-booking-kpr/internal/domain"
+)
+
+// typedItems returns the typed records of filename, decoded once per snapshot
+// and shared by readers until the file is reloaded.
+func typedItems[T any, PT interface {
+	*T
+	domain.Record
+}](rs *runtimeState, filename string) map[string]T {
+	snap := rs.snapshot(filename)
+	if snap == nil {
+		return map[string]T{}
+	}
+	return typedOf[T, PT](snap)
+}
+
+func typedOf[T any, PT interface {
+	*T
+	domain.Record
+}](snap *fileSnapshot) map[string]T {
+	snap.typedOnce.Do(func() {
+		snap.typed = domain.DecodeItems[T, PT](snap.jf.Items)
+	})
+	m, _ := snap.typed.(map[string]T)
+	return m
+}
+
+func (rs *runtimeState) snapshot(filename string) *fileSnapshot {
+	rs.mu.RLock()
+	defer rs.mu.RUnlock()
+	return rs.files[filename]
+}
+
+func (rs *runtimeState) Users() map[string]domain.User {
+	return typedItems[domain.User](rs, domain.UsersFile)
+}
+
+func (rs *runtimeState) Sites() map[string]domain.Site {
+	return typedItems[domain.Site](rs, domain.SitesFile)
+}
+
+func (rs *runtimeState) Subsites() map[string]domain.Subsite {
+	return typedItems[domain.Subsite](rs, domain.SubsitesFile)
+}
+
+func (rs *runtimeState) Zones() map[string]domain.Zone {
+	return typedItems[domain.Zone](rs, domain.ZonesFile)
+}
+
+func (rs *runtimeState) Domains() map[string]domain.Domain {
+	return typedItems[domain.Domain](rs, domain.DomainsFile)
+}
+
+func (rs *runtimeState) Bookings() map[string]domain.Booking {
+	return typedItems[domain.Booking](rs, domain.BookingsFile)
+}
+
+func (rs *runtimeState) KPRApplications() map[string]domain.KPRApplication {
+	return typedItems[domain.KPRApplication](rs, domain.KPRApplicationsFile)
+}
+
+func (rs *runtimeState) InstallmentPlans() map[string]domain.InstallmentPlan {
+	return typedItems[domain.InstallmentPlan](rs, domain.InstallmentPlansFile)
+}
+
+func (rs *runtimeState) Payments() map[string]domain.Payment {
+	return typedItems[domain.Payment](rs, domain.PaymentsFile)
+}
+
+func (rs *runtimeState) Tickets() map[string]domain.Ticket {
+	return typedItems[domain.Ticket](rs, domain.TicketsFile)
+}
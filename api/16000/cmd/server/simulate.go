@@ -0,0 +1,23 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+)
+
+const defaultSimulateRateLimit = 30
+
+// simulateRateLimitFromEnv reads KPR_SIMULATE_RATE_LIMIT (requests per minute
+// per client IP on /api/v1/kpr/simulate; 0 disables the limit).
+func simulateRateLimitFromEnv() (int, error) {
+	v := os.Getenv("KPR_SIMULATE_RATE_LIMIT")
+	if v == "" {
+		return defaultSimulateRateLimit, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid KPR_SIMULATE_RATE_LIMIT: %q", v)
+	}
+	return n, nil
+}
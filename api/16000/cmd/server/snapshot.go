@@ -0,0 +1,145 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/itmtjewelry/land-booking-kpr/internal/migrate"
+	"github.com/itmtjewelry/land-booking-kpr/internal/storage"
+)
+
+// runSnapshotCLI implements `server snapshot export <file|-> | import [-dry-run] <file|->`
+// against STORAGE_DIR. Both hold the storage directory exclusively; a running
+// server reloads imported files afterwards.
+func runSnapshotCLI(args []string) int {
+	usage := func() int {
+		_, _ = fmt.Fprintln(os.Stderr, "usage: server snapshot export <file|-> | import [-dry-run] <file|->")
+		return 2
+	}
+	if len(args) == 0 {
+		return usage()
+	}
+
+	dir := os.Getenv("STORAGE_DIR")
+	if dir == "" {
+		_, _ = fmt.Fprintln(os.Stderr, "STORAGE_DIR is required")
+		return 1
+	}
+
+	switch args[0] {
+	case "export":
+		if len(args) != 2 {
+			return usage()
+		}
+		return snapshotExport(dir, args[1])
+	case "import":
+		fs := flag.NewFlagSet("snapshot import", flag.ContinueOnError)
+		dryRun := fs.Bool("dry-run", false, "print the per-file record diff without importing")
+		if err := fs.Parse(args[1:]); err != nil || fs.NArg() != 1 {
+			return usage()
+		}
+		return snapshotImport(dir, fs.Arg(0), *dryRun)
+	default:
+		return usage()
+	}
+}
+
+func snapshotExport(dir, target string) int {
+	var out io.Writer = os.Stdout
+	if target != "-" {
+		f, err := os.OpenFile(target, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+		if err != nil {
+			_, _ = fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		defer f.Close()
+		out = f
+	}
+
+	lock, ok := lockStorageDirCLI(dir, true)
+	if !ok {
+		return 1
+	}
+	defer func() { _ = lock.Unlock() }()
+
+	m, err := storage.ExportSnapshot(dir, out)
+	if err != nil {
+		_, _ = fmt.Fprintln(os.Stderr, "export failed:", err)
+		if target != "-" {
+			_ = os.Remove(target)
+		}
+		return 1
+	}
+	for _, f := range m.Files {
+		_, _ = fmt.Fprintf(os.Stderr, "%s\tv%d\t%d items\t%s\n", f.Name, f.Version, f.Items, f.SHA256)
+	}
+	return 0
+}
+
+func snapshotImport(dir, source string, dryRun bool) int {
+	var in io.Reader = os.Stdin
+	if source != "-" {
+		f, err := os.Open(source)
+		if err != nil {
+			_, _ = fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		defer f.Close()
+		in = f
+	}
+
+	snap, err := storage.ReadSnapshot(in)
+	if err != nil {
+		_, _ = fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	pending, err := migrate.Run(dir, snap.Files, true)
+	if err != nil {
+		_, _ = fmt.Fprintln(os.Stderr, "snapshot rejected:", err)
+		if errors.Is(err, migrate.ErrNewerVersion) {
+			return 3
+		}
+		return 1
+	}
+
+	lock, ok := lockStorageDirCLI(dir, true)
+	if !ok {
+		return 1
+	}
+	defer func() { _ = lock.Unlock() }()
+
+	diff, err := snap.Diff(dir)
+	if err != nil {
+		_, _ = fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	fmt.Println("file\tadded\tremoved\tchanged\tunchanged")
+	for _, d := range diff {
+		fmt.Printf("%s\t%d\t%d\t%d\t%d\n", d.File, d.Added, d.Removed, d.Changed, d.Same)
+	}
+	if dryRun {
+		printMigrateReport(pending)
+		return 0
+	}
+
+	if err := snap.Apply(dir); err != nil {
+		_, _ = fmt.Fprintln(os.Stderr, "import failed:", err)
+		return 1
+	}
+	fmt.Printf("imported %d file(s)\n", len(snap.Files))
+	loaded, err := storage.LoadFiles(dir, snap.Names()...)
+	if err != nil {
+		_, _ = fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	rep, err := migrate.Run(dir, loaded, false)
+	if err != nil {
+		_, _ = fmt.Fprintln(os.Stderr, "migration failed:", err)
+		return 1
+	}
+	printMigrateReport(rep)
+	return 0
+}
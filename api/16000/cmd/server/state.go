@@ -0,0 +1,306 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/itmtjewelry/land-booking-kpr/internal/app"
+	"github.com/itmtjewelry/land-booking-kpr/internal/auth"
+	"github.com/itmtjewelry/land-booking-kpr/internal/domain"
+	"github.com/itmtjewelry/land-booking-kpr/internal/logging"
+	"github.com/itmtjewelry/land-booking-kpr/internal/storage"
+)
+
+// fileSnapshot is one loaded file plus its items decoded once at load time.
+// Snapshots are immutable: a reload builds a new one and swaps the pointer,
+// so readers share decoded items without copying or re-decoding.
+type fileSnapshot struct {
+	jf    storage.JSONFile
+	items map[string]any
+
+	// stat is the file as seen on disk just before it was read (nil if
+	// unknown); a different stat means another process rewrote it.
+	stat os.FileInfo
+
+	// typed is the domain-typed decode of jf.Items, built on first use.
+	typedOnce sync.Once
+	typed     any
+
+	// idx holds the secondary indexes of this snapshot, by field (see index).
+	idxMu sync.Mutex
+	idx   map[string]map[string][]string
+}
+
+func newFileSnapshot(jf storage.JSONFile, stat os.FileInfo) *fileSnapshot {
+	items := make(map[string]any, len(jf.Items))
+	for id, raw := range jf.Items {
+		var v any
+		if err := json.Unmarshal(raw, &v); err != nil {
+			continue
+		}
+		items[id] = v
+	}
+	return &fileSnapshot{jf: jf, items: items, stat: stat}
+}
+
+// statFiles stats the named files of dir; missing files are left out.
+func statFiles(dir string, names []string) map[string]os.FileInfo {
+	out := make(map[string]os.FileInfo, len(names))
+	for _, name := range names {
+		if fi, err := os.Stat(filepath.Join(dir, name)); err == nil {
+			out[name] = fi
+		}
+	}
+	return out
+}
+
+func statChanged(prev, cur os.FileInfo) bool {
+	if prev == nil || cur == nil {
+		return prev != cur
+	}
+	return !os.SameFile(prev, cur) || !prev.ModTime().Equal(cur.ModTime()) || prev.Size() != cur.Size()
+}
+
+type runtimeState struct {
+	mu sync.RWMutex
+
+	storageDir string
+	ready      bool
+	files      map[string]*fileSnapshot
+	loadedList []string
+
+	locks   *storage.Locks
+	lockMu  sync.Mutex
+	lockers map[string]*storageLock
+
+	logger    *logging.CSVLogger
+	sessions  *auth.SessionStore
+	retention storage.RetentionPolicy
+
+	simulateRateLimit int
+	dsrPolicy         domain.DSRPolicy
+}
+
+// newRuntimeState builds the state from lr. stats are the files as seen
+// before lr was read (see fileSnapshot.stat).
+func newRuntimeState(logger *logging.CSVLogger, storageDir string, lr *storage.LoadResult, stats map[string]os.FileInfo, sessionTTL time.Duration, retention storage.RetentionPolicy) *runtimeState {
+	rs := &runtimeState{
+		storageDir: storageDir,
+		ready:      true,
+		locks:      storage.NewLocks(storageDir),
+		lockers:    map[string]*storageLock{},
+		logger:     logger,
+		sessions:   auth.NewSessionStore(sessionTTL),
+		retention:  retention,
+	}
+	// Without its cross-process lock a file cannot be written safely: the
+	// write is refused (storage.ErrLockNotHeld) and storage goes not-ready.
+	rs.locks.OnError = func(filename string, err error) {
+		logger.Log("ERROR", "storage_lock_failed", "", "storage", filename, err.Error())
+		rs.SetStorageNotReady(fmt.Errorf("lock %s: %w", filename, err))
+	}
+	rs.setLoaded(lr, stats)
+	return rs
+}
+
+// setLoaded replaces every snapshot. Callers hold rs.mu (or own rs exclusively).
+func (rs *runtimeState) setLoaded(lr *storage.LoadResult, stats map[string]os.FileInfo) {
+	files := make(map[string]*fileSnapshot, len(lr.Loaded))
+	for name, jf := range lr.Loaded {
+		files[name] = newFileSnapshot(jf, stats[name])
+	}
+	rs.files = files
+	rs.loadedList = lr.LoadedList
+}
+
+func (rs *runtimeState) StorageReady() bool {
+	rs.mu.RLock()
+	defer rs.mu.RUnlock()
+	return rs.ready
+}
+
+func (rs *runtimeState) StorageDir() string {
+	rs.mu.RLock()
+	defer rs.mu.RUnlock()
+	return rs.storageDir
+}
+
+func (rs *runtimeState) Loaded() map[string]storage.JSONFile {
+	rs.mu.RLock()
+	defer rs.mu.RUnlock()
+	out := make(map[string]storage.JSONFile, len(rs.files))
+	for k, snap := range rs.files {
+		out[k] = snap.jf
+	}
+	return out
+}
+
+func (rs *runtimeState) Sessions() *auth.SessionStore {
+	return rs.sessions
+}
+
+func (rs *runtimeState) BackupRetention() storage.RetentionPolicy {
+	return rs.retention
+}
+
+func (rs *runtimeState) SimulateRateLimit() int {
+	return rs.simulateRateLimit
+}
+
+func (rs *runtimeState) DSRPolicy() domain.DSRPolicy {
+	return rs.dsrPolicy
+}
+
+// LockForFile returns the stable cross-process lock of filename (see storageLock).
+func (rs *runtimeState) LockForFile(filename string) sync.Locker {
+	rs.lockMu.Lock()
+	defer rs.lockMu.Unlock()
+	if l, ok := rs.lockers[filename]; ok {
+		return l
+	}
+	l := &storageLock{rs: rs, name: filename, fl: rs.locks.For(filename)}
+	rs.lockers[filename] = l
+	return l
+}
+
+// storageLock is the storage file lock plus a reload of the file when another
+// process rewrote it since it was loaded, so a read-modify-write under the
+// lock never starts from a stale snapshot.
+type storageLock struct {
+	rs   *runtimeState
+	name string
+	fl   *storage.FileLock
+}
+
+func (l *storageLock) Lock() {
+	l.fl.Lock()
+	if err := l.rs.refreshFile(l.name); err != nil {
+		l.rs.logger.Log("ERROR", "storage_reload_failed", "", "storage", l.name, err.Error())
+	}
+}
+
+func (l *storageLock) Unlock() { l.fl.Unlock() }
+
+// refreshFile reloads a loaded file whose stat no longer matches its snapshot.
+// Callers hold the file's lock.
+func (rs *runtimeState) refreshFile(name string) error {
+	if !rs.fileChanged(name) {
+		return nil
+	}
+	if err := rs.ReloadFiles(name); err != nil {
+		return err
+	}
+	rs.logger.Log("INFO", "storage_external_reload", "", "storage", name, "file changed on disk")
+	return nil
+}
+
+func (rs *runtimeState) fileChanged(name string) bool {
+	rs.mu.RLock()
+	snap, ok := rs.files[name]
+	dir := rs.storageDir
+	rs.mu.RUnlock()
+	if !ok {
+		return false
+	}
+	return statChanged(snap.stat, statFiles(dir, []string{name})[name])
+}
+
+// changedFiles lists loaded files rewritten on disk since they were loaded.
+func (rs *runtimeState) changedFiles() []string {
+	rs.mu.RLock()
+	names := append([]string(nil), rs.loadedList...)
+	rs.mu.RUnlock()
+
+	out := []string{}
+	for _, name := range names {
+		if rs.fileChanged(name) {
+			out = append(out, name)
+		}
+	}
+	return out
+}
+
+func (rs *runtimeState) ReloadCore() error {
+	rs.mu.RLock()
+	dir := rs.storageDir
+	rs.mu.RUnlock()
+
+	// Runtime reloads never run journal recovery: other transactions may be mid-commit.
+	stats := statFiles(dir, storage.CoreFiles)
+	lr, err := storage.ReloadCore(dir)
+	if err != nil {
+		return err
+	}
+
+	rs.mu.Lock()
+	// An unapplied transaction keeps storage down until a restart recovers it.
+	rs.ready = !storage.NeedsRecovery(dir)
+	rs.setLoaded(lr, stats)
+	rs.mu.Unlock()
+	return nil
+}
+
+func (rs *runtimeState) SetStorageNotReady(err error) {
+	rs.mu.Lock()
+	rs.ready = false
+	rs.mu.Unlock()
+	rs.logger.Log("ERROR", "storage_not_ready", "", "storage", rs.StorageDir(), err.Error())
+}
+
+// ReloadFiles re-reads and re-decodes only the named files; every other
+// snapshot is kept as is. Decoding happens outside the state lock.
+func (rs *runtimeState) ReloadFiles(filenames ...string) error {
+	rs.mu.RLock()
+	dir := rs.storageDir
+	rs.mu.RUnlock()
+
+	stats := statFiles(dir, filenames)
+	loaded, err := storage.LoadFiles(dir, filenames...)
+	if err != nil {
+		return err
+	}
+	snaps := make(map[string]*fileSnapshot, len(loaded))
+	for name, jf := range loaded {
+		snaps[name] = newFileSnapshot(jf, stats[name])
+	}
+
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	files := make(map[string]*fileSnapshot, len(rs.files)+len(snaps))
+	for name, snap := range rs.files {
+		files[name] = snap
+	}
+	for name, snap := range snaps {
+		if _, ok := files[name]; !ok {
+			rs.loadedList = append(append([]string(nil), rs.loadedList...), name)
+		}
+		files[name] = snap
+	}
+	rs.files = files
+	return nil
+}
+
+// GetItems returns the shared decoded snapshot of a file. It must be treated as read-only.
+func (rs *runtimeState) GetItems(filename string) map[string]any {
+	rs.mu.RLock()
+	snap, ok := rs.files[filename]
+	rs.mu.RUnlock()
+	if !ok || snap.jf.Items == nil {
+		return nil
+	}
+	return snap.items
+}
+
+func (rs *runtimeState) CurrentAppState() app.State {
+	rs.mu.RLock()
+	defer rs.mu.RUnlock()
+	return app.State{
+		StorageReady: rs.ready,
+		StorageDir:   rs.storageDir,
+		LoadedFiles:  rs.loadedList,
+	}
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/itmtjewelry/land-booking-kpr/internal/logging"
+	"github.com/itmtjewelry/land-booking-kpr/internal/storage"
+)
+
+// newTestState writes seed (file -> id -> record) next to empty core files
+// and loads them the way main does.
+func newTestState(t *testing.T, seed map[string]map[string]any) *runtimeState {
+	t.Helper()
+	dir := t.TempDir()
+	for _, name := range storage.CoreFiles {
+		writeTestFile(t, dir, name, seed[name])
+	}
+	stats := statFiles(dir, storage.CoreFiles)
+	lr, err := storage.LoadCore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return newRuntimeState(logging.NewCSVLogger(t.TempDir(), "test"), dir, lr, stats, 0, storage.RetentionPolicy{})
+}
+
+func writeTestFile(t *testing.T, dir, name string, items map[string]any) {
+	t.Helper()
+	jf := storage.JSONFile{Meta: map[string]any{"version": 1}, Items: map[string]json.RawMessage{}}
+	for id, rec := range items {
+		b, err := json.Marshal(rec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		jf.Items[id] = b
+	}
+	if err := storage.WriteJSONFileAtomic(dir, name, jf); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// sameMap reports whether a and b are the same map, not just equal ones.
+func sameMap(a, b any) bool {
+	return reflect.ValueOf(a).Pointer() == reflect.ValueOf(b).Pointer()
+}
+
+func TestReloadFilesKeepsOtherSnapshots(t *testing.T) {
+	rs := newTestState(t, map[string]map[string]any{
+		"zones.json":    {"z1": map[string]any{"id": "z1", "subsite_id": "ss1"}},
+		"bookings.json": {"b1": map[string]any{"id": "b1", "zone_id": "z1"}},
+	})
+
+	before := map[string]*fileSnapshot{}
+	for _, name := range storage.CoreFiles {
+		if before[name] = rs.snapshot(name); before[name] == nil {
+			t.Fatalf("%s not loaded", name)
+		}
+	}
+	zones, zoneRecs := rs.GetItems("zones.json"), rs.Zones()
+
+	writeTestFile(t, rs.StorageDir(), "bookings.json", map[string]any{
+		"b1": map[string]any{"id": "b1", "zone_id": "z1"},
+		"b2": map[string]any{"id": "b2", "zone_id": "z1"},
+	})
+	if err := rs.ReloadFiles("bookings.json"); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range storage.CoreFiles {
+		changed := rs.snapshot(name) != before[name]
+		if changed != (name == "bookings.json") {
+			t.Errorf("%s: snapshot replaced = %v", name, changed)
+		}
+	}
+	if len(rs.GetItems("bookings.json")) != 2 || len(rs.Bookings()) != 2 {
+		t.Fatal("reloaded file not visible")
+	}
+	// Untouched files keep their decoded items, raw and typed.
+	if !sameMap(rs.GetItems("zones.json"), zones) || !sameMap(rs.Zones(), zoneRecs) {
+		t.Fatal("zones.json re-decoded by a bookings.json reload")
+	}
+}
+
+func TestGetItemsSharesSnapshot(t *testing.T) {
+	rs := newTestState(t, map[string]map[string]any{
+		"zones.json": {"z1": map[string]any{"id": "z1", "name": "Z1"}},
+	})
+
+	a, b := rs.GetItems("zones.json"), rs.GetItems("zones.json")
+	if !sameMap(a, b) {
+		t.Fatal("GetItems decoded the file again")
+	}
+	if z, _ := a["z1"].(map[string]any); z["name"] != "Z1" {
+		t.Fatalf("z1 = %v", a["z1"])
+	}
+	if !sameMap(rs.Zones(), rs.Zones()) {
+		t.Fatal("typed records decoded again")
+	}
+	if rs.GetItems("nope.json") != nil {
+		t.Fatal("unknown file has items")
+	}
+
+	// A reload of the file itself does produce fresh items.
+	if err := rs.ReloadFiles("zones.json"); err != nil {
+		t.Fatal(err)
+	}
+	if sameMap(rs.GetItems("zones.json"), a) {
+		t.Fatal("reload kept the old items")
+	}
+}
+
+func TestLockFailureStopsStorage(t *testing.T) {
+	rs := newTestState(t, nil)
+	if err := os.WriteFile(filepath.Join(rs.StorageDir(), storage.LockDirName), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	l := rs.LockForFile("zones.json")
+	l.Lock()
+	if rs.StorageReady() {
+		t.Fatal("storage still ready after a lock failure")
+	}
+	err := storage.WriteJSONFileAtomic(rs.StorageDir(), "zones.json", rs.Loaded()["zones.json"])
+	if !errors.Is(err, storage.ErrLockNotHeld) {
+		t.Fatalf("write without the file lock: %v", err)
+	}
+	l.Unlock()
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/itmtjewelry/land-booking-kpr/internal/storage"
+)
+
+const defaultStorageWatchInterval = 2 * time.Second
+
+// storageWatchIntervalFromEnv reads STORAGE_WATCH_INTERVAL (a duration; 0 disables the watcher).
+func storageWatchIntervalFromEnv() (time.Duration, error) {
+	v := os.Getenv("STORAGE_WATCH_INTERVAL")
+	if v == "" {
+		return defaultStorageWatchInterval, nil
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid STORAGE_WATCH_INTERVAL: %q", v)
+	}
+	return d, nil
+}
+
+// runStorageWatcher polls the loaded files and reloads the ones another
+// process (CLI, ops scripts) rewrote. Taking the file's lock does the reload
+// (see storageLock) and waits out writers that are still mid-change.
+func runStorageWatcher(rs *runtimeState, interval time.Duration, stop <-chan struct{}) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C:
+			for _, name := range rs.changedFiles() {
+				l := rs.LockForFile(name)
+				l.Lock()
+				l.Unlock()
+			}
+		case <-stop:
+			return
+		}
+	}
+}
+
+// lockStorageDirCLI takes the directory lock for a CLI command. A running
+// server finishes its in-flight writes first and reloads what the command
+// changed afterwards.
+func lockStorageDirCLI(dir string, exclusive bool) (*storage.DirLock, bool) {
+	l, err := storage.LockDir(dir, exclusive)
+	if err != nil {
+		_, _ = fmt.Fprintln(os.Stderr, "storage lock failed:", err)
+		return nil, false
+	}
+	return l, true
+}
@@ -7,9 +7,9 @@ import (
 
 const AdminHeader = "X-Admin-Token"
 
-// IsAdmin returns true if request has correct admin token.
-// It does not write any response.
-func IsAdmin(r *http.Request) bool {
+// LegacyAdminToken reports whether the request carries the ADMIN_TOKEN ops token.
+// The token predates user sessions and is still honoured as an ADMIN principal.
+func LegacyAdminToken(r *http.Request) bool {
 	expected := os.Getenv("ADMIN_TOKEN")
 	if expected == "" {
 		return false
@@ -18,21 +18,11 @@ func IsAdmin(r *http.Request) bool {
 	return got != "" && got == expected
 }
 
-// RequireAdmin enforces admin token for write endpoints (writes 401 on failure).
-func RequireAdmin(w http.ResponseWriter, r *http.Request) bool {
-	expected := os.Getenv("ADMIN_TOKEN")
-	if expected == "" {
-		w.Header().Set("Content-Type", "application/json; charset=utf-8")
-		w.WriteHeader(http.StatusUnauthorized)
-		_, _ = w.Write([]byte(`{"ok":false,"error":"admin token not configured"}`))
-		return false
-	}
-	got := r.Header.Get(AdminHeader)
-	if got == "" || got != expected {
-		w.Header().Set("Content-Type", "application/json; charset=utf-8")
-		w.WriteHeader(http.StatusUnauthorized)
-		_, _ = w.Write([]byte(`{"ok":false,"error":"unauthorized"}`))
-		return false
+// IsAdmin returns true if the request principal is ADMIN.
+// It does not write any response.
+func IsAdmin(r *http.Request) bool {
+	if PrincipalFrom(r.Context()).Role == RoleAdmin {
+		return true
 	}
-	return true
+	return LegacyAdminToken(r)
 }
@@ -0,0 +1,64 @@
+package auth
+
+import (
+	"testing"
+	"time"
+)
+
+func TestHashAndVerifyPassword(t *testing.T) {
+	h, err := HashPassword("s3cret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !VerifyPassword(h, "s3cret") {
+		t.Fatal("expected password to verify")
+	}
+	if VerifyPassword(h, "wrong") {
+		t.Fatal("expected wrong password to fail")
+	}
+	if VerifyPassword("plain-text", "plain-text") {
+		t.Fatal("malformed hash must never match")
+	}
+}
+
+func TestSessionStoreExpiry(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	s := NewSessionStore(time.Hour)
+	s.now = func() time.Time { return now }
+
+	sess, err := s.Create("u1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !sess.ExpiresAt.Equal(now.Add(time.Hour)) {
+		t.Fatalf("ExpiresAt = %v", sess.ExpiresAt)
+	}
+	now = now.Add(time.Hour - time.Second)
+	if _, ok := s.Lookup(sess.Token); !ok {
+		t.Fatal("expected live session before the TTL")
+	}
+	now = now.Add(time.Second)
+	if _, ok := s.Lookup(sess.Token); ok {
+		t.Fatal("session must expire once the TTL has passed")
+	}
+}
+
+func TestSessionStoreRevoke(t *testing.T) {
+	s := NewSessionStore(0)
+	if s.ttl != DefaultSessionTTL {
+		t.Fatalf("ttl 0 should default, got %v", s.ttl)
+	}
+	sess, err := s.Create("u1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := s.Lookup(sess.Token); !ok {
+		t.Fatal("expected live session")
+	}
+	if !s.Revoke(sess.Token) {
+		t.Fatal("expected revoke to succeed")
+	}
+	if _, ok := s.Lookup(sess.Token); ok {
+		t.Fatal("revoked session must not resolve")
+	}
+}
@@ -0,0 +1,60 @@
+package auth
+
+import (
+	"context"
+	"strings"
+)
+
+type Role string
+
+const (
+	RoleAdmin         Role = "ADMIN"
+	RoleSalesManager  Role = "SALES_MANAGER"
+	RoleCustomerSales Role = "CUSTOMER_SALES"
+	RoleGuest         Role = "GUEST"
+)
+
+// ParseRole normalizes a stored role string. Unknown roles map to GUEST.
+func ParseRole(s string) Role {
+	switch Role(strings.ToUpper(strings.TrimSpace(s))) {
+	case RoleAdmin:
+		return RoleAdmin
+	case RoleSalesManager:
+		return RoleSalesManager
+	case RoleCustomerSales:
+		return RoleCustomerSales
+	default:
+		return RoleGuest
+	}
+}
+
+// Principal is the identity a request acts as.
+// A request without a valid session is always GUEST (blueprint auth-state rule).
+type Principal struct {
+	UserID   string `json:"user_id"`
+	Username string `json:"username,omitempty"`
+	FullName string `json:"full_name,omitempty"`
+	Role     Role   `json:"role"`
+}
+
+func Guest() Principal {
+	return Principal{Role: RoleGuest}
+}
+
+func (p Principal) Authenticated() bool {
+	return p.Role != RoleGuest && p.UserID != ""
+}
+
+type principalKey struct{}
+
+func WithPrincipal(ctx context.Context, p Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// PrincipalFrom returns the principal attached to ctx, or GUEST.
+func PrincipalFrom(ctx context.Context) Principal {
+	if p, ok := ctx.Value(principalKey{}).(Principal); ok {
+		return p
+	}
+	return Guest()
+}
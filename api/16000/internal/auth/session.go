@@ -0,0 +1,108 @@
+package auth
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const SessionHeader = "X-Session-Token"
+
+const DefaultSessionTTL = 12 * time.Hour
+
+type Session struct {
+	Token     string    `json:"token"`
+	UserID    string    `json:"user_id"`
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// SessionStore keeps issued session tokens in memory.
+// Sessions do not survive a restart; clients simply log in again.
+type SessionStore struct {
+	mu       sync.Mutex
+	ttl      time.Duration
+	sessions map[string]Session
+	now      func() time.Time // the clock; replaced in tests
+}
+
+func NewSessionStore(ttl time.Duration) *SessionStore {
+	if ttl <= 0 {
+		ttl = DefaultSessionTTL
+	}
+	return &SessionStore{
+		ttl:      ttl,
+		sessions: make(map[string]Session),
+		now:      time.Now,
+	}
+}
+
+// Create issues a new token for userID.
+func (s *SessionStore) Create(userID string) (Session, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return Session{}, err
+	}
+	now := s.now().UTC()
+	sess := Session{
+		Token:     hex.EncodeToString(buf),
+		UserID:    userID,
+		CreatedAt: now,
+		ExpiresAt: now.Add(s.ttl),
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pruneLocked(now)
+	s.sessions[sess.Token] = sess
+	return sess, nil
+}
+
+// Lookup returns the live session for token. Expired sessions are dropped.
+func (s *SessionStore) Lookup(token string) (Session, bool) {
+	if token == "" {
+		return Session{}, false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sess, ok := s.sessions[token]
+	if !ok {
+		return Session{}, false
+	}
+	if !s.now().UTC().Before(sess.ExpiresAt) {
+		delete(s.sessions, token)
+		return Session{}, false
+	}
+	return sess, true
+}
+
+func (s *SessionStore) Revoke(token string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.sessions[token]; !ok {
+		return false
+	}
+	delete(s.sessions, token)
+	return true
+}
+
+func (s *SessionStore) pruneLocked(now time.Time) {
+	for tok, sess := range s.sessions {
+		if !now.Before(sess.ExpiresAt) {
+			delete(s.sessions, tok)
+		}
+	}
+}
+
+// TokenFromRequest reads a session token from "Authorization: Bearer" or X-Session-Token.
+func TokenFromRequest(r *http.Request) string {
+	if h := strings.TrimSpace(r.Header.Get("Authorization")); h != "" {
+		if len(h) > 7 && strings.EqualFold(h[:7], "bearer ") {
+			return strings.TrimSpace(h[7:])
+		}
+	}
+	return strings.TrimSpace(r.Header.Get(SessionHeader))
+}
@@ -0,0 +1,51 @@
+package domain
+
+// Booking is a record of bookings.json.
+type Booking struct {
+	ID            string `json:"id"`
+	Rev           Int    `json:"rev,omitempty"`
+	Number        string `json:"number,omitempty"` // document number, e.g. BK/2026/000123
+	SiteID        string `json:"site_id"`
+	SubsiteID     string `json:"subsite_id"`
+	ZoneID        string `json:"zone_id"`
+	CustomerName  string `json:"customer_name"`
+	CustomerPhone string `json:"customer_phone"`
+	CustomerEmail string `json:"customer_email"`
+	Status        string `json:"status"`
+	StartDate     string `json:"start_date"`
+	EndDate       string `json:"end_date"`
+	Price         Number `json:"price"`
+	Notes         string `json:"notes"`
+
+	RequestedByUserID string `json:"requested_by_user_id,omitempty"`
+	ApprovedAt        string `json:"approved_at,omitempty"`
+	ApprovedByUserID  string `json:"approved_by_user_id,omitempty"`
+	RejectedAt        string `json:"rejected_at,omitempty"`
+	RejectedByUserID  string `json:"rejected_by_user_id,omitempty"`
+	RejectionReason   string `json:"rejection_reason,omitempty"`
+	CancelledAt       string `json:"cancelled_at,omitempty"`
+	CancelledByUserID string `json:"cancelled_by_user_id,omitempty"`
+	CancelReason      string `json:"cancel_reason,omitempty"`
+
+	CreatedAt string `json:"created_at,omitempty"`
+	UpdatedAt string `json:"updated_at,omitempty"`
+
+	Extra Extra `json:"-"`
+}
+
+func (b Booking) RecordID() string       { return b.ID }
+func (b *Booking) SetRecordID(id string) { b.ID = id }
+
+func (b *Booking) UnmarshalJSON(data []byte) error {
+	type alias Booking
+	var a alias
+	extra, err := decodeRecord(data, &a)
+	*b = Booking(a)
+	b.Extra = extra
+	return err
+}
+
+func (b Booking) MarshalJSON() ([]byte, error) {
+	type alias Booking
+	return encodeRecord(alias(b), b.Extra)
+}
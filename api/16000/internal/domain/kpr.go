@@ -0,0 +1,323 @@
+package domain
+
+import (
+	"errors"
+	"math"
+	"strings"
+)
+
+// KPRCustomer is the applicant block of a KPR application.
+type KPRCustomer struct {
+	Name    string `json:"name"`
+	Phone   string `json:"phone"`
+	Email   string `json:"email"`
+	NIK     string `json:"nik"`
+	Address string `json:"address"`
+
+	// Affordability inputs; the amounts are monthly.
+	EmploymentType     string `json:"employment_type,omitempty"`
+	MonthlyIncome      Number `json:"monthly_income,omitempty"`
+	MonthlyObligations Number `json:"monthly_obligations,omitempty"`
+
+	Extra Extra `json:"-"`
+}
+
+// Employment types of a KPR applicant.
+const (
+	EmploymentEmployee     = "employee"
+	EmploymentSelfEmployed = "self_employed"
+	EmploymentProfessional = "professional"
+	EmploymentOther        = "other"
+)
+
+// ValidEmploymentType reports whether t is one of the employment types above.
+func ValidEmploymentType(t string) bool {
+	switch t {
+	case EmploymentEmployee, EmploymentSelfEmployed, EmploymentProfessional, EmploymentOther:
+		return true
+	}
+	return false
+}
+
+// KPRPrice is the financing block of a KPR application.
+type KPRPrice struct {
+	LandPrice    Number `json:"land_price"`
+	DpAmount     Number `json:"dp_amount"`
+	DpPaid       Number `json:"dp_paid"`
+	LoanAmount   Number `json:"loan_amount"`
+	TenorMonths  Int    `json:"tenor_months"`
+	InterestRate Number `json:"interest_rate"`
+	AdminFee     Number `json:"admin_fee"`
+	OtherFee     Number `json:"other_fee"`
+	Total        Number `json:"total"`
+
+	Extra Extra `json:"-"`
+}
+
+// KPRApplication is a record of kpr_applications.json.
+type KPRApplication struct {
+	ID        string      `json:"id"`
+	Rev       Int         `json:"rev,omitempty"`
+	BookingID string      `json:"booking_id"`
+	SiteID    string      `json:"site_id"`
+	SubsiteID string      `json:"subsite_id"`
+	ZoneID    string      `json:"zone_id"`
+	Customer  KPRCustomer `json:"customer"`
+	Price     KPRPrice    `json:"price"`
+	Status    string      `json:"status"`
+	Notes     string      `json:"notes"`
+
+	ApprovedAt        string `json:"approved_at,omitempty"`
+	InstallmentPlanID string `json:"installment_plan_id,omitempty"`
+
+	Affordability *Affordability `json:"affordability,omitempty"`
+
+	CreatedAt string `json:"created_at,omitempty"`
+	UpdatedAt string `json:"updated_at,omitempty"`
+
+	Extra Extra `json:"-"`
+}
+
+// Affordability verdicts.
+const (
+	AffordabilityOK         = "ok"
+	AffordabilityExceeds    = "exceeds"    // DSR above the policy limit
+	AffordabilityUnassessed = "unassessed" // no monthly income to assess against
+)
+
+// Affordability is the debt-service-ratio check of an application: existing
+// obligations plus the proposed installment, as a percentage of monthly income.
+type Affordability struct {
+	Installment Number       `json:"installment"`
+	DSR         Number       `json:"dsr"`
+	MaxDSR      Number       `json:"max_dsr"`
+	Verdict     string       `json:"verdict"`
+	AssessedAt  string       `json:"assessed_at"`
+	Override    *DSROverride `json:"override,omitempty"`
+}
+
+// DSROverride records an admin approving an application over the limit
+// or without the income to assess it.
+type DSROverride struct {
+	Reason   string `json:"reason"`
+	ByUserID string `json:"by_user_id"`
+	At       string `json:"at"`
+}
+
+// DSR policy modes: what approving an application over the limit does.
+const (
+	DSRBlock = "block" // refused (also when unassessed) unless an admin overrides it with a reason
+	DSRFlag  = "flag"  // approved; the "exceeds"/"unassessed" verdict stays on the record
+)
+
+// DSRPolicy is the affordability limit, MaxDSR in percent.
+type DSRPolicy struct {
+	MaxDSR float64
+	Mode   string
+}
+
+// ValidateForApprove checks what a flat installment plan needs:
+// customer name, a positive loan amount and a positive tenor.
+func (k KPRApplication) ValidateForApprove() error {
+	if strings.TrimSpace(k.Customer.Name) == "" {
+		return errors.New("customer.name is required")
+	}
+	if k.Price.LoanAmount <= 0 {
+		return errors.New("price.loan_amount must be > 0")
+	}
+	if k.Price.TenorMonths <= 0 {
+		return errors.New("price.tenor_months must be > 0")
+	}
+	return nil
+}
+
+// ScheduleItem is one installment of a plan. Amount is Principal + Interest;
+// Balance is the principal still outstanding after it.
+type ScheduleItem struct {
+	No         Int    `json:"no"`
+	DueDate    string `json:"due_date"`
+	Amount     Number `json:"amount"`
+	Rate       Number `json:"rate,omitempty"` // annual percent charged on this line
+	Principal  Number `json:"principal"`
+	Interest   Number `json:"interest"`
+	Balance    Number `json:"balance"`
+	PaidAmount Number `json:"paid_amount"`
+	Status     string `json:"status"`
+
+	Extra Extra `json:"-"`
+}
+
+// Schedule item statuses.
+const (
+	InstallmentUnpaid  = "unpaid"
+	InstallmentPartial = "partial"
+	InstallmentPaid    = "paid"
+)
+
+// moneyEpsilon matches the tolerance payments have always used.
+const moneyEpsilon = 0.000001
+
+// Remaining is what is still owed on the installment.
+func (s ScheduleItem) Remaining() float64 {
+	return float64(s.Amount - s.PaidAmount)
+}
+
+// IsPaid reports whether the installment is fully paid.
+func (s ScheduleItem) IsPaid() bool {
+	return math.Abs(float64(s.Amount-s.PaidAmount)) < moneyEpsilon
+}
+
+// InstallmentPlan is a record of installment_plans.json.
+type InstallmentPlan struct {
+	ID            string         `json:"id"`
+	Rev           Int            `json:"rev,omitempty"`
+	KPRID         string         `json:"kpr_id"`
+	Formula       string         `json:"formula"` // see internal/finance
+	InterestRate  Number         `json:"interest_rate"`
+	LoanAmount    Number         `json:"loan_amount"`
+	TenorMonths   Int            `json:"tenor_months"`
+	MonthlyAmount Number         `json:"monthly_amount"`
+	Schedule      []ScheduleItem `json:"schedule"`
+
+	// Rate tiers (fixed, then floating = reference rate + margin) and the
+	// recomputations of the unpaid tail after rate changes.
+	RateTiers       []RateTier   `json:"rate_tiers,omitempty"`
+	ReferenceRate   Number       `json:"reference_rate,omitempty"`
+	ScheduleVersion Int          `json:"schedule_version,omitempty"`
+	RateHistory     []RateChange `json:"rate_history,omitempty"`
+
+	CreatedAt string `json:"created_at,omitempty"`
+	UpdatedAt string `json:"updated_at,omitempty"`
+
+	Extra Extra `json:"-"`
+}
+
+// Rate tier types.
+const (
+	RateFixed    = "fixed"
+	RateFloating = "floating"
+)
+
+// RateTier applies from installment FromNo until the next tier.
+type RateTier struct {
+	FromNo Int    `json:"from_no"`
+	Type   string `json:"type"`
+	Rate   Number `json:"rate,omitempty"`   // fixed tiers
+	Margin Number `json:"margin,omitempty"` // floating tiers, over the reference rate
+}
+
+// RateChange is one recomputation of a plan's unpaid tail. Version is the
+// schedule version it produced; the Previous fields and ReplacedLines keep
+// what it replaced.
+type RateChange struct {
+	Version         Int    `json:"version"`
+	EffectiveFromNo Int    `json:"effective_from_no"`
+	Type            string `json:"type"` // RateFixed: new fixed rate; RateFloating: new reference rate
+	Rate            Number `json:"rate"`
+	Reason          string `json:"reason,omitempty"`
+
+	PreviousRateTiers     []RateTier     `json:"previous_rate_tiers"`
+	PreviousReferenceRate Number         `json:"previous_reference_rate"`
+	ReplacedLines         []ScheduleItem `json:"replaced_lines"`
+
+	ChangedAt       string `json:"changed_at"`
+	ChangedByUserID string `json:"changed_by_user_id"`
+}
+
+// AllPaid reports whether every installment is paid (false for an empty schedule).
+func (p InstallmentPlan) AllPaid() bool {
+	if len(p.Schedule) == 0 {
+		return false
+	}
+	for _, it := range p.Schedule {
+		if !it.IsPaid() {
+			return false
+		}
+	}
+	return true
+}
+
+// Installment returns the index of installment no in Schedule, or -1.
+func (p InstallmentPlan) Installment(no int) int {
+	for i, it := range p.Schedule {
+		if int(it.No) == no {
+			return i
+		}
+	}
+	return -1
+}
+
+func (k KPRApplication) RecordID() string        { return k.ID }
+func (k *KPRApplication) SetRecordID(id string)  { k.ID = id }
+func (p InstallmentPlan) RecordID() string       { return p.ID }
+func (p *InstallmentPlan) SetRecordID(id string) { p.ID = id }
+
+func (c *KPRCustomer) UnmarshalJSON(b []byte) error {
+	type alias KPRCustomer
+	var a alias
+	extra, err := decodeRecord(b, &a)
+	*c = KPRCustomer(a)
+	c.Extra = extra
+	return err
+}
+
+func (c KPRCustomer) MarshalJSON() ([]byte, error) {
+	type alias KPRCustomer
+	return encodeRecord(alias(c), c.Extra)
+}
+
+func (p *KPRPrice) UnmarshalJSON(b []byte) error {
+	type alias KPRPrice
+	var a alias
+	extra, err := decodeRecord(b, &a)
+	*p = KPRPrice(a)
+	p.Extra = extra
+	return err
+}
+
+func (p KPRPrice) MarshalJSON() ([]byte, error) {
+	type alias KPRPrice
+	return encodeRecord(alias(p), p.Extra)
+}
+
+func (k *KPRApplication) UnmarshalJSON(b []byte) error {
+	type alias KPRApplication
+	var a alias
+	extra, err := decodeRecord(b, &a)
+	*k = KPRApplication(a)
+	k.Extra = extra
+	return err
+}
+
+func (k KPRApplication) MarshalJSON() ([]byte, error) {
+	type alias KPRApplication
+	return encodeRecord(alias(k), k.Extra)
+}
+
+func (s *ScheduleItem) UnmarshalJSON(b []byte) error {
+	type alias ScheduleItem
+	var a alias
+	extra, err := decodeRecord(b, &a)
+	*s = ScheduleItem(a)
+	s.Extra = extra
+	return err
+}
+
+func (s ScheduleItem) MarshalJSON() ([]byte, error) {
+	type alias ScheduleItem
+	return encodeRecord(alias(s), s.Extra)
+}
+
+func (p *InstallmentPlan) UnmarshalJSON(b []byte) error {
+	type alias InstallmentPlan
+	var a alias
+	extra, err := decodeRecord(b, &a)
+	*p = InstallmentPlan(a)
+	p.Extra = extra
+	return err
+}
+
+func (p InstallmentPlan) MarshalJSON() ([]byte, error) {
+	type alias InstallmentPlan
+	return encodeRecord(alias(p), p.Extra)
+}
@@ -0,0 +1,148 @@
+package domain
+
+import "encoding/json"
+
+// Site is a record of sites.json.
+type Site struct {
+	ID   string `json:"id"`
+	Rev  Int    `json:"rev,omitempty"`
+	Name string `json:"name"`
+
+	ArchivedAt       string `json:"archived_at,omitempty"`
+	ArchivedByUserID string `json:"archived_by_user_id,omitempty"`
+
+	Extra Extra `json:"-"`
+}
+
+// Subsite is a record of subsites.json.
+type Subsite struct {
+	ID     string `json:"id"`
+	Rev    Int    `json:"rev,omitempty"`
+	SiteID string `json:"site_id"`
+	Name   string `json:"name"`
+
+	ArchivedAt       string `json:"archived_at,omitempty"`
+	ArchivedByUserID string `json:"archived_by_user_id,omitempty"`
+
+	Extra Extra `json:"-"`
+}
+
+// Zone is a record of zones.json. Geometry, UI and status history are kept
+// raw: they are validated by the zone handlers, not interpreted here.
+type Zone struct {
+	ID        string `json:"id"`
+	Rev       Int    `json:"rev,omitempty"`
+	SubsiteID string `json:"subsite_id"`
+	Name      string `json:"name"`
+	Price     Number `json:"price,omitempty"`
+	Status    string `json:"status,omitempty"`
+
+	Geometry json.RawMessage `json:"geometry,omitempty"`
+	UI       json.RawMessage `json:"ui,omitempty"`
+
+	BookedByUserID  string `json:"booked_by_user_id,omitempty"`
+	BookedBookingID string `json:"booked_booking_id,omitempty"`
+
+	StatusUpdatedAt       string          `json:"status_updated_at,omitempty"`
+	StatusUpdatedByUserID string          `json:"status_updated_by_user_id,omitempty"`
+	StatusHistory         json.RawMessage `json:"status_history,omitempty"`
+
+	CreatedAt string `json:"created_at,omitempty"`
+	UpdatedAt string `json:"updated_at,omitempty"`
+
+	ArchivedAt       string `json:"archived_at,omitempty"`
+	ArchivedByUserID string `json:"archived_by_user_id,omitempty"`
+
+	Extra Extra `json:"-"`
+}
+
+// ZoneStatusAvailable is the implicit status of zones written before the state machine.
+const ZoneStatusAvailable = "AVAILABLE"
+
+// EffectiveStatus returns Status, treating blank as AVAILABLE.
+func (z Zone) EffectiveStatus() string {
+	if z.Status == "" {
+		return ZoneStatusAvailable
+	}
+	return z.Status
+}
+
+// Domain is a record of domains.json (host → site mapping).
+type Domain struct {
+	ID        string          `json:"id"`
+	Rev       Int             `json:"rev,omitempty"`
+	Domain    string          `json:"domain"`
+	SiteID    string          `json:"site_id"`
+	Theme     json.RawMessage `json:"theme,omitempty"`
+	Status    string          `json:"status,omitempty"`
+	IsDefault bool            `json:"is_default,omitempty"`
+	CreatedAt string          `json:"created_at,omitempty"`
+	UpdatedAt string          `json:"updated_at,omitempty"`
+
+	Extra Extra `json:"-"`
+}
+
+func (s Site) RecordID() string          { return s.ID }
+func (s *Site) SetRecordID(id string)    { s.ID = id }
+func (s Subsite) RecordID() string       { return s.ID }
+func (s *Subsite) SetRecordID(id string) { s.ID = id }
+func (z Zone) RecordID() string          { return z.ID }
+func (z *Zone) SetRecordID(id string)    { z.ID = id }
+func (d Domain) RecordID() string        { return d.ID }
+func (d *Domain) SetRecordID(id string)  { d.ID = id }
+
+func (s *Site) UnmarshalJSON(b []byte) error {
+	type alias Site
+	var a alias
+	extra, err := decodeRecord(b, &a)
+	*s = Site(a)
+	s.Extra = extra
+	return err
+}
+
+func (s Site) MarshalJSON() ([]byte, error) {
+	type alias Site
+	return encodeRecord(alias(s), s.Extra)
+}
+
+func (s *Subsite) UnmarshalJSON(b []byte) error {
+	type alias Subsite
+	var a alias
+	extra, err := decodeRecord(b, &a)
+	*s = Subsite(a)
+	s.Extra = extra
+	return err
+}
+
+func (s Subsite) MarshalJSON() ([]byte, error) {
+	type alias Subsite
+	return encodeRecord(alias(s), s.Extra)
+}
+
+func (z *Zone) UnmarshalJSON(b []byte) error {
+	type alias Zone
+	var a alias
+	extra, err := decodeRecord(b, &a)
+	*z = Zone(a)
+	z.Extra = extra
+	return err
+}
+
+func (z Zone) MarshalJSON() ([]byte, error) {
+	type alias Zone
+	return encodeRecord(alias(z), z.Extra)
+}
+
+func (d *Domain) UnmarshalJSON(b []byte) error {
+	type alias Domain
+	var a alias
+	extra, err := decodeRecord(b, &a)
+	*d = Domain(a)
+	d.Extra = extra
+	return err
+}
+
+func (d Domain) MarshalJSON() ([]byte, error) {
+	type alias Domain
+	return encodeRecord(alias(d), d.Extra)
+}
@@ -0,0 +1,46 @@
+package domain
+
+// Payment types.
+const (
+	PaymentDP          = "dp"
+	PaymentInstallment = "installment"
+	PaymentPenalty     = "penalty"
+)
+
+// Payment is a record of payments.json (append-only ledger).
+type Payment struct {
+	ID            string `json:"id"`
+	Rev           Int    `json:"rev,omitempty"`
+	Type          string `json:"type"`
+	KPRID         string `json:"kpr_id"`
+	BookingID     string `json:"booking_id"`
+	InstallmentNo Int    `json:"installment_no"`
+	Amount        Number `json:"amount"`
+	PaidAt        string `json:"paid_at"`
+	Method        string `json:"method"`
+	Reference     string `json:"reference"`
+	Notes         string `json:"notes"`
+	Bucket        string `json:"bucket,omitempty"`
+	ReceiptNumber string `json:"receipt_number,omitempty"`
+	CreatedAt     string `json:"created_at,omitempty"`
+	UpdatedAt     string `json:"updated_at,omitempty"`
+
+	Extra Extra `json:"-"`
+}
+
+func (p Payment) RecordID() string       { return p.ID }
+func (p *Payment) SetRecordID(id string) { p.ID = id }
+
+func (p *Payment) UnmarshalJSON(b []byte) error {
+	type alias Payment
+	var a alias
+	extra, err := decodeRecord(b, &a)
+	*p = Payment(a)
+	p.Extra = extra
+	return err
+}
+
+func (p Payment) MarshalJSON() ([]byte, error) {
+	type alias Payment
+	return encodeRecord(alias(p), p.Extra)
+}
@@ -0,0 +1,180 @@
+// Package domain defines the typed records stored in the core JSON files.
+//
+// Every record keeps fields it does not know about in Extra, so decoding and
+// re-encoding a record written by an older/newer version never drops data.
+package domain
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Number is a float64 that also accepts numeric strings and null (legacy records).
+type Number float64
+
+func (n *Number) UnmarshalJSON(b []byte) error {
+	f, err := parseFlexibleNumber(b)
+	if err != nil {
+		return err
+	}
+	*n = Number(f)
+	return nil
+}
+
+// Int is an int that also accepts floats ("12.0"), numeric strings and null.
+type Int int
+
+func (i *Int) UnmarshalJSON(b []byte) error {
+	f, err := parseFlexibleNumber(b)
+	if err != nil {
+		return err
+	}
+	*i = Int(f)
+	return nil
+}
+
+func parseFlexibleNumber(b []byte) (float64, error) {
+	b = bytes.TrimSpace(b)
+	if len(b) == 0 || string(b) == "null" {
+		return 0, nil
+	}
+	if b[0] == '"' {
+		var s string
+		if err := json.Unmarshal(b, &s); err != nil {
+			return 0, err
+		}
+		s = strings.TrimSpace(s)
+		if s == "" {
+			return 0, nil
+		}
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid number %q", s)
+		}
+		return f, nil
+	}
+	var f float64
+	if err := json.Unmarshal(b, &f); err != nil {
+		return 0, err
+	}
+	return f, nil
+}
+
+// Extra holds JSON fields a record type does not declare.
+type Extra map[string]json.RawMessage
+
+// knownFields caches the JSON field names declared by a struct type.
+var knownFields sync.Map // reflect.Type -> map[string]bool
+
+func fieldNames(t reflect.Type) map[string]bool {
+	if v, ok := knownFields.Load(t); ok {
+		return v.(map[string]bool)
+	}
+	names := make(map[string]bool, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" || !f.IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(tag, ",")
+		if name == "" {
+			name = f.Name
+		}
+		names[name] = true
+	}
+	knownFields.Store(t, names)
+	return names
+}
+
+// decodeRecord unmarshals data into v (a pointer to an alias struct without
+// custom methods) and returns the fields v does not declare.
+func decodeRecord(data []byte, v any) (Extra, error) {
+	if err := json.Unmarshal(data, v); err != nil {
+		return nil, err
+	}
+	var all map[string]json.RawMessage
+	if err := json.Unmarshal(data, &all); err != nil {
+		return nil, err
+	}
+	known := fieldNames(reflect.TypeOf(v).Elem())
+	var extra Extra
+	for k, raw := range all {
+		if known[k] {
+			continue
+		}
+		if extra == nil {
+			extra = Extra{}
+		}
+		extra[k] = raw
+	}
+	return extra, nil
+}
+
+// encodeRecord marshals v (an alias struct value) and merges extra back in.
+// Declared fields always win over a stale Extra entry of the same name.
+func encodeRecord(v any, extra Extra) ([]byte, error) {
+	b, err := json.Marshal(v)
+	if err != nil || len(extra) == 0 {
+		return b, err
+	}
+	var all map[string]json.RawMessage
+	if err := json.Unmarshal(b, &all); err != nil {
+		return nil, err
+	}
+	known := fieldNames(reflect.TypeOf(v))
+	for k, raw := range extra {
+		if known[k] {
+			continue
+		}
+		all[k] = raw
+	}
+	return json.Marshal(all)
+}
+
+// Record is implemented by every top-level stored record.
+type Record interface {
+	RecordID() string
+	SetRecordID(id string)
+}
+
+// DecodeItems decodes a file's items into typed records. The map key is the
+// record id; records without an "id" field get it from the key. Items that do
+// not decode are skipped, matching GetItems.
+func DecodeItems[T any, PT interface {
+	*T
+	Record
+}](items map[string]json.RawMessage) map[string]T {
+	out := make(map[string]T, len(items))
+	for id, raw := range items {
+		var v T
+		if err := json.Unmarshal(raw, &v); err != nil {
+			continue
+		}
+		if PT(&v).RecordID() == "" {
+			PT(&v).SetRecordID(id)
+		}
+		out[id] = v
+	}
+	return out
+}
+
+// Decode decodes one raw record.
+func Decode[T any, PT interface {
+	*T
+	Record
+}](id string, raw json.RawMessage) (T, error) {
+	var v T
+	if err := json.Unmarshal(raw, &v); err != nil {
+		return v, err
+	}
+	if PT(&v).RecordID() == "" {
+		PT(&v).SetRecordID(id)
+	}
+	return v, nil
+}
@@ -0,0 +1,63 @@
+package domain
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestRoundTripPreservesUnknownFields(t *testing.T) {
+	in := `{"id":"k1","booking_id":"b1","status":"approved","legacy_flag":true,
+		"customer":{"name":"A","nik":"1","mother_name":"M"},
+		"price":{"loan_amount":"400","tenor_months":12.0,"promo":{"code":"X"}}}`
+
+	var k KPRApplication
+	if err := json.Unmarshal([]byte(in), &k); err != nil {
+		t.Fatal(err)
+	}
+	if k.Price.LoanAmount != 400 || k.Price.TenorMonths != 12 {
+		t.Fatalf("flexible numbers not decoded: %+v", k.Price)
+	}
+
+	k.Status = "completed"
+	out, err := json.Marshal(k)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var m map[string]any
+	if err := json.Unmarshal(out, &m); err != nil {
+		t.Fatal(err)
+	}
+	if m["legacy_flag"] != true || m["status"] != "completed" {
+		t.Fatalf("top-level fields wrong: %s", out)
+	}
+	if m["customer"].(map[string]any)["mother_name"] != "M" {
+		t.Fatalf("nested customer extra dropped: %s", out)
+	}
+	if _, ok := m["price"].(map[string]any)["promo"]; !ok {
+		t.Fatalf("nested price extra dropped: %s", out)
+	}
+}
+
+func TestValidateForApprove(t *testing.T) {
+	for _, tc := range []struct {
+		raw string
+		ok  bool
+	}{
+		{`{"customer":{"name":"A"},"price":{"loan_amount":100,"tenor_months":12}}`, true},
+		{`{"customer":{"name":"A"},"price":{"loan_amount":100,"tenor_months":"12"}}`, true},
+		{`{"customer":{"name":"A"},"price":{"loan_amount":100,"tenor_months":0}}`, false},
+		{`{"customer":{"name":" "},"price":{"loan_amount":100,"tenor_months":12}}`, false},
+		{`{"customer":{"name":"A"},"price":{"tenor_months":12}}`, false},
+	} {
+		k, err := Decode[KPRApplication]("k1", json.RawMessage(tc.raw))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := k.ValidateForApprove() == nil; got != tc.ok {
+			t.Errorf("%s: ok=%v, want %v", tc.raw, got, tc.ok)
+		}
+		if k.ID != "k1" {
+			t.Errorf("id not taken from key: %q", k.ID)
+		}
+	}
+}
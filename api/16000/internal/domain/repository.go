@@ -0,0 +1,50 @@
+package domain
+
+// Repository is the typed read view of the core files. Returned maps are
+// keyed by record id and shared between readers: treat them as read-only
+// and copy a record before changing it.
+type Repository interface {
+	Users() map[string]User
+	Sites() map[string]Site
+	Subsites() map[string]Subsite
+	Zones() map[string]Zone
+	Domains() map[string]Domain
+	Bookings() map[string]Booking
+	KPRApplications() map[string]KPRApplication
+	InstallmentPlans() map[string]InstallmentPlan
+	Payments() map[string]Payment
+}
+
+// SupportRepository is the typed read view of the support module files.
+// A missing or unreadable support file reads as empty.
+type SupportRepository interface {
+	Tickets() map[string]Ticket
+}
+
+// Indexes are secondary lookups over the same snapshot as Repository,
+// rebuilt when the underlying file is reloaded. They return record ids in
+// ascending order; the slices are shared and must not be modified.
+type Indexes interface {
+	ZonesBySubsite(subsiteID string) []string
+	BookingsByZone(zoneID string) []string
+	KPRsByBooking(bookingID string) []string
+	PlansByKPR(kprID string) []string
+	PaymentsByKPR(kprID string) []string
+	PaymentsByBooking(bookingID string) []string
+}
+
+// Core file names per record type.
+const (
+	UsersFile            = "users.json"
+	SitesFile            = "sites.json"
+	SubsitesFile         = "subsites.json"
+	ZonesFile            = "zones.json"
+	DomainsFile          = "domains.json"
+	BookingsFile         = "bookings.json"
+	KPRApplicationsFile  = "kpr_applications.json"
+	InstallmentPlansFile = "installment_plans.json"
+	PaymentsFile         = "payments.json"
+)
+
+// TicketsFile is the support module's ticket file (optional).
+const TicketsFile = "support/tickets.json"
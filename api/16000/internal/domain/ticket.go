@@ -0,0 +1,55 @@
+package domain
+
+// Ticket statuses.
+const (
+	TicketOpen       = "OPEN"
+	TicketInProgress = "IN_PROGRESS"
+	TicketResolved   = "RESOLVED"
+	TicketClosed     = "CLOSED"
+)
+
+// Ticket is a record of support/tickets.json. Tickets may reference a
+// booking, zone or site; core records never reference tickets.
+type Ticket struct {
+	ID        string          `json:"id"`
+	Rev       Int             `json:"rev,omitempty"`
+	Subject   string          `json:"subject"`
+	Status    string          `json:"status"`
+	BookingID string          `json:"booking_id,omitempty"`
+	ZoneID    string          `json:"zone_id,omitempty"`
+	SiteID    string          `json:"site_id,omitempty"`
+	Messages  []TicketMessage `json:"messages"`
+
+	CreatedByUserID string `json:"created_by_user_id"`
+	StatusChangedAt string `json:"status_changed_at,omitempty"`
+	StatusChangedBy string `json:"status_changed_by_user_id,omitempty"`
+	CreatedAt       string `json:"created_at,omitempty"`
+	UpdatedAt       string `json:"updated_at,omitempty"`
+
+	Extra Extra `json:"-"`
+}
+
+// TicketMessage is one entry of a ticket's thread (append-only).
+type TicketMessage struct {
+	ID           string `json:"id"`
+	AuthorUserID string `json:"author_user_id"`
+	Body         string `json:"body"`
+	CreatedAt    string `json:"created_at"`
+}
+
+func (t Ticket) RecordID() string       { return t.ID }
+func (t *Ticket) SetRecordID(id string) { t.ID = id }
+
+func (t *Ticket) UnmarshalJSON(b []byte) error {
+	type alias Ticket
+	var a alias
+	extra, err := decodeRecord(b, &a)
+	*t = Ticket(a)
+	t.Extra = extra
+	return err
+}
+
+func (t Ticket) MarshalJSON() ([]byte, error) {
+	type alias Ticket
+	return encodeRecord(alias(t), t.Extra)
+}
@@ -0,0 +1,42 @@
+package domain
+
+import "strings"
+
+// User is a record of users.json.
+type User struct {
+	ID           string `json:"id"`
+	Rev          Int    `json:"rev,omitempty"`
+	Username     string `json:"username"`
+	Email        string `json:"email,omitempty"`
+	FullName     string `json:"full_name,omitempty"`
+	Role         string `json:"role"`
+	Status       string `json:"status,omitempty"`
+	PasswordHash string `json:"password_hash,omitempty"`
+	CreatedAt    string `json:"created_at,omitempty"`
+	UpdatedAt    string `json:"updated_at,omitempty"`
+
+	Extra Extra `json:"-"`
+}
+
+// Active reports whether the user may log in (blank status counts as ACTIVE).
+func (u User) Active() bool {
+	st := strings.ToUpper(strings.TrimSpace(u.Status))
+	return st == "" || st == "ACTIVE"
+}
+
+func (u User) RecordID() string       { return u.ID }
+func (u *User) SetRecordID(id string) { u.ID = id }
+
+func (u *User) UnmarshalJSON(b []byte) error {
+	type alias User
+	var a alias
+	extra, err := decodeRecord(b, &a)
+	*u = User(a)
+	u.Extra = extra
+	return err
+}
+
+func (u User) MarshalJSON() ([]byte, error) {
+	type alias User
+	return encodeRecord(alias(u), u.Extra)
+}
@@ -0,0 +1,242 @@
+// Package finance computes KPR installment schedules. It is pure arithmetic on
+// plain numbers: callers map the result onto storage records.
+//
+// Rates are nominal annual percentages (9.5 = 9.5% p.a.), applied monthly as
+// rate/12. Amounts are rounded to 2 decimals per line; the last line absorbs
+// the rounding so the principal portions always add up to the loan.
+package finance
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+// Installment formulas.
+const (
+	// FormulaFlat is the zero-interest split: loan / tenor every month.
+	FormulaFlat = "flat"
+	// FormulaFlatInterest charges interest on the original loan every month,
+	// so principal and interest portions stay constant.
+	FormulaFlatInterest = "flat_interest"
+	// FormulaAnnuity is bank-style effective-rate amortization: a constant
+	// payment whose interest portion is charged on the outstanding balance.
+	FormulaAnnuity = "annuity"
+)
+
+// MaxRate is the highest accepted annual rate, in percent.
+const MaxRate = 100
+
+var (
+	ErrFormula = errors.New("unknown installment formula")
+	ErrInput   = errors.New("invalid loan terms")
+)
+
+// ValidFormula reports whether f is one of the formulas above.
+func ValidFormula(f string) bool {
+	switch f {
+	case FormulaFlat, FormulaFlatInterest, FormulaAnnuity:
+		return true
+	}
+	return false
+}
+
+// Line is one month of a schedule.
+type Line struct {
+	No        int     // 1-based
+	Rate      float64 // annual rate charged on this line, percent
+	Amount    float64 // Principal + Interest
+	Principal float64
+	Interest  float64
+	Balance   float64 // outstanding principal after this line
+}
+
+// Tier is the rate from installment FromNo until the next tier: a fixed Rate,
+// or when Floating the reference rate of the terms plus Margin.
+type Tier struct {
+	FromNo   int
+	Floating bool
+	Rate     float64
+	Margin   float64
+}
+
+// Terms are the inputs of a schedule.
+type Terms struct {
+	Formula     string
+	Loan        float64
+	AnnualRate  float64 // percent p.a.; ignored by FormulaFlat and when Tiers are set
+	TenorMonths int
+
+	// Tiers, when set, replace AnnualRate: the first starts at installment 1
+	// and each later one at a higher installment.
+	Tiers         []Tier
+	ReferenceRate float64 // percent p.a., for floating tiers
+}
+
+func validRate(r float64) bool {
+	return r >= 0 && r < MaxRate
+}
+
+func (t Terms) validate() error {
+	if !ValidFormula(t.Formula) {
+		return fmt.Errorf("%w: %q", ErrFormula, t.Formula)
+	}
+	if !(t.Loan > 0) || math.IsInf(t.Loan, 0) {
+		return fmt.Errorf("%w: loan must be > 0", ErrInput)
+	}
+	if t.TenorMonths <= 0 {
+		return fmt.Errorf("%w: tenor must be > 0", ErrInput)
+	}
+	if t.Formula == FormulaFlat {
+		if len(t.Tiers) > 0 {
+			return fmt.Errorf("%w: flat plans carry no interest", ErrInput)
+		}
+		return nil
+	}
+	if len(t.Tiers) == 0 {
+		if !validRate(t.AnnualRate) {
+			return fmt.Errorf("%w: interest rate must be in [0, %d)", ErrInput, MaxRate)
+		}
+		return nil
+	}
+	for i, tr := range t.Tiers {
+		switch {
+		case i == 0 && tr.FromNo != 1:
+			return fmt.Errorf("%w: the first rate tier must start at installment 1", ErrInput)
+		case i > 0 && tr.FromNo <= t.Tiers[i-1].FromNo:
+			return fmt.Errorf("%w: rate tiers must start at increasing installments", ErrInput)
+		case tr.FromNo > t.TenorMonths:
+			return fmt.Errorf("%w: rate tier starts after the last installment", ErrInput)
+		case !validRate(t.tierRate(tr)):
+			return fmt.Errorf("%w: rate of tier from installment %d must be in [0, %d)", ErrInput, tr.FromNo, MaxRate)
+		}
+	}
+	return nil
+}
+
+func (t Terms) tierRate(tr Tier) float64 {
+	if tr.Floating {
+		return t.ReferenceRate + tr.Margin
+	}
+	return tr.Rate
+}
+
+// Rate is the annual rate the formula charges on the first installment
+// (0 for FormulaFlat).
+func (t Terms) Rate() float64 {
+	return t.RateAt(1)
+}
+
+// RateAt is the annual rate charged on installment no.
+func (t Terms) RateAt(no int) float64 {
+	if t.Formula == FormulaFlat {
+		return 0
+	}
+	if len(t.Tiers) == 0 {
+		return t.AnnualRate
+	}
+	rate := 0.0
+	for _, tr := range t.Tiers {
+		if tr.FromNo > no {
+			break
+		}
+		rate = t.tierRate(tr)
+	}
+	return rate
+}
+
+// Schedule returns the tenor lines of t.
+func Schedule(t Terms) ([]Line, error) {
+	return Reschedule(t, 1, t.Loan)
+}
+
+// Reschedule returns the lines from installment from to the end of the
+// tenor, starting from balance, the principal outstanding before from. It
+// is how the unpaid tail of a plan is recomputed after a rate change.
+func Reschedule(t Terms, from int, balance float64) ([]Line, error) {
+	if err := t.validate(); err != nil {
+		return nil, err
+	}
+	n := t.TenorMonths
+	if from < 1 || from > n {
+		return nil, fmt.Errorf("%w: installment %d is outside the tenor", ErrInput, from)
+	}
+	if balance < 0 || math.IsNaN(balance) {
+		return nil, fmt.Errorf("%w: balance must be >= 0", ErrInput)
+	}
+	principal := round2(t.Loan / float64(n))
+
+	out := make([]Line, 0, n-from+1)
+	balance = round2(balance)
+	payment := 0.0
+	for i := from; i <= n; i++ {
+		rate := t.RateAt(i)
+		r := rate / 100 / 12
+		l := Line{No: i, Rate: rate}
+		switch t.Formula {
+		case FormulaFlat:
+			l.Principal = principal
+		case FormulaFlatInterest:
+			l.Principal = principal
+			l.Interest = round2(t.Loan * r)
+		case FormulaAnnuity:
+			// The payment is re-amortized over the remaining months
+			// whenever the rate changes.
+			if i == from || rate != out[len(out)-1].Rate {
+				payment = round2(annuity(balance, r, n-i+1))
+			}
+			l.Interest = round2(balance * r)
+			l.Principal = round2(payment - l.Interest)
+		}
+		if i == n || l.Principal > balance {
+			l.Principal = balance
+		}
+		balance = round2(balance - l.Principal)
+		l.Balance = balance
+		l.Amount = round2(l.Principal + l.Interest)
+		out = append(out, l)
+	}
+	return out, nil
+}
+
+func annuity(balance, r float64, months int) float64 {
+	if r == 0 {
+		return balance / float64(months)
+	}
+	return balance * r / (1 - math.Pow(1+r, -float64(months)))
+}
+
+// Payment is the monthly amount of the first installment of t before
+// rounding (for FormulaAnnuity the annuity payment at the first rate).
+// Invalid terms return 0.
+func Payment(t Terms) float64 {
+	if t.validate() != nil {
+		return 0
+	}
+	n := float64(t.TenorMonths)
+	r := t.Rate() / 100 / 12
+	switch t.Formula {
+	case FormulaFlatInterest:
+		return t.Loan/n + t.Loan*r
+	case FormulaAnnuity:
+		return annuity(t.Loan, r, t.TenorMonths)
+	default:
+		return t.Loan / n
+	}
+}
+
+// DSR is the debt-service ratio in percent: existing monthly obligations
+// plus the proposed installment over monthly income.
+func DSR(income, obligations, installment float64) (float64, error) {
+	if !(income > 0) || math.IsInf(income, 0) {
+		return 0, fmt.Errorf("%w: income must be > 0", ErrInput)
+	}
+	if obligations < 0 || installment < 0 {
+		return 0, fmt.Errorf("%w: obligations and installment must be >= 0", ErrInput)
+	}
+	return round2((obligations + installment) / income * 100), nil
+}
+
+func round2(v float64) float64 {
+	return math.Round(v*100) / 100
+}
@@ -0,0 +1,110 @@
+package finance
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func terms(formula string, loan, rate float64, tenor int) Terms {
+	return Terms{Formula: formula, Loan: loan, AnnualRate: rate, TenorMonths: tenor}
+}
+
+func TestSchedule(t *testing.T) {
+	cases := []struct {
+		terms        Terms
+		first        Line
+		lastInterest float64
+	}{
+		{terms(FormulaFlat, 1000, 12, 3), Line{No: 1, Amount: 333.33, Principal: 333.33, Balance: 666.67}, 0},
+		{terms(FormulaFlatInterest, 120_000_000, 12, 12), Line{No: 1, Rate: 12, Amount: 11_200_000, Principal: 10_000_000, Interest: 1_200_000, Balance: 110_000_000}, 1_200_000},
+		{terms(FormulaAnnuity, 100_000_000, 12, 12), Line{No: 1, Rate: 12, Amount: 8_884_878.87, Principal: 7_884_878.87, Interest: 1_000_000, Balance: 92_115_121.13}, 87_969.1},
+	}
+	for _, c := range cases {
+		lines, err := Schedule(c.terms)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(lines) != c.terms.TenorMonths || lines[0] != c.first {
+			t.Fatalf("%s: first line %+v", c.terms.Formula, lines[0])
+		}
+		sum := 0.0
+		for _, l := range lines {
+			sum += l.Principal
+		}
+		last := lines[len(lines)-1]
+		if math.Abs(sum-c.terms.Loan) > 0.001 || last.Balance != 0 || last.Interest != c.lastInterest {
+			t.Fatalf("%s: principal sum %v, last line %+v", c.terms.Formula, sum, last)
+		}
+	}
+}
+
+func TestScheduleRejectsBadTerms(t *testing.T) {
+	if _, err := Schedule(terms("balloon", 1000, 0, 12)); !errors.Is(err, ErrFormula) {
+		t.Fatalf("formula: %v", err)
+	}
+	for _, tm := range []Terms{
+		terms(FormulaAnnuity, 0, 10, 12),
+		terms(FormulaAnnuity, 1000, 10, 0),
+		terms(FormulaAnnuity, 1000, -1, 12),
+		terms(FormulaFlatInterest, 1000, 100, 12),
+	} {
+		if _, err := Schedule(tm); !errors.Is(err, ErrInput) {
+			t.Fatalf("%+v: %v", tm, err)
+		}
+	}
+	// A zero rate is just a flat split.
+	if p := Payment(terms(FormulaAnnuity, 1200, 0, 12)); p != 100 {
+		t.Fatalf("zero-rate annuity payment %v", p)
+	}
+}
+
+func TestTiersAndReschedule(t *testing.T) {
+	tm := terms(FormulaAnnuity, 100_000_000, 0, 24)
+	tm.Tiers = []Tier{{FromNo: 1, Rate: 6}, {FromNo: 13, Floating: true, Margin: 3}}
+	tm.ReferenceRate = 6
+
+	lines, err := Schedule(tm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines[11].Rate != 6 || lines[12].Rate != 9 || lines[23].Balance != 0 {
+		t.Fatalf("tiers not applied: %+v %+v", lines[11], lines[12])
+	}
+	// Same payment within a tier, re-amortized when the rate steps up.
+	if lines[1].Amount != lines[0].Amount || lines[13].Amount != lines[12].Amount || lines[12].Amount <= lines[11].Amount {
+		t.Fatalf("payments: %v %v %v", lines[0].Amount, lines[11].Amount, lines[12].Amount)
+	}
+
+	// Recomputing the tail at unchanged terms reproduces it.
+	tail, err := Reschedule(tm, 13, lines[11].Balance)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tail) != 12 || tail[0] != lines[12] || tail[11] != lines[23] {
+		t.Fatalf("tail differs: %+v vs %+v", tail[0], lines[12])
+	}
+
+	// A higher reference rate raises only the floating tail.
+	tm.ReferenceRate = 8
+	if tail, _ = Reschedule(tm, 13, lines[11].Balance); tail[0].Rate != 11 || tail[11].Balance != 0 {
+		t.Fatalf("floating tail: %+v", tail[0])
+	}
+
+	tm.Tiers = []Tier{{FromNo: 2, Rate: 6}}
+	if _, err := Schedule(tm); !errors.Is(err, ErrInput) {
+		t.Fatalf("tiers not starting at 1: %v", err)
+	}
+}
+
+func TestDSR(t *testing.T) {
+	if got, err := DSR(15_000_000, 1_500_000, 4_500_000); err != nil || got != 40 {
+		t.Fatalf("DSR = %v, %v; want 40", got, err)
+	}
+	if _, err := DSR(0, 0, 1); !errors.Is(err, ErrInput) {
+		t.Fatalf("zero income: %v", err)
+	}
+	if _, err := DSR(1, -1, 1); !errors.Is(err, ErrInput) {
+		t.Fatalf("negative obligations: %v", err)
+	}
+}
@@ -0,0 +1,249 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/itmtjewelry/land-booking-kpr/internal/auth"
+	"github.com/itmtjewelry/land-booking-kpr/internal/storage"
+)
+
+// Archiving keeps a site, subsite or zone (and everything referencing it) but
+// hides it from the public lists; children of an archived record are hidden
+// with it. Hard delete is only for records nothing ever referenced.
+
+// location describes one archivable record type.
+type location struct {
+	file  string // storage file
+	kind  string // for messages
+	field string // reference field on bookings and KPRs
+}
+
+var (
+	siteLocation    = location{file: "sites.json", kind: "site", field: "site_id"}
+	subsiteLocation = location{file: "subsites.json", kind: "subsite", field: "subsite_id"}
+	zoneLocation    = location{file: "zones.json", kind: "zone", field: "zone_id"}
+)
+
+func isArchived(m map[string]any) bool {
+	return m != nil && str(m["archived_at"]) != ""
+}
+
+// siteArchived, subsiteArchived and zoneArchived report whether the record or
+// one of its parents is archived.
+func siteArchived(deps Stage7Deps, siteID string) bool {
+	return isArchived(getItemMap(deps.GetItems("sites.json"), siteID))
+}
+
+func subsiteArchived(deps Stage7Deps, subsiteID string) bool {
+	sub := getItemMap(deps.GetItems("subsites.json"), subsiteID)
+	return isArchived(sub) || (sub != nil && siteArchived(deps, str(sub["site_id"])))
+}
+
+func zoneArchived(deps Stage7Deps, zoneID string) bool {
+	zone := getItemMap(deps.GetItems("zones.json"), zoneID)
+	return isArchived(zone) || (zone != nil && subsiteArchived(deps, str(zone["subsite_id"])))
+}
+
+// includeArchived is the admin-only ?include_archived=true list flag.
+func includeArchived(r *http.Request) bool {
+	v, _ := strconv.ParseBool(r.URL.Query().Get("include_archived"))
+	return v && auth.IsAdmin(r)
+}
+
+func withoutArchived(data []map[string]any) []map[string]any {
+	out := make([]map[string]any, 0, len(data))
+	for _, m := range data {
+		if !isArchived(m) {
+			out = append(out, m)
+		}
+	}
+	return out
+}
+
+// locationUsage counts the bookings and KPRs placed on a site, subsite or zone.
+type locationUsage struct {
+	Bookings       int
+	ActiveBookings int
+	KPRs           int
+	ActiveKPRs     int
+}
+
+func (u locationUsage) referenced() bool { return u.Bookings > 0 || u.KPRs > 0 }
+
+// lockUsage takes the bookings.json -> kpr_applications.json locks, which keep
+// usageOf current until the returned unlock. Callers lock the location file
+// after it; BookingsCreate checks the zone under the bookings.json lock.
+func lockUsage(deps Stage8Deps) (unlock func()) {
+	lockBook := deps.LockForFile("bookings.json")
+	lockKPR := deps.LockForFile("kpr_applications.json")
+	lockBook.Lock()
+	lockKPR.Lock()
+	return func() {
+		lockKPR.Unlock()
+		lockBook.Unlock()
+	}
+}
+
+func usageOf(deps Stage8Deps, loc location, id string) locationUsage {
+	ref := func(siteID, subsiteID, zoneID string) string {
+		switch loc.field {
+		case "site_id":
+			return siteID
+		case "subsite_id":
+			return subsiteID
+		default:
+			return zoneID
+		}
+	}
+
+	var u locationUsage
+	for _, b := range deps.Bookings() {
+		if ref(b.SiteID, b.SubsiteID, b.ZoneID) != id {
+			continue
+		}
+		u.Bookings++
+		if b.Status != bookingStatusRejected && b.Status != bookingStatusCancelled {
+			u.ActiveBookings++
+		}
+	}
+	for _, k := range deps.KPRApplications() {
+		if ref(k.SiteID, k.SubsiteID, k.ZoneID) != id {
+			continue
+		}
+		u.KPRs++
+		switch k.Status {
+		case "draft", "submitted", "approved":
+			u.ActiveKPRs++
+		}
+	}
+	return u
+}
+
+// SiteArchive serves POST /api/v1/sites/{id}/archive.
+func SiteArchive(deps Stage8Deps, id string, w http.ResponseWriter, r *http.Request) {
+	setArchived(deps, siteLocation, id, true, w, r)
+}
+
+// SiteUnarchive serves POST /api/v1/sites/{id}/unarchive.
+func SiteUnarchive(deps Stage8Deps, id string, w http.ResponseWriter, r *http.Request) {
+	setArchived(deps, siteLocation, id, false, w, r)
+}
+
+// SubsiteArchive serves POST /api/v1/subsites/{id}/archive.
+func SubsiteArchive(deps Stage8Deps, id string, w http.ResponseWriter, r *http.Request) {
+	setArchived(deps, subsiteLocation, id, true, w, r)
+}
+
+// SubsiteUnarchive serves POST /api/v1/subsites/{id}/unarchive.
+func SubsiteUnarchive(deps Stage8Deps, id string, w http.ResponseWriter, r *http.Request) {
+	setArchived(deps, subsiteLocation, id, false, w, r)
+}
+
+// ZoneArchive serves POST /api/v1/zones/{id}/archive.
+func ZoneArchive(deps Stage8Deps, id string, w http.ResponseWriter, r *http.Request) {
+	setArchived(deps, zoneLocation, id, true, w, r)
+}
+
+// ZoneUnarchive serves POST /api/v1/zones/{id}/unarchive.
+func ZoneUnarchive(deps Stage8Deps, id string, w http.ResponseWriter, r *http.Request) {
+	setArchived(deps, zoneLocation, id, false, w, r)
+}
+
+func setArchived(deps Stage8Deps, loc location, id string, archive bool, w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w)
+		return
+	}
+	if !deps.StorageReady() {
+		errJSON(w, http.StatusServiceUnavailable, "storage not ready")
+		return
+	}
+
+	id = strings.TrimSpace(id)
+	if id == "" || strings.Contains(id, "/") {
+		errJSON(w, http.StatusBadRequest, "invalid id")
+		return
+	}
+
+	// Lock order: bookings.json -> kpr_applications.json -> the record's file,
+	// so no booking or KPR lands on the record while it is being archived.
+	defer lockUsage(deps)()
+	lockLoc := deps.LockForFile(loc.file)
+	lockLoc.Lock()
+	defer lockLoc.Unlock()
+
+	jf := mustLoadJSONFile(deps, loc.file)
+	raw, ok := jf.Items[id]
+	if !ok {
+		errJSON(w, http.StatusNotFound, loc.kind+" not found")
+		return
+	}
+	if !requireIfMatch(w, r, raw) {
+		return
+	}
+	var rec map[string]any
+	if err := json.Unmarshal(raw, &rec); err != nil {
+		errJSON(w, http.StatusInternalServerError, "invalid stored "+loc.kind)
+		return
+	}
+
+	if archive {
+		if isArchived(rec) {
+			errJSON(w, http.StatusConflict, loc.kind+" is already archived")
+			return
+		}
+		if u := usageOf(deps, loc, id); u.ActiveBookings > 0 || u.ActiveKPRs > 0 {
+			errJSON(w, http.StatusConflict, fmt.Sprintf("cannot archive %s with %d active bookings and %d active KPRs",
+				loc.kind, u.ActiveBookings, u.ActiveKPRs))
+			return
+		}
+		rec["archived_at"] = time.Now().UTC().Format(time.RFC3339)
+		rec["archived_by_user_id"] = auth.PrincipalFrom(r.Context()).UserID
+	} else {
+		if !isArchived(rec) {
+			errJSON(w, http.StatusConflict, loc.kind+" is not archived")
+			return
+		}
+		switch {
+		case loc == subsiteLocation && siteArchived(deps, str(rec["site_id"])):
+			errJSON(w, http.StatusConflict, "site is archived")
+			return
+		case loc == zoneLocation && subsiteArchived(deps, str(rec["subsite_id"])):
+			errJSON(w, http.StatusConflict, "subsite or its site is archived")
+			return
+		}
+		delete(rec, "archived_at")
+		delete(rec, "archived_by_user_id")
+	}
+	jf.Items[id] = mustJSON(rec)
+
+	if err := storage.WriteJSONFileAtomic(deps.StorageDir(), loc.file, jf); err != nil {
+		writeFailed(w, err, "write failed")
+		return
+	}
+	if err := deps.ReloadFiles(loc.file); err != nil {
+		errJSON(w, http.StatusInternalServerError, "reload failed")
+		return
+	}
+	setRecordETag(w, deps, loc.file, id)
+	okData(w, map[string]any{"id": id, "archived": archive})
+}
+
+// keepArchived copies the archive fields of the stored record into a record
+// rebuilt from a PUT payload.
+func keepArchived(stored json.RawMessage, next map[string]any) {
+	var cur map[string]any
+	if json.Unmarshal(stored, &cur) != nil {
+		return
+	}
+	for _, k := range []string{"archived_at", "archived_by_user_id"} {
+		if v, ok := cur[k]; ok {
+			next[k] = v
+		}
+	}
+}
@@ -0,0 +1,203 @@
+package handlers
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/itmtjewelry/land-booking-kpr/internal/auth"
+	"github.com/itmtjewelry/land-booking-kpr/internal/storage"
+)
+
+func newBooking(zoneID string) map[string]any {
+	return map[string]any{
+		"site_id": "s1", "subsite_id": "ss1", "zone_id": zoneID,
+		"customer_name": "Budi", "start_date": "2026-11-01", "end_date": "2026-11-02",
+	}
+}
+
+func TestArchiveZone(t *testing.T) {
+	d := newTestDeps(t, locationSeed())
+	list := func(p auth.Principal, q string) map[string]bool {
+		return listIDs(call(t, ZonesHandler(d), p, "GET", "/api/v1/zones?subsite_id=ss1"+q, nil, nil))
+	}
+
+	if res := call(t, byID(d, ZoneArchive, "z1"), adminP, "POST", "/", nil, nil); res.Code != http.StatusPreconditionRequired {
+		t.Fatalf("archive without If-Match: %d", res.Code)
+	}
+	if res := call(t, byID(d, ZoneArchive, "z1"), adminP, "POST", "/", nil, anyIfMatch); res.Code != http.StatusOK {
+		t.Fatalf("archive: %d %s", res.Code, res.Raw)
+	}
+	z := d.record("zones.json", "z1")
+	if str(z["archived_at"]) == "" || z["archived_by_user_id"] != adminP.UserID {
+		t.Fatalf("archive fields not set: %v", z)
+	}
+	if res := call(t, byID(d, ZoneArchive, "z1"), adminP, "POST", "/", nil, anyIfMatch); res.Code != http.StatusConflict {
+		t.Fatalf("archive twice: %d", res.Code)
+	}
+
+	// Hidden from lists; include_archived is admin-only.
+	if got := list(guestP, ""); got["z1"] || !got["z2"] {
+		t.Fatalf("public list: %v", got)
+	}
+	if got := list(guestP, "&include_archived=true"); got["z1"] {
+		t.Fatalf("include_archived honoured for guest: %v", got)
+	}
+	if got := list(adminP, "&include_archived=true"); !got["z1"] || !got["z2"] {
+		t.Fatalf("admin include_archived: %v", got)
+	}
+
+	// No new bookings on an archived zone.
+	if res := call(t, handler(d, BookingsWriteCollection), salesP, "POST", "/", newBooking("z1"), nil); res.Code != http.StatusConflict {
+		t.Fatalf("booking on archived zone: %d %s", res.Code, res.Raw)
+	}
+
+	if res := call(t, byID(d, ZoneUnarchive, "z1"), adminP, "POST", "/", nil, anyIfMatch); res.Code != http.StatusOK {
+		t.Fatalf("unarchive: %d %s", res.Code, res.Raw)
+	}
+	if z := d.record("zones.json", "z1"); z["archived_at"] != nil || z["archived_by_user_id"] != nil {
+		t.Fatalf("archive fields not cleared: %v", z)
+	}
+	if res := call(t, byID(d, ZoneUnarchive, "z1"), adminP, "POST", "/", nil, anyIfMatch); res.Code != http.StatusConflict {
+		t.Fatalf("unarchive twice: %d", res.Code)
+	}
+}
+
+func TestArchiveParents(t *testing.T) {
+	d := newTestDeps(t, locationSeed())
+	post := func(f func(Stage8Deps, string, http.ResponseWriter, *http.Request), id string) int {
+		return call(t, byID(d, f, id), adminP, "POST", "/", nil, anyIfMatch).Code
+	}
+
+	if c := post(ZoneArchive, "z1"); c != http.StatusOK {
+		t.Fatalf("archive zone: %d", c)
+	}
+	if c := post(SiteArchive, "s1"); c != http.StatusOK {
+		t.Fatalf("archive site: %d", c)
+	}
+	// Children of an archived site are hidden with it.
+	if res := call(t, ZonesHandler(d), guestP, "GET", "/api/v1/zones?subsite_id=ss1", nil, nil); res.Code != http.StatusNotFound {
+		t.Fatalf("zones of archived site: %d", res.Code)
+	}
+	// A child cannot come back before its parent.
+	if c := post(ZoneUnarchive, "z1"); c != http.StatusConflict {
+		t.Fatalf("unarchive zone under archived site: %d", c)
+	}
+	if c := post(SiteUnarchive, "s1"); c != http.StatusOK {
+		t.Fatalf("unarchive site: %d", c)
+	}
+	if c := post(ZoneUnarchive, "z1"); c != http.StatusOK {
+		t.Fatalf("unarchive zone: %d", c)
+	}
+}
+
+func TestArchiveRefusedWhileActive(t *testing.T) {
+	seed := locationSeed()
+	seed["bookings.json"] = map[string]any{
+		"b1": map[string]any{"id": "b1", "site_id": "s1", "subsite_id": "ss1", "zone_id": "z1", "status": bookingStatusRequested},
+		"b2": map[string]any{"id": "b2", "site_id": "s1", "subsite_id": "ss1", "zone_id": "z2", "status": bookingStatusCancelled},
+	}
+	d := newTestDeps(t, seed)
+
+	if res := call(t, byID(d, ZoneArchive, "z1"), adminP, "POST", "/", nil, anyIfMatch); res.Code != http.StatusConflict {
+		t.Fatalf("archive with active booking: %d", res.Code)
+	}
+	if res := call(t, byID(d, SubsiteArchive, "ss1"), adminP, "POST", "/", nil, anyIfMatch); res.Code != http.StatusConflict {
+		t.Fatalf("archive subsite with active booking: %d", res.Code)
+	}
+	// A cancelled booking does not block archiving...
+	if res := call(t, byID(d, ZoneArchive, "z2"), adminP, "POST", "/", nil, anyIfMatch); res.Code != http.StatusOK {
+		t.Fatalf("archive with cancelled booking: %d %s", res.Code, res.Raw)
+	}
+}
+
+func TestDeleteRefusedWhenReferenced(t *testing.T) {
+	seed := locationSeed()
+	seed["bookings.json"] = map[string]any{
+		"b1": map[string]any{"id": "b1", "site_id": "s1", "subsite_id": "ss1", "zone_id": "z1", "status": bookingStatusCancelled},
+	}
+	d := newTestDeps(t, seed)
+	del := func(f func(Stage8Deps, string, http.ResponseWriter, *http.Request), id string) testResponse {
+		return call(t, byID(d, f, id), adminP, "DELETE", "/", nil, nil)
+	}
+
+	// ...but any reference, even a cancelled one, blocks a hard delete.
+	for _, tc := range []struct {
+		name string
+		f    func(Stage8Deps, string, http.ResponseWriter, *http.Request)
+		id   string
+	}{
+		{"zone", ZonesWriteByID, "z1"},
+		{"subsite", SubsitesWriteByID, "ss1"},
+		{"site", SitesWriteByID, "s1"},
+	} {
+		if res := del(tc.f, tc.id); res.Code != http.StatusConflict {
+			t.Errorf("delete referenced %s: %d %s", tc.name, res.Code, res.Raw)
+		}
+	}
+	if res := del(ZonesWriteByID, "z2"); res.Code != http.StatusOK || res.data()["deleted"] != true {
+		t.Fatalf("delete unreferenced zone: %d %s", res.Code, res.Raw)
+	}
+	if d.record("zones.json", "z2") != nil {
+		t.Fatal("zone not deleted")
+	}
+}
+
+// BookingsCreate checks the zone under the bookings.json lock, so a zone
+// archived while the request waits for the lock is refused.
+func TestBookingCreateChecksZoneUnderLock(t *testing.T) {
+	d := newTestDeps(t, locationSeed())
+
+	lock := d.LockForFile("bookings.json")
+	lock.Lock()
+	done := make(chan testResponse)
+	go func() {
+		done <- call(t, handler(d, BookingsWriteCollection), salesP, "POST", "/", newBooking("z1"), nil)
+	}()
+	time.Sleep(20 * time.Millisecond) // let the request block on the lock
+
+	// Archive z1 the way setArchived does, while holding bookings.json.
+	jf := d.Loaded()["zones.json"]
+	z := d.record("zones.json", "z1")
+	z["archived_at"] = "2026-10-16T00:00:00Z"
+	jf.Items["z1"] = mustJSON(z)
+	if err := storage.WriteJSONFileAtomic(d.StorageDir(), "zones.json", jf); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.ReloadFiles("zones.json"); err != nil {
+		t.Fatal(err)
+	}
+	lock.Unlock()
+
+	if res := <-done; res.Code != http.StatusConflict {
+		t.Fatalf("booking on zone archived while waiting: %d %s", res.Code, res.Raw)
+	}
+	if len(d.Bookings()) != 0 {
+		t.Fatal("booking written")
+	}
+}
+
+func TestDeleteSiteRefusedWhileMapped(t *testing.T) {
+	seed := locationSeed()
+	delete(seed["subsites.json"], "ss2")
+	delete(seed["zones.json"], "z3")
+	seed["domains.json"] = map[string]any{
+		"d1": map[string]any{"id": "d1", "domain": "dua.example.com", "site_id": "s2", "status": "INACTIVE"},
+	}
+	d := newTestDeps(t, seed)
+
+	// Even an INACTIVE mapping keeps its site.
+	if res := call(t, byID(d, SitesWriteByID, "s2"), adminP, "DELETE", "/", nil, nil); res.Code != http.StatusConflict {
+		t.Fatalf("delete mapped site: %d %s", res.Code, res.Raw)
+	}
+	if d.record("sites.json", "s2") == nil {
+		t.Fatal("mapped site deleted")
+	}
+
+	if res := call(t, byID(d, DomainsWriteByID, "d1"), adminP, "DELETE", "/", nil, nil); res.Code != http.StatusOK {
+		t.Fatalf("delete mapping: %d %s", res.Code, res.Raw)
+	}
+	if res := call(t, byID(d, SitesWriteByID, "s2"), adminP, "DELETE", "/", nil, nil); res.Code != http.StatusOK || res.data()["deleted"] != true {
+		t.Fatalf("delete unmapped site: %d %s", res.Code, res.Raw)
+	}
+}
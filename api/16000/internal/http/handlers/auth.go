@@ -0,0 +1,124 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/itmtjewelry/land-booking-kpr/internal/auth"
+	"github.com/itmtjewelry/land-booking-kpr/internal/domain"
+)
+
+type loginPayload struct {
+	// Login is matched against users.json username or email (case-insensitive).
+	Login    string `json:"login"`
+	Password string `json:"password"`
+}
+
+// AuthLogin serves POST /api/v1/auth/login
+func AuthLogin(deps Stage8Deps, w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w)
+		return
+	}
+	if !deps.StorageReady() {
+		errJSON(w, http.StatusServiceUnavailable, "storage not ready")
+		return
+	}
+
+	var p loginPayload
+	if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+		errJSON(w, http.StatusBadRequest, "invalid json")
+		return
+	}
+	p.Login = strings.TrimSpace(p.Login)
+	if p.Login == "" || p.Password == "" {
+		errJSON(w, http.StatusBadRequest, "login and password are required")
+		return
+	}
+
+	user, found := findUserByLogin(deps, p.Login)
+	// Same response for unknown user and bad password.
+	if !found || !auth.VerifyPassword(user.PasswordHash, p.Password) {
+		errJSON(w, http.StatusUnauthorized, "invalid credentials")
+		return
+	}
+	if !user.Active() {
+		Forbidden(w, "user disabled")
+		return
+	}
+
+	sess, err := deps.Sessions().Create(user.ID)
+	if err != nil {
+		errJSON(w, http.StatusInternalServerError, "session create failed")
+		return
+	}
+
+	okData(w, map[string]any{
+		"token":      sess.Token,
+		"expires_at": sess.ExpiresAt.Format(time.RFC3339),
+		"user":       userPrincipal(user),
+	})
+}
+
+// AuthLogout serves POST /api/v1/auth/logout
+func AuthLogout(deps Stage8Deps, w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w)
+		return
+	}
+	token := auth.TokenFromRequest(r)
+	if token == "" {
+		errJSON(w, http.StatusBadRequest, "session token is required")
+		return
+	}
+	revoked := deps.Sessions().Revoke(token)
+	okData(w, map[string]any{"revoked": revoked})
+}
+
+// AuthMe serves GET /api/v1/auth/me (GUEST when not logged in).
+func AuthMe(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w)
+		return
+	}
+	okData(w, auth.PrincipalFrom(r.Context()))
+}
+
+// ResolvePrincipal maps a request to its principal.
+//
+// Order: session token -> legacy ADMIN_TOKEN -> GUEST.
+// The user record is re-read on every request so role changes and
+// disabled accounts take effect without waiting for the session to expire.
+func ResolvePrincipal(deps Stage8Deps, r *http.Request) auth.Principal {
+	if token := auth.TokenFromRequest(r); token != "" {
+		if sess, ok := deps.Sessions().Lookup(token); ok {
+			if u, ok := deps.Users()[sess.UserID]; ok && u.Active() {
+				return userPrincipal(u)
+			}
+		}
+	}
+	if auth.LegacyAdminToken(r) {
+		return auth.Principal{UserID: "admin-token", Username: "admin-token", Role: auth.RoleAdmin}
+	}
+	return auth.Guest()
+}
+
+func findUserByLogin(deps Stage8Deps, login string) (domain.User, bool) {
+	for _, u := range deps.Users() {
+		if strings.EqualFold(u.Username, login) || strings.EqualFold(u.Email, login) {
+			return u, true
+		}
+	}
+	return domain.User{}, false
+}
+
+func userPrincipal(u domain.User) auth.Principal {
+	return auth.Principal{
+		UserID:   u.ID,
+		Username: u.Username,
+		FullName: u.FullName,
+		Role:     auth.ParseRole(u.Role),
+	}
+}
@@ -0,0 +1,131 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/itmtjewelry/land-booking-kpr/internal/migrate"
+	"github.com/itmtjewelry/land-booking-kpr/internal/storage"
+)
+
+// Admin backup management (ADMIN only via the permission matrix).
+
+type backupRestorePayload struct {
+	File   string `json:"file"`
+	Backup string `json:"backup"`
+}
+
+// AdminBackupsList serves GET /api/v1/admin/backups?file=zones.json (newest first).
+func AdminBackupsList(deps Stage8Deps, w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w)
+		return
+	}
+	if !deps.StorageReady() {
+		errJSON(w, http.StatusServiceUnavailable, "storage not ready")
+		return
+	}
+	file := strings.TrimSpace(r.URL.Query().Get("file"))
+	if file == "" {
+		okData(w, map[string]any{"files": storage.BackupFiles()})
+		return
+	}
+
+	backups, err := storage.ListBackups(deps.StorageDir(), file)
+	if err != nil {
+		writeBackupErr(w, err)
+		return
+	}
+	okData(w, map[string]any{"file": file, "backups": backups})
+}
+
+// AdminBackupsRestore serves POST /api/v1/admin/backups/restore {file, backup}.
+func AdminBackupsRestore(deps Stage8Deps, w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w)
+		return
+	}
+	if !deps.StorageReady() {
+		errJSON(w, http.StatusServiceUnavailable, "storage not ready")
+		return
+	}
+
+	var p backupRestorePayload
+	if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+		errJSON(w, http.StatusBadRequest, "invalid json")
+		return
+	}
+	p.File = strings.TrimSpace(p.File)
+	p.Backup = strings.TrimSpace(p.Backup)
+	if p.File == "" || p.Backup == "" {
+		errJSON(w, http.StatusBadRequest, "file and backup are required")
+		return
+	}
+
+	// Checked before the name picks a lock: only managed files have one.
+	if !storage.IsBackupFile(p.File) {
+		errJSON(w, http.StatusBadRequest, storage.ErrUnknownStorageFile.Error()+": "+strconv.Quote(p.File))
+		return
+	}
+
+	mu := deps.LockForFile(p.File)
+	mu.Lock()
+	defer mu.Unlock()
+
+	if err := storage.RestoreBackup(deps.StorageDir(), p.File, p.Backup); err != nil {
+		writeBackupErr(w, err)
+		return
+	}
+	// The backup may predate a schema migration.
+	rep, err := migrate.File(deps.StorageDir(), p.File)
+	if err != nil {
+		errJSON(w, http.StatusInternalServerError, "restored but migration failed: "+err.Error())
+		return
+	}
+	if err := deps.ReloadFiles(p.File); err != nil {
+		errJSON(w, http.StatusInternalServerError, "reload failed")
+		return
+	}
+	okData(w, map[string]any{"file": p.File, "restored_from": p.Backup, "migrated": rep.Files})
+}
+
+// AdminBackupsPrune serves POST /api/v1/admin/backups/prune (configured retention policy).
+func AdminBackupsPrune(deps Stage8Deps, w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w)
+		return
+	}
+	if !deps.StorageReady() {
+		errJSON(w, http.StatusServiceUnavailable, "storage not ready")
+		return
+	}
+
+	policy := deps.BackupRetention()
+	removed, err := storage.PruneBackups(deps.StorageDir(), policy, time.Now())
+	if err != nil {
+		errJSON(w, http.StatusInternalServerError, "prune failed: "+err.Error())
+		return
+	}
+	okData(w, map[string]any{
+		"max_age_hours": int(policy.MaxAge.Hours()),
+		"keep_per_file": policy.KeepPerFile,
+		"removed":       removed,
+	})
+}
+
+func writeBackupErr(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, storage.ErrUnknownStorageFile):
+		errJSON(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, storage.ErrBackupNotFound):
+		errJSON(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, storage.ErrBackupInvalid):
+		errJSON(w, http.StatusUnprocessableEntity, err.Error())
+	default:
+		errJSON(w, http.StatusInternalServerError, "backup operation failed")
+	}
+}
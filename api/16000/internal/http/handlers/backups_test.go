@@ -0,0 +1,19 @@
+package handlers
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestBackupRestoreUnknownFile(t *testing.T) {
+	d := newTestDeps(t, nil)
+	for _, file := range []string{"nope.json", "../users.json", "zones.json.bak.1"} {
+		res := call(t, handler(d, AdminBackupsRestore), adminP, "POST", "/", map[string]any{"file": file, "backup": "x"}, nil)
+		if res.Code != http.StatusBadRequest {
+			t.Fatalf("restore %s: %d %s", file, res.Code, res.Raw)
+		}
+		if _, ok := d.locks[file]; ok {
+			t.Fatalf("restore %s took a lock for it", file)
+		}
+	}
+}
@@ -12,6 +12,7 @@ import (
 
 type bookingOut struct {
 	ID            string  `json:"id"`
+	Rev           int     `json:"rev"`
 	SiteID        string  `json:"site_id"`
 	SubsiteID     string  `json:"subsite_id"`
 	ZoneID        string  `json:"zone_id"`
@@ -43,23 +44,25 @@ func BookingsHandler(deps Stage8Deps) http.HandlerFunc {
 			errJSON(w, http.StatusBadRequest, "zone_id is required")
 			return
 		}
+		if !zoneInScope(deps, r, zoneID) {
+			errJSON(w, http.StatusNotFound, "zone not found")
+			return
+		}
 
 		admin := auth.IsAdmin(r)
 		items := deps.GetItems("bookings.json")
 
-		out := make([]bookingOut, 0, 32)
-		for _, v := range items {
-			m, ok := v.(map[string]any)
+		ids := deps.BookingsByZone(zoneID)
+		out := make([]bookingOut, 0, len(ids))
+		for _, id := range ids {
+			m, ok := items[id].(map[string]any)
 			if !ok {
 				continue
 			}
-			zid, _ := m["zone_id"].(string)
-			if zid != zoneID {
-				continue
-			}
 
 			b := bookingOut{
 				ID:           str(m["id"]),
+				Rev:          intFromAny(m["rev"]),
 				SiteID:       str(m["site_id"]),
 				SubsiteID:    str(m["subsite_id"]),
 				ZoneID:       str(m["zone_id"]),
@@ -81,11 +84,15 @@ func BookingsHandler(deps Stage8Deps) http.HandlerFunc {
 			out = append(out, b)
 		}
 
+		// Ties broken by id: the body hash is the list ETag.
 		sort.Slice(out, func(i, j int) bool {
-			return out[i].StartDate < out[j].StartDate
+			if out[i].StartDate != out[j].StartDate {
+				return out[i].StartDate < out[j].StartDate
+			}
+			return out[i].ID < out[j].ID
 		})
 
-		okData(w, out)
+		okDataCached(w, r, out)
 	}
 }
 
@@ -107,6 +114,10 @@ func AvailabilityHandler(deps Stage8Deps) http.HandlerFunc {
 			errJSON(w, http.StatusBadRequest, "zone_id, from, to are required")
 			return
 		}
+		if !zoneInScope(deps, r, zoneID) {
+			errJSON(w, http.StatusNotFound, "zone not found")
+			return
+		}
 
 		fromT, err := time.Parse("2006-01-02", fromS)
 		if err != nil {
@@ -133,18 +144,22 @@ func AvailabilityHandler(deps Stage8Deps) http.HandlerFunc {
 		}
 
 		blocks := make([]block, 0, 32)
+		requests := make([]block, 0, 8)
 		available := true
+		if z := getItemMap(deps.GetItems("zones.json"), zoneID); z != nil && zoneStatus(z) != zoneStatusAvailable {
+			available = false
+		}
+		if zoneArchived(deps, zoneID) {
+			available = false
+		}
 
-		for _, v := range items {
-			m, ok := v.(map[string]any)
+		for _, id := range deps.BookingsByZone(zoneID) {
+			m, ok := items[id].(map[string]any)
 			if !ok {
 				continue
 			}
-			if str(m["zone_id"]) != zoneID {
-				continue
-			}
 			status := str(m["status"])
-			if status == "cancelled" {
+			if status == bookingStatusCancelled || status == bookingStatusRejected {
 				continue
 			}
 
@@ -157,6 +172,16 @@ func AvailabilityHandler(deps Stage8Deps) http.HandlerFunc {
 			}
 
 			if rangesOverlap(fromT, toT, sT, eT) {
+				// Competing requests are listed but do not block until approved.
+				if status != bookingStatusApproved {
+					requests = append(requests, block{
+						BookingID: str(m["id"]),
+						Status:    status,
+						StartDate: bs,
+						EndDate:   be,
+					})
+					continue
+				}
 				available = false
 				blocks = append(blocks, block{
 					BookingID: str(m["id"]),
@@ -167,16 +192,24 @@ func AvailabilityHandler(deps Stage8Deps) http.HandlerFunc {
 			}
 		}
 
-		sort.Slice(blocks, func(i, j int) bool {
-			return blocks[i].StartDate < blocks[j].StartDate
-		})
-
-		okData(w, map[string]any{
-			"zone_id":   zoneID,
-			"from":      fromS,
-			"to":        toS,
-			"available": available,
-			"blocked":   blocks,
+		byStart := func(bs []block) func(i, j int) bool {
+			return func(i, j int) bool {
+				if bs[i].StartDate != bs[j].StartDate {
+					return bs[i].StartDate < bs[j].StartDate
+				}
+				return bs[i].BookingID < bs[j].BookingID
+			}
+		}
+		sort.Slice(blocks, byStart(blocks))
+		sort.Slice(requests, byStart(requests))
+
+		okDataCached(w, r, map[string]any{
+			"zone_id":          zoneID,
+			"from":             fromS,
+			"to":               toS,
+			"available":        available,
+			"blocked":          blocks,
+			"pending_requests": requests,
 		})
 	}
 }
@@ -0,0 +1,317 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/itmtjewelry/land-booking-kpr/internal/auth"
+	"github.com/itmtjewelry/land-booking-kpr/internal/storage"
+)
+
+// Booking → zone coupling (blueprint section 8):
+//   - APPROVED: zone AVAILABLE → BOOKED, booked_by_user_id set, competing requests auto-rejected
+//   - CANCELLED (if approved): zone BOOKED → AVAILABLE, booked_by_user_id cleared
+//
+// bookings.json and zones.json are locked together (bookings first) and committed in one storage.Tx.
+
+type bookingActionPayload struct {
+	Reason string `json:"reason"`
+}
+
+func decodeBookingAction(w http.ResponseWriter, r *http.Request) (bookingActionPayload, bool) {
+	var p bookingActionPayload
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+			errJSON(w, http.StatusBadRequest, "invalid json")
+			return p, false
+		}
+	}
+	p.Reason = strings.TrimSpace(p.Reason)
+	return p, true
+}
+
+// BookingApproveByID serves POST /api/v1/bookings/{id}/approve
+func BookingApproveByID(deps Stage8Deps, id string, w http.ResponseWriter, r *http.Request) {
+	if !deps.StorageReady() {
+		errJSON(w, http.StatusServiceUnavailable, "storage not ready")
+		return
+	}
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w)
+		return
+	}
+	if _, ok := decodeBookingAction(w, r); !ok {
+		return
+	}
+
+	lockBook := deps.LockForFile("bookings.json")
+	lockZone := deps.LockForFile("zones.json")
+	lockBook.Lock()
+	defer lockBook.Unlock()
+	lockZone.Lock()
+	defer lockZone.Unlock()
+
+	bookJF := mustLoadJSONFile(deps, "bookings.json")
+	zoneJF := mustLoadJSONFile(deps, "zones.json")
+
+	b, ok := decodeItem(bookJF, id)
+	if !ok {
+		errJSON(w, http.StatusNotFound, "booking not found")
+		return
+	}
+	if !requireIfMatch(w, r, bookJF.Items[id]) {
+		return
+	}
+	if st := str(b["status"]); !validStatusTransition(st, bookingStatusApproved) || st == bookingStatusApproved {
+		errJSON(w, http.StatusConflict, "only "+bookingStatusRequested+" bookings can be approved")
+		return
+	}
+
+	zoneID := str(b["zone_id"])
+	zone, ok := decodeItem(zoneJF, zoneID)
+	if !ok {
+		errJSON(w, http.StatusConflict, "zone not found")
+		return
+	}
+	if zs := zoneStatus(zone); !validZoneTransition(zs, zoneStatusBooked) {
+		errJSON(w, http.StatusConflict, "zone is "+zs+"; cannot book")
+		return
+	}
+
+	sT, eT, err := parseRange(str(b["start_date"]), str(b["end_date"]))
+	if err != nil {
+		errJSON(w, http.StatusConflict, "stored booking has invalid dates")
+		return
+	}
+	if hasBookingOverlap(deps, id, zoneID, sT, eT) {
+		errJSON(w, http.StatusConflict, "date range overlaps approved booking")
+		return
+	}
+
+	principal := auth.PrincipalFrom(r.Context())
+	now := time.Now().UTC()
+	ts := now.Format(time.RFC3339)
+
+	b["status"] = bookingStatusApproved
+	b["approved_at"] = ts
+	b["approved_by_user_id"] = principal.UserID
+	b["updated_at"] = ts
+	bookJF.Items[id] = mustJSON(b)
+
+	bookedBy := str(b["requested_by_user_id"])
+	if bookedBy == "" {
+		bookedBy = principal.UserID
+	}
+	applyZoneStatus(zone, zoneStatusBooked, principal.UserID, "booking "+id+" approved", now)
+	zone["booked_by_user_id"] = bookedBy
+	zone["booked_booking_id"] = id
+	zoneJF.Items[zoneID] = mustJSON(zone)
+
+	// Auto-reject the other requests competing for the same zone.
+	rejected := make([]string, 0)
+	for _, otherID := range deps.BookingsByZone(zoneID) {
+		if otherID == id {
+			continue
+		}
+		o, ok := decodeItem(bookJF, otherID)
+		if !ok || str(o["zone_id"]) != zoneID || str(o["status"]) != bookingStatusRequested {
+			continue
+		}
+		o["status"] = bookingStatusRejected
+		o["rejected_at"] = ts
+		o["rejected_by_user_id"] = principal.UserID
+		o["rejection_reason"] = "auto-rejected: zone booked by booking " + id
+		o["updated_at"] = ts
+		bookJF.Items[otherID] = mustJSON(o)
+		rejected = append(rejected, otherID)
+	}
+
+	tx := storage.BeginTx(deps.StorageDir())
+	tx.Stage("bookings.json", bookJF)
+	tx.Stage("zones.json", zoneJF)
+	if !commitTx(deps, w, tx, "write failed") {
+		return
+	}
+	if err := deps.ReloadFiles("bookings.json", "zones.json"); err != nil {
+		errJSON(w, http.StatusInternalServerError, "reload failed")
+		return
+	}
+
+	setRecordETag(w, deps, "bookings.json", id)
+	okData(w, map[string]any{
+		"id":            id,
+		"status":        bookingStatusApproved,
+		"zone_id":       zoneID,
+		"zone_status":   zoneStatusBooked,
+		"auto_rejected": rejected,
+	})
+}
+
+// BookingRejectByID serves POST /api/v1/bookings/{id}/reject ({reason} optional).
+// Rejecting a request never changes the zone.
+func BookingRejectByID(deps Stage8Deps, id string, w http.ResponseWriter, r *http.Request) {
+	if !deps.StorageReady() {
+		errJSON(w, http.StatusServiceUnavailable, "storage not ready")
+		return
+	}
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w)
+		return
+	}
+	p, ok := decodeBookingAction(w, r)
+	if !ok {
+		return
+	}
+
+	lockBook := deps.LockForFile("bookings.json")
+	lockBook.Lock()
+	defer lockBook.Unlock()
+
+	bookJF := mustLoadJSONFile(deps, "bookings.json")
+	b, ok := decodeItem(bookJF, id)
+	if !ok {
+		errJSON(w, http.StatusNotFound, "booking not found")
+		return
+	}
+	if !requireIfMatch(w, r, bookJF.Items[id]) {
+		return
+	}
+	if str(b["status"]) != bookingStatusRequested {
+		errJSON(w, http.StatusConflict, "only "+bookingStatusRequested+" bookings can be rejected")
+		return
+	}
+
+	ts := time.Now().UTC().Format(time.RFC3339)
+	b["status"] = bookingStatusRejected
+	b["rejected_at"] = ts
+	b["rejected_by_user_id"] = auth.PrincipalFrom(r.Context()).UserID
+	b["rejection_reason"] = p.Reason
+	b["updated_at"] = ts
+	bookJF.Items[id] = mustJSON(b)
+
+	if err := storage.WriteJSONFileAtomic(deps.StorageDir(), "bookings.json", bookJF); err != nil {
+		writeFailed(w, err, "write failed")
+		return
+	}
+	if err := deps.ReloadFiles("bookings.json"); err != nil {
+		errJSON(w, http.StatusInternalServerError, "reload failed")
+		return
+	}
+	setRecordETag(w, deps, "bookings.json", id)
+	okData(w, map[string]any{"id": id, "status": bookingStatusRejected})
+}
+
+// BookingCancelByID serves POST /api/v1/bookings/{id}/cancel.
+// Allowed for the requester or ADMIN; cancelling an approved booking releases the zone.
+func BookingCancelByID(deps Stage8Deps, id string, w http.ResponseWriter, r *http.Request) {
+	if !deps.StorageReady() {
+		errJSON(w, http.StatusServiceUnavailable, "storage not ready")
+		return
+	}
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w)
+		return
+	}
+	p, ok := decodeBookingAction(w, r)
+	if !ok {
+		return
+	}
+
+	lockBook := deps.LockForFile("bookings.json")
+	lockZone := deps.LockForFile("zones.json")
+	lockBook.Lock()
+	defer lockBook.Unlock()
+	lockZone.Lock()
+	defer lockZone.Unlock()
+
+	bookJF := mustLoadJSONFile(deps, "bookings.json")
+	zoneJF := mustLoadJSONFile(deps, "zones.json")
+
+	b, ok := decodeItem(bookJF, id)
+	if !ok {
+		errJSON(w, http.StatusNotFound, "booking not found")
+		return
+	}
+	if !requireIfMatch(w, r, bookJF.Items[id]) {
+		return
+	}
+
+	// Blueprint: cancel is allowed for the requester or ADMIN.
+	principal := auth.PrincipalFrom(r.Context())
+	if !auth.IsAdmin(r) && str(b["requested_by_user_id"]) != principal.UserID {
+		Forbidden(w, "only the requester or ADMIN may cancel this booking")
+		return
+	}
+
+	status := str(b["status"])
+	if status == bookingStatusCancelled {
+		setRecordETag(w, deps, "bookings.json", id)
+		okData(w, map[string]any{"id": id, "status": bookingStatusCancelled})
+		return
+	}
+	if !validStatusTransition(status, bookingStatusCancelled) {
+		errJSON(w, http.StatusConflict, "cannot cancel "+status+" booking")
+		return
+	}
+
+	now := time.Now().UTC()
+	ts := now.Format(time.RFC3339)
+
+	zoneReleased := false
+	if status == bookingStatusApproved {
+		zoneID := str(b["zone_id"])
+		if zone, ok := decodeItem(zoneJF, zoneID); ok && str(zone["booked_booking_id"]) == id {
+			zs := zoneStatus(zone)
+			if zs == zoneStatusSold {
+				errJSON(w, http.StatusConflict, "zone already sold; cannot cancel approved booking")
+				return
+			}
+			if zs == zoneStatusBooked {
+				applyZoneStatus(zone, zoneStatusAvailable, principal.UserID, "booking "+id+" cancelled", now)
+				delete(zone, "booked_by_user_id")
+				delete(zone, "booked_booking_id")
+				zoneJF.Items[zoneID] = mustJSON(zone)
+				zoneReleased = true
+			}
+		}
+	}
+
+	b["status"] = bookingStatusCancelled
+	b["cancelled_at"] = ts
+	b["cancelled_by_user_id"] = principal.UserID
+	if p.Reason != "" {
+		b["cancel_reason"] = p.Reason
+	}
+	b["updated_at"] = ts
+	bookJF.Items[id] = mustJSON(b)
+
+	tx := storage.BeginTx(deps.StorageDir())
+	tx.Stage("bookings.json", bookJF)
+	if zoneReleased {
+		tx.Stage("zones.json", zoneJF)
+	}
+	if !commitTx(deps, w, tx, "write failed") {
+		return
+	}
+	if err := deps.ReloadFiles(tx.Files()...); err != nil {
+		errJSON(w, http.StatusInternalServerError, "reload failed")
+		return
+	}
+	setRecordETag(w, deps, "bookings.json", id)
+	okData(w, map[string]any{"id": id, "status": bookingStatusCancelled, "zone_released": zoneReleased})
+}
+
+// decodeItem unmarshals one record from a JSONFile into a fresh map.
+func decodeItem(jf storage.JSONFile, id string) (map[string]any, bool) {
+	raw, ok := jf.Items[id]
+	if !ok {
+		return nil, false
+	}
+	var m map[string]any
+	if err := json.Unmarshal(raw, &m); err != nil || m == nil {
+		return nil, false
+	}
+	return m, true
+}
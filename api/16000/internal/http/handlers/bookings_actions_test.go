@@ -0,0 +1,182 @@
+package handlers
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/itmtjewelry/land-booking-kpr/internal/auth"
+)
+
+// actionSeed has two requests competing for z1 (b1 by salesP, b2 by otherP)
+// and one for z2 (b3).
+func actionSeed() map[string]map[string]any {
+	seed := locationSeed()
+	booking := func(id, zoneID, status, by string) map[string]any {
+		return map[string]any{
+			"id": id, "site_id": "s1", "subsite_id": "ss1", "zone_id": zoneID, "status": status,
+			"requested_by_user_id": by, "start_date": "2026-11-01", "end_date": "2026-11-02",
+		}
+	}
+	seed["bookings.json"] = map[string]any{
+		"b1": booking("b1", "z1", bookingStatusRequested, salesP.UserID),
+		"b2": booking("b2", "z1", bookingStatusRequested, otherP.UserID),
+		"b3": booking("b3", "z2", bookingStatusRequested, otherP.UserID),
+	}
+	return seed
+}
+
+func bookingAction(t *testing.T, d *testDeps, f func(Stage8Deps, string, http.ResponseWriter, *http.Request), id string, p auth.Principal, body any) testResponse {
+	t.Helper()
+	return call(t, byID(d, f, id), p, "POST", "/", body, anyIfMatch)
+}
+
+func TestBookingApprove(t *testing.T) {
+	d := newTestDeps(t, actionSeed())
+
+	if res := call(t, byID(d, BookingApproveByID, "b1"), managerP, "POST", "/", nil, nil); res.Code != http.StatusPreconditionRequired {
+		t.Fatalf("approve without If-Match: %d", res.Code)
+	}
+	res := bookingAction(t, d, BookingApproveByID, "b1", managerP, nil)
+	if res.Code != http.StatusOK {
+		t.Fatalf("approve: %d %s", res.Code, res.Raw)
+	}
+	if got, _ := res.data()["auto_rejected"].([]any); len(got) != 1 || got[0] != "b2" {
+		t.Fatalf("auto_rejected = %v", res.data()["auto_rejected"])
+	}
+
+	b1 := d.record("bookings.json", "b1")
+	if b1["status"] != bookingStatusApproved || b1["approved_by_user_id"] != managerP.UserID {
+		t.Fatalf("b1 not approved: %v", b1)
+	}
+	z := d.record("zones.json", "z1")
+	if z["status"] != zoneStatusBooked || z["booked_by_user_id"] != salesP.UserID || z["booked_booking_id"] != "b1" {
+		t.Fatalf("zone not booked for the requester: %v", z)
+	}
+
+	// The competing request on z1 is rejected; the one on z2 is untouched.
+	if b2 := d.record("bookings.json", "b2"); b2["status"] != bookingStatusRejected || str(b2["rejection_reason"]) == "" {
+		t.Fatalf("competing request not auto-rejected: %v", b2)
+	}
+	if b3 := d.record("bookings.json", "b3"); b3["status"] != bookingStatusRequested {
+		t.Fatalf("request on another zone changed: %v", b3)
+	}
+	if z2 := d.record("zones.json", "z2"); z2["status"] != zoneStatusAvailable {
+		t.Fatalf("other zone changed: %v", z2)
+	}
+}
+
+func TestBookingApproveZoneNotAvailable(t *testing.T) {
+	d := newTestDeps(t, actionSeed())
+	if res := call(t, byID(d, ZoneMarkUnavailable, "z2"), adminP, "POST", "/", map[string]any{"reason": "flooded"}, anyIfMatch); res.Code != http.StatusOK {
+		t.Fatalf("mark unavailable: %d %s", res.Code, res.Raw)
+	}
+	if res := bookingAction(t, d, BookingApproveByID, "b3", managerP, nil); res.Code != http.StatusConflict {
+		t.Fatalf("approve on NOT_AVAILABLE zone: %d", res.Code)
+	}
+	if b3 := d.record("bookings.json", "b3"); b3["status"] != bookingStatusRequested {
+		t.Fatalf("booking changed: %v", b3)
+	}
+}
+
+func TestBookingReject(t *testing.T) {
+	d := newTestDeps(t, actionSeed())
+
+	res := bookingAction(t, d, BookingRejectByID, "b1", managerP, map[string]any{"reason": " no documents "})
+	if res.Code != http.StatusOK {
+		t.Fatalf("reject: %d %s", res.Code, res.Raw)
+	}
+	b1 := d.record("bookings.json", "b1")
+	if b1["status"] != bookingStatusRejected || b1["rejection_reason"] != "no documents" || b1["rejected_by_user_id"] != managerP.UserID {
+		t.Fatalf("b1 not rejected: %v", b1)
+	}
+	if z := d.record("zones.json", "z1"); z["status"] != zoneStatusAvailable || z["status_history"] != nil {
+		t.Fatalf("reject touched the zone: %v", z)
+	}
+}
+
+func TestBookingCancel(t *testing.T) {
+	d := newTestDeps(t, actionSeed())
+	if res := bookingAction(t, d, BookingApproveByID, "b1", managerP, nil); res.Code != http.StatusOK {
+		t.Fatalf("approve: %d %s", res.Code, res.Raw)
+	}
+
+	// Only the requester or ADMIN may cancel; staff rank alone is not enough.
+	for _, p := range []auth.Principal{otherP, managerP} {
+		if res := bookingAction(t, d, BookingCancelByID, "b1", p, nil); res.Code != http.StatusForbidden || res.errCode() != "forbidden" {
+			t.Fatalf("cancel by %s: %d %s", p.UserID, res.Code, res.Raw)
+		}
+	}
+	if b1 := d.record("bookings.json", "b1"); b1["status"] != bookingStatusApproved {
+		t.Fatalf("forbidden cancel changed the booking: %v", b1)
+	}
+
+	res := bookingAction(t, d, BookingCancelByID, "b1", salesP, map[string]any{"reason": "changed my mind"})
+	if res.Code != http.StatusOK || res.data()["zone_released"] != true {
+		t.Fatalf("requester cancel: %d %s", res.Code, res.Raw)
+	}
+	if b1 := d.record("bookings.json", "b1"); b1["status"] != bookingStatusCancelled || b1["cancel_reason"] != "changed my mind" {
+		t.Fatalf("b1 not cancelled: %v", b1)
+	}
+	z := d.record("zones.json", "z1")
+	if z["status"] != zoneStatusAvailable || z["booked_by_user_id"] != nil || z["booked_booking_id"] != nil {
+		t.Fatalf("zone not released: %v", z)
+	}
+	if hist, _ := z["status_history"].([]any); len(hist) != 2 {
+		t.Fatalf("status_history = %v", z["status_history"])
+	}
+
+	// Cancelling again is a no-op.
+	if res := bookingAction(t, d, BookingCancelByID, "b1", salesP, nil); res.Code != http.StatusOK {
+		t.Fatalf("cancel twice: %d", res.Code)
+	}
+	// ADMIN may cancel anyone's request; a REQUESTED booking releases nothing.
+	if res := bookingAction(t, d, BookingCancelByID, "b3", adminP, nil); res.Code != http.StatusOK || res.data()["zone_released"] != false {
+		t.Fatalf("admin cancel: %d %s", res.Code, res.Raw)
+	}
+}
+
+func TestBookingCancelSoldZone(t *testing.T) {
+	d := newTestDeps(t, actionSeed())
+	if res := bookingAction(t, d, BookingApproveByID, "b1", managerP, nil); res.Code != http.StatusOK {
+		t.Fatalf("approve: %d %s", res.Code, res.Raw)
+	}
+	if res := call(t, byID(d, ZoneMarkSold, "z1"), adminP, "POST", "/", nil, anyIfMatch); res.Code != http.StatusOK {
+		t.Fatalf("mark sold: %d %s", res.Code, res.Raw)
+	}
+	if res := bookingAction(t, d, BookingCancelByID, "b1", salesP, nil); res.Code != http.StatusConflict {
+		t.Fatalf("cancel on sold zone: %d", res.Code)
+	}
+	if z := d.record("zones.json", "z1"); z["status"] != zoneStatusSold || z["booked_by_user_id"] != salesP.UserID {
+		t.Fatalf("sold zone changed: %v", z)
+	}
+}
+
+func TestBookingForbiddenTransitions(t *testing.T) {
+	approve, reject, cancel := BookingApproveByID, BookingRejectByID, BookingCancelByID
+	for _, tc := range []struct {
+		name   string
+		status string
+		action func(Stage8Deps, string, http.ResponseWriter, *http.Request)
+	}{
+		{"approve APPROVED", bookingStatusApproved, approve},
+		{"approve REJECTED", bookingStatusRejected, approve},
+		{"approve CANCELLED", bookingStatusCancelled, approve},
+		{"reject APPROVED", bookingStatusApproved, reject},
+		{"reject REJECTED", bookingStatusRejected, reject},
+		{"reject CANCELLED", bookingStatusCancelled, reject},
+		{"cancel REJECTED", bookingStatusRejected, cancel},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			seed := actionSeed()
+			seed["bookings.json"]["b1"].(map[string]any)["status"] = tc.status
+			d := newTestDeps(t, seed)
+
+			if res := bookingAction(t, d, tc.action, "b1", adminP, nil); res.Code != http.StatusConflict {
+				t.Fatalf("got %d %s", res.Code, res.Raw)
+			}
+			if b1 := d.record("bookings.json", "b1"); b1["status"] != tc.status {
+				t.Fatalf("status changed to %v", b1["status"])
+			}
+		})
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"github.com/itmtjewelry/land-booking-kpr/internal/auth"
+	"github.com/itmtjewelry/land-booking-kpr/internal/ids"
 	"github.com/itmtjewelry/land-booking-kpr/internal/storage"
 )
 
@@ -30,9 +31,6 @@ func BookingsWriteCollection(deps Stage8Deps, w http.ResponseWriter, r *http.Req
 		errJSON(w, http.StatusServiceUnavailable, "storage not ready")
 		return
 	}
-	if !auth.RequireAdmin(w, r) {
-		return
-	}
 	if r.Method != http.MethodPost {
 		methodNotAllowed(w)
 		return
@@ -76,84 +74,109 @@ func BookingsWriteCollection(deps Stage8Deps, w http.ResponseWriter, r *http.Req
 		return
 	}
 
-	if p.Status == "" {
-		p.Status = "pending"
+	// New bookings always start as requests; approval goes through /approve
+	// so the zone is updated in the same unit.
+	// "pending" is the v1 name and still accepted from older clients.
+	p.Status = strings.ToUpper(p.Status)
+	if p.Status == "" || p.Status == "PENDING" {
+		p.Status = bookingStatusRequested
 	}
-	if p.Status != "pending" && p.Status != "confirmed" && p.Status != "cancelled" {
-		errJSON(w, http.StatusBadRequest, "invalid status")
+	if p.Status != bookingStatusRequested {
+		errJSON(w, http.StatusBadRequest, "new bookings must be "+bookingStatusRequested+"; use /approve")
 		return
 	}
 
-	// Validate chain: zone exists and matches subsite + site
-	if err := validateZoneChain(deps, p.SiteID, p.SubsiteID, p.ZoneID); err != nil {
-		errJSON(w, http.StatusBadRequest, err.Error())
-		return
-	}
+	principal := auth.PrincipalFrom(r.Context())
 
 	if strings.TrimSpace(p.ID) == "" {
-		p.ID = genID("booking")
+		p.ID = ids.New("booking")
 	}
 
+	// The zone is checked under the bookings.json lock: archiving takes it
+	// first, so the zone cannot be archived between the check and the write.
 	filename := "bookings.json"
 	mu := deps.LockForFile(filename)
 	mu.Lock()
 	defer mu.Unlock()
 
-	loaded := deps.Loaded()
-	jf, ok := loaded[filename]
-	if !ok {
-		jf = storage.JSONFile{
-			Meta:  map[string]any{"version": 1, "updated_at": nil},
-			Items: map[string]json.RawMessage{},
-		}
+	// Validate chain: zone exists and matches subsite + site
+	if err := validateZoneChain(deps, p.SiteID, p.SubsiteID, p.ZoneID); err != nil {
+		errJSON(w, http.StatusBadRequest, err.Error())
+		return
 	}
-	if jf.Meta == nil {
-		jf.Meta = map[string]any{"version": 1, "updated_at": nil}
+	// Requests are accepted only while the zone is AVAILABLE.
+	if z := getItemMap(deps.GetItems("zones.json"), p.ZoneID); z != nil && zoneStatus(z) != zoneStatusAvailable {
+		errJSON(w, http.StatusConflict, "zone is not available")
+		return
 	}
-	if jf.Items == nil {
-		jf.Items = make(map[string]json.RawMessage)
+	if zoneArchived(deps, p.ZoneID) {
+		errJSON(w, http.StatusConflict, "zone is archived")
+		return
 	}
 
+	jf := mustLoadJSONFile(deps, filename)
+
 	if _, exists := jf.Items[p.ID]; exists {
 		errJSON(w, http.StatusConflict, "id already exists")
 		return
 	}
 
-	// Strict overlap check for pending + confirmed
+	// Multiple requests may compete for a zone; only approved bookings block.
 	if conflict := hasBookingOverlap(deps, "", p.ZoneID, sT, eT); conflict {
 		errJSON(w, http.StatusConflict, "date range overlaps existing booking")
 		return
 	}
 
 	obj := map[string]any{
-		"id":             p.ID,
-		"site_id":        p.SiteID,
-		"subsite_id":     p.SubsiteID,
-		"zone_id":        p.ZoneID,
-		"customer_name":  p.CustomerName,
-		"customer_phone": p.CustomerPhone,
-		"customer_email": p.CustomerEmail,
-		"status":         p.Status,
-		"start_date":     p.StartDate,
-		"end_date":       p.EndDate,
-		"price":          p.Price,
-		"notes":          p.Notes,
-		"created_at":     now,
-		"updated_at":     now,
+		"id":                   p.ID,
+		"site_id":              p.SiteID,
+		"subsite_id":           p.SubsiteID,
+		"zone_id":              p.ZoneID,
+		"customer_name":        p.CustomerName,
+		"customer_phone":       p.CustomerPhone,
+		"customer_email":       p.CustomerEmail,
+		"status":               p.Status,
+		"start_date":           p.StartDate,
+		"end_date":             p.EndDate,
+		"price":                p.Price,
+		"notes":                p.Notes,
+		"requested_by_user_id": principal.UserID,
+		"created_at":           now,
+		"updated_at":           now,
+	}
+
+	// The booking number is written in the same transaction as the booking,
+	// so a failed write does not consume it.
+	lockSeq := deps.LockForFile(storage.SequencesFile)
+	lockSeq.Lock()
+	defer lockSeq.Unlock()
+	seq, err := storage.LoadSequences(deps.StorageDir())
+	if err != nil {
+		errJSON(w, http.StatusInternalServerError, "load sequences failed")
+		return
 	}
+	number, err := ids.NextDocNumber(&seq, ids.DocBooking, p.SiteID, time.Now().UTC())
+	if err != nil {
+		errJSON(w, http.StatusInternalServerError, "booking counter unreadable")
+		return
+	}
+	obj["number"] = number
 
 	jf.Items[p.ID] = mustJSON(obj)
 
-	if err := storage.WriteJSONFileAtomic(deps.StorageDir(), filename, jf); err != nil {
-		errJSON(w, http.StatusInternalServerError, "write failed")
+	tx := storage.BeginTx(deps.StorageDir())
+	tx.Stage(filename, jf)
+	tx.Stage(storage.SequencesFile, seq)
+	if !commitTx(deps, w, tx, "write failed") {
 		return
 	}
-	if err := deps.ReloadCore(); err != nil {
+	if err := deps.ReloadFiles(filename); err != nil {
 		errJSON(w, http.StatusInternalServerError, "reload failed")
 		return
 	}
 
-	okData(w, map[string]any{"id": p.ID})
+	setRecordETag(w, deps, filename, p.ID)
+	okData(w, map[string]any{"id": p.ID, "number": number})
 }
 
 func BookingsWriteByID(deps Stage8Deps, id string, w http.ResponseWriter, r *http.Request) {
@@ -161,9 +184,6 @@ func BookingsWriteByID(deps Stage8Deps, id string, w http.ResponseWriter, r *htt
 		errJSON(w, http.StatusServiceUnavailable, "storage not ready")
 		return
 	}
-	if !auth.RequireAdmin(w, r) {
-		return
-	}
 
 	id = strings.TrimSpace(id)
 	if id == "" {
@@ -182,80 +202,19 @@ func BookingsWriteByID(deps Stage8Deps, id string, w http.ResponseWriter, r *htt
 	}
 }
 
-func BookingCancelByID(deps Stage8Deps, id string, w http.ResponseWriter, r *http.Request) {
-	if !deps.StorageReady() {
-		errJSON(w, http.StatusServiceUnavailable, "storage not ready")
-		return
-	}
-	if !auth.RequireAdmin(w, r) {
-		return
-	}
-	if r.Method != http.MethodPost {
-		methodNotAllowed(w)
-		return
-	}
-
+func bookingsPut(deps Stage8Deps, id string, w http.ResponseWriter, r *http.Request) {
 	filename := "bookings.json"
 	mu := deps.LockForFile(filename)
 	mu.Lock()
 	defer mu.Unlock()
 
-	loaded := deps.Loaded()
-	jf, ok := loaded[filename]
-	if !ok || jf.Items == nil {
-		errJSON(w, http.StatusBadRequest, "booking not found")
-		return
-	}
+	jf := mustLoadJSONFile(deps, filename)
 	raw, exists := jf.Items[id]
 	if !exists {
 		errJSON(w, http.StatusBadRequest, "booking not found")
 		return
 	}
-
-	var m map[string]any
-	if err := json.Unmarshal(raw, &m); err != nil {
-		errJSON(w, http.StatusInternalServerError, "invalid stored booking")
-		return
-	}
-
-	status := str(m["status"])
-	if status == "cancelled" {
-		okData(w, map[string]any{"id": id, "status": "cancelled"})
-		return
-	}
-
-	m["status"] = "cancelled"
-	m["updated_at"] = time.Now().UTC().Format(time.RFC3339)
-
-	jf.Items[id] = mustJSON(m)
-
-	if err := storage.WriteJSONFileAtomic(deps.StorageDir(), filename, jf); err != nil {
-		errJSON(w, http.StatusInternalServerError, "write failed")
-		return
-	}
-	if err := deps.ReloadCore(); err != nil {
-		errJSON(w, http.StatusInternalServerError, "reload failed")
-		return
-	}
-
-	okData(w, map[string]any{"id": id, "status": "cancelled"})
-}
-
-func bookingsPut(deps Stage8Deps, id string, w http.ResponseWriter, r *http.Request) {
-	filename := "bookings.json"
-	mu := deps.LockForFile(filename)
-	mu.Lock()
-	defer mu.Unlock()
-
-	loaded := deps.Loaded()
-	jf, ok := loaded[filename]
-	if !ok || jf.Items == nil {
-		errJSON(w, http.StatusBadRequest, "booking not found")
-		return
-	}
-	raw, exists := jf.Items[id]
-	if !exists {
-		errJSON(w, http.StatusBadRequest, "booking not found")
+	if !requireIfMatch(w, r, raw) {
 		return
 	}
 
@@ -266,8 +225,8 @@ func bookingsPut(deps Stage8Deps, id string, w http.ResponseWriter, r *http.Requ
 	}
 
 	curStatus := str(cur["status"])
-	if curStatus == "cancelled" {
-		errJSON(w, http.StatusConflict, "cannot update cancelled booking")
+	if curStatus == bookingStatusCancelled || curStatus == bookingStatusRejected {
+		errJSON(w, http.StatusConflict, "cannot update "+curStatus+" booking")
 		return
 	}
 
@@ -313,26 +272,27 @@ func bookingsPut(deps Stage8Deps, id string, w http.ResponseWriter, r *http.Requ
 		return
 	}
 
+	// Status changes are coupled to the zone and go through /approve, /reject, /cancel.
 	newStatus := strings.TrimSpace(p.Status)
-	if newStatus == "" {
-		newStatus = curStatus
+	if newStatus != "" && newStatus != curStatus {
+		errJSON(w, http.StatusConflict, "use /approve, /reject or /cancel to change booking status")
+		return
 	}
-	if newStatus != "pending" && newStatus != "confirmed" && newStatus != "cancelled" {
-		errJSON(w, http.StatusBadRequest, "invalid status")
+	newStatus = curStatus
+
+	// An approved booking holds its zone; moving it would desync zones.json.
+	if curStatus == bookingStatusApproved && zoneID != str(cur["zone_id"]) {
+		errJSON(w, http.StatusConflict, "cannot move an approved booking to another zone")
 		return
 	}
-	// status flow A: pending -> confirmed -> cancelled
-	if !validStatusTransition(curStatus, newStatus) {
-		errJSON(w, http.StatusConflict, "invalid status transition")
+	if zoneID != str(cur["zone_id"]) && zoneArchived(deps, zoneID) {
+		errJSON(w, http.StatusConflict, "zone is archived")
 		return
 	}
 
-	// Overlap check if still active (pending/confirmed)
-	if newStatus != "cancelled" {
-		if conflict := hasBookingOverlap(deps, id, zoneID, sT, eT); conflict {
-			errJSON(w, http.StatusConflict, "date range overlaps existing booking")
-			return
-		}
+	if conflict := hasBookingOverlap(deps, id, zoneID, sT, eT); conflict {
+		errJSON(w, http.StatusConflict, "date range overlaps existing booking")
+		return
 	}
 
 	// Apply updates
@@ -363,14 +323,15 @@ func bookingsPut(deps Stage8Deps, id string, w http.ResponseWriter, r *http.Requ
 	jf.Items[id] = mustJSON(cur)
 
 	if err := storage.WriteJSONFileAtomic(deps.StorageDir(), filename, jf); err != nil {
-		errJSON(w, http.StatusInternalServerError, "write failed")
+		writeFailed(w, err, "write failed")
 		return
 	}
-	if err := deps.ReloadCore(); err != nil {
+	if err := deps.ReloadFiles(filename); err != nil {
 		errJSON(w, http.StatusInternalServerError, "reload failed")
 		return
 	}
 
+	setRecordETag(w, deps, filename, id)
 	okData(w, map[string]any{"id": id})
 }
 
@@ -395,17 +356,24 @@ type errBad string
 
 func (e errBad) Error() string { return string(e) }
 
+// Booking statuses (blueprint section 8). Schema v1 used pending/confirmed/
+// rejected/cancelled; internal/migrate rewrites stored bookings on startup.
+const (
+	bookingStatusRequested = "REQUESTED"
+	bookingStatusApproved  = "APPROVED"
+	bookingStatusRejected  = "REJECTED"
+	bookingStatusCancelled = "CANCELLED"
+)
+
 func validStatusTransition(cur, next string) bool {
 	if cur == next {
 		return true
 	}
 	switch cur {
-	case "pending":
-		return next == "confirmed" || next == "cancelled"
-	case "confirmed":
-		return next == "cancelled"
-	case "cancelled":
-		return false
+	case bookingStatusRequested:
+		return next == bookingStatusApproved || next == bookingStatusRejected || next == bookingStatusCancelled
+	case bookingStatusApproved:
+		return next == bookingStatusCancelled
 	default:
 		return false
 	}
@@ -450,19 +418,18 @@ func validateZoneChain(deps Stage8Deps, siteID, subsiteID, zoneID string) error
 
 func hasBookingOverlap(deps Stage8Deps, ignoreID, zoneID string, sT, eT time.Time) bool {
 	items := deps.GetItems("bookings.json")
-	for _, v := range items {
-		m, ok := v.(map[string]any)
+	for _, id := range deps.BookingsByZone(zoneID) {
+		m, ok := items[id].(map[string]any)
 		if !ok {
 			continue
 		}
-		if str(m["id"]) == ignoreID {
+		if id == ignoreID {
 			continue
 		}
 		if str(m["zone_id"]) != zoneID {
 			continue
 		}
-		status := str(m["status"])
-		if status == "cancelled" {
+		if str(m["status"]) != bookingStatusApproved {
 			continue
 		}
 		os := str(m["start_date"])
@@ -0,0 +1,34 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/itmtjewelry/land-booking-kpr/internal/storage"
+)
+
+func TestBookingCreateNumber(t *testing.T) {
+	d := newTestDeps(t, locationSeed())
+	res := call(t, handler(d, BookingsWriteCollection), salesP, "POST", "/", newBooking("z1"), nil)
+	if want := fmt.Sprintf("BK/%d/000001", time.Now().UTC().Year()); res.Code != http.StatusOK || res.data()["number"] != want {
+		t.Fatalf("create: %d %s, want number %s", res.Code, res.Raw, want)
+	}
+}
+
+// A damaged counter fails the create instead of handing out number 1 again.
+func TestBookingCreateDamagedCounter(t *testing.T) {
+	key := fmt.Sprintf("BK/s1/%d", time.Now().UTC().Year())
+	seed := locationSeed()
+	seed[storage.SequencesFile] = map[string]any{key: map[string]any{"id": key, "last": "41"}}
+	d := newTestDeps(t, seed)
+
+	res := call(t, handler(d, BookingsWriteCollection), salesP, "POST", "/", newBooking("z1"), nil)
+	if res.Code != http.StatusInternalServerError {
+		t.Fatalf("create: %d %s", res.Code, res.Raw)
+	}
+	if n := len(d.GetItems("bookings.json")); n != 0 {
+		t.Fatalf("%d bookings written", n)
+	}
+}
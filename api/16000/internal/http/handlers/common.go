@@ -24,6 +24,7 @@ type ErrorShape struct {
 type Stage7Deps interface {
 	StorageReady() bool
 	// GetItems returns the "items" object-of-objects for a given core JSON filename (e.g. "sites.json").
+	// The result is a shared, pre-decoded snapshot: callers must not mutate it (copy first).
 	GetItems(filename string) map[string]any
 }
 
@@ -51,6 +52,14 @@ func ServiceUnavailable(w http.ResponseWriter) {
 	})
 }
 
+func NotFound(w http.ResponseWriter, code, message string) {
+	WriteJSON(w, http.StatusNotFound, Envelope{
+		OK:   false,
+		Data: []any{},
+		Err:  &ErrorShape{Code: code, Message: message},
+	})
+}
+
 func BadRequest(w http.ResponseWriter, code, message string) {
 	WriteJSON(w, http.StatusBadRequest, Envelope{
 		OK:   false,
@@ -59,6 +68,14 @@ func BadRequest(w http.ResponseWriter, code, message string) {
 	})
 }
 
+func Forbidden(w http.ResponseWriter, message string) {
+	WriteJSON(w, http.StatusForbidden, Envelope{
+		OK:   false,
+		Data: []any{},
+		Err:  &ErrorShape{Code: "forbidden", Message: message},
+	})
+}
+
 // ItemsToSlice converts an "items" map (object-of-objects) into a stable, deterministic slice.
 // It adds the key as field "id" on each returned object.
 func ItemsToSlice(items map[string]any) []map[string]any {
@@ -71,9 +88,14 @@ func ItemsToSlice(items map[string]any) []map[string]any {
 		keys = append(keys, k)
 	}
 	sort.Strings(keys)
+	return ItemsByIDs(items, keys)
+}
 
-	out := make([]map[string]any, 0, len(keys))
-	for _, id := range keys {
+// ItemsByIDs is ItemsToSlice for the given ids only (in the given order),
+// e.g. the result of an index lookup. Unknown ids are skipped.
+func ItemsByIDs(items map[string]any, ids []string) []map[string]any {
+	out := make([]map[string]any, 0, len(ids))
+	for _, id := range ids {
 		raw, ok := items[id]
 		if !ok || raw == nil {
 			continue
@@ -0,0 +1,313 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/itmtjewelry/land-booking-kpr/internal/auth"
+	"github.com/itmtjewelry/land-booking-kpr/internal/domain"
+	"github.com/itmtjewelry/land-booking-kpr/internal/storage"
+)
+
+// testDeps is a Stage8Deps over a temp storage dir. It re-reads files on
+// every reload and decodes on every read: slow, but plainly correct.
+type testDeps struct {
+	t   *testing.T
+	dir string
+
+	mu    sync.Mutex
+	files map[string]storage.JSONFile
+	ready bool
+
+	lockMu sync.Mutex
+	locks  map[string]*sync.Mutex
+}
+
+var _ Stage8Deps = (*testDeps)(nil)
+
+// newTestDeps writes seed (file -> id -> record) next to empty core files
+// and loads them.
+func newTestDeps(t *testing.T, seed map[string]map[string]any) *testDeps {
+	t.Helper()
+	d := &testDeps{t: t, dir: t.TempDir(), ready: true, locks: map[string]*sync.Mutex{}}
+	names := append([]string(nil), storage.CoreFiles...)
+	for name := range seed {
+		if !containsString(names, name) {
+			names = append(names, name)
+		}
+	}
+	for _, name := range names {
+		jf := storage.JSONFile{Meta: map[string]any{"version": 1}, Items: map[string]json.RawMessage{}}
+		for id, rec := range seed[name] {
+			jf.Items[id] = mustJSON(rec)
+		}
+		if err := storage.WriteJSONFileAtomic(d.dir, name, jf); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := d.ReloadFiles(names...); err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+func (d *testDeps) StorageReady() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.ready
+}
+
+func (d *testDeps) SetStorageNotReady(error) {
+	d.mu.Lock()
+	d.ready = false
+	d.mu.Unlock()
+}
+
+func (d *testDeps) StorageDir() string { return d.dir }
+
+func (d *testDeps) Loaded() map[string]storage.JSONFile {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	out := make(map[string]storage.JSONFile, len(d.files))
+	for k, v := range d.files {
+		out[k] = v
+	}
+	return out
+}
+
+func (d *testDeps) GetItems(filename string) map[string]any {
+	jf, ok := d.Loaded()[filename]
+	if !ok {
+		return nil
+	}
+	out := make(map[string]any, len(jf.Items))
+	for id, raw := range jf.Items {
+		var m map[string]any
+		if json.Unmarshal(raw, &m) == nil {
+			out[id] = m
+		}
+	}
+	return out
+}
+
+func (d *testDeps) LockForFile(filename string) sync.Locker {
+	d.lockMu.Lock()
+	defer d.lockMu.Unlock()
+	if l, ok := d.locks[filename]; ok {
+		return l
+	}
+	l := &sync.Mutex{}
+	d.locks[filename] = l
+	return l
+}
+
+func (d *testDeps) ReloadCore() error { return d.ReloadFiles(storage.CoreFiles...) }
+
+func (d *testDeps) ReloadFiles(filenames ...string) error {
+	loaded, err := storage.LoadFiles(d.dir, filenames...)
+	if err != nil {
+		return err
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.files == nil {
+		d.files = map[string]storage.JSONFile{}
+	}
+	for name, jf := range loaded {
+		d.files[name] = jf
+	}
+	return nil
+}
+
+func (d *testDeps) BackupRetention() storage.RetentionPolicy { return storage.RetentionPolicy{} }
+func (d *testDeps) SimulateRateLimit() int                   { return 0 }
+func (d *testDeps) DSRPolicy() domain.DSRPolicy {
+	return domain.DSRPolicy{MaxDSR: 40, Mode: domain.DSRBlock}
+}
+func (d *testDeps) Sessions() *auth.SessionStore { return auth.NewSessionStore(0) }
+
+func testTyped[T any, PT interface {
+	*T
+	domain.Record
+}](d *testDeps, filename string) map[string]T {
+	return domain.DecodeItems[T, PT](d.Loaded()[filename].Items)
+}
+
+func (d *testDeps) Users() map[string]domain.User { return testTyped[domain.User](d, domain.UsersFile) }
+func (d *testDeps) Sites() map[string]domain.Site { return testTyped[domain.Site](d, domain.SitesFile) }
+func (d *testDeps) Subsites() map[string]domain.Subsite {
+	return testTyped[domain.Subsite](d, domain.SubsitesFile)
+}
+func (d *testDeps) Zones() map[string]domain.Zone { return testTyped[domain.Zone](d, domain.ZonesFile) }
+func (d *testDeps) Domains() map[string]domain.Domain {
+	return testTyped[domain.Domain](d, domain.DomainsFile)
+}
+func (d *testDeps) Bookings() map[string]domain.Booking {
+	return testTyped[domain.Booking](d, domain.BookingsFile)
+}
+func (d *testDeps) KPRApplications() map[string]domain.KPRApplication {
+	return testTyped[domain.KPRApplication](d, domain.KPRApplicationsFile)
+}
+func (d *testDeps) InstallmentPlans() map[string]domain.InstallmentPlan {
+	return testTyped[domain.InstallmentPlan](d, domain.InstallmentPlansFile)
+}
+func (d *testDeps) Payments() map[string]domain.Payment {
+	return testTyped[domain.Payment](d, domain.PaymentsFile)
+}
+func (d *testDeps) Tickets() map[string]domain.Ticket {
+	return testTyped[domain.Ticket](d, domain.TicketsFile)
+}
+
+// scan is the linear-scan version of the Indexes.
+func scan[T any](m map[string]T, key func(T) string, want string) []string {
+	var out []string
+	for id, rec := range m {
+		if key(rec) == want {
+			out = append(out, id)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+func (d *testDeps) ZonesBySubsite(id string) []string {
+	return scan(d.Zones(), func(z domain.Zone) string { return z.SubsiteID }, id)
+}
+func (d *testDeps) BookingsByZone(id string) []string {
+	return scan(d.Bookings(), func(b domain.Booking) string { return b.ZoneID }, id)
+}
+func (d *testDeps) KPRsByBooking(id string) []string {
+	return scan(d.KPRApplications(), func(k domain.KPRApplication) string { return k.BookingID }, id)
+}
+func (d *testDeps) PlansByKPR(id string) []string {
+	return scan(d.InstallmentPlans(), func(p domain.InstallmentPlan) string { return p.KPRID }, id)
+}
+func (d *testDeps) PaymentsByKPR(id string) []string {
+	return scan(d.Payments(), func(p domain.Payment) string { return p.KPRID }, id)
+}
+func (d *testDeps) PaymentsByBooking(id string) []string {
+	return scan(d.Payments(), func(p domain.Payment) string { return p.BookingID }, id)
+}
+
+// record returns the stored record filename/id as a map (nil when missing).
+func (d *testDeps) record(filename, id string) map[string]any {
+	m, _ := d.GetItems(filename)[id].(map[string]any)
+	return m
+}
+
+// Test principals.
+var (
+	adminP     = auth.Principal{UserID: "u_admin", Role: auth.RoleAdmin}
+	managerP   = auth.Principal{UserID: "u_mgr", Role: auth.RoleSalesManager}
+	salesP     = auth.Principal{UserID: "u_sales", Role: auth.RoleCustomerSales}
+	otherP     = auth.Principal{UserID: "u_sales2", Role: auth.RoleCustomerSales}
+	guestP     = auth.Guest()
+	anyIfMatch = map[string]string{"If-Match": "*"}
+)
+
+// testResponse is a recorded handler response.
+type testResponse struct {
+	Code int
+	Body map[string]any
+	Raw  string
+}
+
+// data returns the "data" object of an ok response.
+func (r testResponse) data() map[string]any {
+	m, _ := r.Body["data"].(map[string]any)
+	return m
+}
+
+// errCode returns error.code of an Envelope error response.
+func (r testResponse) errCode() string {
+	e, _ := r.Body["error"].(map[string]any)
+	return str(e["code"])
+}
+
+// listIDs returns the ids in the "data" list of a response.
+func listIDs(res testResponse) map[string]bool {
+	out := map[string]bool{}
+	list, _ := res.Body["data"].([]any)
+	for _, v := range list {
+		if m, ok := v.(map[string]any); ok {
+			out[str(m["id"])] = true
+		}
+	}
+	return out
+}
+
+// handler and byID adapt the deps-first handlers to http.HandlerFunc.
+func handler(d *testDeps, f func(Stage8Deps, http.ResponseWriter, *http.Request)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) { f(d, w, r) }
+}
+
+func byID(d *testDeps, f func(Stage8Deps, string, http.ResponseWriter, *http.Request), id string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) { f(d, id, w, r) }
+}
+
+// testScopeHeader limits a test request to a site, as withSiteScope would.
+const testScopeHeader = "X-Test-Scope"
+
+// call runs h as principal p. body is JSON-encoded unless it is nil or
+// already a string.
+func call(t *testing.T, h http.HandlerFunc, p auth.Principal, method, target string, body any, headers map[string]string) testResponse {
+	t.Helper()
+	var rd *bytes.Reader
+	switch b := body.(type) {
+	case nil:
+		rd = bytes.NewReader(nil)
+	case string:
+		rd = bytes.NewReader([]byte(b))
+	default:
+		rd = bytes.NewReader(mustJSON(b))
+	}
+	r := httptest.NewRequest(method, target, rd)
+	for k, v := range headers {
+		r.Header.Set(k, v)
+	}
+	ctx := auth.WithPrincipal(r.Context(), p)
+	if scope := r.Header.Get(testScopeHeader); scope != "" {
+		ctx = WithSiteScope(ctx, scope)
+	}
+	w := httptest.NewRecorder()
+	h(w, r.WithContext(ctx))
+
+	out := testResponse{Code: w.Code, Raw: w.Body.String()}
+	_ = json.NewDecoder(strings.NewReader(out.Raw)).Decode(&out.Body)
+	return out
+}
+
+// locationSeed is one site s1 with subsite ss1 and AVAILABLE zones z1, z2,
+// plus site s2 / subsite ss2 / zone z3 for scope tests.
+func locationSeed() map[string]map[string]any {
+	return map[string]map[string]any{
+		"sites.json": {
+			"s1": map[string]any{"id": "s1", "name": "Site 1"},
+			"s2": map[string]any{"id": "s2", "name": "Site 2"},
+		},
+		"subsites.json": {
+			"ss1": map[string]any{"id": "ss1", "site_id": "s1", "name": "Sub 1"},
+			"ss2": map[string]any{"id": "ss2", "site_id": "s2", "name": "Sub 2"},
+		},
+		"zones.json": {
+			"z1": map[string]any{"id": "z1", "site_id": "s1", "subsite_id": "ss1", "name": "Z1", "status": zoneStatusAvailable, "price": 100000000},
+			"z2": map[string]any{"id": "z2", "site_id": "s1", "subsite_id": "ss1", "name": "Z2", "status": zoneStatusAvailable, "price": 100000000},
+			"z3": map[string]any{"id": "z3", "site_id": "s2", "subsite_id": "ss2", "name": "Z3", "status": zoneStatusAvailable, "price": 100000000},
+		},
+	}
+}
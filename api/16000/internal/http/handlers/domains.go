@@ -0,0 +1,163 @@
+package handlers
+
+import (
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// DomainMatch is the result of resolving a request host against domains.json.
+type DomainMatch struct {
+	Host      string `json:"host"`
+	MatchedBy string `json:"matched_by"` // exact | wildcard | default
+	DomainID  string `json:"domain_id"`
+	Domain    string `json:"domain"`
+	SiteID    string `json:"site_id"`
+	Theme     any    `json:"theme"`
+	Status    string `json:"status"`
+}
+
+// DomainsResolve serves GET /api/v1/domains/resolve?host=...
+// When host is omitted the request Host header is used.
+func DomainsResolve(deps Stage7Deps) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			methodNotAllowed(w)
+			return
+		}
+		if !deps.StorageReady() {
+			errJSON(w, http.StatusServiceUnavailable, "storage not ready")
+			return
+		}
+
+		host := strings.TrimSpace(r.URL.Query().Get("host"))
+		if host == "" {
+			host = r.Host
+		}
+		host = NormalizeHost(host)
+		if host == "" {
+			errJSON(w, http.StatusBadRequest, "host is required")
+			return
+		}
+
+		m, ok := ResolveDomain(deps.GetItems("domains.json"), host)
+		if !ok {
+			errJSON(w, http.StatusNotFound, "domain not mapped")
+			return
+		}
+		okData(w, m)
+	}
+}
+
+// DomainsList serves GET /api/v1/domains (admin).
+func DomainsList(deps Stage7Deps) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			methodNotAllowed(w)
+			return
+		}
+		if !deps.StorageReady() {
+			errJSON(w, http.StatusServiceUnavailable, "storage not ready")
+			return
+		}
+		okDataCached(w, r, ItemsToSlice(deps.GetItems("domains.json")))
+	}
+}
+
+// NormalizeHost lowercases a host and strips any port and trailing dot.
+func NormalizeHost(h string) string {
+	h = strings.ToLower(strings.TrimSpace(h))
+	if host, _, err := net.SplitHostPort(h); err == nil {
+		h = host
+	}
+	h = strings.TrimPrefix(h, "[")
+	h = strings.TrimSuffix(h, "]")
+	return strings.TrimSuffix(h, ".")
+}
+
+// ResolveDomain matches host against domains.json items.
+//
+// Only ACTIVE mappings match; an INACTIVE one is skipped as if it were absent.
+// Precedence:
+//  1. exact domain match
+//  2. wildcard "*.example.com" (longest suffix wins; does not match the apex)
+//  3. the mapping flagged is_default
+func ResolveDomain(items map[string]any, host string) (DomainMatch, bool) {
+	host = NormalizeHost(host)
+
+	ids := make([]string, 0, len(items))
+	for id := range items {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	var (
+		wildID, defID string
+		wildLen       int
+	)
+	for _, id := range ids {
+		m, ok := items[id].(map[string]any)
+		if !ok {
+			continue
+		}
+		d := NormalizeHost(str(m["domain"]))
+		if d == "" || domainStatus(m) != "ACTIVE" {
+			continue
+		}
+		if d == host {
+			return domainMatch(host, "exact", id, m), true
+		}
+		if strings.HasPrefix(d, "*.") {
+			suffix := d[1:] // ".example.com"
+			if strings.HasSuffix(host, suffix) && len(host) > len(suffix) && len(suffix) > wildLen {
+				wildID, wildLen = id, len(suffix)
+			}
+		}
+		if defID == "" && boolFromAny(m["is_default"]) {
+			defID = id
+		}
+	}
+
+	if wildID != "" {
+		return domainMatch(host, "wildcard", wildID, items[wildID].(map[string]any)), true
+	}
+	if defID != "" {
+		return domainMatch(host, "default", defID, items[defID].(map[string]any)), true
+	}
+	return DomainMatch{}, false
+}
+
+func domainMatch(host, by, id string, m map[string]any) DomainMatch {
+	return DomainMatch{
+		Host:      host,
+		MatchedBy: by,
+		DomainID:  id,
+		Domain:    str(m["domain"]),
+		SiteID:    str(m["site_id"]),
+		Theme:     m["theme"],
+		Status:    domainStatus(m),
+	}
+}
+
+func domainStatus(m map[string]any) string {
+	st := strings.ToUpper(str(m["status"]))
+	if st == "" {
+		return "ACTIVE"
+	}
+	return st
+}
+
+func boolFromAny(v any) bool {
+	switch t := v.(type) {
+	case bool:
+		return t
+	case string:
+		s := strings.ToLower(strings.TrimSpace(t))
+		return s == "true" || s == "1" || s == "yes"
+	case float64:
+		return t != 0
+	default:
+		return false
+	}
+}
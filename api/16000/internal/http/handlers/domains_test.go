@@ -0,0 +1,49 @@
+package handlers
+
+import "testing"
+
+func TestResolveDomain(t *testing.T) {
+	items := map[string]any{
+		"d1": map[string]any{"domain": "www.matahariland.co.id", "site_id": "s1"},
+		"d2": map[string]any{"domain": "*.matahariland.co.id", "site_id": "s2"},
+		"d3": map[string]any{"domain": "*.promo.matahariland.co.id", "site_id": "s3"},
+		"d4": map[string]any{"domain": "fallback.local", "site_id": "s9", "is_default": true},
+	}
+
+	cases := []struct {
+		host, site, by string
+	}{
+		{"WWW.Matahariland.co.id:15080", "s1", "exact"},
+		{"blok-a.matahariland.co.id", "s2", "wildcard"},
+		{"x.promo.matahariland.co.id", "s3", "wildcard"},
+		{"matahariland.co.id", "s9", "default"},
+		{"other.example.com", "s9", "default"},
+	}
+	for _, c := range cases {
+		m, ok := ResolveDomain(items, c.host)
+		if !ok {
+			t.Fatalf("%s: expected match", c.host)
+		}
+		if m.SiteID != c.site || m.MatchedBy != c.by {
+			t.Fatalf("%s: got site=%s by=%s, want site=%s by=%s", c.host, m.SiteID, m.MatchedBy, c.site, c.by)
+		}
+	}
+
+	// INACTIVE mappings never match: the host falls through to the next rule.
+	items["d5"] = map[string]any{"domain": "old.matahariland.co.id", "site_id": "s5", "status": "INACTIVE"}
+	items["d6"] = map[string]any{"domain": "*.lama.co.id", "site_id": "s6", "status": "inactive"}
+	for host, site := range map[string]string{"old.matahariland.co.id": "s2", "x.lama.co.id": "s9"} {
+		if m, ok := ResolveDomain(items, host); !ok || m.SiteID != site {
+			t.Fatalf("%s: got %+v, want site %s", host, m, site)
+		}
+	}
+	items["d4"].(map[string]any)["status"] = "INACTIVE"
+	if _, ok := ResolveDomain(items, "other.example.com"); ok {
+		t.Fatal("INACTIVE default mapping matched")
+	}
+
+	delete(items, "d4")
+	if _, ok := ResolveDomain(items, "other.example.com"); ok {
+		t.Fatal("expected no match without a default mapping")
+	}
+}
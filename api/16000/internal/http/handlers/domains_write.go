@@ -0,0 +1,236 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/itmtjewelry/land-booking-kpr/internal/ids"
+	"github.com/itmtjewelry/land-booking-kpr/internal/storage"
+)
+
+type domainPayload struct {
+	ID        string `json:"id"`
+	Domain    string `json:"domain"`
+	SiteID    string `json:"site_id"`
+	Theme     any    `json:"theme"`
+	Status    string `json:"status"`
+	IsDefault bool   `json:"is_default"`
+}
+
+func (p *domainPayload) normalize() error {
+	p.Domain = NormalizeHost(p.Domain)
+	p.SiteID = strings.TrimSpace(p.SiteID)
+	p.Status = strings.ToUpper(strings.TrimSpace(p.Status))
+	if p.Domain == "" {
+		return errBad("domain is required")
+	}
+	if strings.Contains(p.Domain[1:], "*") || (strings.HasPrefix(p.Domain, "*") && !strings.HasPrefix(p.Domain, "*.")) {
+		return errBad("wildcard domains must look like *.example.com")
+	}
+	if p.SiteID == "" {
+		return errBad("site_id is required")
+	}
+	if p.Status == "" {
+		p.Status = "ACTIVE"
+	}
+	if p.Status != "ACTIVE" && p.Status != "INACTIVE" {
+		return errBad("status must be ACTIVE or INACTIVE")
+	}
+	return nil
+}
+
+// checkDomainUnique rejects a duplicate domain name or a second default mapping.
+func checkDomainUnique(jf storage.JSONFile, selfID string, p domainPayload) (int, string) {
+	for id, raw := range jf.Items {
+		if id == selfID {
+			continue
+		}
+		var m map[string]any
+		if err := json.Unmarshal(raw, &m); err != nil {
+			continue
+		}
+		if NormalizeHost(str(m["domain"])) == p.Domain {
+			return http.StatusConflict, "domain already mapped"
+		}
+		if p.IsDefault && boolFromAny(m["is_default"]) {
+			return http.StatusConflict, "another default domain exists"
+		}
+	}
+	return 0, ""
+}
+
+func DomainsWriteCollection(deps Stage8Deps, w http.ResponseWriter, r *http.Request) {
+	if !deps.StorageReady() {
+		errJSON(w, http.StatusServiceUnavailable, "storage not ready")
+		return
+	}
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w)
+		return
+	}
+
+	var p domainPayload
+	if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+		errJSON(w, http.StatusBadRequest, "invalid json")
+		return
+	}
+	if err := p.normalize(); err != nil {
+		errJSON(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if strings.TrimSpace(p.ID) == "" {
+		p.ID = ids.New("domain")
+	}
+
+	filename := "domains.json"
+	mu := deps.LockForFile(filename)
+	mu.Lock()
+	defer mu.Unlock()
+
+	// Checked under the domains.json lock, which a site delete also takes.
+	siteItems := deps.GetItems("sites.json")
+	if _, ok := siteItems[p.SiteID]; !ok {
+		errJSON(w, http.StatusBadRequest, "site_id not found")
+		return
+	}
+
+	jf := mustLoadJSONFile(deps, filename)
+
+	if _, exists := jf.Items[p.ID]; exists {
+		errJSON(w, http.StatusConflict, "id already exists")
+		return
+	}
+	if status, msg := checkDomainUnique(jf, p.ID, p); status != 0 {
+		errJSON(w, status, msg)
+		return
+	}
+
+	now := time.Now().UTC().Format(time.RFC3339)
+	jf.Items[p.ID] = mustJSON(map[string]any{
+		"id":         p.ID,
+		"domain":     p.Domain,
+		"site_id":    p.SiteID,
+		"theme":      p.Theme,
+		"status":     p.Status,
+		"is_default": p.IsDefault,
+		"created_at": now,
+		"updated_at": now,
+	})
+
+	if err := storage.WriteJSONFileAtomic(deps.StorageDir(), filename, jf); err != nil {
+		writeFailed(w, err, "write failed")
+		return
+	}
+	if err := deps.ReloadFiles(filename); err != nil {
+		errJSON(w, http.StatusInternalServerError, "reload failed")
+		return
+	}
+
+	setRecordETag(w, deps, filename, p.ID)
+	okData(w, map[string]any{"id": p.ID})
+}
+
+func DomainsWriteByID(deps Stage8Deps, id string, w http.ResponseWriter, r *http.Request) {
+	if !deps.StorageReady() {
+		errJSON(w, http.StatusServiceUnavailable, "storage not ready")
+		return
+	}
+
+	id = strings.TrimSpace(id)
+	if id == "" {
+		errJSON(w, http.StatusBadRequest, "invalid id")
+		return
+	}
+
+	filename := "domains.json"
+	mu := deps.LockForFile(filename)
+	mu.Lock()
+	defer mu.Unlock()
+
+	jf := mustLoadJSONFile(deps, filename)
+
+	switch r.Method {
+	case http.MethodPut:
+		raw, exists := jf.Items[id]
+		if !exists {
+			errJSON(w, http.StatusBadRequest, "id not found")
+			return
+		}
+		if !requireIfMatch(w, r, raw) {
+			return
+		}
+		var cur map[string]any
+		if err := json.Unmarshal(raw, &cur); err != nil {
+			errJSON(w, http.StatusInternalServerError, "invalid stored domain")
+			return
+		}
+
+		var p domainPayload
+		if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+			errJSON(w, http.StatusBadRequest, "invalid json")
+			return
+		}
+		if err := p.normalize(); err != nil {
+			errJSON(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		siteItems := deps.GetItems("sites.json")
+		if _, ok := siteItems[p.SiteID]; !ok {
+			errJSON(w, http.StatusBadRequest, "site_id not found")
+			return
+		}
+		if status, msg := checkDomainUnique(jf, id, p); status != 0 {
+			errJSON(w, status, msg)
+			return
+		}
+
+		cur["id"] = id
+		cur["domain"] = p.Domain
+		cur["site_id"] = p.SiteID
+		cur["theme"] = p.Theme
+		cur["status"] = p.Status
+		cur["is_default"] = p.IsDefault
+		cur["updated_at"] = time.Now().UTC().Format(time.RFC3339)
+		jf.Items[id] = mustJSON(cur)
+
+		if err := storage.WriteJSONFileAtomic(deps.StorageDir(), filename, jf); err != nil {
+			writeFailed(w, err, "write failed")
+			return
+		}
+		if err := deps.ReloadFiles(filename); err != nil {
+			errJSON(w, http.StatusInternalServerError, "reload failed")
+			return
+		}
+		setRecordETag(w, deps, filename, id)
+		okData(w, map[string]any{"id": id})
+		return
+
+	case http.MethodDelete:
+		if _, exists := jf.Items[id]; !exists {
+			okData(w, map[string]any{"deleted": false})
+			return
+		}
+		if !checkIfMatch(w, r, jf.Items[id]) {
+			return
+		}
+		delete(jf.Items, id)
+
+		if err := storage.WriteJSONFileAtomic(deps.StorageDir(), filename, jf); err != nil {
+			writeFailed(w, err, "write failed")
+			return
+		}
+		if err := deps.ReloadFiles(filename); err != nil {
+			errJSON(w, http.StatusInternalServerError, "reload failed")
+			return
+		}
+		okData(w, map[string]any{"deleted": true})
+		return
+
+	default:
+		methodNotAllowed(w)
+	}
+}
@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/itmtjewelry/land-booking-kpr/internal/storage"
+)
+
+// Optimistic concurrency.
+//
+// Records carry a storage-maintained revision (storage.RevField). Single-record
+// reads and writes return it as a strong ETag ("<rev>"); PUT and status
+// transition endpoints require it back in If-Match. List endpoints return an
+// ETag over the response body and answer If-None-Match with 304.
+
+func recordETag(rev int) string {
+	return `"` + strconv.Itoa(rev) + `"`
+}
+
+// requireIfMatch fails with 428 when If-Match is missing and 412 when it does
+// not name the current revision of raw. Both carry the current ETag.
+func requireIfMatch(w http.ResponseWriter, r *http.Request, raw json.RawMessage) bool {
+	cur := recordETag(storage.ItemRev(raw))
+	im := strings.TrimSpace(r.Header.Get("If-Match"))
+	if im == "" {
+		w.Header().Set("ETag", cur)
+		errJSON(w, http.StatusPreconditionRequired, "If-Match header is required")
+		return false
+	}
+	if !etagMatches(im, cur) {
+		w.Header().Set("ETag", cur)
+		errJSON(w, http.StatusPreconditionFailed, "record was modified; current ETag is "+cur)
+		return false
+	}
+	return true
+}
+
+// checkIfMatch is requireIfMatch for endpoints where If-Match is optional (DELETE).
+func checkIfMatch(w http.ResponseWriter, r *http.Request, raw json.RawMessage) bool {
+	if strings.TrimSpace(r.Header.Get("If-Match")) == "" {
+		return true
+	}
+	return requireIfMatch(w, r, raw)
+}
+
+// setRecordETag sets the ETag of a record from the current (reloaded) snapshot.
+func setRecordETag(w http.ResponseWriter, deps Stage8Deps, filename, id string) {
+	if raw, ok := deps.Loaded()[filename].Items[id]; ok {
+		w.Header().Set("ETag", recordETag(storage.ItemRev(raw)))
+	}
+}
+
+// etagMatches reports whether a comma-separated If-Match / If-None-Match
+// header names etag. "*" matches anything; weak prefixes are ignored.
+func etagMatches(header, etag string) bool {
+	for _, v := range strings.Split(header, ",") {
+		v = strings.TrimPrefix(strings.TrimSpace(v), "W/")
+		if v == "*" || v == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// WriteJSONCached writes a 200 JSON response with an ETag over its body, or
+// 304 Not Modified when the request's If-None-Match already names it.
+func WriteJSONCached(w http.ResponseWriter, r *http.Request, payload any) {
+	b, err := json.Marshal(payload)
+	if err != nil {
+		WriteJSON(w, http.StatusInternalServerError, Envelope{
+			OK:   false,
+			Data: []any{},
+			Err:  &ErrorShape{Code: "encode_failed", Message: "response encoding failed"},
+		})
+		return
+	}
+	b = append(b, '\n')
+	sum := sha256.Sum256(b)
+	etag := `"` + hex.EncodeToString(sum[:12]) + `"`
+
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", "no-cache")
+	if inm := r.Header.Get("If-None-Match"); inm != "" && etagMatches(inm, etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(b)
+}
+
+func okDataCached(w http.ResponseWriter, r *http.Request, data any) {
+	WriteJSONCached(w, r, map[string]any{"ok": true, "data": data})
+}
@@ -2,13 +2,16 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 	"sort"
 	"strconv"
 	"strings"
 	"time"
 
-	"github.com/itmtjewelry/land-booking-kpr/internal/auth"
+	"github.com/itmtjewelry/land-booking-kpr/internal/domain"
+	"github.com/itmtjewelry/land-booking-kpr/internal/finance"
+	"github.com/itmtjewelry/land-booking-kpr/internal/ids"
 	"github.com/itmtjewelry/land-booking-kpr/internal/storage"
 )
 
@@ -25,14 +28,12 @@ func InstallmentsRead(deps Stage8Deps) http.HandlerFunc {
 		}
 
 		items := deps.GetItems("installment_plans.json")
-		for _, v := range items {
-			m, ok := v.(map[string]any)
+		for _, id := range deps.PlansByKPR(kprID) {
+			m, ok := items[id].(map[string]any)
 			if !ok {
 				continue
 			}
-			if str(m["kpr_id"]) != kprID {
-				continue
-			}
+			w.Header().Set("ETag", recordETag(intFromAny(m["rev"])))
 			okData(w, m)
 			return
 		}
@@ -40,6 +41,111 @@ func InstallmentsRead(deps Stage8Deps) http.HandlerFunc {
 	}
 }
 
+// installmentsGeneratePayload is the optional body of plan generation.
+// Formula defaults to flat; InterestRate (percent p.a.) defaults to the
+// KPR's price.interest_rate. RateTiers replace InterestRate, e.g. fixed for
+// months 1-36 then floating (ReferenceRate + margin).
+type installmentsGeneratePayload struct {
+	Formula       string            `json:"formula"`
+	InterestRate  *float64          `json:"interest_rate"`
+	RateTiers     []domain.RateTier `json:"rate_tiers"`
+	ReferenceRate *float64          `json:"reference_rate"`
+}
+
+// planTerms maps a plan's stored terms onto finance.Terms.
+func planTerms(p domain.InstallmentPlan) (finance.Terms, error) {
+	tiers, err := financeTiers(p.RateTiers)
+	if err != nil {
+		return finance.Terms{}, err
+	}
+	return finance.Terms{
+		Formula:       p.Formula,
+		Loan:          float64(p.LoanAmount),
+		AnnualRate:    float64(p.InterestRate),
+		TenorMonths:   int(p.TenorMonths),
+		Tiers:         tiers,
+		ReferenceRate: float64(p.ReferenceRate),
+	}, nil
+}
+
+// financeTiers normalizes the tier types in place and converts them.
+func financeTiers(tiers []domain.RateTier) ([]finance.Tier, error) {
+	out := make([]finance.Tier, 0, len(tiers))
+	for i := range tiers {
+		t := &tiers[i]
+		t.Type = strings.ToLower(strings.TrimSpace(t.Type))
+		switch t.Type {
+		case domain.RateFixed:
+			t.Margin = 0
+		case domain.RateFloating:
+			t.Rate = 0
+		default:
+			return nil, errors.New("rate_tiers[].type must be fixed or floating")
+		}
+		out = append(out, finance.Tier{
+			FromNo:   int(t.FromNo),
+			Floating: t.Type == domain.RateFloating,
+			Rate:     float64(t.Rate),
+			Margin:   float64(t.Margin),
+		})
+	}
+	return out, nil
+}
+
+func hasFloatingTier(tiers []domain.RateTier) bool {
+	for _, t := range tiers {
+		if t.Type == domain.RateFloating {
+			return true
+		}
+	}
+	return false
+}
+
+// newScheduleTerms validates and defaults the terms of a new schedule. Plan
+// generation and the simulator both build theirs here, so a quote matches the
+// plan it leads to: formula defaults to flat, and floating rate tiers need a
+// reference rate.
+func newScheduleTerms(formula string, loan, rate float64, tenor int, tiers []domain.RateTier, referenceRate *float64) (finance.Terms, error) {
+	terms := finance.Terms{
+		Formula:     strings.ToLower(strings.TrimSpace(formula)),
+		Loan:        loan,
+		AnnualRate:  rate,
+		TenorMonths: tenor,
+	}
+	if terms.Formula == "" {
+		terms.Formula = finance.FormulaFlat
+	}
+	if !finance.ValidFormula(terms.Formula) {
+		return finance.Terms{}, errors.New("formula must be flat, flat_interest or annuity")
+	}
+	var err error
+	if terms.Tiers, err = financeTiers(tiers); err != nil {
+		return finance.Terms{}, err
+	}
+	if hasFloatingTier(tiers) {
+		if referenceRate == nil {
+			return finance.Terms{}, errors.New("reference_rate is required with floating rate tiers")
+		}
+		terms.ReferenceRate = *referenceRate
+	}
+	return terms, nil
+}
+
+// scheduleItem is the unpaid schedule line for l.
+func scheduleItem(l finance.Line, dueDate string) domain.ScheduleItem {
+	return domain.ScheduleItem{
+		No:        domain.Int(l.No),
+		DueDate:   dueDate,
+		Amount:    domain.Number(l.Amount),
+		Rate:      domain.Number(l.Rate),
+		Principal: domain.Number(l.Principal),
+		Interest:  domain.Number(l.Interest),
+		Balance:   domain.Number(l.Balance),
+		Status:    domain.InstallmentUnpaid,
+	}
+}
+
+// InstallmentsGenerate serves POST /api/v1/installments/{kpr_id}/generate.
 func InstallmentsGenerate(deps Stage8Deps, kprID string, w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		methodNotAllowed(w)
@@ -49,8 +155,13 @@ func InstallmentsGenerate(deps Stage8Deps, kprID string, w http.ResponseWriter,
 		errJSON(w, http.StatusServiceUnavailable, "storage not ready")
 		return
 	}
-	if !auth.RequireAdmin(w, r) {
-		return
+
+	var p installmentsGeneratePayload
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+			errJSON(w, http.StatusBadRequest, "invalid json")
+			return
+		}
 	}
 
 	kprID = strings.TrimSpace(kprID)
@@ -59,41 +170,53 @@ func InstallmentsGenerate(deps Stage8Deps, kprID string, w http.ResponseWriter,
 		return
 	}
 
+	// Lock order: kpr_applications.json -> installment_plans.json (same as payments)
+	lockKPR := deps.LockForFile("kpr_applications.json")
+	lockPlan := deps.LockForFile("installment_plans.json")
+	lockKPR.Lock()
+	defer lockKPR.Unlock()
+	lockPlan.Lock()
+	defer lockPlan.Unlock()
+
+	kprJF := mustLoadJSONFile(deps, "kpr_applications.json")
+	jf := mustLoadJSONFile(deps, "installment_plans.json")
+
 	// Ensure KPR exists and approved
-	kprs := deps.GetItems("kpr_applications.json")
-	var kpr map[string]any
-	for _, v := range kprs {
-		m, ok := v.(map[string]any)
-		if !ok {
-			continue
-		}
-		if str(m["id"]) == kprID {
-			kpr = m
-			break
-		}
-	}
-	if kpr == nil {
+	raw, ok := kprJF.Items[kprID]
+	if !ok {
 		errJSON(w, http.StatusBadRequest, "kpr not found")
 		return
 	}
-	if str(kpr["status"]) != "approved" {
+	kpr, err := domain.Decode[domain.KPRApplication](kprID, raw)
+	if err != nil {
+		errJSON(w, http.StatusInternalServerError, "invalid stored kpr")
+		return
+	}
+	if kpr.Status != "approved" {
 		errJSON(w, http.StatusConflict, "installments can be generated only when approved")
 		return
 	}
 
-	pm, _ := kpr["price"].(map[string]any)
-	loan, _ := pm["loan_amount"].(float64)
-
-	tenor := intFromAny(pm["tenor_months"])
-	if loan <= 0 || tenor <= 0 {
+	rate := float64(kpr.Price.InterestRate)
+	if p.InterestRate != nil {
+		rate = *p.InterestRate
+	}
+	terms, err := newScheduleTerms(p.Formula, float64(kpr.Price.LoanAmount), rate, int(kpr.Price.TenorMonths), p.RateTiers, p.ReferenceRate)
+	if err != nil {
+		errJSON(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if terms.Loan <= 0 || terms.TenorMonths <= 0 {
 		errJSON(w, http.StatusBadRequest, "invalid loan_amount/tenor_months")
 		return
 	}
+	lines, err := finance.Schedule(terms)
+	if err != nil {
+		errJSON(w, http.StatusBadRequest, err.Error())
+		return
+	}
 
-	monthly := loan / float64(tenor)
-
-	approvedAt := str(kpr["approved_at"])
-	apT, err := time.Parse(time.RFC3339, approvedAt)
+	apT, err := time.Parse(time.RFC3339, kpr.ApprovedAt)
 	if err != nil {
 		apT = time.Now().UTC()
 	}
@@ -101,90 +224,57 @@ func InstallmentsGenerate(deps Stage8Deps, kprID string, w http.ResponseWriter,
 	y, m, _ := apT.Date()
 	first := time.Date(y, m, 5, 0, 0, 0, 0, time.UTC).AddDate(0, 1, 0)
 
-	type schedItem struct {
-		No         int     `json:"no"`
-		DueDate    string  `json:"due_date"`
-		Amount     float64 `json:"amount"`
-		PaidAmount float64 `json:"paid_amount"`
-		Status     string  `json:"status"`
-	}
-
-	schedule := make([]schedItem, 0, tenor)
-	for i := 0; i < tenor; i++ {
-		d := first.AddDate(0, i, 0)
-		schedule = append(schedule, schedItem{
-			No:         i + 1,
-			DueDate:    d.Format("2006-01-02"),
-			Amount:     monthly,
-			PaidAmount: 0,
-			Status:     "unpaid",
-		})
+	schedule := make([]domain.ScheduleItem, 0, len(lines))
+	for i, l := range lines {
+		schedule = append(schedule, scheduleItem(l, first.AddDate(0, i, 0).Format("2006-01-02")))
 	}
 
-	filename := "installment_plans.json"
-	mu := deps.LockForFile(filename)
-	mu.Lock()
-	defer mu.Unlock()
-
-	jf := mustLoadPlanFile(deps, filename)
-
 	// prevent duplicate plan for same kpr
-	for _, raw := range jf.Items {
-		var m map[string]any
-		if err := json.Unmarshal(raw, &m); err != nil {
-			continue
-		}
-		if str(m["kpr_id"]) == kprID {
-			errJSON(w, http.StatusConflict, "installment plan already exists")
-			return
-		}
+	if _, exists := planForKPR(deps, jf, kprID); exists {
+		errJSON(w, http.StatusConflict, "installment plan already exists")
+		return
 	}
 
-	id := genID("plan")
+	id := ids.New("plan")
 	now := time.Now().UTC().Format(time.RFC3339)
 
-	obj := map[string]any{
-		"id":             id,
-		"kpr_id":         kprID,
-		"formula":        "flat",
-		"loan_amount":    loan,
-		"tenor_months":   tenor,
-		"monthly_amount": monthly,
-		"schedule":       schedule,
-		"created_at":     now,
-		"updated_at":     now,
+	obj := domain.InstallmentPlan{
+		ID:            id,
+		KPRID:         kprID,
+		Formula:       terms.Formula,
+		InterestRate:  domain.Number(terms.Rate()),
+		LoanAmount:    domain.Number(terms.Loan),
+		TenorMonths:   domain.Int(terms.TenorMonths),
+		MonthlyAmount: domain.Number(lines[0].Amount),
+		Schedule:      schedule,
+
+		RateTiers:       p.RateTiers,
+		ReferenceRate:   domain.Number(terms.ReferenceRate),
+		ScheduleVersion: 1,
+
+		CreatedAt: now,
+		UpdatedAt: now,
 	}
 
 	jf.Items[id] = mustJSON(obj)
 
-	if err := storage.WriteJSONFileAtomic(deps.StorageDir(), filename, jf); err != nil {
-		errJSON(w, http.StatusInternalServerError, "write failed")
+	// KPR and plan point at each other; commit both or neither.
+	kpr.InstallmentPlanID = id
+	kpr.UpdatedAt = now
+	kprJF.Items[kprID] = mustJSON(kpr)
+
+	tx := storage.BeginTx(deps.StorageDir())
+	tx.Stage("installment_plans.json", jf)
+	tx.Stage("kpr_applications.json", kprJF)
+	if !commitTx(deps, w, tx, "write failed") {
 		return
 	}
-	if err := deps.ReloadCore(); err != nil {
+	if err := deps.ReloadFiles("installment_plans.json", "kpr_applications.json"); err != nil {
 		errJSON(w, http.StatusInternalServerError, "reload failed")
 		return
 	}
 
-	okData(w, map[string]any{"id": id})
-}
-
-func mustLoadPlanFile(deps Stage8Deps, filename string) storage.JSONFile {
-	loaded := deps.Loaded()
-	jf, ok := loaded[filename]
-	if !ok || jf.Items == nil {
-		return storage.JSONFile{
-			Meta:  map[string]any{"version": 1, "updated_at": nil},
-			Items: map[string]json.RawMessage{},
-		}
-	}
-	if jf.Meta == nil {
-		jf.Meta = map[string]any{"version": 1, "updated_at": nil}
-	}
-	if jf.Items == nil {
-		jf.Items = map[string]json.RawMessage{}
-	}
-	return jf
+	okData(w, map[string]any{"id": id, "formula": terms.Formula, "interest_rate": terms.Rate(), "monthly_amount": lines[0].Amount})
 }
 
 func intFromAny(v any) int {
@@ -213,3 +303,14 @@ func sortScheduleIfNeeded(m map[string]any) {
 	})
 	m["schedule"] = arr
 }
+
+// planForKPR finds the installment plan of kprID in jf, a copy of
+// installment_plans.json taken under its lock (so the index matches it).
+func planForKPR(deps Stage8Deps, jf storage.JSONFile, kprID string) (domain.InstallmentPlan, bool) {
+	for _, id := range deps.PlansByKPR(kprID) {
+		if p, err := domain.Decode[domain.InstallmentPlan](id, jf.Items[id]); err == nil {
+			return p, true
+		}
+	}
+	return domain.InstallmentPlan{}, false
+}
@@ -0,0 +1,199 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/itmtjewelry/land-booking-kpr/internal/auth"
+	"github.com/itmtjewelry/land-booking-kpr/internal/domain"
+	"github.com/itmtjewelry/land-booking-kpr/internal/finance"
+	"github.com/itmtjewelry/land-booking-kpr/internal/storage"
+)
+
+// rateChangePayload records a rate change from installment EffectiveFromNo:
+// either a new fixed Rate for the rest of the tenor (later tiers are
+// dropped) or a new floating ReferenceRate (tiers are kept).
+type rateChangePayload struct {
+	EffectiveFromNo int      `json:"effective_from_no"`
+	Rate            *float64 `json:"rate"`
+	ReferenceRate   *float64 `json:"reference_rate"`
+	Reason          string   `json:"reason"`
+}
+
+// InstallmentsRateChange serves POST /api/v1/installments/{kpr_id}/rate-change.
+// It recomputes the schedule from effective_from_no, which must not have any
+// payment yet; paid lines are never touched. The replaced lines and terms
+// are kept in rate_history under the new schedule_version.
+func InstallmentsRateChange(deps Stage8Deps, kprID string, w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w)
+		return
+	}
+	if !deps.StorageReady() {
+		errJSON(w, http.StatusServiceUnavailable, "storage not ready")
+		return
+	}
+
+	var p rateChangePayload
+	if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+		errJSON(w, http.StatusBadRequest, "invalid json")
+		return
+	}
+	if (p.Rate == nil) == (p.ReferenceRate == nil) {
+		errJSON(w, http.StatusBadRequest, "exactly one of rate or reference_rate is required")
+		return
+	}
+
+	kprID = strings.TrimSpace(kprID)
+	if kprID == "" || strings.Contains(kprID, "/") {
+		errJSON(w, http.StatusBadRequest, "invalid kpr id")
+		return
+	}
+
+	// Lock order: kpr_applications.json -> installment_plans.json (same as payments)
+	lockKPR := deps.LockForFile("kpr_applications.json")
+	lockPlan := deps.LockForFile("installment_plans.json")
+	lockKPR.Lock()
+	defer lockKPR.Unlock()
+	lockPlan.Lock()
+	defer lockPlan.Unlock()
+
+	jf := mustLoadJSONFile(deps, "installment_plans.json")
+	plan, ok := planForKPR(deps, jf, kprID)
+	if !ok {
+		errJSON(w, http.StatusNotFound, "installment plan not found")
+		return
+	}
+	if !requireIfMatch(w, r, jf.Items[plan.ID]) {
+		return
+	}
+	if plan.Formula == finance.FormulaFlat {
+		errJSON(w, http.StatusConflict, "flat plans carry no interest")
+		return
+	}
+
+	eff := p.EffectiveFromNo
+	if eff < 1 || eff > int(plan.TenorMonths) {
+		errJSON(w, http.StatusBadRequest, "effective_from_no must be between 1 and "+strconv.Itoa(int(plan.TenorMonths)))
+		return
+	}
+	balance := float64(plan.LoanAmount)
+	for _, it := range plan.Schedule {
+		no := int(it.No)
+		if no == eff-1 {
+			balance = float64(it.Balance)
+		}
+		if no >= eff && it.PaidAmount > 0 {
+			errJSON(w, http.StatusConflict, "installment "+strconv.Itoa(no)+" already has payments")
+			return
+		}
+	}
+
+	prevTiers := plan.RateTiers
+	if len(prevTiers) == 0 {
+		prevTiers = []domain.RateTier{{FromNo: 1, Type: domain.RateFixed, Rate: plan.InterestRate}}
+	}
+	change := domain.RateChange{
+		EffectiveFromNo:       domain.Int(eff),
+		Reason:                strings.TrimSpace(p.Reason),
+		PreviousRateTiers:     prevTiers,
+		PreviousReferenceRate: plan.ReferenceRate,
+	}
+
+	next := plan
+	if p.Rate != nil {
+		next.RateTiers = make([]domain.RateTier, 0, len(prevTiers)+1)
+		for _, t := range prevTiers {
+			if int(t.FromNo) < eff {
+				next.RateTiers = append(next.RateTiers, t)
+			}
+		}
+		next.RateTiers = append(next.RateTiers, domain.RateTier{FromNo: domain.Int(eff), Type: domain.RateFixed, Rate: domain.Number(*p.Rate)})
+		change.Type, change.Rate = domain.RateFixed, domain.Number(*p.Rate)
+	} else {
+		next.RateTiers = append([]domain.RateTier(nil), prevTiers...)
+		if !floatingFrom(next.RateTiers, eff, int(plan.TenorMonths)) {
+			errJSON(w, http.StatusConflict, "no floating rate tier applies from installment "+strconv.Itoa(eff))
+			return
+		}
+		next.ReferenceRate = domain.Number(*p.ReferenceRate)
+		change.Type, change.Rate = domain.RateFloating, domain.Number(*p.ReferenceRate)
+	}
+
+	terms, err := planTerms(next)
+	if err != nil {
+		errJSON(w, http.StatusInternalServerError, "invalid stored plan")
+		return
+	}
+	lines, err := finance.Reschedule(terms, eff, balance)
+	if err != nil {
+		errJSON(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// Recomputed lines keep their due dates.
+	schedule := make([]domain.ScheduleItem, 0, len(plan.Schedule))
+	due := map[int]string{}
+	for _, it := range plan.Schedule {
+		if int(it.No) < eff {
+			schedule = append(schedule, it)
+		} else {
+			change.ReplacedLines = append(change.ReplacedLines, it)
+			due[int(it.No)] = it.DueDate
+		}
+	}
+	for _, l := range lines {
+		schedule = append(schedule, scheduleItem(l, due[l.No]))
+	}
+
+	version := int(plan.ScheduleVersion)
+	if version < 1 {
+		version = 1
+	}
+	now := time.Now().UTC().Format(time.RFC3339)
+	change.Version = domain.Int(version + 1)
+	change.ChangedAt = now
+	change.ChangedByUserID = auth.PrincipalFrom(r.Context()).UserID
+
+	next.Schedule = schedule
+	next.MonthlyAmount = domain.Number(lines[0].Amount)
+	next.ScheduleVersion = change.Version
+	next.RateHistory = append(append([]domain.RateChange(nil), plan.RateHistory...), change)
+	next.UpdatedAt = now
+	jf.Items[plan.ID] = mustJSON(next)
+
+	if err := storage.WriteJSONFileAtomic(deps.StorageDir(), "installment_plans.json", jf); err != nil {
+		writeFailed(w, err, "write failed")
+		return
+	}
+	if err := deps.ReloadFiles("installment_plans.json"); err != nil {
+		errJSON(w, http.StatusInternalServerError, "reload failed")
+		return
+	}
+	setRecordETag(w, deps, "installment_plans.json", plan.ID)
+	okData(w, map[string]any{
+		"id":                plan.ID,
+		"kpr_id":            kprID,
+		"schedule_version":  change.Version,
+		"effective_from_no": eff,
+		"monthly_amount":    lines[0].Amount,
+	})
+}
+
+// floatingFrom reports whether a floating tier covers any installment from
+// no to the end of the tenor.
+func floatingFrom(tiers []domain.RateTier, no, tenor int) bool {
+	for i, t := range tiers {
+		end := tenor
+		if i+1 < len(tiers) {
+			end = int(tiers[i+1].FromNo) - 1
+		}
+		if t.Type == domain.RateFloating && end >= no {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,202 @@
+package handlers
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/itmtjewelry/land-booking-kpr/internal/domain"
+	"github.com/itmtjewelry/land-booking-kpr/internal/finance"
+)
+
+// kprSeed adds the approved KPR k1 (loan 80,000,000 over 24 months at 9%)
+// for zone z1 to locationSeed.
+func kprSeed() map[string]map[string]any {
+	seed := locationSeed()
+	seed["kpr_applications.json"] = map[string]any{
+		"k1": map[string]any{
+			"id": "k1", "booking_id": "b1", "status": "approved", "approved_at": "2026-10-10T00:00:00Z",
+			"customer": map[string]any{"name": "Budi"},
+			"price":    map[string]any{"loan_amount": 80_000_000, "tenor_months": 24, "interest_rate": 9},
+		},
+	}
+	return seed
+}
+
+// A simulator quote for the KPR's terms is the plan generation then stores.
+func TestGenerateMatchesSimulate(t *testing.T) {
+	tiers := []map[string]any{
+		{"from_no": 1, "type": "fixed", "rate": 7.5},
+		{"from_no": 13, "type": "floating", "margin": 3},
+	}
+	for _, tc := range []struct {
+		name string
+		opts map[string]any // formula and rate inputs, given to both
+	}{
+		{"defaults", nil},
+		{"annuity", map[string]any{"formula": "annuity"}},
+		{"tiers", map[string]any{"formula": "annuity", "rate_tiers": tiers, "reference_rate": 6}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			d := newTestDeps(t, kprSeed())
+
+			quote := map[string]any{"land_price": 100_000_000, "dp_amount": 20_000_000, "tenor_months": 24, "interest_rate": 9, "schedule": true}
+			for k, v := range tc.opts {
+				quote[k] = v
+			}
+			sim := call(t, handler(d, KPRSimulate), guestP, "POST", "/", quote, nil)
+			if sim.Code != http.StatusOK {
+				t.Fatalf("simulate: %d %s", sim.Code, sim.Raw)
+			}
+			if res := call(t, byID(d, InstallmentsGenerate, "k1"), adminP, "POST", "/", tc.opts, nil); res.Code != http.StatusOK {
+				t.Fatalf("generate: %d %s", res.Code, res.Raw)
+			}
+
+			plans := d.InstallmentPlans()
+			if len(plans) != 1 {
+				t.Fatalf("plans: %v", plans)
+			}
+			for _, p := range plans {
+				q := sim.data()
+				if p.Formula != q["formula"] || float64(p.MonthlyAmount) != q["monthly_installment"] || float64(p.InterestRate) != q["interest_rate"] {
+					t.Fatalf("plan %s %v %v, quote %v %v %v", p.Formula, p.MonthlyAmount, p.InterestRate, q["formula"], q["monthly_installment"], q["interest_rate"])
+				}
+				lines, _ := q["schedule"].([]any)
+				if len(lines) != len(p.Schedule) {
+					t.Fatalf("quote has %d lines, plan %d", len(lines), len(p.Schedule))
+				}
+				for i, s := range p.Schedule {
+					l := lines[i].(map[string]any)
+					if float64(s.Amount) != l["amount"] || float64(s.Principal) != l["principal"] ||
+						float64(s.Interest) != l["interest"] || float64(s.Balance) != l["balance"] || float64(s.Rate) != l["rate"] {
+						t.Fatalf("line %d: plan %+v, quote %v", i+1, s, l)
+					}
+				}
+			}
+		})
+	}
+}
+
+// Floating tiers without a reference rate are refused by both.
+func TestFloatingTiersNeedReferenceRate(t *testing.T) {
+	d := newTestDeps(t, kprSeed())
+	tiers := []map[string]any{{"from_no": 1, "type": "floating", "margin": 3}}
+
+	quote := map[string]any{"land_price": 100_000_000, "tenor_months": 24, "rate_tiers": tiers}
+	if res := call(t, handler(d, KPRSimulate), guestP, "POST", "/", quote, nil); res.Code != http.StatusBadRequest {
+		t.Fatalf("simulate: %d %s", res.Code, res.Raw)
+	}
+	if res := call(t, byID(d, InstallmentsGenerate, "k1"), adminP, "POST", "/", map[string]any{"rate_tiers": tiers}, nil); res.Code != http.StatusBadRequest {
+		t.Fatalf("generate: %d %s", res.Code, res.Raw)
+	}
+}
+
+// planSeed adds to kprSeed the annuity plan p1 of k1 at 9%, due on the 5th
+// of every month from 2026-11, with paid[no] already paid on installment no.
+func planSeed(t *testing.T, paid map[int]float64) map[string]map[string]any {
+	t.Helper()
+	seed := kprSeed()
+	lines, err := finance.Schedule(finance.Terms{Formula: finance.FormulaAnnuity, Loan: 80_000_000, AnnualRate: 9, TenorMonths: 24})
+	if err != nil {
+		t.Fatal(err)
+	}
+	first := time.Date(2026, 11, 5, 0, 0, 0, 0, time.UTC)
+	plan := domain.InstallmentPlan{
+		ID: "p1", KPRID: "k1", Formula: finance.FormulaAnnuity, InterestRate: 9, LoanAmount: 80_000_000,
+		TenorMonths: 24, MonthlyAmount: domain.Number(lines[0].Amount), ScheduleVersion: 1,
+	}
+	for i, l := range lines {
+		it := scheduleItem(l, first.AddDate(0, i, 0).Format("2006-01-02"))
+		if amt, ok := paid[l.No]; ok {
+			it.PaidAmount = domain.Number(amt)
+			it.Status = domain.InstallmentPartial
+			if it.IsPaid() {
+				it.Status = domain.InstallmentPaid
+			}
+		}
+		plan.Schedule = append(plan.Schedule, it)
+	}
+	seed["installment_plans.json"] = map[string]any{"p1": plan}
+	seed["kpr_applications.json"]["k1"].(map[string]any)["installment_plan_id"] = "p1"
+	return seed
+}
+
+func rateChange(t *testing.T, d *testDeps, body map[string]any) testResponse {
+	t.Helper()
+	return call(t, byID(d, InstallmentsRateChange, "k1"), adminP, "POST", "/", body, anyIfMatch)
+}
+
+func TestRateChange(t *testing.T) {
+	before := planSeed(t, nil)["installment_plans.json"]["p1"].(domain.InstallmentPlan)
+	full := float64(before.Schedule[0].Amount)
+	d := newTestDeps(t, planSeed(t, map[int]float64{1: full, 2: full, 3: full}))
+
+	res := rateChange(t, d, map[string]any{"effective_from_no": 4, "rate": 12, "reason": "repricing"})
+	if res.Code != http.StatusOK || res.data()["schedule_version"] != 2.0 {
+		t.Fatalf("rate change: %d %s", res.Code, res.Raw)
+	}
+	plan := d.InstallmentPlans()["p1"]
+	if len(plan.Schedule) != 24 {
+		t.Fatalf("schedule has %d lines", len(plan.Schedule))
+	}
+	for i, it := range plan.Schedule {
+		was := before.Schedule[i]
+		if it.No != was.No || it.DueDate != was.DueDate {
+			t.Fatalf("line %d moved: %+v, was %+v", i+1, it, was)
+		}
+		if i < 3 {
+			// Paid lines are kept as they were, payments included.
+			if it.Amount != was.Amount || it.Rate != 9 || it.Balance != was.Balance || float64(it.PaidAmount) != full || it.Status != domain.InstallmentPaid {
+				t.Fatalf("paid line %d changed: %+v", i+1, it)
+			}
+		} else if it.Rate != 12 || it.Amount <= was.Amount || it.PaidAmount != 0 {
+			t.Fatalf("line %d not recomputed at 12%%: %+v", i+1, it)
+		}
+	}
+	if last := plan.Schedule[23]; last.Balance != 0 {
+		t.Fatalf("tail does not amortize: %+v", last)
+	}
+	if plan.ScheduleVersion != 2 || plan.MonthlyAmount != plan.Schedule[3].Amount || len(plan.RateHistory) != 1 {
+		t.Fatalf("version %d, monthly %v, history %d", plan.ScheduleVersion, plan.MonthlyAmount, len(plan.RateHistory))
+	}
+	ch := plan.RateHistory[0]
+	if ch.Version != 2 || ch.EffectiveFromNo != 4 || ch.Type != domain.RateFixed || ch.Rate != 12 || ch.Reason != "repricing" ||
+		ch.ChangedByUserID != adminP.UserID || len(ch.ReplacedLines) != 21 || !reflect.DeepEqual(ch.ReplacedLines[0], before.Schedule[3]) ||
+		len(ch.PreviousRateTiers) != 1 || ch.PreviousRateTiers[0].Rate != 9 {
+		t.Fatalf("rate_history[0] = %+v", ch)
+	}
+
+	// A second change appends to the history and keeps the first.
+	if res := rateChange(t, d, map[string]any{"effective_from_no": 10, "rate": 10}); res.Code != http.StatusOK {
+		t.Fatalf("second change: %d %s", res.Code, res.Raw)
+	}
+	next := d.InstallmentPlans()["p1"]
+	if next.ScheduleVersion != 3 || len(next.RateHistory) != 2 || next.RateHistory[1].Version != 3 ||
+		!reflect.DeepEqual(next.RateHistory[0], ch) || len(next.RateHistory[1].PreviousRateTiers) != 2 {
+		t.Fatalf("after second change: version %d, history %+v", next.ScheduleVersion, next.RateHistory)
+	}
+	if !reflect.DeepEqual(next.Schedule[:9], plan.Schedule[:9]) || next.Schedule[9].Rate != 10 || next.Schedule[9].DueDate != before.Schedule[9].DueDate {
+		t.Fatalf("second change touched lines before 10 or lost due dates")
+	}
+}
+
+func TestRateChangeRefusedOverPayments(t *testing.T) {
+	// Installment 5 is partly paid.
+	d := newTestDeps(t, planSeed(t, map[int]float64{5: 1000}))
+
+	for _, eff := range []int{1, 4, 5} {
+		if res := rateChange(t, d, map[string]any{"effective_from_no": eff, "rate": 12}); res.Code != http.StatusConflict {
+			t.Fatalf("from %d: %d %s", eff, res.Code, res.Raw)
+		}
+	}
+	if plan := d.InstallmentPlans()["p1"]; plan.ScheduleVersion != 1 || plan.RateHistory != nil || plan.Schedule[5].Rate != 9 {
+		t.Fatalf("refused change stored: %+v", plan)
+	}
+	if res := rateChange(t, d, map[string]any{"effective_from_no": 6, "rate": 12}); res.Code != http.StatusOK {
+		t.Fatalf("from 6: %d %s", res.Code, res.Raw)
+	}
+	if plan := d.InstallmentPlans()["p1"]; plan.Schedule[4].PaidAmount != 1000 || plan.Schedule[4].Rate != 9 || plan.Schedule[5].Rate != 12 {
+		t.Fatalf("after change from 6: %+v / %+v", plan.Schedule[4], plan.Schedule[5])
+	}
+}
@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"net/http"
+	"sort"
+
+	"github.com/itmtjewelry/land-booking-kpr/internal/integrity"
+	"github.com/itmtjewelry/land-booking-kpr/internal/storage"
+)
+
+// AdminIntegrityCheck serves GET /api/v1/admin/integrity (read-only report).
+func AdminIntegrityCheck(deps Stage8Deps, w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w)
+		return
+	}
+	if !deps.StorageReady() {
+		errJSON(w, http.StatusServiceUnavailable, "storage not ready")
+		return
+	}
+	rep := integrity.Check(deps.Loaded())
+	okData(w, map[string]any{
+		"ok":     rep.OK(),
+		"counts": rep.Counts,
+		"files":  rep.Files,
+		"issues": rep.Issues,
+	})
+}
+
+// AdminIntegrityRepair serves POST /api/v1/admin/integrity/repair.
+// Only derived payment state is repaired (see integrity.Repair).
+func AdminIntegrityRepair(deps Stage8Deps, w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w)
+		return
+	}
+	if !deps.StorageReady() {
+		errJSON(w, http.StatusServiceUnavailable, "storage not ready")
+		return
+	}
+
+	// Same lock order as payments.
+	lockKPR := deps.LockForFile("kpr_applications.json")
+	lockPlan := deps.LockForFile("installment_plans.json")
+	lockPay := deps.LockForFile("payments.json")
+	lockKPR.Lock()
+	defer lockKPR.Unlock()
+	lockPlan.Lock()
+	defer lockPlan.Unlock()
+	lockPay.Lock()
+	defer lockPay.Unlock()
+
+	changed, fixed, err := integrity.Repair(deps.Loaded())
+	if err != nil {
+		errJSON(w, http.StatusInternalServerError, "repair failed: "+err.Error())
+		return
+	}
+
+	written := make([]string, 0, len(changed))
+	for name := range changed {
+		written = append(written, name)
+	}
+	sort.Strings(written)
+
+	if len(written) > 0 {
+		tx := storage.BeginTx(deps.StorageDir())
+		for _, name := range written {
+			tx.Stage(name, changed[name])
+		}
+		if !commitTx(deps, w, tx, "write failed") {
+			return
+		}
+		if err := deps.ReloadFiles(written...); err != nil {
+			errJSON(w, http.StatusInternalServerError, "reload failed")
+			return
+		}
+	}
+
+	remaining := integrity.Check(deps.Loaded())
+	okData(w, map[string]any{
+		"repaired":  fixed,
+		"written":   written,
+		"remaining": remaining.Issues,
+	})
+}
@@ -2,11 +2,16 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
+	"io"
 	"net/http"
 	"strings"
 	"time"
 
 	"github.com/itmtjewelry/land-booking-kpr/internal/auth"
+	"github.com/itmtjewelry/land-booking-kpr/internal/domain"
+	"github.com/itmtjewelry/land-booking-kpr/internal/ids"
+	"github.com/itmtjewelry/land-booking-kpr/internal/migrate"
 	"github.com/itmtjewelry/land-booking-kpr/internal/storage"
 )
 
@@ -16,6 +21,10 @@ type kprCustomer struct {
 	Email   string `json:"email"`
 	NIK     string `json:"nik"`
 	Address string `json:"address"`
+
+	EmploymentType     string   `json:"employment_type"`
+	MonthlyIncome      *float64 `json:"monthly_income"`
+	MonthlyObligations *float64 `json:"monthly_obligations"`
 }
 
 type kprPrice struct {
@@ -80,8 +89,16 @@ func KPRApprove(deps Stage8Deps, id string, w http.ResponseWriter, r *http.Reque
 		methodNotAllowed(w)
 		return
 	}
-	// submitted -> approved, but validate required fields for flat plan
-	kprTransitionWithValidate(deps, id, w, r, "submitted", "approved", validateKPRForApprove)
+	var p kprApprovePayload
+	if err := json.NewDecoder(r.Body).Decode(&p); err != nil && !errors.Is(err, io.EOF) {
+		errJSON(w, http.StatusBadRequest, "invalid json")
+		return
+	}
+	// submitted -> approved, once the required fields and affordability check out
+	now := time.Now().UTC().Format(time.RFC3339)
+	userID := auth.PrincipalFrom(r.Context()).UserID
+	kprTransitionWithValidate(deps, id, w, r, "submitted", "approved",
+		approveKPR(deps.DSRPolicy(), strings.TrimSpace(p.DSROverrideReason), userID, now))
 }
 
 func KPRReject(deps Stage8Deps, id string, w http.ResponseWriter, r *http.Request) {
@@ -98,9 +115,6 @@ func KPRCancel(deps Stage8Deps, id string, w http.ResponseWriter, r *http.Reques
 		return
 	}
 	// allowed: draft/submitted -> cancelled
-	if !auth.RequireAdmin(w, r) {
-		return
-	}
 	filename := "kpr_applications.json"
 	mu := deps.LockForFile(filename)
 	mu.Lock()
@@ -113,31 +127,34 @@ func KPRCancel(deps Stage8Deps, id string, w http.ResponseWriter, r *http.Reques
 		errJSON(w, http.StatusBadRequest, "kpr not found")
 		return
 	}
+	if !requireIfMatch(w, r, raw) {
+		return
+	}
 
-	var cur map[string]any
-	if err := json.Unmarshal(raw, &cur); err != nil {
+	cur, err := domain.Decode[domain.KPRApplication](id, raw)
+	if err != nil {
 		errJSON(w, http.StatusInternalServerError, "invalid stored kpr")
 		return
 	}
 
-	st := str(cur["status"])
-	if st != "draft" && st != "submitted" {
+	if cur.Status != "draft" && cur.Status != "submitted" {
 		errJSON(w, http.StatusConflict, "cancel allowed only for draft/submitted")
 		return
 	}
 
-	cur["status"] = "cancelled"
-	cur["updated_at"] = time.Now().UTC().Format(time.RFC3339)
+	cur.Status = "cancelled"
+	cur.UpdatedAt = time.Now().UTC().Format(time.RFC3339)
 	jf.Items[id] = mustJSON(cur)
 
 	if err := storage.WriteJSONFileAtomic(deps.StorageDir(), filename, jf); err != nil {
-		errJSON(w, http.StatusInternalServerError, "write failed")
+		writeFailed(w, err, "write failed")
 		return
 	}
-	if err := deps.ReloadCore(); err != nil {
+	if err := deps.ReloadFiles(filename); err != nil {
 		errJSON(w, http.StatusInternalServerError, "reload failed")
 		return
 	}
+	setRecordETag(w, deps, filename, id)
 	okData(w, map[string]any{"id": id, "status": "cancelled"})
 }
 
@@ -156,46 +173,48 @@ func kprGetByBooking(deps Stage8Deps, w http.ResponseWriter, r *http.Request) {
 	}
 
 	admin := auth.IsAdmin(r)
-	items := deps.GetItems("kpr_applications.json")
-
-	for _, v := range items {
-		m, ok := v.(map[string]any)
+	kprs := deps.KPRApplications()
+	for _, id := range deps.KPRsByBooking(bookingID) {
+		k, ok := kprs[id]
 		if !ok {
 			continue
 		}
-		if str(m["booking_id"]) != bookingID {
-			continue
-		}
 
 		out := map[string]any{
-			"id":         m["id"],
-			"booking_id": m["booking_id"],
-			"site_id":    m["site_id"],
-			"subsite_id": m["subsite_id"],
-			"zone_id":    m["zone_id"],
-			"status":     m["status"],
-			"notes":      m["notes"],
-			"created_at": m["created_at"],
-			"updated_at": m["updated_at"],
+			"id":         k.ID,
+			"rev":        int(k.Rev),
+			"booking_id": k.BookingID,
+			"site_id":    k.SiteID,
+			"subsite_id": k.SubsiteID,
+			"zone_id":    k.ZoneID,
+			"status":     k.Status,
+			"notes":      k.Notes,
+			"created_at": k.CreatedAt,
+			"updated_at": k.UpdatedAt,
 		}
 
 		// Guest-safe: hide NIK/address, show basic only
-		if cust, ok := m["customer"].(map[string]any); ok {
-			outCust := map[string]any{
-				"name":  cust["name"],
-				"phone": cust["phone"],
-				"email": cust["email"],
-			}
-			if admin {
-				outCust["nik"] = cust["nik"]
-				outCust["address"] = cust["address"]
-			}
-			out["customer"] = outCust
+		outCust := map[string]any{
+			"name":  k.Customer.Name,
+			"phone": k.Customer.Phone,
+			"email": k.Customer.Email,
 		}
+		if admin {
+			outCust["nik"] = k.Customer.NIK
+			outCust["address"] = k.Customer.Address
+			outCust["employment_type"] = k.Customer.EmploymentType
+			outCust["monthly_income"] = k.Customer.MonthlyIncome
+			outCust["monthly_obligations"] = k.Customer.MonthlyObligations
+		}
+		out["customer"] = outCust
 
 		if admin {
-			out["price"] = m["price"]
+			out["price"] = k.Price
+			if k.Affordability != nil {
+				out["affordability"] = k.Affordability
+			}
 		}
+		w.Header().Set("ETag", recordETag(int(k.Rev)))
 		okData(w, out)
 		return
 	}
@@ -208,9 +227,6 @@ func kprCreate(deps Stage8Deps, w http.ResponseWriter, r *http.Request) {
 		errJSON(w, http.StatusServiceUnavailable, "storage not ready")
 		return
 	}
-	if !auth.RequireAdmin(w, r) {
-		return
-	}
 
 	var p kprCreatePayload
 	if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
@@ -225,18 +241,12 @@ func kprCreate(deps Stage8Deps, w http.ResponseWriter, r *http.Request) {
 	}
 
 	// booking must exist & be confirmed
-	bookings := deps.GetItems("bookings.json")
-	bAny, ok := bookings[p.BookingID]
+	b, ok := deps.Bookings()[p.BookingID]
 	if !ok {
 		errJSON(w, http.StatusBadRequest, "booking not found")
 		return
 	}
-	bMap, ok := bAny.(map[string]any)
-	if !ok {
-		errJSON(w, http.StatusInternalServerError, "invalid stored booking")
-		return
-	}
-	if str(bMap["status"]) != "confirmed" {
+	if b.Status != bookingStatusApproved {
 		errJSON(w, http.StatusConflict, "booking not confirmed")
 		return
 	}
@@ -248,61 +258,38 @@ func kprCreate(deps Stage8Deps, w http.ResponseWriter, r *http.Request) {
 
 	jf := mustLoadJSONFile(deps, filename)
 
-	// only 1 KPR per booking
-	for _, raw := range jf.Items {
-		var m map[string]any
-		if err := json.Unmarshal(raw, &m); err != nil {
-			continue
-		}
-		if str(m["booking_id"]) == p.BookingID {
-			errJSON(w, http.StatusConflict, "kpr already exists for booking")
-			return
-		}
+	// only 1 KPR per booking (the index is current: we hold the file lock)
+	if len(deps.KPRsByBooking(p.BookingID)) > 0 {
+		errJSON(w, http.StatusConflict, "kpr already exists for booking")
+		return
 	}
 
-	id := genID("kpr")
+	id := ids.New("kpr")
 	now := time.Now().UTC().Format(time.RFC3339)
 
-	obj := map[string]any{
-		"id":         id,
-		"booking_id": p.BookingID,
-		"site_id":    bMap["site_id"],
-		"subsite_id": bMap["subsite_id"],
-		"zone_id":    bMap["zone_id"],
-		"customer": map[string]any{
-			"name":    "",
-			"phone":   "",
-			"email":   "",
-			"nik":     "",
-			"address": "",
-		},
-		"price": map[string]any{
-			"land_price":    0,
-			"dp_amount":     0,
-			"dp_paid":       0,
-			"loan_amount":   0,
-			"tenor_months":  0,
-			"interest_rate": 0,
-			"admin_fee":     0,
-			"other_fee":     0,
-			"total":         0,
-		},
-		"status":     "draft",
-		"notes":      p.Notes,
-		"created_at": now,
-		"updated_at": now,
+	obj := domain.KPRApplication{
+		ID:        id,
+		BookingID: p.BookingID,
+		SiteID:    b.SiteID,
+		SubsiteID: b.SubsiteID,
+		ZoneID:    b.ZoneID,
+		Status:    "draft",
+		Notes:     p.Notes,
+		CreatedAt: now,
+		UpdatedAt: now,
 	}
 
 	jf.Items[id] = mustJSON(obj)
 
 	if err := storage.WriteJSONFileAtomic(deps.StorageDir(), filename, jf); err != nil {
-		errJSON(w, http.StatusInternalServerError, "write failed")
+		writeFailed(w, err, "write failed")
 		return
 	}
-	if err := deps.ReloadCore(); err != nil {
+	if err := deps.ReloadFiles(filename); err != nil {
 		errJSON(w, http.StatusInternalServerError, "reload failed")
 		return
 	}
+	setRecordETag(w, deps, filename, id)
 	okData(w, map[string]any{"id": id})
 }
 
@@ -311,9 +298,6 @@ func kprUpdate(deps Stage8Deps, id string, w http.ResponseWriter, r *http.Reques
 		errJSON(w, http.StatusServiceUnavailable, "storage not ready")
 		return
 	}
-	if !auth.RequireAdmin(w, r) {
-		return
-	}
 
 	filename := "kpr_applications.json"
 	mu := deps.LockForFile(filename)
@@ -327,15 +311,17 @@ func kprUpdate(deps Stage8Deps, id string, w http.ResponseWriter, r *http.Reques
 		errJSON(w, http.StatusBadRequest, "kpr not found")
 		return
 	}
+	if !requireIfMatch(w, r, raw) {
+		return
+	}
 
-	var cur map[string]any
-	if err := json.Unmarshal(raw, &cur); err != nil {
+	cur, err := domain.Decode[domain.KPRApplication](id, raw)
+	if err != nil {
 		errJSON(w, http.StatusInternalServerError, "invalid stored kpr")
 		return
 	}
 
-	st := str(cur["status"])
-	if st != "draft" && st != "submitted" {
+	if cur.Status != "draft" && cur.Status != "submitted" {
 		errJSON(w, http.StatusConflict, "updates allowed only for draft/submitted")
 		return
 	}
@@ -347,78 +333,63 @@ func kprUpdate(deps Stage8Deps, id string, w http.ResponseWriter, r *http.Reques
 	}
 
 	if strings.TrimSpace(p.Notes) != "" {
-		cur["notes"] = strings.TrimSpace(p.Notes)
+		cur.Notes = strings.TrimSpace(p.Notes)
 	}
 
 	if p.Customer != nil {
-		cm, _ := cur["customer"].(map[string]any)
-		if cm == nil {
-			cm = map[string]any{}
-		}
-		if strings.TrimSpace(p.Customer.Name) != "" {
-			cm["name"] = strings.TrimSpace(p.Customer.Name)
-		}
-		if strings.TrimSpace(p.Customer.Phone) != "" {
-			cm["phone"] = strings.TrimSpace(p.Customer.Phone)
-		}
-		if strings.TrimSpace(p.Customer.Email) != "" {
-			cm["email"] = strings.TrimSpace(p.Customer.Email)
-		}
-		if strings.TrimSpace(p.Customer.NIK) != "" {
-			cm["nik"] = strings.TrimSpace(p.Customer.NIK)
-		}
-		if strings.TrimSpace(p.Customer.Address) != "" {
-			cm["address"] = strings.TrimSpace(p.Customer.Address)
+		setIfNotBlank(&cur.Customer.Name, p.Customer.Name)
+		setIfNotBlank(&cur.Customer.Phone, p.Customer.Phone)
+		setIfNotBlank(&cur.Customer.Email, p.Customer.Email)
+		setIfNotBlank(&cur.Customer.NIK, p.Customer.NIK)
+		setIfNotBlank(&cur.Customer.Address, p.Customer.Address)
+		if err := applyAffordabilityInputs(&cur.Customer, *p.Customer); err != nil {
+			errJSON(w, http.StatusBadRequest, err.Error())
+			return
 		}
-		cur["customer"] = cm
 	}
 
 	if p.Price != nil {
-		pm, _ := cur["price"].(map[string]any)
-		if pm == nil {
-			pm = map[string]any{}
-		}
-		pm["land_price"] = p.Price.LandPrice
-		pm["dp_amount"] = p.Price.DpAmount
-		// dp_paid is updated later by payments stage; keep current if exists
-		if _, ok := pm["dp_paid"]; !ok {
-			pm["dp_paid"] = 0
-		}
-		pm["loan_amount"] = p.Price.LoanAmount
-		pm["tenor_months"] = p.Price.TenorMonths
-		pm["interest_rate"] = p.Price.InterestRate
-		pm["admin_fee"] = p.Price.AdminFee
-		pm["other_fee"] = p.Price.OtherFee
-		pm["total"] = p.Price.Total
-		cur["price"] = pm
-	}
-
-	cur["updated_at"] = time.Now().UTC().Format(time.RFC3339)
+		// dp_paid is owned by the payments stage and is left as stored.
+		cur.Price.LandPrice = domain.Number(p.Price.LandPrice)
+		cur.Price.DpAmount = domain.Number(p.Price.DpAmount)
+		cur.Price.LoanAmount = domain.Number(p.Price.LoanAmount)
+		cur.Price.TenorMonths = domain.Int(p.Price.TenorMonths)
+		cur.Price.InterestRate = domain.Number(p.Price.InterestRate)
+		cur.Price.AdminFee = domain.Number(p.Price.AdminFee)
+		cur.Price.OtherFee = domain.Number(p.Price.OtherFee)
+		cur.Price.Total = domain.Number(p.Price.Total)
+	}
+
+	cur.UpdatedAt = time.Now().UTC().Format(time.RFC3339)
+	// Re-assessed on every change; approval assesses again under the policy in force then.
+	a := assessAffordability(cur, deps.DSRPolicy(), cur.UpdatedAt)
+	cur.Affordability = &a
 	jf.Items[id] = mustJSON(cur)
 
 	if err := storage.WriteJSONFileAtomic(deps.StorageDir(), filename, jf); err != nil {
-		errJSON(w, http.StatusInternalServerError, "write failed")
+		writeFailed(w, err, "write failed")
 		return
 	}
-	if err := deps.ReloadCore(); err != nil {
+	if err := deps.ReloadFiles(filename); err != nil {
 		errJSON(w, http.StatusInternalServerError, "reload failed")
 		return
 	}
-	okData(w, map[string]any{"id": id})
+	setRecordETag(w, deps, filename, id)
+	okData(w, map[string]any{"id": id, "affordability": a})
 }
 
 func kprTransition(deps Stage8Deps, id string, w http.ResponseWriter, r *http.Request, from, to string) {
 	kprTransitionWithValidate(deps, id, w, r, from, to, nil)
 }
 
-func kprTransitionWithValidate(deps Stage8Deps, id string, w http.ResponseWriter, r *http.Request, from, to string, validator func(map[string]any) error) {
+// kprTransitionWithValidate moves the KPR from -> to. validator, when set,
+// may update the record before it is stored; an error stops the transition
+// with its status code.
+func kprTransitionWithValidate(deps Stage8Deps, id string, w http.ResponseWriter, r *http.Request, from, to string, validator func(*domain.KPRApplication) (int, error)) {
 	if !deps.StorageReady() {
 		errJSON(w, http.StatusServiceUnavailable, "storage not ready")
 		return
 	}
-	if !auth.RequireAdmin(w, r) {
-		return
-	}
 
 	filename := "kpr_applications.json"
 	mu := deps.LockForFile(filename)
@@ -432,86 +403,79 @@ func kprTransitionWithValidate(deps Stage8Deps, id string, w http.ResponseWriter
 		errJSON(w, http.StatusBadRequest, "kpr not found")
 		return
 	}
+	if !requireIfMatch(w, r, raw) {
+		return
+	}
 
-	var cur map[string]any
-	if err := json.Unmarshal(raw, &cur); err != nil {
+	cur, err := domain.Decode[domain.KPRApplication](id, raw)
+	if err != nil {
 		errJSON(w, http.StatusInternalServerError, "invalid stored kpr")
 		return
 	}
 
-	st := str(cur["status"])
-	if st != from {
+	if cur.Status != from {
 		errJSON(w, http.StatusConflict, "invalid status transition")
 		return
 	}
 
 	if validator != nil {
-		if err := validator(cur); err != nil {
-			errJSON(w, http.StatusBadRequest, err.Error())
+		if status, err := validator(&cur); err != nil {
+			errJSON(w, status, err.Error())
 			return
 		}
 	}
 
-	cur["status"] = to
-	cur["updated_at"] = time.Now().UTC().Format(time.RFC3339)
+	cur.Status = to
+	cur.UpdatedAt = time.Now().UTC().Format(time.RFC3339)
 	if to == "approved" {
-		cur["approved_at"] = cur["updated_at"]
+		cur.ApprovedAt = cur.UpdatedAt
 	}
 
 	jf.Items[id] = mustJSON(cur)
 
 	if err := storage.WriteJSONFileAtomic(deps.StorageDir(), filename, jf); err != nil {
-		errJSON(w, http.StatusInternalServerError, "write failed")
+		writeFailed(w, err, "write failed")
 		return
 	}
-	if err := deps.ReloadCore(); err != nil {
+	if err := deps.ReloadFiles(filename); err != nil {
 		errJSON(w, http.StatusInternalServerError, "reload failed")
 		return
 	}
-	okData(w, map[string]any{"id": id, "status": to})
+	setRecordETag(w, deps, filename, id)
+	out := map[string]any{"id": id, "status": to}
+	if cur.Affordability != nil && to == "approved" {
+		out["affordability"] = cur.Affordability
+	}
+	okData(w, out)
 }
 
-func validateKPRForApprove(cur map[string]any) error {
-	// require customer basic + price.loan_amount + tenor_months > 0
-	cm, _ := cur["customer"].(map[string]any)
-	if strings.TrimSpace(str(cm["name"])) == "" {
-		return errBad("customer.name is required")
-	}
-	pm, _ := cur["price"].(map[string]any)
-	loan, _ := pm["loan_amount"].(float64)
-	tenorF, ok := pm["tenor_months"].(float64) // json numbers decode to float64 in map
-	if ok {
-		// stored from update might be float64
-	}
-	tenorI := 0
-	if ok {
-		tenorI = int(tenorF)
-	} else if t, ok2 := pm["tenor_months"].(int); ok2 {
-		tenorI = t
+// setIfNotBlank trims v and stores it in dst unless it is blank.
+func setIfNotBlank(dst *string, v string) {
+	if v = strings.TrimSpace(v); v != "" {
+		*dst = v
 	}
-	if loan <= 0 {
-		return errBad("price.loan_amount must be > 0")
-	}
-	if tenorI <= 0 {
-		return errBad("price.tenor_months must be > 0")
-	}
-	return nil
 }
 
+// mustLoadJSONFile returns a private copy of a loaded file: callers may mutate
+// Meta/Items without touching the in-memory state until they write and reload.
 func mustLoadJSONFile(deps Stage8Deps, filename string) storage.JSONFile {
 	loaded := deps.Loaded()
 	jf, ok := loaded[filename]
 	if !ok || jf.Items == nil {
 		return storage.JSONFile{
-			Meta:  map[string]any{"version": 1, "updated_at": nil},
+			Meta:  map[string]any{"version": migrate.CurrentVersion(filename), "updated_at": nil},
 			Items: map[string]json.RawMessage{},
 		}
 	}
-	if jf.Meta == nil {
-		jf.Meta = map[string]any{"version": 1, "updated_at": nil}
+	out := storage.JSONFile{
+		Meta:  storage.CloneMap(jf.Meta),
+		Items: make(map[string]json.RawMessage, len(jf.Items)),
+	}
+	if out.Meta == nil {
+		out.Meta = map[string]any{"version": migrate.CurrentVersion(filename), "updated_at": nil}
 	}
-	if jf.Items == nil {
-		jf.Items = map[string]json.RawMessage{}
+	for k, v := range jf.Items {
+		out.Items[k] = v
 	}
-	return jf
+	return out
 }
@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strings"
+
+	"github.com/itmtjewelry/land-booking-kpr/internal/domain"
+	"github.com/itmtjewelry/land-booking-kpr/internal/finance"
+)
+
+// kprApprovePayload is the optional body of POST /api/v1/kpr/{id}/approve.
+// DSROverrideReason approves an application whose debt-service ratio is over
+// the limit (ADMIN; the reason is kept on the record).
+type kprApprovePayload struct {
+	DSROverrideReason string `json:"dsr_override_reason"`
+}
+
+// assessAffordability computes the debt-service ratio of k. The proposed
+// installment is the annuity payment of the KPR price (the bank-style
+// amount; at 0% it is the flat split), so it does not depend on which
+// formula the plan is later generated with.
+func assessAffordability(k domain.KPRApplication, policy domain.DSRPolicy, now string) domain.Affordability {
+	installment := finance.Payment(finance.Terms{
+		Formula:     finance.FormulaAnnuity,
+		Loan:        float64(k.Price.LoanAmount),
+		AnnualRate:  float64(k.Price.InterestRate),
+		TenorMonths: int(k.Price.TenorMonths),
+	})
+	a := domain.Affordability{
+		Installment: domain.Number(math.Round(installment*100) / 100),
+		MaxDSR:      domain.Number(policy.MaxDSR),
+		Verdict:     domain.AffordabilityUnassessed,
+		AssessedAt:  now,
+	}
+	dsr, err := finance.DSR(float64(k.Customer.MonthlyIncome), float64(k.Customer.MonthlyObligations), float64(a.Installment))
+	if err != nil {
+		return a
+	}
+	a.DSR = domain.Number(dsr)
+	a.Verdict = domain.AffordabilityOK
+	if dsr > policy.MaxDSR {
+		a.Verdict = domain.AffordabilityExceeds
+	}
+	return a
+}
+
+// approveKPR is the approve validator: the required fields, then the
+// affordability check. Its result is stored on k; over the limit, or with no
+// income to assess, a block policy refuses the approval unless it carries an
+// override reason.
+func approveKPR(policy domain.DSRPolicy, overrideReason, userID, now string) func(*domain.KPRApplication) (int, error) {
+	return func(k *domain.KPRApplication) (int, error) {
+		if err := k.ValidateForApprove(); err != nil {
+			return http.StatusBadRequest, err
+		}
+		a := assessAffordability(*k, policy, now)
+		if a.Verdict != domain.AffordabilityOK {
+			if overrideReason != "" {
+				a.Override = &domain.DSROverride{Reason: overrideReason, ByUserID: userID, At: now}
+			} else if policy.Mode == domain.DSRBlock {
+				if a.Verdict == domain.AffordabilityUnassessed {
+					return http.StatusConflict, errors.New("customer.monthly_income is required to assess affordability; dsr_override_reason is required to approve without it")
+				}
+				return http.StatusConflict, fmt.Errorf("debt-service ratio %.2f%% exceeds %.2f%%; dsr_override_reason is required to approve", float64(a.DSR), policy.MaxDSR)
+			}
+		}
+		k.Affordability = &a
+		return 0, nil
+	}
+}
+
+// applyAffordabilityInputs copies the affordability fields of c onto dst.
+// Amounts left out (nil) keep their stored value.
+func applyAffordabilityInputs(dst *domain.KPRCustomer, c kprCustomer) error {
+	if t := strings.ToLower(strings.TrimSpace(c.EmploymentType)); t != "" {
+		if !domain.ValidEmploymentType(t) {
+			return errors.New("customer.employment_type must be employee, self_employed, professional or other")
+		}
+		dst.EmploymentType = t
+	}
+	for _, f := range []struct {
+		name string
+		v    *float64
+		dst  *domain.Number
+	}{
+		{"monthly_income", c.MonthlyIncome, &dst.MonthlyIncome},
+		{"monthly_obligations", c.MonthlyObligations, &dst.MonthlyObligations},
+	} {
+		if f.v == nil {
+			continue
+		}
+		if *f.v < 0 || math.IsInf(*f.v, 0) {
+			return errors.New("customer." + f.name + " must be >= 0")
+		}
+		*f.dst = domain.Number(*f.v)
+	}
+	return nil
+}
@@ -0,0 +1,115 @@
+package handlers
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/itmtjewelry/land-booking-kpr/internal/domain"
+)
+
+func TestApproveKPRAffordability(t *testing.T) {
+	kpr := func(income float64) domain.KPRApplication {
+		k := domain.KPRApplication{}
+		k.Customer.Name = "Budi"
+		k.Customer.MonthlyIncome = domain.Number(income)
+		k.Customer.MonthlyObligations = 1_000_000
+		k.Price.LoanAmount = 12_000_000
+		k.Price.TenorMonths = 12 // 0%: installment 1,000,000
+		return k
+	}
+	block := domain.DSRPolicy{MaxDSR: 40, Mode: domain.DSRBlock}
+	flag := domain.DSRPolicy{MaxDSR: 40, Mode: domain.DSRFlag}
+
+	for _, tc := range []struct {
+		name    string
+		income  float64
+		policy  domain.DSRPolicy
+		reason  string
+		status  int
+		verdict string
+	}{
+		{"within limit", 5_000_000, block, "", 0, domain.AffordabilityOK},
+		{"blocked", 4_000_000, block, "", http.StatusConflict, ""},
+		{"overridden", 4_000_000, block, "guarantor", 0, domain.AffordabilityExceeds},
+		{"flagged", 4_000_000, flag, "", 0, domain.AffordabilityExceeds},
+		{"no income blocked", 0, block, "", http.StatusConflict, ""},
+		{"no income overridden", 0, block, "salary slip pending", 0, domain.AffordabilityUnassessed},
+		{"no income flagged", 0, flag, "", 0, domain.AffordabilityUnassessed},
+	} {
+		k := kpr(tc.income)
+		status, err := approveKPR(tc.policy, tc.reason, "u1", "now")(&k)
+		if status != tc.status || (err != nil) != (tc.status != 0) {
+			t.Errorf("%s: status %d, err %v", tc.name, status, err)
+			continue
+		}
+		if tc.status != 0 {
+			continue
+		}
+		a := k.Affordability
+		if a == nil || a.Verdict != tc.verdict {
+			t.Errorf("%s: affordability %+v, want verdict %s", tc.name, a, tc.verdict)
+			continue
+		}
+		if (a.Override != nil) != (tc.reason != "") {
+			t.Errorf("%s: override %+v", tc.name, a.Override)
+		}
+	}
+
+	// At the limit is still within it.
+	k := kpr(5_000_000)
+	if _, err := approveKPR(block, "", "u1", "now")(&k); err != nil || k.Affordability.DSR != 40 || k.Affordability.Installment != 1_000_000 {
+		t.Fatalf("dsr: %+v, %v", k.Affordability, err)
+	}
+}
+
+func TestKPRApproveStoresAffordability(t *testing.T) {
+	kpr := func(id string, income float64) map[string]any {
+		customer := map[string]any{"name": "Budi", "monthly_obligations": 1_000_000}
+		if income > 0 {
+			customer["monthly_income"] = income
+		}
+		return map[string]any{
+			"id": id, "booking_id": "b1", "status": "submitted", "customer": customer,
+			"price": map[string]any{"loan_amount": 12_000_000, "tenor_months": 12},
+		}
+	}
+	seed := locationSeed()
+	seed["kpr_applications.json"] = map[string]any{
+		"k_ok":     kpr("k_ok", 5_000_000),
+		"k_over":   kpr("k_over", 4_000_000),
+		"k_income": kpr("k_income", 0),
+	}
+	d := newTestDeps(t, seed) // block mode, 40%
+	approve := func(id string, body any) testResponse {
+		return call(t, byID(d, KPRApprove, id), adminP, "POST", "/", body, anyIfMatch)
+	}
+	stored := func(id string) domain.KPRApplication { return d.KPRApplications()[id] }
+
+	for _, id := range []string{"k_over", "k_income"} {
+		if res := approve(id, nil); res.Code != http.StatusConflict {
+			t.Fatalf("%s without override: %d %s", id, res.Code, res.Raw)
+		}
+		if k := stored(id); k.Status != "submitted" || k.Affordability != nil {
+			t.Fatalf("%s changed by a refused approval: %+v", id, k)
+		}
+	}
+
+	if res := approve("k_ok", nil); res.Code != http.StatusOK {
+		t.Fatalf("k_ok: %d %s", res.Code, res.Raw)
+	}
+	if a := stored("k_ok").Affordability; a == nil || a.Verdict != domain.AffordabilityOK || a.DSR != 40 || a.Installment != 1_000_000 || a.MaxDSR != 40 || a.Override != nil {
+		t.Fatalf("k_ok affordability: %+v", a)
+	}
+
+	for id, verdict := range map[string]string{"k_over": domain.AffordabilityExceeds, "k_income": domain.AffordabilityUnassessed} {
+		res := approve(id, map[string]any{"dsr_override_reason": " guarantor "})
+		if res.Code != http.StatusOK {
+			t.Fatalf("%s with override: %d %s", id, res.Code, res.Raw)
+		}
+		k := stored(id)
+		if a := k.Affordability; k.Status != "approved" || a == nil || a.Verdict != verdict || a.Override == nil ||
+			a.Override.Reason != "guarantor" || a.Override.ByUserID != adminP.UserID || a.Override.At == "" {
+			t.Fatalf("%s: %+v / %+v", id, k.Status, k.Affordability)
+		}
+	}
+}
@@ -0,0 +1,203 @@
+package handlers
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/itmtjewelry/land-booking-kpr/internal/domain"
+	"github.com/itmtjewelry/land-booking-kpr/internal/finance"
+)
+
+// maxSimulateTenor bounds the public calculator (30 years).
+const maxSimulateTenor = 360
+
+// kprSimulatePayload is the calculator input: land_price or zone_id (its
+// price), dp_percent or dp_amount, tenor_months, interest_rate (percent
+// p.a.) and formula. Formula, rate_tiers and reference_rate default and
+// validate as in plan generation (rate tiers in the JSON body only).
+type kprSimulatePayload struct {
+	LandPrice     *float64          `json:"land_price"`
+	ZoneID        string            `json:"zone_id"`
+	DpPercent     *float64          `json:"dp_percent"`
+	DpAmount      *float64          `json:"dp_amount"`
+	TenorMonths   int               `json:"tenor_months"`
+	InterestRate  float64           `json:"interest_rate"`
+	Formula       string            `json:"formula"`
+	RateTiers     []domain.RateTier `json:"rate_tiers"`
+	ReferenceRate *float64          `json:"reference_rate"`
+	Schedule      bool              `json:"schedule"`
+}
+
+type simulateLine struct {
+	No        int     `json:"no"`
+	Rate      float64 `json:"rate"`
+	Amount    float64 `json:"amount"`
+	Principal float64 `json:"principal"`
+	Interest  float64 `json:"interest"`
+	Balance   float64 `json:"balance"`
+}
+
+// KPRSimulate serves GET and POST /api/v1/kpr/simulate, the public KPR
+// calculator. It stores nothing and computes with the same code as
+// installment plan generation, so a quote matches the plan it leads to.
+func KPRSimulate(deps Stage8Deps, w http.ResponseWriter, r *http.Request) {
+	var p kprSimulatePayload
+	switch r.Method {
+	case http.MethodGet:
+		var msg string
+		if p, msg = simulatePayloadFromQuery(r.URL.Query()); msg != "" {
+			errJSON(w, http.StatusBadRequest, msg)
+			return
+		}
+	case http.MethodPost:
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64<<10)).Decode(&p); err != nil {
+			errJSON(w, http.StatusBadRequest, "invalid json")
+			return
+		}
+	default:
+		methodNotAllowed(w)
+		return
+	}
+
+	landPrice := 0.0
+	switch {
+	case p.LandPrice != nil:
+		landPrice = *p.LandPrice
+	case strings.TrimSpace(p.ZoneID) != "":
+		if !deps.StorageReady() {
+			errJSON(w, http.StatusServiceUnavailable, "storage not ready")
+			return
+		}
+		zoneID := strings.TrimSpace(p.ZoneID)
+		zone, ok := deps.Zones()[zoneID]
+		if !ok || !zoneInScope(deps, r, zoneID) || zoneArchived(deps, zoneID) {
+			errJSON(w, http.StatusNotFound, "zone not found")
+			return
+		}
+		if zone.Price <= 0 {
+			errJSON(w, http.StatusConflict, "zone has no price")
+			return
+		}
+		landPrice = float64(zone.Price)
+	default:
+		errJSON(w, http.StatusBadRequest, "land_price or zone_id is required")
+		return
+	}
+	if !(landPrice > 0) || math.IsInf(landPrice, 0) {
+		errJSON(w, http.StatusBadRequest, "land_price must be > 0")
+		return
+	}
+
+	dp := 0.0
+	switch {
+	case p.DpAmount != nil && p.DpPercent != nil:
+		errJSON(w, http.StatusBadRequest, "give dp_percent or dp_amount, not both")
+		return
+	case p.DpAmount != nil:
+		dp = *p.DpAmount
+	case p.DpPercent != nil:
+		if *p.DpPercent < 0 || *p.DpPercent >= 100 {
+			errJSON(w, http.StatusBadRequest, "dp_percent must be in [0, 100)")
+			return
+		}
+		dp = math.Round(landPrice*(*p.DpPercent)) / 100
+	}
+	if dp < 0 || dp >= landPrice {
+		errJSON(w, http.StatusBadRequest, "dp_amount must be >= 0 and below land_price")
+		return
+	}
+	if p.TenorMonths <= 0 || p.TenorMonths > maxSimulateTenor {
+		errJSON(w, http.StatusBadRequest, "tenor_months must be between 1 and "+strconv.Itoa(maxSimulateTenor))
+		return
+	}
+
+	terms, err := newScheduleTerms(p.Formula, landPrice-dp, p.InterestRate, p.TenorMonths, p.RateTiers, p.ReferenceRate)
+	if err != nil {
+		errJSON(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	lines, err := finance.Schedule(terms)
+	if err != nil {
+		errJSON(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	totalInterest, totalInstallments := 0.0, 0.0
+	for _, l := range lines {
+		totalInterest += l.Interest
+		totalInstallments += l.Amount
+	}
+	out := map[string]any{
+		"land_price":          landPrice,
+		"dp_amount":           dp,
+		"loan_amount":         terms.Loan,
+		"tenor_months":        terms.TenorMonths,
+		"formula":             terms.Formula,
+		"interest_rate":       terms.Rate(),
+		"monthly_installment": lines[0].Amount,
+		"total_interest":      math.Round(totalInterest*100) / 100,
+		"total_installments":  math.Round(totalInstallments*100) / 100,
+		"total_paid":          math.Round((dp+totalInstallments)*100) / 100,
+	}
+	if len(p.RateTiers) > 0 {
+		out["rate_tiers"] = p.RateTiers
+		out["reference_rate"] = terms.ReferenceRate
+	}
+	if p.Schedule {
+		table := make([]simulateLine, 0, len(lines))
+		for _, l := range lines {
+			table = append(table, simulateLine(l))
+		}
+		out["schedule"] = table
+	}
+	okData(w, out)
+}
+
+// simulatePayloadFromQuery reads the GET form of the calculator input.
+func simulatePayloadFromQuery(q url.Values) (kprSimulatePayload, string) {
+	var p kprSimulatePayload
+	float := func(name string, dst **float64) string {
+		v := strings.TrimSpace(q.Get(name))
+		if v == "" {
+			return ""
+		}
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return name + " must be a number"
+		}
+		*dst = &f
+		return ""
+	}
+	var rate *float64
+	for _, f := range []struct {
+		name string
+		dst  **float64
+	}{
+		{"land_price", &p.LandPrice},
+		{"dp_percent", &p.DpPercent},
+		{"dp_amount", &p.DpAmount},
+		{"interest_rate", &rate},
+	} {
+		if msg := float(f.name, f.dst); msg != "" {
+			return p, msg
+		}
+	}
+	if rate != nil {
+		p.InterestRate = *rate
+	}
+	if v := strings.TrimSpace(q.Get("tenor_months")); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return p, "tenor_months must be an integer"
+		}
+		p.TenorMonths = n
+	}
+	p.ZoneID = q.Get("zone_id")
+	p.Formula = q.Get("formula")
+	p.Schedule, _ = strconv.ParseBool(q.Get("schedule"))
+	return p, ""
+}
@@ -8,7 +8,8 @@ import (
 	"strings"
 	"time"
 
-	"github.com/itmtjewelry/land-booking-kpr/internal/auth"
+	"github.com/itmtjewelry/land-booking-kpr/internal/domain"
+	"github.com/itmtjewelry/land-booking-kpr/internal/ids"
 	"github.com/itmtjewelry/land-booking-kpr/internal/storage"
 )
 
@@ -39,9 +40,6 @@ func paymentsGet(deps Stage8Deps, w http.ResponseWriter, r *http.Request) {
 		errJSON(w, http.StatusServiceUnavailable, "storage not ready")
 		return
 	}
-	if !auth.RequireAdmin(w, r) {
-		return
-	}
 
 	kprID := strings.TrimSpace(r.URL.Query().Get("kpr_id"))
 	bookingID := strings.TrimSpace(r.URL.Query().Get("booking_id"))
@@ -51,16 +49,17 @@ func paymentsGet(deps Stage8Deps, w http.ResponseWriter, r *http.Request) {
 	}
 
 	items := deps.GetItems("payments.json")
-	out := make([]map[string]any, 0, 32)
+	ids := deps.PaymentsByKPR(kprID)
+	if kprID == "" {
+		ids = deps.PaymentsByBooking(bookingID)
+	}
+	out := make([]map[string]any, 0, len(ids))
 
-	for _, v := range items {
-		m, ok := v.(map[string]any)
+	for _, id := range ids {
+		m, ok := items[id].(map[string]any)
 		if !ok {
 			continue
 		}
-		if kprID != "" && str(m["kpr_id"]) != kprID {
-			continue
-		}
 		if bookingID != "" && str(m["booking_id"]) != bookingID {
 			continue
 		}
@@ -77,7 +76,7 @@ func paymentsGet(deps Stage8Deps, w http.ResponseWriter, r *http.Request) {
 		return ai < aj
 	})
 
-	okData(w, out)
+	okDataCached(w, r, out)
 }
 
 func paymentsCreate(deps Stage8Deps, w http.ResponseWriter, r *http.Request) {
@@ -85,9 +84,6 @@ func paymentsCreate(deps Stage8Deps, w http.ResponseWriter, r *http.Request) {
 		errJSON(w, http.StatusServiceUnavailable, "storage not ready")
 		return
 	}
-	if !auth.RequireAdmin(w, r) {
-		return
-	}
 
 	var p paymentCreatePayload
 	if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
@@ -133,6 +129,7 @@ func paymentsCreate(deps Stage8Deps, w http.ResponseWriter, r *http.Request) {
 	lockKPR := deps.LockForFile("kpr_applications.json")
 	lockPlan := deps.LockForFile("installment_plans.json")
 	lockPay := deps.LockForFile("payments.json")
+	lockSeq := deps.LockForFile(storage.SequencesFile)
 
 	lockKPR.Lock()
 	defer lockKPR.Unlock()
@@ -140,11 +137,13 @@ func paymentsCreate(deps Stage8Deps, w http.ResponseWriter, r *http.Request) {
 	defer lockPlan.Unlock()
 	lockPay.Lock()
 	defer lockPay.Unlock()
+	lockSeq.Lock()
+	defer lockSeq.Unlock()
 
 	// Load fresh JSONFile snapshots from in-memory
 	kprJF := mustLoadJSONFile(deps, "kpr_applications.json")
-	planJF := mustLoadPlanFile(deps, "installment_plans.json")
-	payJF := mustLoadPlanFile(deps, "payments.json") // same shape helper
+	planJF := mustLoadJSONFile(deps, "installment_plans.json")
+	payJF := mustLoadJSONFile(deps, "payments.json")
 
 	// Find KPR raw
 	kprRaw, ok := kprJF.Items[p.KPRID]
@@ -152,17 +151,17 @@ func paymentsCreate(deps Stage8Deps, w http.ResponseWriter, r *http.Request) {
 		errJSON(w, http.StatusBadRequest, "kpr not found")
 		return
 	}
-	var kpr map[string]any
-	if err := json.Unmarshal(kprRaw, &kpr); err != nil {
+	kpr, err := domain.Decode[domain.KPRApplication](p.KPRID, kprRaw)
+	if err != nil {
 		errJSON(w, http.StatusInternalServerError, "invalid stored kpr")
 		return
 	}
-	if str(kpr["status"]) != "approved" && str(kpr["status"]) != "completed" {
+	if kpr.Status != "approved" && kpr.Status != "completed" {
 		errJSON(w, http.StatusConflict, "payments allowed only for approved/completed kpr")
 		return
 	}
 
-	bookingID := str(kpr["booking_id"])
+	bookingID := kpr.BookingID
 	if bookingID == "" {
 		errJSON(w, http.StatusInternalServerError, "kpr missing booking_id")
 		return
@@ -173,19 +172,16 @@ func paymentsCreate(deps Stage8Deps, w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Find installment plan for this KPR
-	planID, planObj, err := findPlanByKPR(planJF, p.KPRID)
-	if err != nil {
-		errJSON(w, http.StatusBadRequest, err.Error())
+	plan, ok := planForKPR(deps, planJF, p.KPRID)
+	if !ok {
+		errJSON(w, http.StatusBadRequest, "installment plan not found for kpr")
 		return
 	}
 
 	// Validate payment against remaining (reject overpay)
 	if p.InstallmentNo == 0 {
 		// DP
-		pm, _ := kpr["price"].(map[string]any)
-		dpAmount := floatFromAny(pm["dp_amount"])
-		dpPaid := floatFromAny(pm["dp_paid"])
-		remain := dpAmount - dpPaid
+		remain := float64(kpr.Price.DpAmount - kpr.Price.DpPaid)
 		if remain <= 0 {
 			errJSON(w, http.StatusConflict, "dp already fully paid")
 			return
@@ -195,29 +191,22 @@ func paymentsCreate(deps Stage8Deps, w http.ResponseWriter, r *http.Request) {
 			return
 		}
 		// apply
-		pm["dp_paid"] = dpPaid + p.Amount
-		kpr["price"] = pm
+		kpr.Price.DpPaid += domain.Number(p.Amount)
 	} else {
 		// Installment payment must match schedule
-		sched, ok := planObj["schedule"].([]any)
-		if !ok || len(sched) == 0 {
+		if len(plan.Schedule) == 0 {
 			errJSON(w, http.StatusInternalServerError, "invalid plan schedule")
 			return
 		}
-		if p.InstallmentNo < 1 || p.InstallmentNo > len(sched) {
+		idx := plan.Installment(p.InstallmentNo)
+		if idx < 0 {
 			errJSON(w, http.StatusBadRequest, "installment_no out of range")
 			return
 		}
 
-		itAny := sched[p.InstallmentNo-1]
-		it, ok := itAny.(map[string]any)
-		if !ok {
-			errJSON(w, http.StatusInternalServerError, "invalid schedule item")
-			return
-		}
-		amt := floatFromAny(it["amount"])
-		paid := floatFromAny(it["paid_amount"])
-		remain := amt - paid
+		// The schedule slice is freshly decoded, so it can be updated in place.
+		it := &plan.Schedule[idx]
+		remain := it.Remaining()
 		if remain <= 0 {
 			errJSON(w, http.StatusConflict, "installment already fully paid")
 			return
@@ -228,108 +217,79 @@ func paymentsCreate(deps Stage8Deps, w http.ResponseWriter, r *http.Request) {
 		}
 
 		// apply schedule update (derived state)
-		newPaid := paid + p.Amount
-		it["paid_amount"] = newPaid
-		if approxEqual(newPaid, amt) {
-			it["status"] = "paid"
+		it.PaidAmount += domain.Number(p.Amount)
+		if it.IsPaid() {
+			it.Status = domain.InstallmentPaid
 		} else {
-			it["status"] = "partial"
+			it.Status = domain.InstallmentPartial
 		}
-		sched[p.InstallmentNo-1] = it
-		planObj["schedule"] = sched
-		planObj["updated_at"] = time.Now().UTC().Format(time.RFC3339)
+		plan.UpdatedAt = time.Now().UTC().Format(time.RFC3339)
 	}
 
 	// Append payment record (append-only)
-	paymentID := genID("payment")
-	now := time.Now().UTC().Format(time.RFC3339)
-	pType := "installment"
+	paymentID := ids.New("payment")
+	nowT := time.Now().UTC()
+	now := nowT.Format(time.RFC3339)
+	pType := domain.PaymentInstallment
 	if p.InstallmentNo == 0 {
-		pType = "dp"
+		pType = domain.PaymentDP
+	}
+
+	seq, err := storage.LoadSequences(deps.StorageDir())
+	if err != nil {
+		errJSON(w, http.StatusInternalServerError, "load sequences failed")
+		return
+	}
+	receipt, err := ids.NextDocNumber(&seq, ids.DocReceipt, kpr.SiteID, nowT)
+	if err != nil {
+		errJSON(w, http.StatusInternalServerError, "receipt counter unreadable")
+		return
 	}
 
-	payObj := map[string]any{
-		"id":             paymentID,
-		"type":           pType,
-		"kpr_id":         p.KPRID,
-		"booking_id":     bookingID,
-		"installment_no": p.InstallmentNo,
-		"amount":         p.Amount,
-		"paid_at":        paidAt,
-		"method":         p.Method,
-		"reference":      p.Reference,
-		"notes":          p.Notes,
-		"created_at":     now,
+	payObj := domain.Payment{
+		ID:            paymentID,
+		Type:          pType,
+		KPRID:         p.KPRID,
+		BookingID:     bookingID,
+		InstallmentNo: domain.Int(p.InstallmentNo),
+		Amount:        domain.Number(p.Amount),
+		PaidAt:        paidAt,
+		Method:        p.Method,
+		Reference:     p.Reference,
+		Notes:         p.Notes,
+		ReceiptNumber: receipt,
+		CreatedAt:     now,
 	}
 
 	payJF.Items[paymentID] = mustJSON(payObj)
 
 	// If installments all paid => KPR completed (derived)
-	if p.InstallmentNo != 0 {
-		if allInstallmentsPaid(planObj) {
-			kpr["status"] = "completed"
-			kpr["updated_at"] = time.Now().UTC().Format(time.RFC3339)
-		}
+	if p.InstallmentNo != 0 && plan.AllPaid() {
+		kpr.Status = "completed"
 	}
 
-	// Persist all modified files atomically
-	// 1) payments.json
-	if err := storage.WriteJSONFileAtomic(deps.StorageDir(), "payments.json", payJF); err != nil {
-		errJSON(w, http.StatusInternalServerError, "write payments failed")
-		return
-	}
-	// 2) installment_plans.json (only changed for installment payments)
-	planJF.Items[planID] = mustJSON(planObj)
-	if err := storage.WriteJSONFileAtomic(deps.StorageDir(), "installment_plans.json", planJF); err != nil {
-		errJSON(w, http.StatusInternalServerError, "write plan failed")
-		return
-	}
-	// 3) kpr_applications.json (dp_paid or status completed)
-	kpr["updated_at"] = time.Now().UTC().Format(time.RFC3339)
+	// Persist all modified files in one transaction:
+	// payments.json (ledger), installment_plans.json (schedule), kpr_applications.json (dp_paid / completed),
+	// sequences.json (receipt number)
+	planJF.Items[plan.ID] = mustJSON(plan)
+	kpr.UpdatedAt = now
 	kprJF.Items[p.KPRID] = mustJSON(kpr)
-	if err := storage.WriteJSONFileAtomic(deps.StorageDir(), "kpr_applications.json", kprJF); err != nil {
-		errJSON(w, http.StatusInternalServerError, "write kpr failed")
+
+	tx := storage.BeginTx(deps.StorageDir())
+	tx.Stage("payments.json", payJF)
+	tx.Stage("installment_plans.json", planJF)
+	tx.Stage("kpr_applications.json", kprJF)
+	tx.Stage(storage.SequencesFile, seq)
+	if !commitTx(deps, w, tx, "write payment failed") {
 		return
 	}
 
-	if err := deps.ReloadCore(); err != nil {
+	if err := deps.ReloadFiles("payments.json", "installment_plans.json", "kpr_applications.json"); err != nil {
 		errJSON(w, http.StatusInternalServerError, "reload failed")
 		return
 	}
 
-	okData(w, map[string]any{"id": paymentID})
-}
-
-func findPlanByKPR(planJF storage.JSONFile, kprID string) (string, map[string]any, error) {
-	for id, raw := range planJF.Items {
-		var m map[string]any
-		if err := json.Unmarshal(raw, &m); err != nil {
-			continue
-		}
-		if str(m["kpr_id"]) == kprID {
-			return id, m, nil
-		}
-	}
-	return "", nil, errBad("installment plan not found for kpr")
-}
-
-func allInstallmentsPaid(plan map[string]any) bool {
-	sched, ok := plan["schedule"].([]any)
-	if !ok || len(sched) == 0 {
-		return false
-	}
-	for _, itAny := range sched {
-		it, ok := itAny.(map[string]any)
-		if !ok {
-			return false
-		}
-		amt := floatFromAny(it["amount"])
-		paid := floatFromAny(it["paid_amount"])
-		if !approxEqual(paid, amt) {
-			return false
-		}
-	}
-	return true
+	okData(w, map[string]any{"id": paymentID, "receipt_number": receipt})
 }
 
 func approxEqual(a, b float64) bool {
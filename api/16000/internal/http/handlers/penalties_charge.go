@@ -6,6 +6,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/itmtjewelry/land-booking-kpr/internal/ids"
 	"github.com/itmtjewelry/land-booking-kpr/internal/storage"
 )
 
@@ -27,9 +28,6 @@ func PenaltiesCharge(deps Stage8Deps, w http.ResponseWriter, r *http.Request) {
 		methodNotAllowed(w)
 		return
 	}
-	if !requireAdminQuick(w, r) {
-		return
-	}
 
 	var req penaltyChargeReq
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -69,8 +67,7 @@ func PenaltiesCharge(deps Stage8Deps, w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Load plan by KPR
-	plans := deps.GetItems("installment_plans.json")
-	_, plan := findPlanMapByKPR(plans, req.KPRID)
+	_, plan := findPlanMapByKPR(deps, req.KPRID)
 	if plan == nil {
 		errJSON(w, http.StatusBadRequest, "installment plan not found")
 		return
@@ -128,12 +125,12 @@ func PenaltiesCharge(deps Stage8Deps, w http.ResponseWriter, r *http.Request) {
 	lockPay.Lock()
 	defer lockPay.Unlock()
 
-	payJF := mustLoadPlanFile(deps, "payments.json")
+	payJF := mustLoadJSONFile(deps, "payments.json")
 
 	// Duplicate prevention: same kpr_id + installment_no + bucket
-	for _, raw := range payJF.Items {
+	for _, pid := range deps.PaymentsByKPR(req.KPRID) {
 		var m map[string]any
-		if err := json.Unmarshal(raw, &m); err != nil {
+		if err := json.Unmarshal(payJF.Items[pid], &m); err != nil {
 			continue
 		}
 		if str(m["type"]) != "penalty" {
@@ -152,7 +149,7 @@ func PenaltiesCharge(deps Stage8Deps, w http.ResponseWriter, r *http.Request) {
 	}
 
 	now := time.Now().UTC()
-	id := "penalty_" + now.Format("20060102T150405.000000000Z")
+	id := ids.New("penalty")
 
 	method := strings.TrimSpace(req.Method)
 	if method == "" {
@@ -188,7 +185,11 @@ func PenaltiesCharge(deps Stage8Deps, w http.ResponseWriter, r *http.Request) {
 	payJF.Meta["updated_at"] = now.Format(time.RFC3339)
 
 	if err := storage.WriteJSONFileAtomic(deps.StorageDir(), "payments.json", payJF); err != nil {
-		errJSON(w, http.StatusInternalServerError, "write failed: "+err.Error())
+		writeFailed(w, err, "write failed: "+err.Error())
+		return
+	}
+	if err := deps.ReloadFiles("payments.json"); err != nil {
+		errJSON(w, http.StatusInternalServerError, "reload failed")
 		return
 	}
 
@@ -16,9 +16,6 @@ func PenaltiesPreview(deps Stage8Deps) http.HandlerFunc {
 			methodNotAllowed(w)
 			return
 		}
-		if !requireAdminQuick(w, r) {
-			return
-		}
 
 		kprID := strings.TrimSpace(r.URL.Query().Get("kpr_id"))
 		if kprID == "" {
@@ -46,8 +43,7 @@ func PenaltiesPreview(deps Stage8Deps) http.HandlerFunc {
 			return
 		}
 
-		plans := deps.GetItems("installment_plans.json")
-		_, plan := findPlanMapByKPR(plans, kprID)
+		_, plan := findPlanMapByKPR(deps, kprID)
 		if plan == nil {
 			errJSON(w, http.StatusBadRequest, "installment plan not found")
 			return
@@ -1,11 +1,14 @@
 package handlers
 
 import (
+	"math"
 	"net/http"
-	"os"
 	"sort"
 	"strings"
 	"time"
+
+	"github.com/itmtjewelry/land-booking-kpr/internal/auth"
+	"github.com/itmtjewelry/land-booking-kpr/internal/storage"
 )
 
 func ReportKPRStatement(deps Stage8Deps) http.HandlerFunc {
@@ -25,7 +28,7 @@ func ReportKPRStatement(deps Stage8Deps) http.HandlerFunc {
 			return
 		}
 
-		isAdmin := adminTokenOK(r)
+		isAdmin := auth.IsAdmin(r)
 
 		// Load required files (in-memory)
 		kprs := deps.GetItems("kpr_applications.json")
@@ -62,8 +65,7 @@ func ReportKPRStatement(deps Stage8Deps) http.HandlerFunc {
 		zone := getItemMap(deps.GetItems("zones.json"), zoneID)
 
 		// Plan (find by kpr_id)
-		plans := deps.GetItems("installment_plans.json")
-		planID, plan := findPlanMapByKPR(plans, kprID)
+		planID, plan := findPlanMapByKPR(deps, kprID)
 		if plan == nil {
 			errJSON(w, http.StatusBadRequest, "installment plan not found for kpr")
 			return
@@ -71,8 +73,7 @@ func ReportKPRStatement(deps Stage8Deps) http.HandlerFunc {
 		_ = planID
 
 		// Payments
-		pays := deps.GetItems("payments.json")
-		payList := filterPaymentsForKPR(pays, kprID)
+		payList := filterPaymentsForKPR(deps, kprID)
 
 		// Customer (guest-safe)
 		customer := map[string]any{}
@@ -111,6 +112,13 @@ func ReportKPRStatement(deps Stage8Deps) http.HandlerFunc {
 			price["loan_amount"] = loanAmount
 			price["tenor_months"] = tenor
 			price["monthly_amount"] = monthly
+			price["formula"] = str(plan["formula"])
+			price["interest_rate"] = floatFromAny(plan["interest_rate"])
+			if tiers, ok := plan["rate_tiers"]; ok {
+				price["rate_tiers"] = tiers
+				price["reference_rate"] = floatFromAny(plan["reference_rate"])
+			}
+			price["schedule_version"] = max(intFromAny(plan["schedule_version"]), 1)
 		}
 
 		// Schedule + progress
@@ -162,11 +170,13 @@ func ReportKPRStatement(deps Stage8Deps) http.HandlerFunc {
 		}
 		instTotal := len(schedule)
 		instPaidCount := 0
-		principalPaid := 0.0
+		principalPaid, interestPaid := 0.0, 0.0
 		for _, it := range schedule {
 			amt := floatFromAny(it["amount"])
 			paid := floatFromAny(it["paid_amount"])
-			principalPaid += paid
+			pp := paidPrincipal(it, paid)
+			principalPaid += pp
+			interestPaid += paid - pp
 			if approxEqual(paid, amt) {
 				instPaidCount++
 			}
@@ -195,6 +205,7 @@ func ReportKPRStatement(deps Stage8Deps) http.HandlerFunc {
 			"installments_paid_count": instPaidCount,
 			"installments_total":      instTotal,
 			"principal_paid":          principalPaid,
+			"interest_paid":           interestPaid,
 			"principal_remaining":     principalRemaining,
 			"overall_status":          overall,
 		}
@@ -241,9 +252,6 @@ func ReportZoneSummary(deps Stage8Deps) http.HandlerFunc {
 			methodNotAllowed(w)
 			return
 		}
-		if !requireAdminQuick(w, r) {
-			return
-		}
 
 		zoneID := strings.TrimSpace(r.URL.Query().Get("zone_id"))
 		if zoneID == "" {
@@ -253,7 +261,6 @@ func ReportZoneSummary(deps Stage8Deps) http.HandlerFunc {
 
 		bookings := deps.GetItems("bookings.json")
 		kprs := deps.GetItems("kpr_applications.json")
-		plans := deps.GetItems("installment_plans.json")
 
 		bookingCount := 0
 		confirmedCount := 0
@@ -262,39 +269,32 @@ func ReportZoneSummary(deps Stage8Deps) http.HandlerFunc {
 		principalPaid := 0.0
 		principalRemaining := 0.0
 
-		for _, bAny := range bookings {
-			b, ok := bAny.(map[string]any)
+		for _, bookingID := range deps.BookingsByZone(zoneID) {
+			b, ok := bookings[bookingID].(map[string]any)
 			if !ok {
 				continue
 			}
-			if str(b["zone_id"]) != zoneID {
-				continue
-			}
 			bookingCount++
-			if str(b["status"]) == "confirmed" {
+			if str(b["status"]) == bookingStatusApproved {
 				confirmedCount++
 			}
 
-			// find KPR for this booking
-			bookingID := str(b["id"])
-			for _, kAny := range kprs {
-				k, ok := kAny.(map[string]any)
+			// KPRs of this booking
+			for _, kprID := range deps.KPRsByBooking(bookingID) {
+				k, ok := kprs[kprID].(map[string]any)
 				if !ok {
 					continue
 				}
-				if str(k["booking_id"]) != bookingID {
-					continue
-				}
 				pm, _ := k["price"].(map[string]any)
 				dpCollected += floatFromAny(pm["dp_paid"])
 				loan := floatFromAny(pm["loan_amount"])
 
-				_, plan := findPlanMapByKPR(plans, str(k["id"]))
+				_, plan := findPlanMapByKPR(deps, kprID)
 				if plan != nil {
 					sched := normalizeSchedule(plan["schedule"])
 					paid := 0.0
 					for _, it := range sched {
-						paid += floatFromAny(it["paid_amount"])
+						paid += paidPrincipal(it, floatFromAny(it["paid_amount"]))
 					}
 					principalPaid += paid
 					rem := loan - paid
@@ -337,13 +337,9 @@ func ReportPortfolio(deps Stage8Deps) http.HandlerFunc {
 			methodNotAllowed(w)
 			return
 		}
-		if !requireAdminQuick(w, r) {
-			return
-		}
 
 		bookings := deps.GetItems("bookings.json")
 		kprs := deps.GetItems("kpr_applications.json")
-		plans := deps.GetItems("installment_plans.json")
 
 		bookingByStatus := map[string]int{}
 		for _, bAny := range bookings {
@@ -359,7 +355,7 @@ func ReportPortfolio(deps Stage8Deps) http.HandlerFunc {
 		principalPaid := 0.0
 		principalRemaining := 0.0
 
-		for _, kAny := range kprs {
+		for kprID, kAny := range kprs {
 			k, ok := kAny.(map[string]any)
 			if !ok {
 				continue
@@ -370,12 +366,12 @@ func ReportPortfolio(deps Stage8Deps) http.HandlerFunc {
 			dpCollected += floatFromAny(pm["dp_paid"])
 			loan := floatFromAny(pm["loan_amount"])
 
-			_, plan := findPlanMapByKPR(plans, str(k["id"]))
+			_, plan := findPlanMapByKPR(deps, kprID)
 			if plan != nil {
 				sched := normalizeSchedule(plan["schedule"])
 				paid := 0.0
 				for _, it := range sched {
-					paid += floatFromAny(it["paid_amount"])
+					paid += paidPrincipal(it, floatFromAny(it["paid_amount"]))
 				}
 				principalPaid += paid
 				rem := loan - paid
@@ -407,26 +403,6 @@ func ReportPortfolio(deps Stage8Deps) http.HandlerFunc {
 
 /* ---------------- helpers ---------------- */
 
-func adminTokenOK(r *http.Request) bool {
-	token := strings.TrimSpace(r.Header.Get("X-Admin-Token"))
-	if token == "" {
-		return false
-	}
-	secret := strings.TrimSpace(os.Getenv("ADMIN_TOKEN"))
-	if secret == "" {
-		return false
-	}
-	return token == secret
-}
-
-func requireAdminQuick(w http.ResponseWriter, r *http.Request) bool {
-	if !adminTokenOK(r) {
-		errJSON(w, http.StatusUnauthorized, "admin token required")
-		return false
-	}
-	return true
-}
-
 func getItemMap(items map[string]any, id string) map[string]any {
 	if id == "" {
 		return nil
@@ -449,19 +425,32 @@ func pickIDName(m map[string]any) map[string]any {
 	}
 }
 
-func findPlanMapByKPR(plans map[string]any, kprID string) (string, map[string]any) {
-	for id, v := range plans {
-		m, ok := v.(map[string]any)
-		if !ok {
-			continue
-		}
-		if str(m["kpr_id"]) == kprID {
+func findPlanMapByKPR(deps Stage8Deps, kprID string) (string, map[string]any) {
+	plans := deps.GetItems("installment_plans.json")
+	for _, id := range deps.PlansByKPR(kprID) {
+		if m, ok := plans[id].(map[string]any); ok {
 			return id, m
 		}
 	}
 	return "", nil
 }
 
+// paidPrincipal is the principal part of paid on a schedule line. Payments
+// settle a line's interest first; lines without a principal/interest split
+// are all principal.
+func paidPrincipal(it map[string]any, paid float64) float64 {
+	if _, ok := it["principal"]; !ok {
+		return paid
+	}
+	pp := paid - floatFromAny(it["interest"])
+	if pp < 0 {
+		return 0
+	}
+	return math.Min(pp, floatFromAny(it["principal"]))
+}
+
+// normalizeSchedule returns copies of the schedule items (GetItems data is a shared
+// snapshot and must not be mutated) with paid_amount/status defaulted, sorted by no.
 func normalizeSchedule(v any) []map[string]any {
 	raw, ok := v.([]any)
 	if !ok || len(raw) == 0 {
@@ -469,10 +458,11 @@ func normalizeSchedule(v any) []map[string]any {
 	}
 	out := make([]map[string]any, 0, len(raw))
 	for _, it := range raw {
-		m, ok := it.(map[string]any)
+		src, ok := it.(map[string]any)
 		if !ok {
 			continue
 		}
+		m := storage.CloneMap(src)
 		// ensure missing fields default
 		if _, ok := m["paid_amount"]; !ok {
 			m["paid_amount"] = 0
@@ -496,14 +486,12 @@ func normalizeSchedule(v any) []map[string]any {
 	return out
 }
 
-func filterPaymentsForKPR(items map[string]any, kprID string) []map[string]any {
-	out := make([]map[string]any, 0, 32)
-	for _, v := range items {
-		m, ok := v.(map[string]any)
-		if !ok {
-			continue
-		}
-		if str(m["kpr_id"]) == kprID {
+func filterPaymentsForKPR(deps Stage8Deps, kprID string) []map[string]any {
+	items := deps.GetItems("payments.json")
+	ids := deps.PaymentsByKPR(kprID)
+	out := make([]map[string]any, 0, len(ids))
+	for _, id := range ids {
+		if m, ok := items[id].(map[string]any); ok {
 			out = append(out, m)
 		}
 	}
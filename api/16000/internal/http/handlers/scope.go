@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/itmtjewelry/land-booking-kpr/internal/auth"
+)
+
+// SiteScopeHeader lets ADMIN pick a site explicitly ("*" = all sites),
+// overriding the site mapped from the Host header.
+const SiteScopeHeader = "X-Site-Scope"
+
+type siteScopeKey struct{}
+
+// WithSiteScope attaches the site a request is limited to. Empty siteID means unscoped.
+func WithSiteScope(ctx context.Context, siteID string) context.Context {
+	return context.WithValue(ctx, siteScopeKey{}, siteID)
+}
+
+// SiteScopeFrom returns the scoped site_id, if any.
+func SiteScopeFrom(ctx context.Context) (string, bool) {
+	s, _ := ctx.Value(siteScopeKey{}).(string)
+	return s, s != ""
+}
+
+// ResolveSiteScope decides which site a request is limited to.
+//
+// ADMIN may override with X-Site-Scope. Otherwise the Host header is resolved
+// through domains.json; a host with no mapping (and no default) stays unscoped.
+func ResolveSiteScope(deps Stage7Deps, r *http.Request) string {
+	if v := strings.TrimSpace(r.Header.Get(SiteScopeHeader)); v != "" && auth.IsAdmin(r) {
+		if v == "*" {
+			return ""
+		}
+		return v
+	}
+	m, ok := ResolveDomain(deps.GetItems("domains.json"), r.Host)
+	if !ok {
+		return ""
+	}
+	return m.SiteID
+}
+
+func siteInScope(r *http.Request, siteID string) bool {
+	scope, ok := SiteScopeFrom(r.Context())
+	return !ok || scope == siteID
+}
+
+func subsiteInScope(deps Stage7Deps, r *http.Request, subsiteID string) bool {
+	if _, ok := SiteScopeFrom(r.Context()); !ok {
+		return true
+	}
+	sub := getItemMap(deps.GetItems("subsites.json"), subsiteID)
+	return sub != nil && siteInScope(r, str(sub["site_id"]))
+}
+
+func zoneInScope(deps Stage7Deps, r *http.Request, zoneID string) bool {
+	if _, ok := SiteScopeFrom(r.Context()); !ok {
+		return true
+	}
+	zone := getItemMap(deps.GetItems("zones.json"), zoneID)
+	return zone != nil && subsiteInScope(deps, r, str(zone["subsite_id"]))
+}
@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/itmtjewelry/land-booking-kpr/internal/auth"
+)
+
+func TestResolveSiteScope(t *testing.T) {
+	seed := locationSeed()
+	seed["domains.json"] = map[string]any{
+		"d1": map[string]any{"id": "d1", "domain": "satu.example.com", "site_id": "s1", "status": "ACTIVE"},
+	}
+	d := newTestDeps(t, seed)
+	t.Setenv("ADMIN_TOKEN", "ops-secret")
+
+	for _, tc := range []struct {
+		name    string
+		p       auth.Principal
+		host    string
+		headers map[string]string
+		want    string
+	}{
+		{"host mapping", guestP, "satu.example.com", nil, "s1"},
+		{"unmapped host", guestP, "other.example.com", nil, ""},
+		{"guest override ignored", guestP, "satu.example.com", map[string]string{SiteScopeHeader: "s2"}, "s1"},
+		{"staff override ignored", managerP, "satu.example.com", map[string]string{SiteScopeHeader: "*"}, "s1"},
+		{"admin override", adminP, "satu.example.com", map[string]string{SiteScopeHeader: "s2"}, "s2"},
+		{"admin all sites", adminP, "satu.example.com", map[string]string{SiteScopeHeader: "*"}, ""},
+		{"admin without override", adminP, "satu.example.com", nil, "s1"},
+		{"ops token override", guestP, "satu.example.com", map[string]string{SiteScopeHeader: "s2", auth.AdminHeader: "ops-secret"}, "s2"},
+		{"wrong ops token", guestP, "satu.example.com", map[string]string{SiteScopeHeader: "s2", auth.AdminHeader: "guess"}, "s1"},
+	} {
+		r := httptest.NewRequest("GET", "/api/v1/zones", nil)
+		r.Host = tc.host
+		for k, v := range tc.headers {
+			r.Header.Set(k, v)
+		}
+		r = r.WithContext(auth.WithPrincipal(r.Context(), tc.p))
+		if got := ResolveSiteScope(d, r); got != tc.want {
+			t.Errorf("%s: scope = %q, want %q", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestScopeHidesOtherSites(t *testing.T) {
+	d := newTestDeps(t, locationSeed())
+	scoped := map[string]string{testScopeHeader: "s1"}
+
+	for _, tc := range []struct {
+		name   string
+		h      http.HandlerFunc
+		target string
+		want   int
+	}{
+		{"subsites of own site", SubsitesHandler(d), "/api/v1/subsites?site_id=s1", http.StatusOK},
+		{"subsites of other site", SubsitesHandler(d), "/api/v1/subsites?site_id=s2", http.StatusNotFound},
+		{"zones of own subsite", ZonesHandler(d), "/api/v1/zones?subsite_id=ss1", http.StatusOK},
+		{"zones of other subsite", ZonesHandler(d), "/api/v1/zones?subsite_id=ss2", http.StatusNotFound},
+		{"zones of unknown subsite", ZonesHandler(d), "/api/v1/zones?subsite_id=nope", http.StatusNotFound},
+		{"bookings of own zone", BookingsHandler(d), "/api/v1/bookings?zone_id=z1", http.StatusOK},
+		{"bookings of other zone", BookingsHandler(d), "/api/v1/bookings?zone_id=z3", http.StatusNotFound},
+		{"availability of other zone", AvailabilityHandler(d), "/api/v1/availability?zone_id=z3&from=2026-11-01&to=2026-11-30", http.StatusNotFound},
+	} {
+		if res := call(t, tc.h, guestP, "GET", tc.target, nil, scoped); res.Code != tc.want {
+			t.Errorf("%s: %d, want %d (%s)", tc.name, res.Code, tc.want, res.Raw)
+		}
+	}
+
+	// Unscoped requests see every site.
+	if res := call(t, ZonesHandler(d), guestP, "GET", "/api/v1/zones?subsite_id=ss2", nil, nil); res.Code != http.StatusOK {
+		t.Fatalf("unscoped zones of ss2: %d", res.Code)
+	}
+}
+
+func TestInScope(t *testing.T) {
+	d := newTestDeps(t, locationSeed())
+	r := httptest.NewRequest("GET", "/", nil)
+	if !zoneInScope(d, r, "z3") || !subsiteInScope(d, r, "ss2") || !siteInScope(r, "s2") {
+		t.Fatal("unscoped request refused")
+	}
+
+	r = r.WithContext(WithSiteScope(r.Context(), "s1"))
+	for _, tc := range []struct {
+		name string
+		got  bool
+		want bool
+	}{
+		{"site s1", siteInScope(r, "s1"), true},
+		{"site s2", siteInScope(r, "s2"), false},
+		{"subsite ss1", subsiteInScope(d, r, "ss1"), true},
+		{"subsite ss2", subsiteInScope(d, r, "ss2"), false},
+		{"unknown subsite", subsiteInScope(d, r, "nope"), false},
+		{"zone z1", zoneInScope(d, r, "z1"), true},
+		{"zone z3", zoneInScope(d, r, "z3"), false},
+		{"unknown zone", zoneInScope(d, r, "nope"), false},
+	} {
+		if tc.got != tc.want {
+			t.Errorf("%s: got %v, want %v", tc.name, tc.got, tc.want)
+		}
+	}
+}
+
+// A disabled domain stops scoping requests to its site.
+func TestResolveSiteScopeInactiveDomain(t *testing.T) {
+	seed := locationSeed()
+	seed["domains.json"] = map[string]any{
+		"d1": map[string]any{"id": "d1", "domain": "satu.example.com", "site_id": "s1", "status": "INACTIVE"},
+		"d2": map[string]any{"id": "d2", "domain": "dua.example.com", "site_id": "s2", "is_default": true},
+	}
+	d := newTestDeps(t, seed)
+
+	r := httptest.NewRequest("GET", "/api/v1/zones", nil)
+	r.Host = "satu.example.com"
+	r = r.WithContext(auth.WithPrincipal(r.Context(), guestP))
+	if got := ResolveSiteScope(d, r); got != "s2" {
+		t.Fatalf("scope = %q, want the default site s2", got)
+	}
+}
@@ -2,7 +2,8 @@ package handlers
 
 import "net/http"
 
-// SitesHandler serves GET /api/v1/sites
+// SitesHandler serves GET /api/v1/sites (limited to the Host-scoped site, if any).
+// Archived sites are listed only for admins with ?include_archived=true.
 func SitesHandler(deps Stage7Deps) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodGet {
@@ -16,7 +17,13 @@ func SitesHandler(deps Stage7Deps) http.HandlerFunc {
 
 		items := deps.GetItems("sites.json")
 		data := ItemsToSlice(items)
+		if scope, ok := SiteScopeFrom(r.Context()); ok {
+			data = FilterByStringField(data, "id", scope)
+		}
+		if !includeArchived(r) {
+			data = withoutArchived(data)
+		}
 
-		WriteJSON(w, http.StatusOK, Envelope{OK: true, Data: data})
+		WriteJSONCached(w, r, Envelope{OK: true, Data: data})
 	}
 }
@@ -5,7 +5,7 @@ import (
 	"net/http"
 	"strings"
 
-	"github.com/itmtjewelry/land-booking-kpr/internal/auth"
+	"github.com/itmtjewelry/land-booking-kpr/internal/ids"
 	"github.com/itmtjewelry/land-booking-kpr/internal/storage"
 )
 
@@ -19,9 +19,6 @@ func SitesWriteCollection(deps Stage8Deps, w http.ResponseWriter, r *http.Reques
 		errJSON(w, http.StatusServiceUnavailable, "storage not ready")
 		return
 	}
-	if !auth.RequireAdmin(w, r) {
-		return
-	}
 	if r.Method != http.MethodPost {
 		methodNotAllowed(w)
 		return
@@ -39,7 +36,7 @@ func SitesWriteCollection(deps Stage8Deps, w http.ResponseWriter, r *http.Reques
 		return
 	}
 	if strings.TrimSpace(p.ID) == "" {
-		p.ID = genID("site")
+		p.ID = ids.New("site")
 	}
 
 	filename := "sites.json"
@@ -47,20 +44,7 @@ func SitesWriteCollection(deps Stage8Deps, w http.ResponseWriter, r *http.Reques
 	mu.Lock()
 	defer mu.Unlock()
 
-	loaded := deps.Loaded()
-	jf, ok := loaded[filename]
-	if !ok {
-		jf = storage.JSONFile{
-			Meta:  map[string]any{"version": 1, "updated_at": nil},
-			Items: map[string]json.RawMessage{},
-		}
-	}
-	if jf.Meta == nil {
-		jf.Meta = map[string]any{"version": 1, "updated_at": nil}
-	}
-	if jf.Items == nil {
-		jf.Items = make(map[string]json.RawMessage)
-	}
+	jf := mustLoadJSONFile(deps, filename)
 
 	if _, exists := jf.Items[p.ID]; exists {
 		errJSON(w, http.StatusConflict, "id already exists")
@@ -71,14 +55,15 @@ func SitesWriteCollection(deps Stage8Deps, w http.ResponseWriter, r *http.Reques
 	jf.Items[p.ID] = raw
 
 	if err := storage.WriteJSONFileAtomic(deps.StorageDir(), filename, jf); err != nil {
-		errJSON(w, http.StatusInternalServerError, "write failed")
+		writeFailed(w, err, "write failed")
 		return
 	}
-	if err := deps.ReloadCore(); err != nil {
+	if err := deps.ReloadFiles(filename); err != nil {
 		errJSON(w, http.StatusInternalServerError, "reload failed")
 		return
 	}
 
+	setRecordETag(w, deps, filename, p.ID)
 	okData(w, map[string]any{"id": p.ID})
 }
 
@@ -87,9 +72,6 @@ func SitesWriteByID(deps Stage8Deps, id string, w http.ResponseWriter, r *http.R
 		errJSON(w, http.StatusServiceUnavailable, "storage not ready")
 		return
 	}
-	if !auth.RequireAdmin(w, r) {
-		return
-	}
 
 	id = strings.TrimSpace(id)
 	if id == "" {
@@ -98,24 +80,19 @@ func SitesWriteByID(deps Stage8Deps, id string, w http.ResponseWriter, r *http.R
 	}
 
 	filename := "sites.json"
+	// A delete checks usage and domain mappings: lock order bookings.json ->
+	// kpr_applications.json -> domains.json -> sites.json.
+	if r.Method == http.MethodDelete {
+		defer lockUsage(deps)()
+		lockDom := deps.LockForFile("domains.json")
+		lockDom.Lock()
+		defer lockDom.Unlock()
+	}
 	mu := deps.LockForFile(filename)
 	mu.Lock()
 	defer mu.Unlock()
 
-	loaded := deps.Loaded()
-	jf, ok := loaded[filename]
-	if !ok {
-		jf = storage.JSONFile{
-			Meta:  map[string]any{"version": 1, "updated_at": nil},
-			Items: map[string]json.RawMessage{},
-		}
-	}
-	if jf.Meta == nil {
-		jf.Meta = map[string]any{"version": 1, "updated_at": nil}
-	}
-	if jf.Items == nil {
-		jf.Items = make(map[string]json.RawMessage)
-	}
+	jf := mustLoadJSONFile(deps, filename)
 
 	switch r.Method {
 	case http.MethodPut:
@@ -123,6 +100,9 @@ func SitesWriteByID(deps Stage8Deps, id string, w http.ResponseWriter, r *http.R
 			errJSON(w, http.StatusBadRequest, "id not found")
 			return
 		}
+		if !requireIfMatch(w, r, jf.Items[id]) {
+			return
+		}
 
 		var p sitePayload
 		if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
@@ -135,17 +115,20 @@ func SitesWriteByID(deps Stage8Deps, id string, w http.ResponseWriter, r *http.R
 			return
 		}
 
-		raw, _ := json.Marshal(map[string]any{"id": id, "name": p.Name})
+		obj := map[string]any{"id": id, "name": p.Name}
+		keepArchived(jf.Items[id], obj)
+		raw, _ := json.Marshal(obj)
 		jf.Items[id] = raw
 
 		if err := storage.WriteJSONFileAtomic(deps.StorageDir(), filename, jf); err != nil {
-			errJSON(w, http.StatusInternalServerError, "write failed")
+			writeFailed(w, err, "write failed")
 			return
 		}
-		if err := deps.ReloadCore(); err != nil {
+		if err := deps.ReloadFiles(filename); err != nil {
 			errJSON(w, http.StatusInternalServerError, "reload failed")
 			return
 		}
+		setRecordETag(w, deps, filename, id)
 		okData(w, map[string]any{"id": id})
 		return
 
@@ -162,18 +145,31 @@ func SitesWriteByID(deps Stage8Deps, id string, w http.ResponseWriter, r *http.R
 				return
 			}
 		}
+		if usageOf(deps, siteLocation, id).referenced() {
+			errJSON(w, http.StatusConflict, "site is referenced by bookings or KPRs; archive it instead")
+			return
+		}
+		for _, v := range deps.GetItems("domains.json") {
+			if m, ok := v.(map[string]any); ok && str(m["site_id"]) == id {
+				errJSON(w, http.StatusConflict, "site is mapped by a domain; remove the mapping first")
+				return
+			}
+		}
 
 		if _, exists := jf.Items[id]; !exists {
 			okData(w, map[string]any{"deleted": false})
 			return
 		}
+		if !checkIfMatch(w, r, jf.Items[id]) {
+			return
+		}
 		delete(jf.Items, id)
 
 		if err := storage.WriteJSONFileAtomic(deps.StorageDir(), filename, jf); err != nil {
-			errJSON(w, http.StatusInternalServerError, "write failed")
+			writeFailed(w, err, "write failed")
 			return
 		}
-		if err := deps.ReloadCore(); err != nil {
+		if err := deps.ReloadFiles(filename); err != nil {
 			errJSON(w, http.StatusInternalServerError, "reload failed")
 			return
 		}
@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/itmtjewelry/land-booking-kpr/internal/migrate"
+	"github.com/itmtjewelry/land-booking-kpr/internal/storage"
+)
+
+// Whole-storage snapshots (ADMIN only via the permission matrix).
+
+// maxSnapshotUpload caps the compressed archive accepted by import.
+const maxSnapshotUpload = 256 << 20
+
+// AdminSnapshotExport serves GET /api/v1/admin/snapshot as a tar.gz download.
+func AdminSnapshotExport(deps Stage8Deps, w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w)
+		return
+	}
+	if !deps.StorageReady() {
+		errJSON(w, http.StatusServiceUnavailable, "storage not ready")
+		return
+	}
+
+	// Exclusive: waits for in-flight writes so all files are from one state.
+	// The archive is built in memory so the lock is not held while sending.
+	lock, err := storage.LockDir(deps.StorageDir(), true)
+	if err != nil {
+		errJSON(w, http.StatusInternalServerError, "storage lock failed: "+err.Error())
+		return
+	}
+	var buf bytes.Buffer
+	_, err = storage.ExportSnapshot(deps.StorageDir(), &buf)
+	_ = lock.Unlock()
+	if err != nil {
+		errJSON(w, http.StatusInternalServerError, "export failed: "+err.Error())
+		return
+	}
+
+	name := "storage-snapshot-" + time.Now().UTC().Format("20060102_150405") + ".tar.gz"
+	w.Header().Set("Content-Type", "application/gzip")
+	w.Header().Set("Content-Disposition", `attachment; filename="`+name+`"`)
+	w.Header().Set("Content-Length", strconv.Itoa(buf.Len()))
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(buf.Bytes())
+}
+
+// AdminSnapshotImport serves POST /api/v1/admin/snapshot/import[?dry_run=true]
+// with a tar.gz body. A dry run only reports the per-file record diff.
+func AdminSnapshotImport(deps Stage8Deps, w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w)
+		return
+	}
+	if !deps.StorageReady() {
+		errJSON(w, http.StatusServiceUnavailable, "storage not ready")
+		return
+	}
+	dryRun, _ := strconv.ParseBool(r.URL.Query().Get("dry_run"))
+
+	snap, err := storage.ReadSnapshot(http.MaxBytesReader(w, r.Body, maxSnapshotUpload))
+	if err != nil {
+		errJSON(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	// Refuse snapshots this binary cannot serve before touching anything.
+	pending, err := migrate.Run(deps.StorageDir(), snap.Files, true)
+	if err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, migrate.ErrNewerVersion) {
+			status = http.StatusConflict
+		}
+		errJSON(w, status, "snapshot rejected: "+err.Error())
+		return
+	}
+
+	lock, err := storage.LockDir(deps.StorageDir(), true)
+	if err != nil {
+		errJSON(w, http.StatusInternalServerError, "storage lock failed: "+err.Error())
+		return
+	}
+	defer func() { _ = lock.Unlock() }()
+
+	diff, err := snap.Diff(deps.StorageDir())
+	if err != nil {
+		errJSON(w, http.StatusInternalServerError, "diff failed: "+err.Error())
+		return
+	}
+	if dryRun {
+		okData(w, map[string]any{"dry_run": true, "files": diff, "migrations": pending.Files})
+		return
+	}
+
+	if err := snap.Apply(deps.StorageDir()); err != nil {
+		errJSON(w, http.StatusInternalServerError, "import failed: "+err.Error())
+		return
+	}
+	loaded, err := storage.LoadFiles(deps.StorageDir(), snap.Names()...)
+	if err != nil {
+		errJSON(w, http.StatusInternalServerError, "imported but reload failed: "+err.Error())
+		return
+	}
+	rep, err := migrate.Run(deps.StorageDir(), loaded, false)
+	if err != nil {
+		errJSON(w, http.StatusInternalServerError, "imported but migration failed: "+err.Error())
+		return
+	}
+	if err := deps.ReloadCore(); err != nil {
+		errJSON(w, http.StatusInternalServerError, "reload failed")
+		return
+	}
+	okData(w, map[string]any{"dry_run": false, "files": diff, "migrated": rep.Files})
+}
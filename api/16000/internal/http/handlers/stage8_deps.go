@@ -3,14 +3,40 @@ package handlers
 import (
 	"sync"
 
+	"github.com/itmtjewelry/land-booking-kpr/internal/auth"
+	"github.com/itmtjewelry/land-booking-kpr/internal/domain"
 	"github.com/itmtjewelry/land-booking-kpr/internal/storage"
 )
 
 type Stage8Deps interface {
 	Stage7Deps
+	// Typed, read-only view of the core files (see internal/domain).
+	domain.Repository
+	domain.Indexes
+	domain.SupportRepository
 
 	StorageDir() string
 	Loaded() map[string]storage.JSONFile
-	LockForFile(filename string) *sync.Mutex
+	// LockForFile returns the cross-process lock of a storage file (the same
+	// lock for the same name). Holding it, the file's snapshot is current.
+	LockForFile(filename string) sync.Locker
 	ReloadCore() error
+	// ReloadFiles re-reads only the named files and swaps them into the snapshot.
+	ReloadFiles(filenames ...string) error
+	// SetStorageNotReady stops serving storage after a failure that needs
+	// recovery (a committed transaction that could not be applied).
+	SetStorageNotReady(err error)
+
+	// BackupRetention is the policy used by the backup pruner.
+	BackupRetention() storage.RetentionPolicy
+
+	// SimulateRateLimit is the per-IP limit of the public KPR calculator,
+	// in requests per minute (0 disables it).
+	SimulateRateLimit() int
+
+	// DSRPolicy is the affordability limit checked when a KPR is approved.
+	DSRPolicy() domain.DSRPolicy
+
+	// Sessions returns the login session store.
+	Sessions() *auth.SessionStore
 }
@@ -2,7 +2,8 @@ package handlers
 
 import "net/http"
 
-// SubsitesHandler serves GET /api/v1/subsites?site_id=...
+// SubsitesHandler serves GET /api/v1/subsites?site_id=...[&include_archived=true]
+// An archived site has no public subsites.
 func SubsitesHandler(deps Stage7Deps) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodGet {
@@ -15,15 +16,26 @@ func SubsitesHandler(deps Stage7Deps) http.HandlerFunc {
 		}
 
 		siteID := r.URL.Query().Get("site_id")
+		if siteID == "" {
+			// Host-scoped requests may omit site_id.
+			siteID, _ = SiteScopeFrom(r.Context())
+		}
 		if siteID == "" {
 			BadRequest(w, "missing_site_id", "missing required query param: site_id")
 			return
 		}
+		all := includeArchived(r)
+		if !siteInScope(r, siteID) || (!all && siteArchived(deps, siteID)) {
+			NotFound(w, "site_not_found", "site not found")
+			return
+		}
 
 		items := deps.GetItems("subsites.json")
-		all := ItemsToSlice(items)
-		data := FilterByStringField(all, "site_id", siteID)
+		data := FilterByStringField(ItemsToSlice(items), "site_id", siteID)
+		if !all {
+			data = withoutArchived(data)
+		}
 
-		WriteJSON(w, http.StatusOK, Envelope{OK: true, Data: data})
+		WriteJSONCached(w, r, Envelope{OK: true, Data: data})
 	}
 }
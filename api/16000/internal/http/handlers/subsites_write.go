@@ -5,7 +5,7 @@ import (
 	"net/http"
 	"strings"
 
-	"github.com/itmtjewelry/land-booking-kpr/internal/auth"
+	"github.com/itmtjewelry/land-booking-kpr/internal/ids"
 	"github.com/itmtjewelry/land-booking-kpr/internal/storage"
 )
 
@@ -20,9 +20,6 @@ func SubsitesWriteCollection(deps Stage8Deps, w http.ResponseWriter, r *http.Req
 		errJSON(w, http.StatusServiceUnavailable, "storage not ready")
 		return
 	}
-	if !auth.RequireAdmin(w, r) {
-		return
-	}
 	if r.Method != http.MethodPost {
 		methodNotAllowed(w)
 		return
@@ -51,9 +48,13 @@ func SubsitesWriteCollection(deps Stage8Deps, w http.ResponseWriter, r *http.Req
 		errJSON(w, http.StatusBadRequest, "site_id not found")
 		return
 	}
+	if siteArchived(deps, p.SiteID) {
+		errJSON(w, http.StatusConflict, "site is archived")
+		return
+	}
 
 	if strings.TrimSpace(p.ID) == "" {
-		p.ID = genID("subsite")
+		p.ID = ids.New("subsite")
 	}
 
 	filename := "subsites.json"
@@ -61,20 +62,7 @@ func SubsitesWriteCollection(deps Stage8Deps, w http.ResponseWriter, r *http.Req
 	mu.Lock()
 	defer mu.Unlock()
 
-	loaded := deps.Loaded()
-	jf, ok := loaded[filename]
-	if !ok {
-		jf = storage.JSONFile{
-			Meta:  map[string]any{"version": 1, "updated_at": nil},
-			Items: map[string]json.RawMessage{},
-		}
-	}
-	if jf.Meta == nil {
-		jf.Meta = map[string]any{"version": 1, "updated_at": nil}
-	}
-	if jf.Items == nil {
-		jf.Items = make(map[string]json.RawMessage)
-	}
+	jf := mustLoadJSONFile(deps, filename)
 
 	if _, exists := jf.Items[p.ID]; exists {
 		errJSON(w, http.StatusConflict, "id already exists")
@@ -89,14 +77,15 @@ func SubsitesWriteCollection(deps Stage8Deps, w http.ResponseWriter, r *http.Req
 	jf.Items[p.ID] = raw
 
 	if err := storage.WriteJSONFileAtomic(deps.StorageDir(), filename, jf); err != nil {
-		errJSON(w, http.StatusInternalServerError, "write failed")
+		writeFailed(w, err, "write failed")
 		return
 	}
-	if err := deps.ReloadCore(); err != nil {
+	if err := deps.ReloadFiles(filename); err != nil {
 		errJSON(w, http.StatusInternalServerError, "reload failed")
 		return
 	}
 
+	setRecordETag(w, deps, filename, p.ID)
 	okData(w, map[string]any{"id": p.ID})
 }
 
@@ -105,9 +94,6 @@ func SubsitesWriteByID(deps Stage8Deps, id string, w http.ResponseWriter, r *htt
 		errJSON(w, http.StatusServiceUnavailable, "storage not ready")
 		return
 	}
-	if !auth.RequireAdmin(w, r) {
-		return
-	}
 
 	id = strings.TrimSpace(id)
 	if id == "" {
@@ -116,24 +102,16 @@ func SubsitesWriteByID(deps Stage8Deps, id string, w http.ResponseWriter, r *htt
 	}
 
 	filename := "subsites.json"
+	// A delete checks usage: lock order bookings.json -> kpr_applications.json
+	// -> subsites.json, as when archiving.
+	if r.Method == http.MethodDelete {
+		defer lockUsage(deps)()
+	}
 	mu := deps.LockForFile(filename)
 	mu.Lock()
 	defer mu.Unlock()
 
-	loaded := deps.Loaded()
-	jf, ok := loaded[filename]
-	if !ok {
-		jf = storage.JSONFile{
-			Meta:  map[string]any{"version": 1, "updated_at": nil},
-			Items: map[string]json.RawMessage{},
-		}
-	}
-	if jf.Meta == nil {
-		jf.Meta = map[string]any{"version": 1, "updated_at": nil}
-	}
-	if jf.Items == nil {
-		jf.Items = make(map[string]json.RawMessage)
-	}
+	jf := mustLoadJSONFile(deps, filename)
 
 	switch r.Method {
 	case http.MethodPut:
@@ -141,6 +119,9 @@ func SubsitesWriteByID(deps Stage8Deps, id string, w http.ResponseWriter, r *htt
 			errJSON(w, http.StatusBadRequest, "id not found")
 			return
 		}
+		if !requireIfMatch(w, r, jf.Items[id]) {
+			return
+		}
 
 		var p subsitePayload
 		if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
@@ -164,22 +145,29 @@ func SubsitesWriteByID(deps Stage8Deps, id string, w http.ResponseWriter, r *htt
 			errJSON(w, http.StatusBadRequest, "site_id not found")
 			return
 		}
+		if p.SiteID != str(getItemMap(deps.GetItems("subsites.json"), id)["site_id"]) && siteArchived(deps, p.SiteID) {
+			errJSON(w, http.StatusConflict, "site is archived")
+			return
+		}
 
-		raw, _ := json.Marshal(map[string]any{
+		obj := map[string]any{
 			"id":      id,
 			"site_id": p.SiteID,
 			"name":    p.Name,
-		})
+		}
+		keepArchived(jf.Items[id], obj)
+		raw, _ := json.Marshal(obj)
 		jf.Items[id] = raw
 
 		if err := storage.WriteJSONFileAtomic(deps.StorageDir(), filename, jf); err != nil {
-			errJSON(w, http.StatusInternalServerError, "write failed")
+			writeFailed(w, err, "write failed")
 			return
 		}
-		if err := deps.ReloadCore(); err != nil {
+		if err := deps.ReloadFiles(filename); err != nil {
 			errJSON(w, http.StatusInternalServerError, "reload failed")
 			return
 		}
+		setRecordETag(w, deps, filename, id)
 		okData(w, map[string]any{"id": id})
 		return
 
@@ -195,18 +183,25 @@ func SubsitesWriteByID(deps Stage8Deps, id string, w http.ResponseWriter, r *htt
 				return
 			}
 		}
+		if usageOf(deps, subsiteLocation, id).referenced() {
+			errJSON(w, http.StatusConflict, "subsite is referenced by bookings or KPRs; archive it instead")
+			return
+		}
 
 		if _, exists := jf.Items[id]; !exists {
 			okData(w, map[string]any{"deleted": false})
 			return
 		}
+		if !checkIfMatch(w, r, jf.Items[id]) {
+			return
+		}
 		delete(jf.Items, id)
 
 		if err := storage.WriteJSONFileAtomic(deps.StorageDir(), filename, jf); err != nil {
-			errJSON(w, http.StatusInternalServerError, "write failed")
+			writeFailed(w, err, "write failed")
 			return
 		}
-		if err := deps.ReloadCore(); err != nil {
+		if err := deps.ReloadFiles(filename); err != nil {
 			errJSON(w, http.StatusInternalServerError, "reload failed")
 			return
 		}
@@ -0,0 +1,438 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/itmtjewelry/land-booking-kpr/internal/auth"
+	"github.com/itmtjewelry/land-booking-kpr/internal/domain"
+	"github.com/itmtjewelry/land-booking-kpr/internal/ids"
+	"github.com/itmtjewelry/land-booking-kpr/internal/storage"
+)
+
+// Support tickets (blueprint section 9) live in support/tickets.json. The
+// module only reads core files to validate links; it takes no core lock and
+// the core never reads tickets, so a missing or broken tickets file leaves
+// the rest of the API untouched.
+//
+// ADMIN and SALES_MANAGER see and manage every ticket; CUSTOMER_SALES only
+// the tickets they opened.
+
+const (
+	maxTicketSubject = 200
+	maxTicketMessage = 5000
+)
+
+// ticketTransitions lists the statuses each status may move to. CLOSED is final.
+var ticketTransitions = map[string][]string{
+	domain.TicketOpen:       {domain.TicketInProgress, domain.TicketResolved, domain.TicketClosed},
+	domain.TicketInProgress: {domain.TicketOpen, domain.TicketResolved, domain.TicketClosed},
+	domain.TicketResolved:   {domain.TicketOpen, domain.TicketClosed},
+}
+
+func validTicketTransition(from, to string) bool {
+	for _, s := range ticketTransitions[from] {
+		if s == to {
+			return true
+		}
+	}
+	return false
+}
+
+func isStaffRequest(r *http.Request) bool {
+	role := auth.PrincipalFrom(r.Context()).Role
+	return role == auth.RoleAdmin || role == auth.RoleSalesManager
+}
+
+// ticketVisible reports whether the caller may see t: staff see every ticket
+// in the site scope, others only their own.
+func ticketVisible(r *http.Request, t domain.Ticket) bool {
+	if t.SiteID != "" && !siteInScope(r, t.SiteID) {
+		return false
+	}
+	return isStaffRequest(r) || t.CreatedByUserID == auth.PrincipalFrom(r.Context()).UserID
+}
+
+type ticketSummary struct {
+	ID              string `json:"id"`
+	Rev             int    `json:"rev"`
+	Subject         string `json:"subject"`
+	Status          string `json:"status"`
+	BookingID       string `json:"booking_id,omitempty"`
+	ZoneID          string `json:"zone_id,omitempty"`
+	SiteID          string `json:"site_id,omitempty"`
+	MessageCount    int    `json:"message_count"`
+	CreatedByUserID string `json:"created_by_user_id"`
+	CreatedAt       string `json:"created_at,omitempty"`
+	UpdatedAt       string `json:"updated_at,omitempty"`
+}
+
+// TicketsCollection serves GET and POST /api/v1/tickets.
+func TicketsCollection(deps Stage8Deps, w http.ResponseWriter, r *http.Request) {
+	if !deps.StorageReady() {
+		errJSON(w, http.StatusServiceUnavailable, "storage not ready")
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		ticketsList(deps, w, r)
+	case http.MethodPost:
+		ticketCreate(deps, w, r)
+	default:
+		methodNotAllowed(w)
+	}
+}
+
+// ticketsList filters on status, booking_id, zone_id and site_id; newest
+// activity first.
+func ticketsList(deps Stage8Deps, w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	status := strings.ToUpper(strings.TrimSpace(q.Get("status")))
+	bookingID := strings.TrimSpace(q.Get("booking_id"))
+	zoneID := strings.TrimSpace(q.Get("zone_id"))
+	siteID := strings.TrimSpace(q.Get("site_id"))
+
+	out := []ticketSummary{}
+	for _, t := range deps.Tickets() {
+		if !ticketVisible(r, t) {
+			continue
+		}
+		if (status != "" && t.Status != status) ||
+			(bookingID != "" && t.BookingID != bookingID) ||
+			(zoneID != "" && t.ZoneID != zoneID) ||
+			(siteID != "" && t.SiteID != siteID) {
+			continue
+		}
+		out = append(out, ticketSummary{
+			ID:              t.ID,
+			Rev:             int(t.Rev),
+			Subject:         t.Subject,
+			Status:          t.Status,
+			BookingID:       t.BookingID,
+			ZoneID:          t.ZoneID,
+			SiteID:          t.SiteID,
+			MessageCount:    len(t.Messages),
+			CreatedByUserID: t.CreatedByUserID,
+			CreatedAt:       t.CreatedAt,
+			UpdatedAt:       t.UpdatedAt,
+		})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].UpdatedAt != out[j].UpdatedAt {
+			return out[i].UpdatedAt > out[j].UpdatedAt
+		}
+		return out[i].ID < out[j].ID
+	})
+	okDataCached(w, r, out)
+}
+
+type ticketCreatePayload struct {
+	Subject   string `json:"subject"`
+	Message   string `json:"message"`
+	BookingID string `json:"booking_id"`
+	ZoneID    string `json:"zone_id"`
+	SiteID    string `json:"site_id"`
+}
+
+func ticketCreate(deps Stage8Deps, w http.ResponseWriter, r *http.Request) {
+	var p ticketCreatePayload
+	if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+		errJSON(w, http.StatusBadRequest, "invalid json")
+		return
+	}
+	p.Subject = strings.TrimSpace(p.Subject)
+	p.Message = strings.TrimSpace(p.Message)
+	switch {
+	case p.Subject == "":
+		errJSON(w, http.StatusBadRequest, "subject is required")
+		return
+	case len(p.Subject) > maxTicketSubject:
+		errJSON(w, http.StatusBadRequest, "subject is too long")
+		return
+	case p.Message == "":
+		errJSON(w, http.StatusBadRequest, "message is required")
+		return
+	case len(p.Message) > maxTicketMessage:
+		errJSON(w, http.StatusBadRequest, "message is too long")
+		return
+	}
+
+	t := domain.Ticket{
+		BookingID: strings.TrimSpace(p.BookingID),
+		ZoneID:    strings.TrimSpace(p.ZoneID),
+		SiteID:    strings.TrimSpace(p.SiteID),
+	}
+	if msg := resolveTicketLinks(deps, r, &t); msg != "" {
+		errJSON(w, http.StatusBadRequest, msg)
+		return
+	}
+
+	principal := auth.PrincipalFrom(r.Context())
+	now := time.Now().UTC().Format(time.RFC3339)
+	t.ID = ids.New("ticket")
+	t.Subject = p.Subject
+	t.Status = domain.TicketOpen
+	t.CreatedByUserID = principal.UserID
+	t.CreatedAt = now
+	t.UpdatedAt = now
+	t.Messages = []domain.TicketMessage{{
+		ID:           ids.New("msg"),
+		AuthorUserID: principal.UserID,
+		Body:         p.Message,
+		CreatedAt:    now,
+	}}
+
+	lock := deps.LockForFile(domain.TicketsFile)
+	lock.Lock()
+	defer lock.Unlock()
+
+	jf, ok := loadTickets(deps, w)
+	if !ok {
+		return
+	}
+	jf.Items[t.ID] = mustJSON(t)
+	if !writeTickets(deps, w, jf) {
+		return
+	}
+	setRecordETag(w, deps, domain.TicketsFile, t.ID)
+	okData(w, deps.Tickets()[t.ID])
+}
+
+// resolveTicketLinks checks the optional booking, zone and site links
+// against the core files and fills in the ones implied by the others (a
+// booking implies its zone and site, a zone its site). Non-staff may only
+// link their own bookings, and every link must be in the site scope. It
+// returns an error message, or "" when the links are valid.
+func resolveTicketLinks(deps Stage8Deps, r *http.Request, t *domain.Ticket) string {
+	if t.BookingID != "" {
+		b, ok := deps.Bookings()[t.BookingID]
+		if !ok || (!isStaffRequest(r) && b.RequestedByUserID != auth.PrincipalFrom(r.Context()).UserID) {
+			return "booking not found"
+		}
+		if t.ZoneID != "" && t.ZoneID != b.ZoneID {
+			return "zone_id does not match the booking"
+		}
+		if t.SiteID != "" && t.SiteID != b.SiteID {
+			return "site_id does not match the booking"
+		}
+		t.ZoneID, t.SiteID = b.ZoneID, b.SiteID
+	}
+	if t.ZoneID != "" {
+		z, ok := deps.Zones()[t.ZoneID]
+		if !ok || !zoneInScope(deps, r, t.ZoneID) {
+			return "zone not found"
+		}
+		siteID := deps.Subsites()[z.SubsiteID].SiteID
+		if t.SiteID != "" && t.SiteID != siteID {
+			return "site_id does not match the zone"
+		}
+		t.SiteID = siteID
+	}
+	if t.SiteID != "" {
+		if _, ok := deps.Sites()[t.SiteID]; !ok || !siteInScope(r, t.SiteID) {
+			return "site not found"
+		}
+	}
+	return ""
+}
+
+// TicketByID serves GET /api/v1/tickets/{id}.
+func TicketByID(deps Stage8Deps, id string, w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w)
+		return
+	}
+	if !deps.StorageReady() {
+		errJSON(w, http.StatusServiceUnavailable, "storage not ready")
+		return
+	}
+	t, ok := deps.Tickets()[id]
+	if !ok || !ticketVisible(r, t) {
+		errJSON(w, http.StatusNotFound, "ticket not found")
+		return
+	}
+	setRecordETag(w, deps, domain.TicketsFile, id)
+	okData(w, t)
+}
+
+// TicketMessageCreate serves POST /api/v1/tickets/{id}/messages. A message
+// from the ticket owner reopens a RESOLVED ticket; CLOSED tickets take no
+// more messages.
+func TicketMessageCreate(deps Stage8Deps, id string, w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w)
+		return
+	}
+	if !deps.StorageReady() {
+		errJSON(w, http.StatusServiceUnavailable, "storage not ready")
+		return
+	}
+	var p struct {
+		Body string `json:"body"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+		errJSON(w, http.StatusBadRequest, "invalid json")
+		return
+	}
+	p.Body = strings.TrimSpace(p.Body)
+	if p.Body == "" {
+		errJSON(w, http.StatusBadRequest, "body is required")
+		return
+	}
+	if len(p.Body) > maxTicketMessage {
+		errJSON(w, http.StatusBadRequest, "body is too long")
+		return
+	}
+
+	lock := deps.LockForFile(domain.TicketsFile)
+	lock.Lock()
+	defer lock.Unlock()
+
+	jf, ok := loadTickets(deps, w)
+	if !ok {
+		return
+	}
+	t, ok := decodeTicket(jf, id)
+	if !ok || !ticketVisible(r, t) {
+		errJSON(w, http.StatusNotFound, "ticket not found")
+		return
+	}
+	if !checkIfMatch(w, r, jf.Items[id]) {
+		return
+	}
+	if t.Status == domain.TicketClosed {
+		errJSON(w, http.StatusConflict, "ticket is closed")
+		return
+	}
+
+	principal := auth.PrincipalFrom(r.Context())
+	now := time.Now().UTC().Format(time.RFC3339)
+	msg := domain.TicketMessage{
+		ID:           ids.New("msg"),
+		AuthorUserID: principal.UserID,
+		Body:         p.Body,
+		CreatedAt:    now,
+	}
+	t.Messages = append(t.Messages, msg)
+	if t.Status == domain.TicketResolved && principal.UserID == t.CreatedByUserID {
+		t.Status = domain.TicketOpen
+		t.StatusChangedAt = now
+		t.StatusChangedBy = principal.UserID
+	}
+	t.UpdatedAt = now
+	jf.Items[id] = mustJSON(t)
+
+	if !writeTickets(deps, w, jf) {
+		return
+	}
+	setRecordETag(w, deps, domain.TicketsFile, id)
+	okData(w, map[string]any{"id": id, "status": t.Status, "message": msg})
+}
+
+// TicketStatusUpdate serves POST /api/v1/tickets/{id}/status. Staff may make
+// any transition; the ticket owner may only close it or reopen it once
+// resolved.
+func TicketStatusUpdate(deps Stage8Deps, id string, w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w)
+		return
+	}
+	if !deps.StorageReady() {
+		errJSON(w, http.StatusServiceUnavailable, "storage not ready")
+		return
+	}
+	var p struct {
+		Status string `json:"status"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+		errJSON(w, http.StatusBadRequest, "invalid json")
+		return
+	}
+	next := strings.ToUpper(strings.TrimSpace(p.Status))
+	switch next {
+	case domain.TicketOpen, domain.TicketInProgress, domain.TicketResolved, domain.TicketClosed:
+	default:
+		errJSON(w, http.StatusBadRequest, "status must be OPEN, IN_PROGRESS, RESOLVED or CLOSED")
+		return
+	}
+
+	lock := deps.LockForFile(domain.TicketsFile)
+	lock.Lock()
+	defer lock.Unlock()
+
+	jf, ok := loadTickets(deps, w)
+	if !ok {
+		return
+	}
+	t, ok := decodeTicket(jf, id)
+	if !ok || !ticketVisible(r, t) {
+		errJSON(w, http.StatusNotFound, "ticket not found")
+		return
+	}
+	if !requireIfMatch(w, r, jf.Items[id]) {
+		return
+	}
+	if !isStaffRequest(r) && next != domain.TicketClosed &&
+		(t.Status != domain.TicketResolved || next != domain.TicketOpen) {
+		Forbidden(w, "only staff may set this status")
+		return
+	}
+	if t.Status == next {
+		setRecordETag(w, deps, domain.TicketsFile, id)
+		okData(w, map[string]any{"id": id, "status": next})
+		return
+	}
+	if !validTicketTransition(t.Status, next) {
+		errJSON(w, http.StatusConflict, "cannot move "+t.Status+" ticket to "+next)
+		return
+	}
+
+	now := time.Now().UTC().Format(time.RFC3339)
+	t.Status = next
+	t.StatusChangedAt = now
+	t.StatusChangedBy = auth.PrincipalFrom(r.Context()).UserID
+	t.UpdatedAt = now
+	jf.Items[id] = mustJSON(t)
+
+	if !writeTickets(deps, w, jf) {
+		return
+	}
+	setRecordETag(w, deps, domain.TicketsFile, id)
+	okData(w, map[string]any{"id": id, "status": next})
+}
+
+// loadTickets reads the tickets file from disk; callers hold its lock. The
+// file may not exist yet, or may have been created by another process since
+// the snapshot was taken.
+func loadTickets(deps Stage8Deps, w http.ResponseWriter) (storage.JSONFile, bool) {
+	jf, err := storage.LoadOptionalFile(deps.StorageDir(), domain.TicketsFile)
+	if err != nil {
+		errJSON(w, http.StatusServiceUnavailable, "support storage unavailable")
+		return jf, false
+	}
+	return jf, true
+}
+
+func writeTickets(deps Stage8Deps, w http.ResponseWriter, jf storage.JSONFile) bool {
+	if err := storage.WriteJSONFileAtomic(deps.StorageDir(), domain.TicketsFile, jf); err != nil {
+		writeFailed(w, err, "write failed")
+		return false
+	}
+	if err := deps.ReloadFiles(domain.TicketsFile); err != nil {
+		errJSON(w, http.StatusInternalServerError, "reload failed")
+		return false
+	}
+	return true
+}
+
+func decodeTicket(jf storage.JSONFile, id string) (domain.Ticket, bool) {
+	var t domain.Ticket
+	raw, ok := jf.Items[id]
+	if !ok || json.Unmarshal(raw, &t) != nil {
+		return t, false
+	}
+	return t, true
+}
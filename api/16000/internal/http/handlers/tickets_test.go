@@ -0,0 +1,137 @@
+package handlers
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/itmtjewelry/land-booking-kpr/internal/auth"
+	"github.com/itmtjewelry/land-booking-kpr/internal/domain"
+)
+
+func ticketSeed() map[string]map[string]any {
+	seed := locationSeed()
+	seed["bookings.json"] = map[string]any{
+		"b1": map[string]any{"id": "b1", "site_id": "s1", "subsite_id": "ss1", "zone_id": "z1", "status": bookingStatusRequested, "requested_by_user_id": salesP.UserID},
+		"b2": map[string]any{"id": "b2", "site_id": "s1", "subsite_id": "ss1", "zone_id": "z2", "status": bookingStatusRequested, "requested_by_user_id": otherP.UserID},
+	}
+	seed[domain.TicketsFile] = map[string]any{}
+	return seed
+}
+
+func createTicket(t *testing.T, d *testDeps, p auth.Principal, body map[string]any, headers map[string]string) testResponse {
+	t.Helper()
+	if body["subject"] == nil {
+		body["subject"] = "Help"
+	}
+	if body["message"] == nil {
+		body["message"] = "Please call me"
+	}
+	return call(t, handler(d, TicketsCollection), p, "POST", "/api/v1/tickets", body, headers)
+}
+
+func TestTicketLinks(t *testing.T) {
+	d := newTestDeps(t, ticketSeed())
+
+	res := createTicket(t, d, salesP, map[string]any{"booking_id": "b1"}, nil)
+	if res.Code != http.StatusOK {
+		t.Fatalf("own booking: %d %s", res.Code, res.Raw)
+	}
+	if got := res.data(); got["zone_id"] != "z1" || got["site_id"] != "s1" {
+		t.Fatalf("links not derived from the booking: %v", got)
+	}
+
+	// Someone else's booking reads as missing for non-staff...
+	if res := createTicket(t, d, salesP, map[string]any{"booking_id": "b2"}, nil); res.Code != http.StatusBadRequest {
+		t.Fatalf("other's booking: %d %s", res.Code, res.Raw)
+	}
+	// ...but staff may link any booking.
+	if res := createTicket(t, d, managerP, map[string]any{"booking_id": "b2"}, nil); res.Code != http.StatusOK {
+		t.Fatalf("staff linking a booking: %d %s", res.Code, res.Raw)
+	}
+
+	// Links outside the site scope are refused.
+	scoped := map[string]string{testScopeHeader: "s1"}
+	if res := createTicket(t, d, salesP, map[string]any{"zone_id": "z3"}, scoped); res.Code != http.StatusBadRequest {
+		t.Fatalf("zone out of scope: %d %s", res.Code, res.Raw)
+	}
+	if res := createTicket(t, d, salesP, map[string]any{"site_id": "s2"}, scoped); res.Code != http.StatusBadRequest {
+		t.Fatalf("site out of scope: %d %s", res.Code, res.Raw)
+	}
+	if res := createTicket(t, d, salesP, map[string]any{"zone_id": "z2"}, scoped); res.Code != http.StatusOK || res.data()["site_id"] != "s1" {
+		t.Fatalf("zone in scope: %d %s", res.Code, res.Raw)
+	}
+}
+
+func TestTicketVisibility(t *testing.T) {
+	d := newTestDeps(t, ticketSeed())
+	mine := createTicket(t, d, salesP, map[string]any{}, nil).data()["id"].(string)
+	theirs := createTicket(t, d, otherP, map[string]any{}, nil).data()["id"].(string)
+
+	listed := func(p auth.Principal) map[string]bool {
+		return listIDs(call(t, handler(d, TicketsCollection), p, "GET", "/api/v1/tickets", nil, nil))
+	}
+	if got := listed(salesP); !got[mine] || got[theirs] {
+		t.Fatalf("CUSTOMER_SALES list: %v", got)
+	}
+	if got := listed(managerP); !got[mine] || !got[theirs] {
+		t.Fatalf("SALES_MANAGER list: %v", got)
+	}
+	if res := call(t, byID(d, TicketByID, theirs), salesP, "GET", "/", nil, nil); res.Code != http.StatusNotFound {
+		t.Fatalf("other's ticket: %d", res.Code)
+	}
+	if res := call(t, byID(d, TicketMessageCreate, theirs), salesP, "POST", "/", map[string]any{"body": "hi"}, nil); res.Code != http.StatusNotFound {
+		t.Fatalf("message on other's ticket: %d", res.Code)
+	}
+	if res := call(t, byID(d, TicketByID, theirs), adminP, "GET", "/", nil, nil); res.Code != http.StatusOK {
+		t.Fatalf("admin reading a ticket: %d", res.Code)
+	}
+}
+
+func TestTicketStatus(t *testing.T) {
+	d := newTestDeps(t, ticketSeed())
+	id := createTicket(t, d, salesP, map[string]any{}, nil).data()["id"].(string)
+	setStatus := func(p auth.Principal, status string) testResponse {
+		return call(t, byID(d, TicketStatusUpdate, id), p, "POST", "/", map[string]any{"status": status}, anyIfMatch)
+	}
+	status := func() string { return d.Tickets()[id].Status }
+
+	if res := setStatus(salesP, domain.TicketInProgress); res.Code != http.StatusForbidden || res.errCode() != "forbidden" {
+		t.Fatalf("owner setting IN_PROGRESS: %d %s", res.Code, res.Raw)
+	}
+	if res := setStatus(managerP, domain.TicketInProgress); res.Code != http.StatusOK || status() != domain.TicketInProgress {
+		t.Fatalf("staff setting IN_PROGRESS: %d %s", res.Code, res.Raw)
+	}
+	if res := setStatus(managerP, domain.TicketResolved); res.Code != http.StatusOK {
+		t.Fatalf("staff resolving: %d", res.Code)
+	}
+
+	// A staff message leaves it RESOLVED; the owner's reopens it.
+	if res := call(t, byID(d, TicketMessageCreate, id), managerP, "POST", "/", map[string]any{"body": "done"}, nil); res.Code != http.StatusOK || status() != domain.TicketResolved {
+		t.Fatalf("staff message: %d %s", res.Code, status())
+	}
+	if res := call(t, byID(d, TicketMessageCreate, id), salesP, "POST", "/", map[string]any{"body": "still broken"}, nil); res.Code != http.StatusOK || status() != domain.TicketOpen {
+		t.Fatalf("owner message on RESOLVED: %d %s", res.Code, status())
+	}
+	if tk := d.Tickets()[id]; len(tk.Messages) != 3 || tk.StatusChangedBy != salesP.UserID {
+		t.Fatalf("thread/status change not recorded: %+v", tk)
+	}
+
+	// The owner may also reopen a RESOLVED ticket explicitly, and close it.
+	if res := setStatus(managerP, domain.TicketResolved); res.Code != http.StatusOK {
+		t.Fatalf("resolve again: %d", res.Code)
+	}
+	if res := setStatus(salesP, domain.TicketOpen); res.Code != http.StatusOK || status() != domain.TicketOpen {
+		t.Fatalf("owner reopening: %d %s", res.Code, res.Raw)
+	}
+	if res := setStatus(salesP, domain.TicketClosed); res.Code != http.StatusOK {
+		t.Fatalf("owner closing: %d", res.Code)
+	}
+
+	// CLOSED is final.
+	if res := setStatus(managerP, domain.TicketOpen); res.Code != http.StatusConflict {
+		t.Fatalf("reopening CLOSED: %d", res.Code)
+	}
+	if res := call(t, byID(d, TicketMessageCreate, id), salesP, "POST", "/", map[string]any{"body": "?"}, nil); res.Code != http.StatusConflict {
+		t.Fatalf("message on CLOSED: %d", res.Code)
+	}
+}
@@ -1,11 +1,11 @@
 package handlers
 
 import (
-	"crypto/sha1"
-	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"net/http"
-	"time"
+
+	"github.com/itmtjewelry/land-booking-kpr/internal/storage"
 )
 
 func writeJSON(w http.ResponseWriter, status int, payload any) {
@@ -26,8 +26,29 @@ func methodNotAllowed(w http.ResponseWriter) {
 	errJSON(w, http.StatusMethodNotAllowed, "method not allowed")
 }
 
-func genID(prefix string) string {
-	now := time.Now().UTC().Format("20060102T150405.000000000Z")
-	h := sha1.Sum([]byte(now))
-	return prefix + "_" + now + "_" + hex.EncodeToString(h[:6])
+// writeFailed answers a failed write: 503 when the file's storage lock could
+// not be taken (storage is then not ready), 500 msg otherwise.
+func writeFailed(w http.ResponseWriter, err error, msg string) {
+	if errors.Is(err, storage.ErrLockNotHeld) {
+		errJSON(w, http.StatusServiceUnavailable, "storage lock unavailable")
+		return
+	}
+	errJSON(w, http.StatusInternalServerError, msg)
+}
+
+// commitTx commits tx and answers 500 msg when that fails. A transaction that
+// passed its commit point but could not be applied may have moved some files
+// into place: those are reloaded and storage goes not-ready until recovery.
+func commitTx(deps Stage8Deps, w http.ResponseWriter, tx *storage.Tx, msg string) bool {
+	err := tx.Commit()
+	if err == nil {
+		return true
+	}
+	if errors.Is(err, storage.ErrTxNotApplied) {
+		_ = deps.ReloadFiles(tx.Files()...)
+		deps.SetStorageNotReady(err)
+		msg = "write committed but not applied; storage needs recovery"
+	}
+	writeFailed(w, err, msg)
+	return false
 }
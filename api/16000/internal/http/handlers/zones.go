@@ -1,8 +1,13 @@
 package handlers
 
-import "net/http"
+import (
+	"net/http"
 
-// ZonesHandler serves GET /api/v1/zones?subsite_id=...
+	"github.com/itmtjewelry/land-booking-kpr/internal/domain"
+)
+
+// ZonesHandler serves GET /api/v1/zones?subsite_id=...[&include_archived=true]
+// A subsite that is archived, or whose site is, has no public zones.
 func ZonesHandler(deps Stage7Deps) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodGet {
@@ -19,11 +24,23 @@ func ZonesHandler(deps Stage7Deps) http.HandlerFunc {
 			BadRequest(w, "missing_subsite_id", "missing required query param: subsite_id")
 			return
 		}
+		all := includeArchived(r)
+		if !subsiteInScope(deps, r, subsiteID) || (!all && subsiteArchived(deps, subsiteID)) {
+			NotFound(w, "subsite_not_found", "subsite not found")
+			return
+		}
 
 		items := deps.GetItems("zones.json")
-		all := ItemsToSlice(items)
-		data := FilterByStringField(all, "subsite_id", subsiteID)
+		var data []map[string]any
+		if ix, ok := deps.(domain.Indexes); ok {
+			data = ItemsByIDs(items, ix.ZonesBySubsite(subsiteID))
+		} else {
+			data = FilterByStringField(ItemsToSlice(items), "subsite_id", subsiteID)
+		}
+		if !all {
+			data = withoutArchived(data)
+		}
 
-		WriteJSON(w, http.StatusOK, Envelope{OK: true, Data: data})
+		WriteJSONCached(w, r, Envelope{OK: true, Data: data})
 	}
 }
@@ -0,0 +1,140 @@
+package handlers
+
+import (
+	"math"
+	"strings"
+)
+
+// Zone geometry (blueprint section 6): NORMALIZED coordinates (0..1)
+// relative to the subsite layout image.
+
+type zonePoint struct {
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+}
+
+type zoneGeometry struct {
+	Type   string      `json:"type"`
+	Points []zonePoint `json:"points"`
+}
+
+const geometryEpsilon = 1e-9
+
+// normalizeGeometry validates a polygon and returns it in canonical form:
+// type POLYGON, open ring (a repeated closing vertex is dropped).
+func normalizeGeometry(g zoneGeometry) (zoneGeometry, error) {
+	g.Type = strings.ToUpper(strings.TrimSpace(g.Type))
+	if g.Type == "" {
+		g.Type = "POLYGON"
+	}
+	if g.Type != "POLYGON" {
+		return g, errBad("geometry.type must be POLYGON")
+	}
+
+	for _, p := range g.Points {
+		if math.IsNaN(p.X) || math.IsNaN(p.Y) || p.X < 0 || p.X > 1 || p.Y < 0 || p.Y > 1 {
+			return g, errBad("geometry.points must be normalized (0..1)")
+		}
+	}
+
+	pts := append([]zonePoint(nil), g.Points...)
+	// Closed-consistent: accept either an open ring or one closed by repeating the first vertex.
+	if len(pts) >= 2 && samePoint(pts[0], pts[len(pts)-1]) {
+		pts = pts[:len(pts)-1]
+	}
+	if len(pts) < 3 {
+		return g, errBad("geometry.points needs at least 3 distinct vertices")
+	}
+	for i := range pts {
+		if samePoint(pts[i], pts[(i+1)%len(pts)]) {
+			return g, errBad("geometry.points has repeated consecutive vertices")
+		}
+	}
+	for i := 0; i < len(pts); i++ {
+		for j := i + 1; j < len(pts); j++ {
+			if samePoint(pts[i], pts[j]) {
+				return g, errBad("geometry.points repeats a vertex")
+			}
+		}
+	}
+	if polygonSelfIntersects(pts) {
+		return g, errBad("geometry polygon must not self-intersect")
+	}
+	if math.Abs(polygonArea(pts)) <= geometryEpsilon {
+		return g, errBad("geometry polygon has zero area")
+	}
+
+	g.Points = pts
+	return g, nil
+}
+
+func samePoint(a, b zonePoint) bool {
+	return math.Abs(a.X-b.X) <= geometryEpsilon && math.Abs(a.Y-b.Y) <= geometryEpsilon
+}
+
+// polygonArea returns the signed shoelace area of an open ring.
+func polygonArea(pts []zonePoint) float64 {
+	a := 0.0
+	for i := range pts {
+		j := (i + 1) % len(pts)
+		a += pts[i].X*pts[j].Y - pts[j].X*pts[i].Y
+	}
+	return a / 2
+}
+
+// polygonSelfIntersects checks every pair of non-adjacent edges.
+func polygonSelfIntersects(pts []zonePoint) bool {
+	n := len(pts)
+	for i := 0; i < n; i++ {
+		a1, a2 := pts[i], pts[(i+1)%n]
+		for j := i + 1; j < n; j++ {
+			// adjacent edges share a vertex by construction
+			if j == i+1 || (i == 0 && j == n-1) {
+				continue
+			}
+			b1, b2 := pts[j], pts[(j+1)%n]
+			if segmentsIntersect(a1, a2, b1, b2) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func segmentsIntersect(p1, p2, q1, q2 zonePoint) bool {
+	d1 := orientation(q1, q2, p1)
+	d2 := orientation(q1, q2, p2)
+	d3 := orientation(p1, p2, q1)
+	d4 := orientation(p1, p2, q2)
+
+	if d1*d2 < 0 && d3*d4 < 0 {
+		return true
+	}
+	// collinear / touching cases
+	if d1 == 0 && onSegment(q1, q2, p1) {
+		return true
+	}
+	if d2 == 0 && onSegment(q1, q2, p2) {
+		return true
+	}
+	if d3 == 0 && onSegment(p1, p2, q1) {
+		return true
+	}
+	if d4 == 0 && onSegment(p1, p2, q2) {
+		return true
+	}
+	return false
+}
+
+func orientation(a, b, c zonePoint) float64 {
+	v := (b.X-a.X)*(c.Y-a.Y) - (b.Y-a.Y)*(c.X-a.X)
+	if math.Abs(v) <= geometryEpsilon {
+		return 0
+	}
+	return v
+}
+
+func onSegment(a, b, p zonePoint) bool {
+	return p.X >= math.Min(a.X, b.X)-geometryEpsilon && p.X <= math.Max(a.X, b.X)+geometryEpsilon &&
+		p.Y >= math.Min(a.Y, b.Y)-geometryEpsilon && p.Y <= math.Max(a.Y, b.Y)+geometryEpsilon
+}
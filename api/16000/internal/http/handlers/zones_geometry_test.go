@@ -0,0 +1,35 @@
+package handlers
+
+import "testing"
+
+func pts(xy ...float64) []zonePoint {
+	out := make([]zonePoint, 0, len(xy)/2)
+	for i := 0; i+1 < len(xy); i += 2 {
+		out = append(out, zonePoint{X: xy[i], Y: xy[i+1]})
+	}
+	return out
+}
+
+func TestNormalizeGeometry(t *testing.T) {
+	g, err := normalizeGeometry(zoneGeometry{Points: pts(0.1, 0.1, 0.4, 0.1, 0.4, 0.3, 0.1, 0.3, 0.1, 0.1)})
+	if err != nil {
+		t.Fatalf("closed square: %v", err)
+	}
+	if g.Type != "POLYGON" || len(g.Points) != 4 {
+		t.Fatalf("expected open ring of 4 POLYGON points, got %s/%d", g.Type, len(g.Points))
+	}
+
+	bad := map[string]zoneGeometry{
+		"out of range":   {Points: pts(0, 0, 1.2, 0, 1, 1)},
+		"too few":        {Points: pts(0, 0, 1, 1, 0, 0)},
+		"collinear":      {Points: pts(0, 0, 0.5, 0.5, 1, 1)},
+		"bow tie":        {Points: pts(0, 0, 1, 1, 1, 0, 0, 1)},
+		"wrong type":     {Type: "circle", Points: pts(0, 0, 1, 0, 1, 1)},
+		"repeated point": {Points: pts(0, 0, 1, 0, 1, 0, 0, 1)},
+	}
+	for name, g := range bad {
+		if _, err := normalizeGeometry(g); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
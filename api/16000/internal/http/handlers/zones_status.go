@@ -0,0 +1,195 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/itmtjewelry/land-booking-kpr/internal/auth"
+	"github.com/itmtjewelry/land-booking-kpr/internal/storage"
+)
+
+// Zone status state machine (blueprint section 7).
+const (
+	zoneStatusAvailable    = "AVAILABLE"
+	zoneStatusBooked       = "BOOKED"
+	zoneStatusSold         = "SOLD"
+	zoneStatusNotAvailable = "NOT_AVAILABLE"
+)
+
+// zoneTransitions lists every allowed transition. Anything else is forbidden,
+// including AVAILABLE→SOLD, NOT_AVAILABLE→BOOKED and NOT_AVAILABLE→SOLD.
+var zoneTransitions = map[string][]string{
+	zoneStatusAvailable:    {zoneStatusBooked, zoneStatusNotAvailable},
+	zoneStatusBooked:       {zoneStatusSold, zoneStatusAvailable},
+	zoneStatusNotAvailable: {zoneStatusAvailable},
+	zoneStatusSold:         {},
+}
+
+func validZoneTransition(cur, next string) bool {
+	for _, s := range zoneTransitions[cur] {
+		if s == next {
+			return true
+		}
+	}
+	return false
+}
+
+// zoneStatus returns the stored status; zones written before the state machine are AVAILABLE.
+func zoneStatus(z map[string]any) string {
+	st := strings.ToUpper(str(z["status"]))
+	if st == "" {
+		return zoneStatusAvailable
+	}
+	return st
+}
+
+// applyZoneStatus moves zone to next and records who/when. It does not validate the transition.
+func applyZoneStatus(z map[string]any, next, userID, reason string, now time.Time) {
+	prev := zoneStatus(z)
+	ts := now.UTC().Format(time.RFC3339)
+
+	z["status"] = next
+	z["status_updated_at"] = ts
+	z["status_updated_by_user_id"] = userID
+
+	switch next {
+	case zoneStatusNotAvailable:
+		z["unavailable_reason"] = reason
+		z["unavailable_since"] = ts
+		z["unavailable_by_user_id"] = userID
+	case zoneStatusSold:
+		z["sold_at"] = ts
+		z["sold_by_user_id"] = userID
+	}
+	if prev == zoneStatusNotAvailable && next != zoneStatusNotAvailable {
+		delete(z, "unavailable_reason")
+		delete(z, "unavailable_since")
+		delete(z, "unavailable_by_user_id")
+	}
+
+	entry := map[string]any{
+		"from":       prev,
+		"to":         next,
+		"at":         ts,
+		"by_user_id": userID,
+	}
+	if reason != "" {
+		entry["reason"] = reason
+	}
+	hist, _ := z["status_history"].([]any)
+	z["status_history"] = append(hist, entry)
+}
+
+type zoneStatusPayload struct {
+	Reason string `json:"reason"`
+}
+
+// ZoneMarkSold serves POST /api/v1/zones/{id}/mark-sold (BOOKED → SOLD).
+func ZoneMarkSold(deps Stage8Deps, id string, w http.ResponseWriter, r *http.Request) {
+	zoneStatusAction(deps, id, w, r, zoneStatusBooked, zoneStatusSold)
+}
+
+// ZoneMarkUnavailable serves POST /api/v1/zones/{id}/mark-unavailable (AVAILABLE → NOT_AVAILABLE).
+func ZoneMarkUnavailable(deps Stage8Deps, id string, w http.ResponseWriter, r *http.Request) {
+	zoneStatusAction(deps, id, w, r, zoneStatusAvailable, zoneStatusNotAvailable)
+}
+
+// ZoneMarkAvailable serves POST /api/v1/zones/{id}/mark-available (NOT_AVAILABLE → AVAILABLE).
+// BOOKED → AVAILABLE only happens through cancelling the approved booking.
+func ZoneMarkAvailable(deps Stage8Deps, id string, w http.ResponseWriter, r *http.Request) {
+	zoneStatusAction(deps, id, w, r, zoneStatusNotAvailable, zoneStatusAvailable)
+}
+
+func zoneStatusAction(deps Stage8Deps, id string, w http.ResponseWriter, r *http.Request, from, to string) {
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w)
+		return
+	}
+	if !deps.StorageReady() {
+		errJSON(w, http.StatusServiceUnavailable, "storage not ready")
+		return
+	}
+
+	id = strings.TrimSpace(id)
+	if id == "" || strings.Contains(id, "/") {
+		errJSON(w, http.StatusBadRequest, "invalid id")
+		return
+	}
+
+	var p zoneStatusPayload
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+			errJSON(w, http.StatusBadRequest, "invalid json")
+			return
+		}
+	}
+	p.Reason = strings.TrimSpace(p.Reason)
+	if to == zoneStatusNotAvailable && p.Reason == "" {
+		errJSON(w, http.StatusBadRequest, "reason is required")
+		return
+	}
+
+	// Lock order: bookings.json -> zones.json (same as booking approve/cancel).
+	lockBook := deps.LockForFile("bookings.json")
+	lockZone := deps.LockForFile("zones.json")
+	lockBook.Lock()
+	defer lockBook.Unlock()
+	lockZone.Lock()
+	defer lockZone.Unlock()
+
+	jf := mustLoadJSONFile(deps, "zones.json")
+	raw, ok := jf.Items[id]
+	if !ok {
+		errJSON(w, http.StatusNotFound, "zone not found")
+		return
+	}
+	if !requireIfMatch(w, r, raw) {
+		return
+	}
+	var zone map[string]any
+	if err := json.Unmarshal(raw, &zone); err != nil {
+		errJSON(w, http.StatusInternalServerError, "invalid stored zone")
+		return
+	}
+
+	cur := zoneStatus(zone)
+	if cur != from || !validZoneTransition(cur, to) {
+		errJSON(w, http.StatusConflict, "invalid zone status transition from "+cur+" to "+to)
+		return
+	}
+	if to == zoneStatusNotAvailable && zoneHasApprovedBooking(deps, id) {
+		errJSON(w, http.StatusConflict, "zone has an approved booking")
+		return
+	}
+
+	principal := auth.PrincipalFrom(r.Context())
+	applyZoneStatus(zone, to, principal.UserID, p.Reason, time.Now())
+	jf.Items[id] = mustJSON(zone)
+
+	if err := storage.WriteJSONFileAtomic(deps.StorageDir(), "zones.json", jf); err != nil {
+		writeFailed(w, err, "write failed")
+		return
+	}
+	if err := deps.ReloadFiles("zones.json"); err != nil {
+		errJSON(w, http.StatusInternalServerError, "reload failed")
+		return
+	}
+	setRecordETag(w, deps, "zones.json", id)
+	okData(w, map[string]any{"id": id, "status": to})
+}
+
+func zoneHasApprovedBooking(deps Stage8Deps, zoneID string) bool {
+	items := deps.GetItems("bookings.json")
+	for _, id := range deps.BookingsByZone(zoneID) {
+		m, ok := items[id].(map[string]any)
+		if !ok {
+			continue
+		}
+		if str(m["zone_id"]) == zoneID && str(m["status"]) == bookingStatusApproved {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,128 @@
+package handlers
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestValidZoneTransition(t *testing.T) {
+	allowed := map[[2]string]bool{
+		{zoneStatusAvailable, zoneStatusBooked}:       true,
+		{zoneStatusAvailable, zoneStatusNotAvailable}: true,
+		{zoneStatusBooked, zoneStatusSold}:            true,
+		{zoneStatusBooked, zoneStatusAvailable}:       true,
+		{zoneStatusNotAvailable, zoneStatusAvailable}: true,
+	}
+	all := []string{zoneStatusAvailable, zoneStatusBooked, zoneStatusSold, zoneStatusNotAvailable}
+	for _, from := range all {
+		for _, to := range all {
+			if got, want := validZoneTransition(from, to), allowed[[2]string{from, to}]; got != want {
+				t.Errorf("%s -> %s: got %v, want %v", from, to, got, want)
+			}
+		}
+	}
+}
+
+func TestApplyZoneStatus(t *testing.T) {
+	z := map[string]any{"id": "z1"} // no status: treated as AVAILABLE
+	t0 := time.Date(2026, 10, 1, 8, 0, 0, 0, time.UTC)
+
+	applyZoneStatus(z, zoneStatusNotAvailable, "u1", "flooded", t0)
+	if z["status"] != zoneStatusNotAvailable || z["unavailable_reason"] != "flooded" ||
+		z["unavailable_since"] != "2026-10-01T08:00:00Z" || z["unavailable_by_user_id"] != "u1" {
+		t.Fatalf("NOT_AVAILABLE metadata: %v", z)
+	}
+
+	applyZoneStatus(z, zoneStatusAvailable, "u2", "", t0.Add(time.Hour))
+	for _, k := range []string{"unavailable_reason", "unavailable_since", "unavailable_by_user_id"} {
+		if _, ok := z[k]; ok {
+			t.Errorf("%s not cleared", k)
+		}
+	}
+	if z["status_updated_by_user_id"] != "u2" || z["status_updated_at"] != "2026-10-01T09:00:00Z" {
+		t.Fatalf("status_updated_*: %v", z)
+	}
+
+	hist, _ := z["status_history"].([]any)
+	if len(hist) != 2 {
+		t.Fatalf("status_history = %v", z["status_history"])
+	}
+	first, second := hist[0].(map[string]any), hist[1].(map[string]any)
+	if first["from"] != zoneStatusAvailable || first["to"] != zoneStatusNotAvailable || first["reason"] != "flooded" || first["by_user_id"] != "u1" {
+		t.Fatalf("first entry: %v", first)
+	}
+	if second["from"] != zoneStatusNotAvailable || second["to"] != zoneStatusAvailable || second["reason"] != nil {
+		t.Fatalf("second entry: %v", second)
+	}
+}
+
+func TestZoneStatusActions(t *testing.T) {
+	seed := locationSeed()
+	seed["zones.json"]["z2"].(map[string]any)["status"] = zoneStatusBooked
+	seed["zones.json"]["z3"].(map[string]any)["status"] = zoneStatusSold
+	d := newTestDeps(t, seed)
+	post := func(f func(Stage8Deps, string, http.ResponseWriter, *http.Request), id string, body any) testResponse {
+		return call(t, byID(d, f, id), adminP, "POST", "/", body, anyIfMatch)
+	}
+	reason := map[string]any{"reason": "survey"}
+
+	for _, tc := range []struct {
+		name string
+		f    func(Stage8Deps, string, http.ResponseWriter, *http.Request)
+		id   string
+	}{
+		{"AVAILABLE -> SOLD", ZoneMarkSold, "z1"},
+		{"AVAILABLE -> AVAILABLE", ZoneMarkAvailable, "z1"},
+		{"BOOKED -> NOT_AVAILABLE", ZoneMarkUnavailable, "z2"},
+		{"BOOKED -> AVAILABLE", ZoneMarkAvailable, "z2"},
+		{"SOLD -> AVAILABLE", ZoneMarkAvailable, "z3"},
+		{"SOLD -> NOT_AVAILABLE", ZoneMarkUnavailable, "z3"},
+	} {
+		if res := post(tc.f, tc.id, reason); res.Code != http.StatusConflict {
+			t.Errorf("%s: %d %s", tc.name, res.Code, res.Raw)
+		}
+	}
+
+	if res := post(ZoneMarkUnavailable, "z1", nil); res.Code != http.StatusBadRequest {
+		t.Fatalf("mark unavailable without reason: %d", res.Code)
+	}
+	if res := post(ZoneMarkUnavailable, "z1", reason); res.Code != http.StatusOK {
+		t.Fatalf("AVAILABLE -> NOT_AVAILABLE: %d %s", res.Code, res.Raw)
+	}
+	if z := d.record("zones.json", "z1"); z["unavailable_reason"] != "survey" || z["unavailable_by_user_id"] != adminP.UserID {
+		t.Fatalf("NOT_AVAILABLE metadata: %v", z)
+	}
+	if res := post(ZoneMarkSold, "z1", nil); res.Code != http.StatusConflict {
+		t.Fatalf("NOT_AVAILABLE -> SOLD: %d", res.Code)
+	}
+	if res := post(ZoneMarkAvailable, "z1", nil); res.Code != http.StatusOK {
+		t.Fatalf("NOT_AVAILABLE -> AVAILABLE: %d %s", res.Code, res.Raw)
+	}
+	z := d.record("zones.json", "z1")
+	if z["status"] != zoneStatusAvailable || z["unavailable_reason"] != nil {
+		t.Fatalf("NOT_AVAILABLE metadata not cleared: %v", z)
+	}
+	if hist, _ := z["status_history"].([]any); len(hist) != 2 {
+		t.Fatalf("status_history = %v", z["status_history"])
+	}
+
+	if res := post(ZoneMarkSold, "z2", nil); res.Code != http.StatusOK {
+		t.Fatalf("BOOKED -> SOLD: %d %s", res.Code, res.Raw)
+	}
+	if z := d.record("zones.json", "z2"); z["status"] != zoneStatusSold || z["sold_by_user_id"] != adminP.UserID {
+		t.Fatalf("sold metadata: %v", z)
+	}
+}
+
+func TestZoneMarkUnavailableWithApprovedBooking(t *testing.T) {
+	seed := locationSeed()
+	seed["bookings.json"] = map[string]any{
+		"b1": map[string]any{"id": "b1", "site_id": "s1", "subsite_id": "ss1", "zone_id": "z1", "status": bookingStatusApproved},
+	}
+	d := newTestDeps(t, seed)
+	res := call(t, byID(d, ZoneMarkUnavailable, "z1"), adminP, "POST", "/", map[string]any{"reason": "x"}, anyIfMatch)
+	if res.Code != http.StatusConflict {
+		t.Fatalf("got %d %s", res.Code, res.Raw)
+	}
+}
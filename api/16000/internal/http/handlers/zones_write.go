@@ -5,14 +5,18 @@ import (
 	"net/http"
 	"strings"
 
-	"github.com/itmtjewelry/land-booking-kpr/internal/auth"
+	"github.com/itmtjewelry/land-booking-kpr/internal/ids"
 	"github.com/itmtjewelry/land-booking-kpr/internal/storage"
 )
 
+// zonePayload uses pointers so PUT can tell "absent" from "empty":
+// absent fields keep their stored value.
 type zonePayload struct {
-	ID        string `json:"id"`
-	SubsiteID string `json:"subsite_id"`
-	Name      string `json:"name"`
+	ID        string         `json:"id"`
+	SubsiteID *string        `json:"subsite_id"`
+	Name      *string        `json:"name"`
+	Geometry  *zoneGeometry  `json:"geometry"`
+	UI        map[string]any `json:"ui"`
 }
 
 func ZonesWriteCollection(deps Stage8Deps, w http.ResponseWriter, r *http.Request) {
@@ -20,9 +24,6 @@ func ZonesWriteCollection(deps Stage8Deps, w http.ResponseWriter, r *http.Reques
 		errJSON(w, http.StatusServiceUnavailable, "storage not ready")
 		return
 	}
-	if !auth.RequireAdmin(w, r) {
-		return
-	}
 	if r.Method != http.MethodPost {
 		methodNotAllowed(w)
 		return
@@ -34,26 +35,48 @@ func ZonesWriteCollection(deps Stage8Deps, w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	p.SubsiteID = strings.TrimSpace(p.SubsiteID)
-	p.Name = strings.TrimSpace(p.Name)
-	if p.SubsiteID == "" {
+	subsiteID := strings.TrimSpace(derefString(p.SubsiteID))
+	name := strings.TrimSpace(derefString(p.Name))
+	if subsiteID == "" {
 		errJSON(w, http.StatusBadRequest, "subsite_id is required")
 		return
 	}
-	if p.Name == "" {
+	if name == "" {
 		errJSON(w, http.StatusBadRequest, "name is required")
 		return
 	}
 
 	// Validate parent subsite exists
 	subItems := deps.GetItems("subsites.json")
-	if _, ok := subItems[p.SubsiteID]; !ok {
+	if _, ok := subItems[subsiteID]; !ok {
 		errJSON(w, http.StatusBadRequest, "subsite_id not found")
 		return
 	}
+	if subsiteArchived(deps, subsiteID) {
+		errJSON(w, http.StatusConflict, "subsite is archived")
+		return
+	}
+
+	obj := map[string]any{
+		"id":         "",
+		"subsite_id": subsiteID,
+		"name":       name,
+		"status":     zoneStatusAvailable,
+	}
+	if p.Geometry != nil {
+		g, err := normalizeGeometry(*p.Geometry)
+		if err != nil {
+			errJSON(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		obj["geometry"] = g
+	}
+	if p.UI != nil {
+		obj["ui"] = p.UI
+	}
 
 	if strings.TrimSpace(p.ID) == "" {
-		p.ID = genID("zone")
+		p.ID = ids.New("zone")
 	}
 
 	filename := "zones.json"
@@ -61,42 +84,27 @@ func ZonesWriteCollection(deps Stage8Deps, w http.ResponseWriter, r *http.Reques
 	mu.Lock()
 	defer mu.Unlock()
 
-	loaded := deps.Loaded()
-	jf, ok := loaded[filename]
-	if !ok {
-		jf = storage.JSONFile{
-			Meta:  map[string]any{"version": 1, "updated_at": nil},
-			Items: map[string]json.RawMessage{},
-		}
-	}
-	if jf.Meta == nil {
-		jf.Meta = map[string]any{"version": 1, "updated_at": nil}
-	}
-	if jf.Items == nil {
-		jf.Items = make(map[string]json.RawMessage)
-	}
+	jf := mustLoadJSONFile(deps, filename)
 
 	if _, exists := jf.Items[p.ID]; exists {
 		errJSON(w, http.StatusConflict, "id already exists")
 		return
 	}
 
-	raw, _ := json.Marshal(map[string]any{
-		"id":         p.ID,
-		"subsite_id": p.SubsiteID,
-		"name":       p.Name,
-	})
+	obj["id"] = p.ID
+	raw, _ := json.Marshal(obj)
 	jf.Items[p.ID] = raw
 
 	if err := storage.WriteJSONFileAtomic(deps.StorageDir(), filename, jf); err != nil {
-		errJSON(w, http.StatusInternalServerError, "write failed")
+		writeFailed(w, err, "write failed")
 		return
 	}
-	if err := deps.ReloadCore(); err != nil {
+	if err := deps.ReloadFiles(filename); err != nil {
 		errJSON(w, http.StatusInternalServerError, "reload failed")
 		return
 	}
 
+	setRecordETag(w, deps, filename, p.ID)
 	okData(w, map[string]any{"id": p.ID})
 }
 
@@ -105,9 +113,6 @@ func ZonesWriteByID(deps Stage8Deps, id string, w http.ResponseWriter, r *http.R
 		errJSON(w, http.StatusServiceUnavailable, "storage not ready")
 		return
 	}
-	if !auth.RequireAdmin(w, r) {
-		return
-	}
 
 	id = strings.TrimSpace(id)
 	if id == "" {
@@ -116,31 +121,32 @@ func ZonesWriteByID(deps Stage8Deps, id string, w http.ResponseWriter, r *http.R
 	}
 
 	filename := "zones.json"
+	// A delete checks usage: lock order bookings.json -> kpr_applications.json
+	// -> zones.json, as when archiving.
+	if r.Method == http.MethodDelete {
+		defer lockUsage(deps)()
+	}
 	mu := deps.LockForFile(filename)
 	mu.Lock()
 	defer mu.Unlock()
 
-	loaded := deps.Loaded()
-	jf, ok := loaded[filename]
-	if !ok {
-		jf = storage.JSONFile{
-			Meta:  map[string]any{"version": 1, "updated_at": nil},
-			Items: map[string]json.RawMessage{},
-		}
-	}
-	if jf.Meta == nil {
-		jf.Meta = map[string]any{"version": 1, "updated_at": nil}
-	}
-	if jf.Items == nil {
-		jf.Items = make(map[string]json.RawMessage)
-	}
+	jf := mustLoadJSONFile(deps, filename)
 
 	switch r.Method {
 	case http.MethodPut:
-		if _, exists := jf.Items[id]; !exists {
+		curRaw, exists := jf.Items[id]
+		if !exists {
 			errJSON(w, http.StatusBadRequest, "id not found")
 			return
 		}
+		if !requireIfMatch(w, r, curRaw) {
+			return
+		}
+		var cur map[string]any
+		if err := json.Unmarshal(curRaw, &cur); err != nil {
+			errJSON(w, http.StatusInternalServerError, "invalid stored zone")
+			return
+		}
 
 		var p zonePayload
 		if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
@@ -148,39 +154,58 @@ func ZonesWriteByID(deps Stage8Deps, id string, w http.ResponseWriter, r *http.R
 			return
 		}
 
-		p.SubsiteID = strings.TrimSpace(p.SubsiteID)
-		p.Name = strings.TrimSpace(p.Name)
-		if p.SubsiteID == "" {
-			errJSON(w, http.StatusBadRequest, "subsite_id is required")
-			return
+		// Partial update: only fields present in the body change.
+		if p.SubsiteID != nil {
+			subsiteID := strings.TrimSpace(*p.SubsiteID)
+			if subsiteID == "" {
+				errJSON(w, http.StatusBadRequest, "subsite_id is required")
+				return
+			}
+			// Validate parent subsite exists
+			subItems := deps.GetItems("subsites.json")
+			if _, ok := subItems[subsiteID]; !ok {
+				errJSON(w, http.StatusBadRequest, "subsite_id not found")
+				return
+			}
+			if subsiteID != str(cur["subsite_id"]) && subsiteArchived(deps, subsiteID) {
+				errJSON(w, http.StatusConflict, "subsite is archived")
+				return
+			}
+			cur["subsite_id"] = subsiteID
 		}
-		if p.Name == "" {
-			errJSON(w, http.StatusBadRequest, "name is required")
-			return
+		if p.Name != nil {
+			name := strings.TrimSpace(*p.Name)
+			if name == "" {
+				errJSON(w, http.StatusBadRequest, "name is required")
+				return
+			}
+			cur["name"] = name
 		}
-
-		// Validate parent subsite exists
-		subItems := deps.GetItems("subsites.json")
-		if _, ok := subItems[p.SubsiteID]; !ok {
-			errJSON(w, http.StatusBadRequest, "subsite_id not found")
-			return
+		if p.Geometry != nil {
+			g, err := normalizeGeometry(*p.Geometry)
+			if err != nil {
+				errJSON(w, http.StatusBadRequest, err.Error())
+				return
+			}
+			cur["geometry"] = g
+		}
+		if p.UI != nil {
+			cur["ui"] = p.UI
 		}
+		cur["id"] = id
 
-		raw, _ := json.Marshal(map[string]any{
-			"id":         id,
-			"subsite_id": p.SubsiteID,
-			"name":       p.Name,
-		})
+		raw, _ := json.Marshal(cur)
 		jf.Items[id] = raw
 
 		if err := storage.WriteJSONFileAtomic(deps.StorageDir(), filename, jf); err != nil {
-			errJSON(w, http.StatusInternalServerError, "write failed")
+			writeFailed(w, err, "write failed")
 			return
 		}
-		if err := deps.ReloadCore(); err != nil {
+		if err := deps.ReloadFiles(filename); err != nil {
 			errJSON(w, http.StatusInternalServerError, "reload failed")
 			return
 		}
+		setRecordETag(w, deps, filename, id)
 		okData(w, map[string]any{"id": id})
 		return
 
@@ -189,13 +214,20 @@ func ZonesWriteByID(deps Stage8Deps, id string, w http.ResponseWriter, r *http.R
 			okData(w, map[string]any{"deleted": false})
 			return
 		}
+		if usageOf(deps, zoneLocation, id).referenced() {
+			errJSON(w, http.StatusConflict, "zone is referenced by bookings or KPRs; archive it instead")
+			return
+		}
+		if !checkIfMatch(w, r, jf.Items[id]) {
+			return
+		}
 		delete(jf.Items, id)
 
 		if err := storage.WriteJSONFileAtomic(deps.StorageDir(), filename, jf); err != nil {
-			errJSON(w, http.StatusInternalServerError, "write failed")
+			writeFailed(w, err, "write failed")
 			return
 		}
-		if err := deps.ReloadCore(); err != nil {
+		if err := deps.ReloadFiles(filename); err != nil {
 			errJSON(w, http.StatusInternalServerError, "reload failed")
 			return
 		}
@@ -206,3 +238,10 @@ func ZonesWriteByID(deps Stage8Deps, id string, w http.ResponseWriter, r *http.R
 		methodNotAllowed(w)
 	}
 }
+
+func derefString(p *string) string {
+	if p == nil {
+		return ""
+	}
+	return *p
+}
@@ -0,0 +1,25 @@
+package http
+
+import (
+	"net/http"
+
+	"github.com/itmtjewelry/land-booking-kpr/internal/auth"
+	"github.com/itmtjewelry/land-booking-kpr/internal/http/handlers"
+)
+
+// withPrincipal resolves the request principal once and attaches it to the context.
+func withPrincipal(deps handlers.Stage8Deps, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p := handlers.ResolvePrincipal(deps, r)
+		next.ServeHTTP(w, r.WithContext(auth.WithPrincipal(r.Context(), p)))
+	})
+}
+
+// withSiteScope limits public reads to the site mapped from the Host header.
+// Must run after withPrincipal (the ADMIN override depends on the principal).
+func withSiteScope(deps handlers.Stage8Deps, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		siteID := handlers.ResolveSiteScope(deps, r)
+		next.ServeHTTP(w, r.WithContext(handlers.WithSiteScope(r.Context(), siteID)))
+	})
+}
@@ -0,0 +1,219 @@
+package http
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/itmtjewelry/land-booking-kpr/internal/auth"
+	"github.com/itmtjewelry/land-booking-kpr/internal/http/handlers"
+)
+
+// permissionRule grants Method on Route to Roles.
+// Route segments written as {name} match any single non-empty path segment.
+type permissionRule struct {
+	Route  string      `json:"route"`
+	Method string      `json:"method"`
+	Roles  []auth.Role `json:"roles"`
+}
+
+var (
+	anyRole   = []auth.Role{auth.RoleAdmin, auth.RoleSalesManager, auth.RoleCustomerSales, auth.RoleGuest}
+	bookers   = []auth.Role{auth.RoleAdmin, auth.RoleSalesManager, auth.RoleCustomerSales}
+	staff     = []auth.Role{auth.RoleAdmin, auth.RoleSalesManager}
+	adminOnly = []auth.Role{auth.RoleAdmin}
+)
+
+// permissionMatrix is the single source of truth for who may call what under /api/v1.
+// A route/method pair that is not listed here is rejected by requirePermission.
+// Ownership rules (e.g. "cancel own booking") are enforced inside the handler.
+var permissionMatrix = []permissionRule{
+	// AUTH
+	{"/api/v1/auth/login", http.MethodPost, anyRole},
+	{"/api/v1/auth/logout", http.MethodPost, anyRole},
+	{"/api/v1/auth/me", http.MethodGet, anyRole},
+	{"/api/v1/auth/permissions", http.MethodGet, anyRole},
+
+	// SITES / SUBSITES / ZONES
+	{"/api/v1/sites", http.MethodGet, anyRole},
+	{"/api/v1/sites", http.MethodPost, adminOnly},
+	{"/api/v1/sites/{id}", http.MethodPut, adminOnly},
+	{"/api/v1/sites/{id}", http.MethodDelete, adminOnly},
+	{"/api/v1/sites/{id}/archive", http.MethodPost, adminOnly},
+	{"/api/v1/sites/{id}/unarchive", http.MethodPost, adminOnly},
+	{"/api/v1/subsites", http.MethodGet, anyRole},
+	{"/api/v1/subsites", http.MethodPost, adminOnly},
+	{"/api/v1/subsites/{id}", http.MethodPut, adminOnly},
+	{"/api/v1/subsites/{id}", http.MethodDelete, adminOnly},
+	{"/api/v1/subsites/{id}/archive", http.MethodPost, adminOnly},
+	{"/api/v1/subsites/{id}/unarchive", http.MethodPost, adminOnly},
+	{"/api/v1/zones", http.MethodGet, anyRole},
+	{"/api/v1/zones", http.MethodPost, adminOnly},
+	{"/api/v1/zones/{id}", http.MethodPut, adminOnly},
+	{"/api/v1/zones/{id}", http.MethodDelete, adminOnly},
+	{"/api/v1/zones/{id}/archive", http.MethodPost, adminOnly},
+	{"/api/v1/zones/{id}/unarchive", http.MethodPost, adminOnly},
+	{"/api/v1/zones/{id}/mark-sold", http.MethodPost, staff},
+	{"/api/v1/zones/{id}/mark-unavailable", http.MethodPost, staff},
+	{"/api/v1/zones/{id}/mark-available", http.MethodPost, staff},
+
+	// DOMAINS
+	{"/api/v1/domains/resolve", http.MethodGet, anyRole},
+	{"/api/v1/domains", http.MethodGet, adminOnly},
+	{"/api/v1/domains", http.MethodPost, adminOnly},
+	{"/api/v1/domains/{id}", http.MethodPut, adminOnly},
+	{"/api/v1/domains/{id}", http.MethodDelete, adminOnly},
+
+	// BOOKINGS
+	{"/api/v1/bookings", http.MethodGet, anyRole},
+	{"/api/v1/bookings", http.MethodPost, bookers},
+	{"/api/v1/bookings/{id}", http.MethodPut, staff},
+	{"/api/v1/bookings/{id}/approve", http.MethodPost, staff},
+	{"/api/v1/bookings/{id}/reject", http.MethodPost, staff},
+	{"/api/v1/bookings/{id}/cancel", http.MethodPost, bookers},
+	{"/api/v1/availability", http.MethodGet, anyRole},
+
+	// KPR + INSTALLMENTS
+	{"/api/v1/kpr", http.MethodGet, anyRole},
+	{"/api/v1/kpr", http.MethodPost, adminOnly},
+	{"/api/v1/kpr/simulate", http.MethodGet, anyRole},
+	{"/api/v1/kpr/simulate", http.MethodPost, anyRole},
+	{"/api/v1/kpr/{id}", http.MethodPut, adminOnly},
+	{"/api/v1/kpr/{id}/submit", http.MethodPost, adminOnly},
+	{"/api/v1/kpr/{id}/approve", http.MethodPost, adminOnly},
+	{"/api/v1/kpr/{id}/reject", http.MethodPost, adminOnly},
+	{"/api/v1/kpr/{id}/cancel", http.MethodPost, adminOnly},
+	{"/api/v1/installments", http.MethodGet, anyRole},
+	{"/api/v1/installments/{kpr_id}/generate", http.MethodPost, adminOnly},
+	{"/api/v1/installments/{kpr_id}/rate-change", http.MethodPost, adminOnly},
+
+	// PAYMENTS
+	{"/api/v1/payments", http.MethodGet, adminOnly},
+	{"/api/v1/payments", http.MethodPost, adminOnly},
+
+	// REPORTS
+	{"/api/v1/reports/kpr-statement", http.MethodGet, anyRole},
+	{"/api/v1/reports/zone-summary", http.MethodGet, staff},
+	{"/api/v1/reports/portfolio", http.MethodGet, staff},
+	{"/api/v1/reports/penalties/preview", http.MethodGet, staff},
+	{"/api/v1/penalties/charge", http.MethodPost, adminOnly},
+
+	// SUPPORT
+	{"/api/v1/tickets", http.MethodGet, bookers},
+	{"/api/v1/tickets", http.MethodPost, bookers},
+	{"/api/v1/tickets/{id}", http.MethodGet, bookers},
+	{"/api/v1/tickets/{id}/messages", http.MethodPost, bookers},
+	{"/api/v1/tickets/{id}/status", http.MethodPost, bookers},
+
+	// ADMIN: STORAGE
+	{"/api/v1/admin/backups", http.MethodGet, adminOnly},
+	{"/api/v1/admin/backups/restore", http.MethodPost, adminOnly},
+	{"/api/v1/admin/backups/prune", http.MethodPost, adminOnly},
+	{"/api/v1/admin/integrity", http.MethodGet, adminOnly},
+	{"/api/v1/admin/integrity/repair", http.MethodPost, adminOnly},
+	{"/api/v1/admin/snapshot", http.MethodGet, adminOnly},
+	{"/api/v1/admin/snapshot/import", http.MethodPost, adminOnly},
+}
+
+// routeMatches reports whether path matches pattern and, if so, how many
+// {name} segments it took; fewer means a more specific match.
+func routeMatches(pattern, path string) (int, bool) {
+	ps := strings.Split(strings.Trim(pattern, "/"), "/")
+	xs := strings.Split(strings.Trim(path, "/"), "/")
+	if len(ps) != len(xs) {
+		return 0, false
+	}
+	params := 0
+	for i := range ps {
+		if strings.HasPrefix(ps[i], "{") && strings.HasSuffix(ps[i], "}") {
+			if strings.TrimSpace(xs[i]) == "" {
+				return 0, false
+			}
+			params++
+			continue
+		}
+		if ps[i] != xs[i] {
+			return 0, false
+		}
+	}
+	return params, true
+}
+
+// matchRules returns the rules of the most specific route matching path, so
+// a literal route (/kpr/simulate) shadows a pattern (/kpr/{id}).
+func matchRules(path string) []permissionRule {
+	var out []permissionRule
+	best := -1
+	for _, rule := range permissionMatrix {
+		params, ok := routeMatches(rule.Route, path)
+		if !ok || (best >= 0 && params > best) {
+			continue
+		}
+		if params < best {
+			out = out[:0]
+		}
+		best = params
+		out = append(out, rule)
+	}
+	return out
+}
+
+func roleAllowed(roles []auth.Role, role auth.Role) bool {
+	for _, r := range roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// requirePermission enforces permissionMatrix before any handler runs.
+// Unknown routes get 404, known routes with an unlisted method get 405,
+// and a role outside the rule gets a 403 envelope.
+func requirePermission(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rules := matchRules(r.URL.Path)
+		for _, rule := range rules {
+			if rule.Method != r.Method {
+				continue
+			}
+			p := auth.PrincipalFrom(r.Context())
+			if !roleAllowed(rule.Roles, p.Role) {
+				handlers.Forbidden(w, "role "+string(p.Role)+" may not "+r.Method+" "+rule.Route)
+				return
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
+		if len(rules) > 0 {
+			handlers.MethodNotAllowed(w)
+			return
+		}
+		handlers.WriteJSON(w, http.StatusNotFound, handlers.Envelope{
+			OK:   false,
+			Data: []any{},
+			Err:  &handlers.ErrorShape{Code: "not_found", Message: "route not found"},
+		})
+	})
+}
+
+// permissionsHandler serves GET /api/v1/auth/permissions (read-only matrix for UI gating).
+func permissionsHandler(w http.ResponseWriter, r *http.Request) {
+	p := auth.PrincipalFrom(r.Context())
+
+	type ruleOut struct {
+		permissionRule
+		Allowed bool `json:"allowed"`
+	}
+	rules := make([]ruleOut, 0, len(permissionMatrix))
+	for _, rule := range permissionMatrix {
+		rules = append(rules, ruleOut{permissionRule: rule, Allowed: roleAllowed(rule.Roles, p.Role)})
+	}
+
+	handlers.WriteJSON(w, http.StatusOK, handlers.Envelope{
+		OK: true,
+		Data: map[string]any{
+			"role":  p.Role,
+			"rules": rules,
+		},
+	})
+}
@@ -0,0 +1,150 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/itmtjewelry/land-booking-kpr/internal/auth"
+)
+
+var testRoles = []auth.Role{auth.RoleAdmin, auth.RoleSalesManager, auth.RoleCustomerSales, auth.RoleGuest}
+
+// permit runs a request as role through requirePermission and returns the
+// status and, for refusals, the envelope's error.code.
+func permit(t *testing.T, role auth.Role, method, path string) (int, string) {
+	t.Helper()
+	h := requirePermission(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot) // reached the handler
+	}))
+	r := httptest.NewRequest(method, path, nil)
+	r = r.WithContext(auth.WithPrincipal(r.Context(), auth.Principal{UserID: "u1", Role: role}))
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if w.Code == http.StatusTeapot {
+		return w.Code, ""
+	}
+
+	var env struct {
+		OK    bool `json:"ok"`
+		Error struct {
+			Code string `json:"code"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &env); err != nil || env.OK {
+		t.Fatalf("%s %s as %s: not an error envelope: %s", method, path, role, w.Body.String())
+	}
+	return w.Code, env.Error.Code
+}
+
+// concrete fills the {name} segments of a route pattern.
+func concrete(route string) string {
+	parts := strings.Split(route, "/")
+	for i, p := range parts {
+		if strings.HasPrefix(p, "{") {
+			parts[i] = "x1"
+		}
+	}
+	return strings.Join(parts, "/")
+}
+
+func TestPermissionMatrix(t *testing.T) {
+	for _, rule := range permissionMatrix {
+		path := concrete(rule.Route)
+		for _, role := range testRoles {
+			code, errCode := permit(t, role, rule.Method, path)
+			if roleAllowed(rule.Roles, role) {
+				if code != http.StatusTeapot {
+					t.Errorf("%s %s as %s: %d %s, want allowed", rule.Method, path, role, code, errCode)
+				}
+			} else if code != http.StatusForbidden || errCode != "forbidden" {
+				t.Errorf("%s %s as %s: %d %s, want 403 forbidden", rule.Method, path, role, code, errCode)
+			}
+		}
+		// No route takes PATCH.
+		if code, errCode := permit(t, auth.RoleAdmin, http.MethodPatch, path); code != http.StatusMethodNotAllowed || errCode != "method_not_allowed" {
+			t.Errorf("PATCH %s: %d %s, want 405", path, code, errCode)
+		}
+	}
+}
+
+func TestPermissionRoles(t *testing.T) {
+	const (
+		ok        = http.StatusTeapot
+		forbidden = http.StatusForbidden
+	)
+	type want struct{ admin, manager, sales, guest int }
+	for _, tc := range []struct {
+		method, path string
+		want         want
+	}{
+		// Public reads and the public tools.
+		{"GET", "/api/v1/zones", want{ok, ok, ok, ok}},
+		{"GET", "/api/v1/domains/resolve", want{ok, ok, ok, ok}},
+		{"GET", "/api/v1/kpr/simulate", want{ok, ok, ok, ok}},
+		{"POST", "/api/v1/kpr/simulate", want{ok, ok, ok, ok}},
+		{"POST", "/api/v1/auth/login", want{ok, ok, ok, ok}},
+		// Bookings: staff approve, bookers request and cancel.
+		{"POST", "/api/v1/bookings", want{ok, ok, ok, forbidden}},
+		{"POST", "/api/v1/bookings/b1/approve", want{ok, ok, forbidden, forbidden}},
+		{"POST", "/api/v1/bookings/b1/reject", want{ok, ok, forbidden, forbidden}},
+		{"POST", "/api/v1/bookings/b1/cancel", want{ok, ok, ok, forbidden}},
+		{"PUT", "/api/v1/bookings/b1", want{ok, ok, forbidden, forbidden}},
+		// Admin-only writes.
+		{"DELETE", "/api/v1/zones/z1", want{ok, forbidden, forbidden, forbidden}},
+		{"GET", "/api/v1/domains", want{ok, forbidden, forbidden, forbidden}},
+		{"POST", "/api/v1/kpr/k1/approve", want{ok, forbidden, forbidden, forbidden}},
+		{"POST", "/api/v1/installments/k1/rate-change", want{ok, forbidden, forbidden, forbidden}},
+		{"POST", "/api/v1/admin/backups/restore", want{ok, forbidden, forbidden, forbidden}},
+		// Support is for signed-in users.
+		{"GET", "/api/v1/tickets/t1", want{ok, ok, ok, forbidden}},
+	} {
+		for i, role := range testRoles {
+			w := []int{tc.want.admin, tc.want.manager, tc.want.sales, tc.want.guest}[i]
+			if code, _ := permit(t, role, tc.method, tc.path); code != w {
+				t.Errorf("%s %s as %s: %d, want %d", tc.method, tc.path, role, code, w)
+			}
+		}
+	}
+}
+
+func TestPermissionUnknownRoutes(t *testing.T) {
+	for _, tc := range []struct {
+		method, path string
+		code         int
+		errCode      string
+	}{
+		{"GET", "/api/v1/nope", http.StatusNotFound, "not_found"},
+		{"GET", "/api/v1/zones/z1/extra/more", http.StatusNotFound, "not_found"},
+		{"POST", "/api/v1/bookings//approve", http.StatusNotFound, "not_found"},
+		{"POST", "/api/v1/bookings/b1/approve/now", http.StatusNotFound, "not_found"},
+		{"GET", "/api/v1/bookings/b1/approve", http.StatusMethodNotAllowed, "method_not_allowed"},
+		// Literal routes shadow the {id} patterns they also match.
+		{"DELETE", "/api/v1/domains/resolve", http.StatusMethodNotAllowed, "method_not_allowed"},
+		{"DELETE", "/api/v1/kpr/simulate", http.StatusMethodNotAllowed, "method_not_allowed"},
+		{"PUT", "/api/v1/kpr/simulate", http.StatusMethodNotAllowed, "method_not_allowed"},
+	} {
+		for _, role := range testRoles {
+			if code, errCode := permit(t, role, tc.method, tc.path); code != tc.code || errCode != tc.errCode {
+				t.Errorf("%s %s as %s: %d %s, want %d %s", tc.method, tc.path, role, code, errCode, tc.code, tc.errCode)
+			}
+		}
+	}
+}
+
+// GUEST only reads: apart from signing in and the public simulator, every
+// write is refused.
+func TestGuestOnlyReads(t *testing.T) {
+	public := map[string]bool{
+		"/api/v1/auth/login":   true,
+		"/api/v1/auth/logout":  true,
+		"/api/v1/kpr/simulate": true,
+	}
+	for _, rule := range permissionMatrix {
+		if rule.Method != http.MethodGet && roleAllowed(rule.Roles, auth.RoleGuest) && !public[rule.Route] {
+			t.Errorf("GUEST may %s %s", rule.Method, rule.Route)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package http
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/itmtjewelry/land-booking-kpr/internal/http/handlers"
+)
+
+// ipLimiter is a per-client token bucket: perMinute requests a minute, with
+// bursts of up to perMinute. Idle clients are forgotten after a while.
+type ipLimiter struct {
+	perMinute int
+
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+type bucket struct {
+	tokens float64
+	seen   time.Time
+}
+
+const limiterIdle = 10 * time.Minute
+
+// newIPLimiter returns nil (no limit) when perMinute <= 0.
+func newIPLimiter(perMinute int) *ipLimiter {
+	if perMinute <= 0 {
+		return nil
+	}
+	return &ipLimiter{perMinute: perMinute, buckets: map[string]*bucket{}}
+}
+
+// allow takes a token for client and, when there is none, reports how long
+// until the next one.
+func (l *ipLimiter) allow(client string, now time.Time) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if now.Sub(l.lastSweep) > limiterIdle {
+		for k, b := range l.buckets {
+			if now.Sub(b.seen) > limiterIdle {
+				delete(l.buckets, k)
+			}
+		}
+		l.lastSweep = now
+	}
+
+	rate := float64(l.perMinute) / float64(time.Minute)
+	b, ok := l.buckets[client]
+	if !ok {
+		b = &bucket{tokens: float64(l.perMinute), seen: now}
+		l.buckets[client] = b
+	}
+	b.tokens = math.Min(float64(l.perMinute), b.tokens+float64(now.Sub(b.seen))*rate)
+	b.seen = now
+	if b.tokens < 1 {
+		return false, time.Duration((1 - b.tokens) / rate)
+	}
+	b.tokens--
+	return true, 0
+}
+
+// clientIP is the peer address of r. Behind a local reverse proxy (peer on
+// loopback) it is the address the proxy appended to X-Forwarded-For, which
+// the client cannot forge.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	if ip := net.ParseIP(host); ip != nil && ip.IsLoopback() {
+		if xff := r.Header.Get("X-Forwarded-For"); xff != "" {
+			parts := strings.Split(xff, ",")
+			if last := strings.TrimSpace(parts[len(parts)-1]); last != "" {
+				return last
+			}
+		}
+	}
+	return host
+}
+
+// rateLimited answers 429 with Retry-After once a client is over l.
+func rateLimited(l *ipLimiter, next http.HandlerFunc) http.HandlerFunc {
+	if l == nil {
+		return next
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		if ok, wait := l.allow(clientIP(r), time.Now()); !ok {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			handlers.WriteJSON(w, http.StatusTooManyRequests, handlers.Envelope{
+				OK:   false,
+				Data: []any{},
+				Err:  &handlers.ErrorShape{Code: "rate_limited", Message: "too many requests"},
+			})
+			return
+		}
+		next(w, r)
+	}
+}
@@ -0,0 +1,45 @@
+package http
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestIPLimiter(t *testing.T) {
+	l := newIPLimiter(2)
+	now := time.Unix(1_700_000_000, 0)
+
+	for i := 0; i < 2; i++ {
+		if ok, _ := l.allow("a", now); !ok {
+			t.Fatalf("request %d refused within the burst", i+1)
+		}
+	}
+	ok, wait := l.allow("a", now)
+	if ok || wait != 30*time.Second {
+		t.Fatalf("third request: ok=%v wait=%v, want refused for 30s", ok, wait)
+	}
+	if ok, _ := l.allow("b", now); !ok {
+		t.Fatal("other client refused")
+	}
+	if ok, _ := l.allow("a", now.Add(30*time.Second)); !ok {
+		t.Fatal("refused after a token refilled")
+	}
+	if newIPLimiter(0) != nil {
+		t.Fatal("limit 0 should disable the limiter")
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	r := httptest.NewRequest("GET", "/", nil)
+	r.RemoteAddr = "203.0.113.7:5000"
+	r.Header.Set("X-Forwarded-For", "10.0.0.1")
+	if got := clientIP(r); got != "203.0.113.7" {
+		t.Fatalf("direct peer: got %q", got)
+	}
+	r.RemoteAddr = "127.0.0.1:5000"
+	r.Header.Set("X-Forwarded-For", "10.0.0.1, 198.51.100.2")
+	if got := clientIP(r); got != "198.51.100.2" {
+		t.Fatalf("behind local proxy: got %q", got)
+	}
+}
@@ -10,6 +10,16 @@ import (
 func NewStage8Router(deps handlers.Stage8Deps) http.Handler {
 	mux := http.NewServeMux()
 
+	// AUTH
+	mux.HandleFunc("/api/v1/auth/login", func(w http.ResponseWriter, r *http.Request) {
+		handlers.AuthLogin(deps, w, r)
+	})
+	mux.HandleFunc("/api/v1/auth/logout", func(w http.ResponseWriter, r *http.Request) {
+		handlers.AuthLogout(deps, w, r)
+	})
+	mux.HandleFunc("/api/v1/auth/me", handlers.AuthMe)
+	mux.HandleFunc("/api/v1/auth/permissions", permissionsHandler)
+
 	// SITES
 	mux.HandleFunc("/api/v1/sites", func(w http.ResponseWriter, r *http.Request) {
 		switch r.Method {
@@ -23,7 +33,17 @@ func NewStage8Router(deps handlers.Stage8Deps) http.Handler {
 		}
 	})
 	mux.HandleFunc("/api/v1/sites/", func(w http.ResponseWriter, r *http.Request) {
-		id := strings.TrimSpace(strings.TrimPrefix(r.URL.Path, "/api/v1/sites/"))
+		path := strings.TrimSpace(strings.TrimPrefix(r.URL.Path, "/api/v1/sites/"))
+		if strings.HasSuffix(path, "/archive") {
+			handlers.SiteArchive(deps, strings.TrimSuffix(path, "/archive"), w, r)
+			return
+		}
+		if strings.HasSuffix(path, "/unarchive") {
+			handlers.SiteUnarchive(deps, strings.TrimSuffix(path, "/unarchive"), w, r)
+			return
+		}
+
+		id := path
 		if id == "" || strings.Contains(id, "/") {
 			w.WriteHeader(http.StatusBadRequest)
 			_, _ = w.Write([]byte("invalid id\n"))
@@ -45,7 +65,17 @@ func NewStage8Router(deps handlers.Stage8Deps) http.Handler {
 		}
 	})
 	mux.HandleFunc("/api/v1/subsites/", func(w http.ResponseWriter, r *http.Request) {
-		id := strings.TrimSpace(strings.TrimPrefix(r.URL.Path, "/api/v1/subsites/"))
+		path := strings.TrimSpace(strings.TrimPrefix(r.URL.Path, "/api/v1/subsites/"))
+		if strings.HasSuffix(path, "/archive") {
+			handlers.SubsiteArchive(deps, strings.TrimSuffix(path, "/archive"), w, r)
+			return
+		}
+		if strings.HasSuffix(path, "/unarchive") {
+			handlers.SubsiteUnarchive(deps, strings.TrimSuffix(path, "/unarchive"), w, r)
+			return
+		}
+
+		id := path
 		if id == "" || strings.Contains(id, "/") {
 			w.WriteHeader(http.StatusBadRequest)
 			_, _ = w.Write([]byte("invalid id\n"))
@@ -67,7 +97,32 @@ func NewStage8Router(deps handlers.Stage8Deps) http.Handler {
 		}
 	})
 	mux.HandleFunc("/api/v1/zones/", func(w http.ResponseWriter, r *http.Request) {
-		id := strings.TrimSpace(strings.TrimPrefix(r.URL.Path, "/api/v1/zones/"))
+		path := strings.TrimSpace(strings.TrimPrefix(r.URL.Path, "/api/v1/zones/"))
+		if strings.HasSuffix(path, "/mark-sold") {
+			id := strings.TrimSuffix(path, "/mark-sold")
+			handlers.ZoneMarkSold(deps, id, w, r)
+			return
+		}
+		if strings.HasSuffix(path, "/mark-unavailable") {
+			id := strings.TrimSuffix(path, "/mark-unavailable")
+			handlers.ZoneMarkUnavailable(deps, id, w, r)
+			return
+		}
+		if strings.HasSuffix(path, "/mark-available") {
+			id := strings.TrimSuffix(path, "/mark-available")
+			handlers.ZoneMarkAvailable(deps, id, w, r)
+			return
+		}
+		if strings.HasSuffix(path, "/archive") {
+			handlers.ZoneArchive(deps, strings.TrimSuffix(path, "/archive"), w, r)
+			return
+		}
+		if strings.HasSuffix(path, "/unarchive") {
+			handlers.ZoneUnarchive(deps, strings.TrimSuffix(path, "/unarchive"), w, r)
+			return
+		}
+
+		id := path
 		if id == "" || strings.Contains(id, "/") {
 			w.WriteHeader(http.StatusBadRequest)
 			_, _ = w.Write([]byte("invalid id\n"))
@@ -76,6 +131,29 @@ func NewStage8Router(deps handlers.Stage8Deps) http.Handler {
 		handlers.ZonesWriteByID(deps, id, w, r)
 	})
 
+	// DOMAINS
+	mux.HandleFunc("/api/v1/domains", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			handlers.DomainsList(deps)(w, r)
+		case http.MethodPost:
+			handlers.DomainsWriteCollection(deps, w, r)
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			_, _ = w.Write([]byte("method not allowed\n"))
+		}
+	})
+	mux.HandleFunc("/api/v1/domains/resolve", handlers.DomainsResolve(deps))
+	mux.HandleFunc("/api/v1/domains/", func(w http.ResponseWriter, r *http.Request) {
+		id := strings.TrimSpace(strings.TrimPrefix(r.URL.Path, "/api/v1/domains/"))
+		if id == "" || strings.Contains(id, "/") {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte("invalid id\n"))
+			return
+		}
+		handlers.DomainsWriteByID(deps, id, w, r)
+	})
+
 	// BOOKINGS
 	mux.HandleFunc("/api/v1/bookings", func(w http.ResponseWriter, r *http.Request) {
 		switch r.Method {
@@ -89,7 +167,27 @@ func NewStage8Router(deps handlers.Stage8Deps) http.Handler {
 		}
 	})
 	mux.HandleFunc("/api/v1/bookings/", func(w http.ResponseWriter, r *http.Request) {
-		id := strings.TrimSpace(strings.TrimPrefix(r.URL.Path, "/api/v1/bookings/"))
+		path := strings.TrimSpace(strings.TrimPrefix(r.URL.Path, "/api/v1/bookings/"))
+		if strings.HasSuffix(path, "/approve") {
+			id := strings.TrimSuffix(path, "/approve")
+			id = strings.TrimSuffix(id, "/")
+			handlers.BookingApproveByID(deps, id, w, r)
+			return
+		}
+		if strings.HasSuffix(path, "/reject") {
+			id := strings.TrimSuffix(path, "/reject")
+			id = strings.TrimSuffix(id, "/")
+			handlers.BookingRejectByID(deps, id, w, r)
+			return
+		}
+		if strings.HasSuffix(path, "/cancel") {
+			id := strings.TrimSuffix(path, "/cancel")
+			id = strings.TrimSuffix(id, "/")
+			handlers.BookingCancelByID(deps, id, w, r)
+			return
+		}
+
+		id := path
 		if id == "" || strings.Contains(id, "/") {
 			w.WriteHeader(http.StatusBadRequest)
 			_, _ = w.Write([]byte("invalid id\n"))
@@ -110,6 +208,10 @@ func NewStage8Router(deps handlers.Stage8Deps) http.Handler {
 	mux.HandleFunc("/api/v1/kpr", func(w http.ResponseWriter, r *http.Request) {
 		handlers.KPRCollection(deps, w, r)
 	})
+	// Public calculator: no session needed, so it is limited per client IP.
+	mux.HandleFunc("/api/v1/kpr/simulate", rateLimited(newIPLimiter(deps.SimulateRateLimit()), func(w http.ResponseWriter, r *http.Request) {
+		handlers.KPRSimulate(deps, w, r)
+	}))
 	mux.HandleFunc("/api/v1/kpr/", func(w http.ResponseWriter, r *http.Request) {
 		path := strings.TrimSpace(strings.TrimPrefix(r.URL.Path, "/api/v1/kpr/"))
 		if path == "" {
@@ -166,6 +268,12 @@ func NewStage8Router(deps handlers.Stage8Deps) http.Handler {
 			handlers.InstallmentsGenerate(deps, id, w, r)
 			return
 		}
+		if strings.HasSuffix(path, "/rate-change") {
+			id := strings.TrimSuffix(path, "/rate-change")
+			id = strings.TrimSuffix(id, "/")
+			handlers.InstallmentsRateChange(deps, id, w, r)
+			return
+		}
 		w.WriteHeader(http.StatusNotFound)
 		_, _ = w.Write([]byte("not found\n"))
 	})
@@ -190,5 +298,56 @@ func NewStage8Router(deps handlers.Stage8Deps) http.Handler {
 		handlers.PenaltiesCharge(deps, w, r)
 	})
 
-	return mux
+	// SUPPORT: tickets (optional module, support/tickets.json)
+	mux.HandleFunc("/api/v1/tickets", func(w http.ResponseWriter, r *http.Request) {
+		handlers.TicketsCollection(deps, w, r)
+	})
+	mux.HandleFunc("/api/v1/tickets/", func(w http.ResponseWriter, r *http.Request) {
+		path := strings.TrimSpace(strings.TrimPrefix(r.URL.Path, "/api/v1/tickets/"))
+		if strings.HasSuffix(path, "/messages") {
+			handlers.TicketMessageCreate(deps, strings.TrimSuffix(path, "/messages"), w, r)
+			return
+		}
+		if strings.HasSuffix(path, "/status") {
+			handlers.TicketStatusUpdate(deps, strings.TrimSuffix(path, "/status"), w, r)
+			return
+		}
+
+		id := path
+		if id == "" || strings.Contains(id, "/") {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte("invalid id\n"))
+			return
+		}
+		handlers.TicketByID(deps, id, w, r)
+	})
+
+	// ADMIN: storage backups
+	mux.HandleFunc("/api/v1/admin/backups", func(w http.ResponseWriter, r *http.Request) {
+		handlers.AdminBackupsList(deps, w, r)
+	})
+	mux.HandleFunc("/api/v1/admin/backups/restore", func(w http.ResponseWriter, r *http.Request) {
+		handlers.AdminBackupsRestore(deps, w, r)
+	})
+	mux.HandleFunc("/api/v1/admin/backups/prune", func(w http.ResponseWriter, r *http.Request) {
+		handlers.AdminBackupsPrune(deps, w, r)
+	})
+
+	// ADMIN: integrity
+	mux.HandleFunc("/api/v1/admin/integrity", func(w http.ResponseWriter, r *http.Request) {
+		handlers.AdminIntegrityCheck(deps, w, r)
+	})
+	mux.HandleFunc("/api/v1/admin/integrity/repair", func(w http.ResponseWriter, r *http.Request) {
+		handlers.AdminIntegrityRepair(deps, w, r)
+	})
+
+	// ADMIN: storage snapshots
+	mux.HandleFunc("/api/v1/admin/snapshot", func(w http.ResponseWriter, r *http.Request) {
+		handlers.AdminSnapshotExport(deps, w, r)
+	})
+	mux.HandleFunc("/api/v1/admin/snapshot/import", func(w http.ResponseWriter, r *http.Request) {
+		handlers.AdminSnapshotImport(deps, w, r)
+	})
+
+	return withPrincipal(deps, withSiteScope(deps, requirePermission(mux)))
 }
@@ -0,0 +1,106 @@
+// Package ids generates record ids and document numbers.
+//
+// Record ids are `<prefix>_<ULID>`: 48 bits of millisecond time followed by
+// 80 random bits, in Crockford base32. They sort by creation time and never
+// repeat within a process (ids made in the same millisecond increment the
+// random part instead of drawing a new one).
+//
+// Document numbers are human-readable sequential numbers per site and year,
+// e.g. `BK/2026/000123`, persisted in storage.SequencesFile.
+package ids
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/itmtjewelry/land-booking-kpr/internal/storage"
+)
+
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+var (
+	mu     sync.Mutex
+	lastMS uint64
+	last   [10]byte // random part of the previous id
+)
+
+// New returns a new id with prefix, e.g. New("booking") = "booking_01J...".
+func New(prefix string) string {
+	return prefix + "_" + ulid(time.Now())
+}
+
+func ulid(now time.Time) string {
+	mu.Lock()
+	ms := uint64(now.UnixMilli())
+	if ms <= lastMS {
+		// Same (or an earlier, after a clock step) millisecond: stay monotonic.
+		ms = lastMS
+		if !increment(&last) {
+			ms++
+			fill(&last)
+		}
+	} else {
+		fill(&last)
+	}
+	lastMS = ms
+
+	var b [16]byte
+	binary.BigEndian.PutUint16(b[0:2], uint16(ms>>32))
+	binary.BigEndian.PutUint32(b[2:6], uint32(ms))
+	copy(b[6:], last[:])
+	mu.Unlock()
+
+	return encode(b)
+}
+
+func fill(r *[10]byte) {
+	if _, err := rand.Read(r[:]); err != nil {
+		panic(fmt.Sprintf("ids: random source failed: %v", err))
+	}
+}
+
+// increment adds one to r; false on overflow.
+func increment(r *[10]byte) bool {
+	for i := len(r) - 1; i >= 0; i-- {
+		r[i]++
+		if r[i] != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// encode writes 128 bits as 26 base32 characters (the first carries 3 bits).
+func encode(b [16]byte) string {
+	hi := binary.BigEndian.Uint64(b[0:8])
+	lo := binary.BigEndian.Uint64(b[8:16])
+	var out [26]byte
+	for i := 25; i >= 0; i-- {
+		out[i] = crockford[lo&31]
+		lo = lo>>5 | hi<<59
+		hi >>= 5
+	}
+	return string(out[:])
+}
+
+// Document number kinds.
+const (
+	DocBooking = "BK" // booking
+	DocReceipt = "KW" // payment receipt (kwitansi)
+)
+
+// NextDocNumber allocates the next number of kind for siteID in the year of
+// now from seq (see storage.LoadSequences). Stage seq in the same transaction
+// as the document so the number and the document are written together.
+// A damaged counter is an error: the document must not be written.
+func NextDocNumber(seq *storage.JSONFile, kind, siteID string, now time.Time) (string, error) {
+	year := now.Year()
+	n, err := storage.NextSequence(seq, fmt.Sprintf("%s/%s/%d", kind, siteID, year))
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s/%d/%06d", kind, year, n), nil
+}
@@ -0,0 +1,70 @@
+package ids
+
+import (
+	"encoding/json"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/itmtjewelry/land-booking-kpr/internal/storage"
+)
+
+func TestNewUniqueAndSorted(t *testing.T) {
+	const workers, per = 8, 2000
+	var mu sync.Mutex
+	seen := make(map[string]bool, workers*per)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < per; i++ {
+				id := New("payment")
+				mu.Lock()
+				if seen[id] {
+					t.Errorf("duplicate id %s", id)
+				}
+				seen[id] = true
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	prev := ""
+	for i := 0; i < 1000; i++ {
+		id := New("x")
+		if len(id) != len("x_")+26 || id <= prev {
+			t.Fatalf("id %q after %q", id, prev)
+		}
+		prev = id
+	}
+}
+
+func TestNextDocNumber(t *testing.T) {
+	seq := storage.JSONFile{Meta: map[string]any{}, Items: map[string]json.RawMessage{}}
+	jan := time.Date(2026, 1, 5, 0, 0, 0, 0, time.UTC)
+
+	var got []string
+	for _, tc := range []struct {
+		kind, site string
+		now        time.Time
+	}{
+		{DocBooking, "s1", jan},
+		{DocBooking, "s1", jan},
+		{DocBooking, "s2", jan},
+		{DocReceipt, "s1", jan},
+		{DocBooking, "s1", jan.AddDate(1, 0, 0)},
+	} {
+		n, err := NextDocNumber(&seq, tc.kind, tc.site, tc.now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, n)
+	}
+	want := "BK/2026/000001 BK/2026/000002 BK/2026/000001 KW/2026/000001 BK/2027/000001"
+	if strings.Join(got, " ") != want {
+		t.Fatalf("got %v", got)
+	}
+}
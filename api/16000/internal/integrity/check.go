@@ -0,0 +1,227 @@
+// Package integrity verifies references between the core files and the
+// derived payment state (schedule paid_amount, KPR dp_paid) against the
+// payments ledger, and repairs what can be recomputed safely.
+package integrity
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/itmtjewelry/land-booking-kpr/internal/domain"
+	"github.com/itmtjewelry/land-booking-kpr/internal/storage"
+)
+
+// Issue kinds.
+const (
+	KindInvalidRecord        = "invalid_record"
+	KindOrphan               = "orphan"
+	KindDuplicateKPR         = "duplicate_kpr"
+	KindSchedulePaidMismatch = "schedule_paid_mismatch"
+	KindDPPaidMismatch       = "dp_paid_mismatch"
+)
+
+// moneyEpsilon matches the tolerance payments use.
+const moneyEpsilon = 0.000001
+
+// Issue is one finding. Repairable issues are fixed by Repair; the rest
+// need a person to decide (e.g. which of two KPRs to keep).
+type Issue struct {
+	Kind       string `json:"kind"`
+	File       string `json:"file"`
+	ID         string `json:"id"`
+	Message    string `json:"message"`
+	Repairable bool   `json:"repairable"`
+}
+
+// Report is the result of Check.
+type Report struct {
+	Issues []Issue        `json:"issues"`
+	Counts map[string]int `json:"counts"`
+	Files  map[string]int `json:"files"`
+}
+
+// OK reports whether no issue was found.
+func (r *Report) OK() bool { return len(r.Issues) == 0 }
+
+func (r *Report) add(kind, file, id string, repairable bool, format string, args ...any) {
+	r.Issues = append(r.Issues, Issue{
+		Kind:       kind,
+		File:       file,
+		ID:         id,
+		Message:    fmt.Sprintf(format, args...),
+		Repairable: repairable,
+	})
+	r.Counts[kind]++
+}
+
+// snapshot is the typed view Check works on.
+type snapshot struct {
+	sites    map[string]domain.Site
+	subsites map[string]domain.Subsite
+	zones    map[string]domain.Zone
+	bookings map[string]domain.Booking
+	kprs     map[string]domain.KPRApplication
+	plans    map[string]domain.InstallmentPlan
+	payments map[string]domain.Payment
+}
+
+// Check inspects the loaded core files. It never modifies files.
+func Check(files map[string]storage.JSONFile) *Report {
+	rep := &Report{Issues: []Issue{}, Counts: map[string]int{}, Files: map[string]int{}}
+	s := snapshot{
+		sites:    decodeFile[domain.Site](rep, files, domain.SitesFile),
+		subsites: decodeFile[domain.Subsite](rep, files, domain.SubsitesFile),
+		zones:    decodeFile[domain.Zone](rep, files, domain.ZonesFile),
+		bookings: decodeFile[domain.Booking](rep, files, domain.BookingsFile),
+		kprs:     decodeFile[domain.KPRApplication](rep, files, domain.KPRApplicationsFile),
+		plans:    decodeFile[domain.InstallmentPlan](rep, files, domain.InstallmentPlansFile),
+		payments: decodeFile[domain.Payment](rep, files, domain.PaymentsFile),
+	}
+
+	checkReferences(rep, s)
+	checkDuplicateKPRs(rep, s)
+	checkLedger(rep, s)
+
+	sort.SliceStable(rep.Issues, func(i, j int) bool {
+		a, b := rep.Issues[i], rep.Issues[j]
+		if a.File != b.File {
+			return a.File < b.File
+		}
+		if a.ID != b.ID {
+			return a.ID < b.ID
+		}
+		return a.Kind < b.Kind
+	})
+	return rep
+}
+
+func decodeFile[T any, PT interface {
+	*T
+	domain.Record
+}](rep *Report, files map[string]storage.JSONFile, name string) map[string]T {
+	jf := files[name]
+	rep.Files[name] = len(jf.Items)
+	out := make(map[string]T, len(jf.Items))
+	for id, raw := range jf.Items {
+		v, err := domain.Decode[T, PT](id, raw)
+		if err != nil {
+			rep.add(KindInvalidRecord, name, id, false, "does not decode: %v", err)
+			continue
+		}
+		out[id] = v
+	}
+	return out
+}
+
+func checkReferences(rep *Report, s snapshot) {
+	for id, ss := range s.subsites {
+		if _, ok := s.sites[ss.SiteID]; !ok {
+			rep.add(KindOrphan, domain.SubsitesFile, id, false, "site_id %q not found", ss.SiteID)
+		}
+	}
+	for id, z := range s.zones {
+		if _, ok := s.subsites[z.SubsiteID]; !ok {
+			rep.add(KindOrphan, domain.ZonesFile, id, false, "subsite_id %q not found", z.SubsiteID)
+		}
+	}
+	for id, b := range s.bookings {
+		if _, ok := s.zones[b.ZoneID]; !ok {
+			rep.add(KindOrphan, domain.BookingsFile, id, false, "zone_id %q not found", b.ZoneID)
+		}
+	}
+	for id, k := range s.kprs {
+		if _, ok := s.bookings[k.BookingID]; !ok {
+			rep.add(KindOrphan, domain.KPRApplicationsFile, id, false, "booking_id %q not found", k.BookingID)
+		}
+	}
+	for id, p := range s.plans {
+		if _, ok := s.kprs[p.KPRID]; !ok {
+			rep.add(KindOrphan, domain.InstallmentPlansFile, id, false, "kpr_id %q not found", p.KPRID)
+		}
+	}
+	for id, p := range s.payments {
+		if _, ok := s.kprs[p.KPRID]; !ok {
+			rep.add(KindOrphan, domain.PaymentsFile, id, false, "kpr_id %q not found", p.KPRID)
+		}
+		if _, ok := s.bookings[p.BookingID]; !ok {
+			rep.add(KindOrphan, domain.PaymentsFile, id, false, "booking_id %q not found", p.BookingID)
+		}
+	}
+}
+
+func checkDuplicateKPRs(rep *Report, s snapshot) {
+	byBooking := map[string][]string{}
+	for id, k := range s.kprs {
+		byBooking[k.BookingID] = append(byBooking[k.BookingID], id)
+	}
+	for bookingID, ids := range byBooking {
+		if len(ids) < 2 {
+			continue
+		}
+		sort.Strings(ids)
+		rep.add(KindDuplicateKPR, domain.KPRApplicationsFile, ids[0], false,
+			"booking %q has %d KPR applications: %v", bookingID, len(ids), ids)
+	}
+}
+
+// ledger sums payments per KPR: DP total and per-installment totals.
+// Penalty payments settle penalties, not the schedule, and are not counted.
+type ledger struct {
+	dp          map[string]float64
+	installment map[string]map[int]float64
+}
+
+func buildLedger(payments map[string]domain.Payment) ledger {
+	l := ledger{dp: map[string]float64{}, installment: map[string]map[int]float64{}}
+	for _, p := range payments {
+		switch {
+		case p.Type == domain.PaymentPenalty:
+		case p.Type == domain.PaymentDP || (p.Type == "" && p.InstallmentNo == 0):
+			l.dp[p.KPRID] += float64(p.Amount)
+		default:
+			if l.installment[p.KPRID] == nil {
+				l.installment[p.KPRID] = map[int]float64{}
+			}
+			l.installment[p.KPRID][int(p.InstallmentNo)] += float64(p.Amount)
+		}
+	}
+	return l
+}
+
+func checkLedger(rep *Report, s snapshot) {
+	l := buildLedger(s.payments)
+
+	for id, k := range s.kprs {
+		if paid := l.dp[id]; !moneyEqual(float64(k.Price.DpPaid), paid) {
+			rep.add(KindDPPaidMismatch, domain.KPRApplicationsFile, id, true,
+				"price.dp_paid %v, dp payments total %v", float64(k.Price.DpPaid), paid)
+		}
+	}
+
+	for id, p := range s.plans {
+		paidByNo := l.installment[p.KPRID]
+		for _, it := range p.Schedule {
+			if paid := paidByNo[int(it.No)]; !moneyEqual(float64(it.PaidAmount), paid) {
+				rep.add(KindSchedulePaidMismatch, domain.InstallmentPlansFile, id, true,
+					"installment %d paid_amount %v, payments total %v", int(it.No), float64(it.PaidAmount), paid)
+			}
+		}
+		for no := range paidByNo {
+			if p.Installment(no) < 0 {
+				rep.add(KindSchedulePaidMismatch, domain.InstallmentPlansFile, id, false,
+					"payments recorded for installment %d which is not in the schedule", no)
+			}
+		}
+	}
+}
+
+func moneyEqual(a, b float64) bool {
+	return math.Abs(a-b) < moneyEpsilon
+}
+
+// marshalItem encodes a repaired record; typed records keep unknown fields.
+func marshalItem(v any) (json.RawMessage, error) {
+	return json.Marshal(v)
+}
@@ -0,0 +1,83 @@
+package integrity
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/itmtjewelry/land-booking-kpr/internal/domain"
+	"github.com/itmtjewelry/land-booking-kpr/internal/storage"
+)
+
+func file(items map[string]string) storage.JSONFile {
+	jf := storage.JSONFile{Meta: map[string]any{"version": 1}, Items: map[string]json.RawMessage{}}
+	for id, raw := range items {
+		jf.Items[id] = json.RawMessage(raw)
+	}
+	return jf
+}
+
+func fixture() map[string]storage.JSONFile {
+	return map[string]storage.JSONFile{
+		domain.SitesFile:    file(map[string]string{"s1": `{"id":"s1"}`}),
+		domain.SubsitesFile: file(map[string]string{"ss1": `{"id":"ss1","site_id":"s1"}`}),
+		domain.ZonesFile: file(map[string]string{
+			"z1": `{"id":"z1","subsite_id":"ss1"}`,
+			"z2": `{"id":"z2","subsite_id":"gone"}`,
+		}),
+		domain.BookingsFile: file(map[string]string{"b1": `{"id":"b1","zone_id":"z1"}`}),
+		domain.KPRApplicationsFile: file(map[string]string{
+			"k1": `{"id":"k1","booking_id":"b1","price":{"dp_amount":100,"dp_paid":40}}`,
+			"k2": `{"id":"k2","booking_id":"b1"}`,
+		}),
+		domain.InstallmentPlansFile: file(map[string]string{
+			"p1": `{"id":"p1","kpr_id":"k1","schedule":[{"no":1,"amount":50,"paid_amount":50,"status":"paid"},{"no":2,"amount":50,"paid_amount":0,"status":"unpaid"}]}`,
+		}),
+		domain.PaymentsFile: file(map[string]string{
+			"pay1": `{"id":"pay1","type":"dp","kpr_id":"k1","booking_id":"b1","installment_no":0,"amount":100}`,
+			"pay2": `{"id":"pay2","type":"installment","kpr_id":"k1","booking_id":"b1","installment_no":1,"amount":20}`,
+			"pay3": `{"id":"pay3","type":"penalty","kpr_id":"k1","booking_id":"b1","installment_no":1,"amount":5}`,
+		}),
+	}
+}
+
+func TestCheck(t *testing.T) {
+	rep := Check(fixture())
+	want := map[string]int{
+		KindOrphan:               1, // z2
+		KindDuplicateKPR:         1, // k1 + k2
+		KindDPPaidMismatch:       1, // k1: 40 vs 100
+		KindSchedulePaidMismatch: 1, // p1 #1: 50 vs 20 (penalty ignored)
+	}
+	for kind, n := range want {
+		if rep.Counts[kind] != n {
+			t.Errorf("%s: got %d, want %d (%+v)", kind, rep.Counts[kind], n, rep.Issues)
+		}
+	}
+}
+
+func TestRepairRecomputesFromLedger(t *testing.T) {
+	files := fixture()
+	changed, fixed, err := Repair(files)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fixed) != 2 || len(changed) != 2 {
+		t.Fatalf("fixed %d issues in %d files", len(fixed), len(changed))
+	}
+	for name, jf := range changed {
+		files[name] = jf
+	}
+
+	rep := Check(files)
+	if rep.Counts[KindDPPaidMismatch] != 0 || rep.Counts[KindSchedulePaidMismatch] != 0 {
+		t.Fatalf("still mismatched after repair: %+v", rep.Issues)
+	}
+	if rep.Counts[KindOrphan] != 1 || rep.Counts[KindDuplicateKPR] != 1 {
+		t.Fatalf("repair must not touch orphans/duplicates: %+v", rep.Counts)
+	}
+
+	p, _ := domain.Decode[domain.InstallmentPlan]("p1", files[domain.InstallmentPlansFile].Items["p1"])
+	if p.Schedule[0].Status != domain.InstallmentPartial {
+		t.Fatalf("status not recomputed: %+v", p.Schedule[0])
+	}
+}
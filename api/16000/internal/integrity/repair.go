@@ -0,0 +1,96 @@
+package integrity
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/itmtjewelry/land-booking-kpr/internal/domain"
+	"github.com/itmtjewelry/land-booking-kpr/internal/storage"
+)
+
+// Repair fixes the repairable issues of Check: KPR dp_paid and schedule
+// paid_amount/status are recomputed from the payments ledger, which is
+// append-only and therefore authoritative. Orphans and duplicates are never
+// touched.
+//
+// It returns updated copies of the files that changed (for the caller to
+// commit together) and the issues that were fixed.
+func Repair(files map[string]storage.JSONFile) (map[string]storage.JSONFile, []Issue, error) {
+	rep := Check(files)
+	fix := map[string]map[string]bool{}
+	fixed := []Issue{}
+	for _, is := range rep.Issues {
+		if !is.Repairable {
+			continue
+		}
+		if fix[is.File] == nil {
+			fix[is.File] = map[string]bool{}
+		}
+		fix[is.File][is.ID] = true
+		fixed = append(fixed, is)
+	}
+	if len(fixed) == 0 {
+		return map[string]storage.JSONFile{}, fixed, nil
+	}
+
+	l := buildLedger(domain.DecodeItems[domain.Payment](files[domain.PaymentsFile].Items))
+	now := time.Now().UTC().Format(time.RFC3339)
+	out := map[string]storage.JSONFile{}
+
+	if ids := fix[domain.KPRApplicationsFile]; len(ids) > 0 {
+		jf := copyFile(files[domain.KPRApplicationsFile])
+		for id := range ids {
+			k, err := domain.Decode[domain.KPRApplication](id, jf.Items[id])
+			if err != nil {
+				return nil, nil, err
+			}
+			k.Price.DpPaid = domain.Number(l.dp[id])
+			k.UpdatedAt = now
+			if jf.Items[id], err = marshalItem(k); err != nil {
+				return nil, nil, err
+			}
+		}
+		out[domain.KPRApplicationsFile] = jf
+	}
+
+	if ids := fix[domain.InstallmentPlansFile]; len(ids) > 0 {
+		jf := copyFile(files[domain.InstallmentPlansFile])
+		for id := range ids {
+			p, err := domain.Decode[domain.InstallmentPlan](id, jf.Items[id])
+			if err != nil {
+				return nil, nil, err
+			}
+			paidByNo := l.installment[p.KPRID]
+			for i := range p.Schedule {
+				it := &p.Schedule[i]
+				it.PaidAmount = domain.Number(paidByNo[int(it.No)])
+				switch {
+				case it.IsPaid():
+					it.Status = domain.InstallmentPaid
+				case it.PaidAmount > 0:
+					it.Status = domain.InstallmentPartial
+				default:
+					it.Status = domain.InstallmentUnpaid
+				}
+			}
+			p.UpdatedAt = now
+			if jf.Items[id], err = marshalItem(p); err != nil {
+				return nil, nil, err
+			}
+		}
+		out[domain.InstallmentPlansFile] = jf
+	}
+
+	return out, fixed, nil
+}
+
+func copyFile(jf storage.JSONFile) storage.JSONFile {
+	out := storage.JSONFile{
+		Meta:  storage.CloneMap(jf.Meta),
+		Items: make(map[string]json.RawMessage, len(jf.Items)),
+	}
+	for k, v := range jf.Items {
+		out.Items[k] = v
+	}
+	return out
+}
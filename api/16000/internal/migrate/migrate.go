@@ -0,0 +1,189 @@
+// Package migrate upgrades storage files to the schema version this binary
+// writes. Every file carries meta.version; migrations are ordered per file and
+// each one lifts the file from From to From+1, one record at a time.
+//
+// Migrations work on plain JSON maps rather than internal/domain types: they
+// describe the schema as it was at that version and must not change when the
+// domain model moves on.
+package migrate
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/itmtjewelry/land-booking-kpr/internal/storage"
+)
+
+// Migration lifts one file from version From to From+1.
+type Migration struct {
+	File        string
+	From        int
+	Description string
+
+	// Record rewrites one record in place and reports whether it changed.
+	Record func(id string, rec map[string]any) (bool, error)
+}
+
+// ErrNewerVersion means a file was written by a newer binary.
+var ErrNewerVersion = errors.New("storage file is newer than this binary")
+
+// CurrentVersion is the schema version this binary writes for file
+// (1 for files no migration touches).
+func CurrentVersion(file string) int {
+	v := 1
+	for _, m := range registry {
+		if m.File == file && m.From+1 > v {
+			v = m.From + 1
+		}
+	}
+	return v
+}
+
+// StepResult is one migration applied to one file.
+type StepResult struct {
+	From        int      `json:"from"`
+	To          int      `json:"to"`
+	Description string   `json:"description"`
+	Changed     []string `json:"changed"`
+}
+
+// FileResult describes the upgrade of one file.
+type FileResult struct {
+	File  string       `json:"file"`
+	From  int          `json:"from"`
+	To    int          `json:"to"`
+	Steps []StepResult `json:"steps"`
+}
+
+// Report lists every file that is (or, in a dry run, would be) upgraded.
+type Report struct {
+	DryRun bool         `json:"dry_run"`
+	Files  []FileResult `json:"files"`
+}
+
+// Run checks the version of every loaded file and upgrades the outdated ones.
+// All upgraded files are committed in one storage transaction, which backs up
+// the current files before replacing them. With dryRun nothing is written.
+//
+// A file whose version is newer than CurrentVersion fails the whole run with
+// ErrNewerVersion, before anything is written.
+func Run(dir string, loaded map[string]storage.JSONFile, dryRun bool) (*Report, error) {
+	names := make([]string, 0, len(loaded))
+	for name := range loaded {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	rep := &Report{DryRun: dryRun, Files: []FileResult{}}
+	staged := map[string]storage.JSONFile{}
+
+	for _, name := range names {
+		jf := loaded[name]
+		from, err := storage.MetaVersion(jf.Meta)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		to := CurrentVersion(name)
+		if from > to {
+			return nil, fmt.Errorf("%s: version %d, binary supports %d: %w", name, from, to, ErrNewerVersion)
+		}
+		if from == to {
+			continue
+		}
+
+		out, fr, err := upgrade(name, jf, from, to)
+		if err != nil {
+			return nil, err
+		}
+		staged[name] = out
+		rep.Files = append(rep.Files, fr)
+	}
+
+	if dryRun || len(staged) == 0 {
+		return rep, nil
+	}
+
+	tx := storage.BeginTx(dir)
+	for _, name := range names {
+		if jf, ok := staged[name]; ok {
+			tx.Stage(name, jf)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("write migrated files: %w", err)
+	}
+	return rep, nil
+}
+
+// upgrade applies every migration of name from version from to to, on a copy of jf.
+func upgrade(name string, jf storage.JSONFile, from, to int) (storage.JSONFile, FileResult, error) {
+	fr := FileResult{File: name, From: from, To: to, Steps: []StepResult{}}
+
+	items := make(map[string]json.RawMessage, len(jf.Items))
+	for id, raw := range jf.Items {
+		items[id] = raw
+	}
+	ids := make([]string, 0, len(items))
+	for id := range items {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	for v := from; v < to; v++ {
+		m, ok := lookup(name, v)
+		if !ok {
+			return jf, fr, fmt.Errorf("%s: no migration from version %d", name, v)
+		}
+		step := StepResult{From: v, To: v + 1, Description: m.Description, Changed: []string{}}
+		for _, id := range ids {
+			var rec map[string]any
+			if err := json.Unmarshal(items[id], &rec); err != nil {
+				return jf, fr, fmt.Errorf("%s: item %s: %w", name, id, err)
+			}
+			changed, err := m.Record(id, rec)
+			if err != nil {
+				return jf, fr, fmt.Errorf("%s: item %s: v%d->v%d: %w", name, id, v, v+1, err)
+			}
+			if !changed {
+				continue
+			}
+			b, err := json.Marshal(rec)
+			if err != nil {
+				return jf, fr, fmt.Errorf("%s: item %s: %w", name, id, err)
+			}
+			items[id] = b
+			step.Changed = append(step.Changed, id)
+		}
+		fr.Steps = append(fr.Steps, step)
+	}
+
+	meta := storage.CloneMap(jf.Meta)
+	if meta == nil {
+		meta = map[string]any{}
+	}
+	meta["version"] = to
+	meta["migrated_at"] = time.Now().UTC().Format(time.RFC3339)
+	return storage.JSONFile{Meta: meta, Items: items}, fr, nil
+}
+
+func lookup(file string, from int) (Migration, bool) {
+	for _, m := range registry {
+		if m.File == file && m.From == from {
+			return m, true
+		}
+	}
+	return Migration{}, false
+}
+
+// File upgrades one file on disk, e.g. after restoring a backup that predates
+// a migration.
+func File(dir, name string) (*Report, error) {
+	loaded, err := storage.LoadFiles(dir, name)
+	if err != nil {
+		return nil, err
+	}
+	return Run(dir, loaded, false)
+}
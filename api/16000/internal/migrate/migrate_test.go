@@ -0,0 +1,91 @@
+package migrate
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/itmtjewelry/land-booking-kpr/internal/storage"
+)
+
+func v1Bookings() map[string]storage.JSONFile {
+	return map[string]storage.JSONFile{
+		"bookings.json": {
+			Meta: map[string]any{"version": float64(1)},
+			Items: map[string]json.RawMessage{
+				"b1": json.RawMessage(`{"id":"b1","status":"pending","x":1}`),
+				"b2": json.RawMessage(`{"id":"b2","status":"confirmed"}`),
+				"b3": json.RawMessage(`{"id":"b3","status":"REJECTED"}`),
+			},
+		},
+	}
+}
+
+func TestRunUpgradesAndDryRunWritesNothing(t *testing.T) {
+	dir := t.TempDir()
+
+	rep, err := Run(dir, v1Bookings(), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rep.Files) != 1 || len(rep.Files[0].Steps[0].Changed) != 2 {
+		t.Fatalf("unexpected dry-run report: %+v", rep)
+	}
+	if _, err := storage.LoadFiles(dir, "bookings.json"); err == nil {
+		t.Fatal("dry run wrote bookings.json")
+	}
+
+	if _, err := Run(dir, v1Bookings(), false); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := storage.LoadFiles(dir, "bookings.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	jf := loaded["bookings.json"]
+	if v, _ := storage.MetaVersion(jf.Meta); v != CurrentVersion("bookings.json") {
+		t.Fatalf("version not bumped: %v", jf.Meta)
+	}
+	var b1 map[string]any
+	_ = json.Unmarshal(jf.Items["b1"], &b1)
+	if b1["status"] != "REQUESTED" || b1["x"] != float64(1) {
+		t.Fatalf("b1 not migrated cleanly: %v", b1)
+	}
+
+	// Already current: nothing to do.
+	rep, err = Run(dir, loaded, false)
+	if err != nil || len(rep.Files) != 0 {
+		t.Fatalf("second run: %+v, %v", rep, err)
+	}
+}
+
+func TestRunRefusesNewerVersion(t *testing.T) {
+	loaded := v1Bookings()
+	jf := loaded["bookings.json"]
+	jf.Meta["version"] = float64(CurrentVersion("bookings.json") + 1)
+
+	if _, err := Run(t.TempDir(), loaded, false); !errors.Is(err, ErrNewerVersion) {
+		t.Fatalf("want ErrNewerVersion, got %v", err)
+	}
+}
+
+func TestPlanPortionsV2(t *testing.T) {
+	rec := map[string]any{
+		"loan_amount": float64(300),
+		"schedule": []any{
+			map[string]any{"no": float64(2), "amount": float64(100)},
+			map[string]any{"no": float64(1), "amount": float64(200)},
+		},
+	}
+	changed, err := planPortionsV2("p1", rec)
+	if err != nil || !changed {
+		t.Fatalf("changed=%v err=%v", changed, err)
+	}
+	first := rec["schedule"].([]any)[1].(map[string]any)
+	if rec["formula"] != "flat" || first["principal"] != float64(200) || first["interest"] != 0 || first["balance"] != float64(100) {
+		t.Fatalf("migrated plan: %v", rec)
+	}
+	if changed, _ := planPortionsV2("p1", rec); changed {
+		t.Fatal("second run changed the plan")
+	}
+}
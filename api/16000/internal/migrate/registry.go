@@ -0,0 +1,112 @@
+package migrate
+
+import (
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// registry holds every migration. Append only: a released migration is never
+// edited, a fix ships as the next version.
+var registry = []Migration{
+	{
+		File:        "bookings.json",
+		From:        1,
+		Description: "booking statuses pending/confirmed/rejected/cancelled -> REQUESTED/APPROVED/REJECTED/CANCELLED",
+		Record:      bookingStatusesV2,
+	},
+	{
+		File:        "zones.json",
+		From:        1,
+		Description: "blank zone status -> AVAILABLE",
+		Record:      zoneStatusV2,
+	},
+	{
+		File:        "installment_plans.json",
+		From:        1,
+		Description: "flat plans: interest_rate 0, schedule principal/interest/balance",
+		Record:      planPortionsV2,
+	},
+}
+
+// bookingStatusesV2 moves bookings to the blueprint status names.
+func bookingStatusesV2(_ string, rec map[string]any) (bool, error) {
+	cur, _ := rec["status"].(string)
+	var next string
+	switch strings.ToLower(strings.TrimSpace(cur)) {
+	case "", "pending", "requested":
+		next = "REQUESTED"
+	case "confirmed", "approved":
+		next = "APPROVED"
+	case "rejected":
+		next = "REJECTED"
+	case "cancelled", "canceled":
+		next = "CANCELLED"
+	default:
+		// Unknown statuses are left as they are.
+		return false, nil
+	}
+	if next == cur {
+		return false, nil
+	}
+	rec["status"] = next
+	return true, nil
+}
+
+// zoneStatusV2 makes the implicit AVAILABLE status explicit.
+func zoneStatusV2(_ string, rec map[string]any) (bool, error) {
+	cur, _ := rec["status"].(string)
+	if strings.TrimSpace(cur) != "" {
+		return false, nil
+	}
+	rec["status"] = "AVAILABLE"
+	return true, nil
+}
+
+// planPortionsV2 spells out what v1 plans implied: zero-interest flat
+// installments, so every line is all principal.
+func planPortionsV2(_ string, rec map[string]any) (bool, error) {
+	changed := false
+	set := func(m map[string]any, key string, v any) {
+		if _, ok := m[key]; !ok {
+			m[key] = v
+			changed = true
+		}
+	}
+	if f, _ := rec["formula"].(string); strings.TrimSpace(f) == "" {
+		rec["formula"] = "flat"
+		changed = true
+	}
+	set(rec, "interest_rate", 0)
+
+	sched, _ := rec["schedule"].([]any)
+	lines := make([]map[string]any, 0, len(sched))
+	for _, v := range sched {
+		if m, ok := v.(map[string]any); ok {
+			lines = append(lines, m)
+		}
+	}
+	sort.SliceStable(lines, func(i, j int) bool { return number(lines[i]["no"]) < number(lines[j]["no"]) })
+
+	balance := number(rec["loan_amount"])
+	for _, m := range lines {
+		set(m, "principal", number(m["amount"]))
+		set(m, "interest", 0)
+		balance -= number(m["principal"])
+		set(m, "balance", math.Max(0, math.Round(balance*100)/100))
+	}
+	return changed, nil
+}
+
+// number reads a JSON number, accepting numeric strings as v1 files did.
+func number(v any) float64 {
+	switch t := v.(type) {
+	case float64:
+		return t
+	case string:
+		f, _ := strconv.ParseFloat(strings.TrimSpace(t), 64)
+		return f
+	}
+	return 0
+}
@@ -0,0 +1,153 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Backups are the `<file>.bak.YYYYMMDD_HHMMSS` copies written before every
+// overwrite (WriteJSONFileAtomic, Tx.Commit). Timestamps are server local time.
+
+const backupTimeLayout = "20060102_150405"
+
+// DefaultBackupRetention follows DECISIONS.md (14 days), with a per-file cap
+// so a burst of writes cannot fill the disk inside the window.
+var DefaultBackupRetention = RetentionPolicy{
+	MaxAge:      14 * 24 * time.Hour,
+	KeepPerFile: 200,
+}
+
+// RetentionPolicy decides which backups PruneBackups removes.
+// Zero values disable the corresponding limit.
+type RetentionPolicy struct {
+	MaxAge      time.Duration
+	KeepPerFile int
+}
+
+var (
+	ErrUnknownStorageFile = errors.New("unknown storage file")
+	ErrBackupNotFound     = errors.New("backup not found")
+	ErrBackupInvalid      = errors.New("backup invalid")
+)
+
+type Backup struct {
+	File      string    `json:"file"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+	Size      int64     `json:"size"`
+}
+
+// BackupFiles lists every file whose backups are managed (core, support and
+// optional files).
+func BackupFiles() []string {
+	out := make([]string, 0, len(CoreFiles)+len(SupportFiles)+len(OptionalFiles))
+	out = append(out, CoreFiles...)
+	out = append(out, SupportFiles...)
+	return append(out, OptionalFiles...)
+}
+
+// IsBackupFile reports whether filename is one of BackupFiles.
+func IsBackupFile(filename string) bool {
+	for _, f := range BackupFiles() {
+		if f == filename {
+			return true
+		}
+	}
+	return false
+}
+
+// ListBackups returns the backups of filename, newest first.
+func ListBackups(storageDir, filename string) ([]Backup, error) {
+	if !IsBackupFile(filename) {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownStorageFile, filename)
+	}
+	full := filepath.Join(storageDir, filename)
+	paths, err := filepath.Glob(full + ".bak.*")
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]Backup, 0, len(paths))
+	for _, p := range paths {
+		name := filepath.Base(p)
+		ts, err := time.ParseInLocation(backupTimeLayout, strings.TrimPrefix(p, full+".bak."), time.Local)
+		if err != nil {
+			continue
+		}
+		info, err := os.Stat(p)
+		if err != nil || !info.Mode().IsRegular() {
+			continue
+		}
+		out = append(out, Backup{File: filename, Name: name, CreatedAt: ts, Size: info.Size()})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].CreatedAt.After(out[j].CreatedAt)
+	})
+	return out, nil
+}
+
+// RestoreBackup replaces filename with one of its backups. The backup is
+// validated with loadOne first; the current file is itself backed up by the write.
+// Callers hold the file's lock and reload afterwards.
+func RestoreBackup(storageDir, filename, backupName string) error {
+	backups, err := ListBackups(storageDir, filename)
+	if err != nil {
+		return err
+	}
+	found := false
+	for _, b := range backups {
+		if b.Name == backupName {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return fmt.Errorf("%w: %q for %s", ErrBackupNotFound, backupName, filename)
+	}
+
+	src := filepath.Join(filepath.Dir(filepath.Join(storageDir, filename)), backupName)
+	jf, err := loadOne(src, filename)
+	if err != nil {
+		return fmt.Errorf("%w: %s: %v", ErrBackupInvalid, backupName, err)
+	}
+	if filename == SequencesFile {
+		cur, err := LoadSequences(storageDir)
+		if err != nil {
+			return err
+		}
+		if *jf, err = mergeSequences(cur, *jf); err != nil {
+			return err
+		}
+	}
+	return WriteJSONFileAtomic(storageDir, filename, *jf)
+}
+
+// PruneBackups deletes backups older than policy.MaxAge and any beyond the
+// newest policy.KeepPerFile of each file. It returns what was removed.
+func PruneBackups(storageDir string, policy RetentionPolicy, now time.Time) ([]Backup, error) {
+	removed := make([]Backup, 0)
+	for _, filename := range BackupFiles() {
+		backups, err := ListBackups(storageDir, filename)
+		if err != nil {
+			return removed, err
+		}
+		for i, b := range backups {
+			expired := policy.MaxAge > 0 && now.Sub(b.CreatedAt) > policy.MaxAge
+			overCap := policy.KeepPerFile > 0 && i >= policy.KeepPerFile
+			if !expired && !overCap {
+				continue
+			}
+			p := filepath.Join(filepath.Dir(filepath.Join(storageDir, filename)), b.Name)
+			if err := os.Remove(p); err != nil && !os.IsNotExist(err) {
+				return removed, fmt.Errorf("remove %s: %w", b.Name, err)
+			}
+			removed = append(removed, b)
+		}
+	}
+	return removed, nil
+}
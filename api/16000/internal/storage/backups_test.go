@@ -0,0 +1,178 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeBackup puts a backup of name taken at ts in dir.
+func writeBackup(t *testing.T, dir, name string, ts time.Time, content string) string {
+	t.Helper()
+	bak := name + ".bak." + ts.Format(backupTimeLayout)
+	if err := os.WriteFile(filepath.Join(dir, bak), []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return bak
+}
+
+func backupJSON(v string) string {
+	b, _ := marshalJSONFile(testFile(v))
+	return string(b)
+}
+
+func backupNames(bs []Backup) []string {
+	out := make([]string, 0, len(bs))
+	for _, b := range bs {
+		out = append(out, b.Name)
+	}
+	return out
+}
+
+func TestListBackups(t *testing.T) {
+	dir := t.TempDir()
+	t0 := time.Date(2026, 10, 1, 12, 0, 0, 0, time.Local)
+	older := writeBackup(t, dir, "zones.json", t0, backupJSON("1"))
+	newest := writeBackup(t, dir, "zones.json", t0.Add(48*time.Hour), backupJSON("3"))
+	middle := writeBackup(t, dir, "zones.json", t0.Add(time.Hour), backupJSON("2"))
+	writeBackup(t, dir, "sites.json", t0, backupJSON("x"))
+	// Neither a badly named file nor a directory counts as a backup.
+	if err := os.WriteFile(filepath.Join(dir, "zones.json.bak.manual"), []byte("{}"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(filepath.Join(dir, "zones.json.bak."+t0.Add(72*time.Hour).Format(backupTimeLayout)), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := ListBackups(dir, "zones.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := backupNames(got)
+	if len(names) != 3 || names[0] != newest || names[1] != middle || names[2] != older {
+		t.Fatalf("backups = %v, want newest first", names)
+	}
+	if !got[2].CreatedAt.Equal(t0) || got[2].File != "zones.json" || got[2].Size != int64(len(backupJSON("1"))) {
+		t.Fatalf("backup = %+v", got[2])
+	}
+
+	if _, err := ListBackups(dir, "../secrets.json"); !errors.Is(err, ErrUnknownStorageFile) {
+		t.Fatalf("unknown file: %v", err)
+	}
+}
+
+func TestRestoreBackup(t *testing.T) {
+	dir := t.TempDir()
+	if err := WriteJSONFileAtomic(dir, "zones.json", testFile("current")); err != nil {
+		t.Fatal(err)
+	}
+	old := writeBackup(t, dir, "zones.json", time.Date(2020, 10, 1, 12, 0, 0, 0, time.Local), backupJSON("old"))
+	broken := writeBackup(t, dir, "zones.json", time.Date(2020, 10, 2, 12, 0, 0, 0, time.Local), `{"items":{}}`)
+
+	if err := RestoreBackup(dir, "zones.json", "zones.json.bak.20190101_000000"); !errors.Is(err, ErrBackupNotFound) {
+		t.Fatalf("missing backup: %v", err)
+	}
+	if err := RestoreBackup(dir, "zones.json", broken); !errors.Is(err, ErrBackupInvalid) {
+		t.Fatalf("invalid backup: %v", err)
+	}
+	if readV(t, dir, "zones.json") != "current" {
+		t.Fatal("invalid backup overwrote the file")
+	}
+
+	if err := RestoreBackup(dir, "zones.json", old); err != nil {
+		t.Fatal(err)
+	}
+	if readV(t, dir, "zones.json") != "old" {
+		t.Fatal("backup not restored")
+	}
+
+	// The file it replaced was backed up first.
+	backups, err := ListBackups(dir, "zones.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(backups) != 3 || readV(t, dir, backups[0].Name) != "current" {
+		t.Fatalf("current file not backed up before restore: %v", backupNames(backups))
+	}
+}
+
+func TestRestoreSequencesKeepsHigherCounters(t *testing.T) {
+	dir := t.TempDir()
+	cur := JSONFile{Meta: map[string]any{"version": 1}, Items: map[string]json.RawMessage{}}
+	for i := 0; i < 5; i++ {
+		NextSequence(&cur, "receipt")
+	}
+	if err := WriteJSONFileAtomic(dir, SequencesFile, cur); err != nil {
+		t.Fatal(err)
+	}
+	old := JSONFile{Meta: map[string]any{"version": 1}, Items: map[string]json.RawMessage{}}
+	NextSequence(&old, "receipt")
+	NextSequence(&old, "invoice")
+	b, _ := marshalJSONFile(old)
+	bak := writeBackup(t, dir, SequencesFile, time.Date(2020, 10, 1, 12, 0, 0, 0, time.Local), string(b))
+
+	if err := RestoreBackup(dir, SequencesFile, bak); err != nil {
+		t.Fatal(err)
+	}
+	got, err := LoadSequences(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, _ := sequenceOf("receipt", got.Items["receipt"])
+	i, _ := sequenceOf("invoice", got.Items["invoice"])
+	if r.Last != 5 || i.Last != 1 {
+		t.Fatalf("receipt = %d, invoice = %d; want 5, 1", r.Last, i.Last)
+	}
+}
+
+func TestPruneBackups(t *testing.T) {
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.Local)
+	day := 24 * time.Hour
+
+	for _, tc := range []struct {
+		name   string
+		policy RetentionPolicy
+		ages   []time.Duration // one zones.json backup per age, newest first
+		keep   int             // how many of the newest survive
+	}{
+		{"no limits", RetentionPolicy{}, []time.Duration{0, 30 * day, 365 * day}, 3},
+		{"exactly MaxAge is kept", RetentionPolicy{MaxAge: 14 * day}, []time.Duration{day, 14 * day, 14*day + time.Second}, 2},
+		{"count cap", RetentionPolicy{KeepPerFile: 2}, []time.Duration{0, time.Hour, 2 * time.Hour}, 2},
+		{"count cap not reached", RetentionPolicy{KeepPerFile: 3}, []time.Duration{0, time.Hour, 2 * time.Hour}, 3},
+		{"both limits", RetentionPolicy{MaxAge: 14 * day, KeepPerFile: 2}, []time.Duration{day, 2 * day, 20 * day}, 2},
+		{"age removes within cap", RetentionPolicy{MaxAge: 14 * day, KeepPerFile: 5}, []time.Duration{day, 15 * day, 20 * day}, 1},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			var names []string
+			for _, age := range tc.ages {
+				names = append(names, writeBackup(t, dir, "zones.json", now.Add(-age), backupJSON("z")))
+			}
+			// The cap is per file: one sites.json backup is always kept.
+			site := writeBackup(t, dir, "sites.json", now, backupJSON("s"))
+
+			removed, err := PruneBackups(dir, tc.policy, now)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := len(names) - tc.keep; len(removed) != want {
+				t.Fatalf("removed %v, want %d", backupNames(removed), want)
+			}
+			left, _ := ListBackups(dir, "zones.json")
+			if got := backupNames(left); len(got) != tc.keep || (tc.keep > 0 && got[0] != names[0]) {
+				t.Fatalf("left %v", got)
+			}
+			for _, b := range removed {
+				if _, err := os.Stat(filepath.Join(dir, b.Name)); !os.IsNotExist(err) {
+					t.Fatalf("%s still on disk", b.Name)
+				}
+			}
+			if _, err := os.Stat(filepath.Join(dir, site)); err != nil {
+				t.Fatalf("sites.json backup pruned: %v", err)
+			}
+		})
+	}
+}
@@ -12,48 +12,82 @@ type JSONFile struct {
 	Items map[string]json.RawMessage `json:"items"`
 }
 
+// CoreFiles are the storage files every load requires.
+var CoreFiles = []string{
+	"users.json",
+	"sites.json",
+	"subsites.json",
+	"zones.json",
+	"bookings.json",
+	"domains.json",
+
+	// Stage 10
+	"kpr_applications.json",
+	"installment_plans.json",
+	"payments.json",
+}
+
+// SupportFiles belong to the support module. They are loaded with the core
+// when present and valid, but a missing or broken file never fails the load:
+// the core must keep working without them.
+var SupportFiles = []string{
+	"support/tickets.json",
+}
+
+// OptionalFiles are validated when present but never required. They are
+// read by their own code when needed, not kept in the load result; the
+// SupportFiles are not listed again here.
+var OptionalFiles = []string{
+	SequencesFile,
+}
+
 type LoadResult struct {
 	Dir        string
 	Loaded     map[string]JSONFile
 	LoadedList []string
+
+	// Skipped are support and optional files present on disk that failed to load.
+	Skipped map[string]error
+
+	// Recovered lists transactions finished or undone before loading (startup only).
+	Recovered []TxRecovery
 }
 
+// LoadCore is the startup load: it first recovers interrupted transactions
+// (see tx.go), then loads every core file.
 func LoadCore(storageDir string) (*LoadResult, error) {
-	if storageDir == "" {
-		return nil, fmt.Errorf("STORAGE_DIR is required")
+	if err := checkStorageDir(storageDir); err != nil {
+		return nil, err
 	}
-
-	info, err := os.Stat(storageDir)
+	recovered, err := RecoverTransactions(storageDir)
 	if err != nil {
-		return nil, fmt.Errorf("STORAGE_DIR not accessible: %w", err)
+		return nil, fmt.Errorf("transaction recovery failed: %w", err)
 	}
-	if !info.IsDir() {
-		return nil, fmt.Errorf("STORAGE_DIR is not a directory: %s", storageDir)
+	res, err := ReloadCore(storageDir)
+	if err != nil {
+		return nil, err
 	}
+	res.Recovered = recovered
+	return res, nil
+}
 
-	coreFiles := []string{
-		"users.json",
-		"sites.json",
-		"subsites.json",
-		"zones.json",
-		"bookings.json",
-		"domains.json",
-
-		// Stage 10
-		"kpr_applications.json",
-		"installment_plans.json",
-		"payments.json",
+// ReloadCore loads every core file without touching transaction journals,
+// so it is safe to call while other transactions are in flight.
+func ReloadCore(storageDir string) (*LoadResult, error) {
+	if err := checkStorageDir(storageDir); err != nil {
+		return nil, err
 	}
 
 	res := &LoadResult{
 		Dir:        storageDir,
-		Loaded:     make(map[string]JSONFile, len(coreFiles)),
-		LoadedList: make([]string, 0, len(coreFiles)),
+		Loaded:     make(map[string]JSONFile, len(CoreFiles)),
+		LoadedList: make([]string, 0, len(CoreFiles)),
+		Skipped:    map[string]error{},
 	}
 
-	for _, name := range coreFiles {
+	for _, name := range CoreFiles {
 		full := filepath.Join(storageDir, name)
-		jf, err := loadOne(full)
+		jf, err := loadOne(full, name)
 		if err != nil {
 			return nil, fmt.Errorf("load %s failed: %w", name, err)
 		}
@@ -61,16 +95,76 @@ func LoadCore(storageDir string) (*LoadResult, error) {
 		res.LoadedList = append(res.LoadedList, name)
 	}
 
-	_ = tryLoadOptional(filepath.Join(storageDir, "support", "tickets.json"))
+	for _, name := range SupportFiles {
+		jf, err := tryLoadOptional(storageDir, name)
+		if err != nil {
+			res.Skipped[name] = err
+			continue
+		}
+		if jf != nil {
+			res.Loaded[name] = *jf
+			res.LoadedList = append(res.LoadedList, name)
+		}
+	}
+	for _, name := range OptionalFiles {
+		if _, err := tryLoadOptional(storageDir, name); err != nil {
+			res.Skipped[name] = err
+		}
+	}
 	return res, nil
 }
 
-func loadOne(path string) (*JSONFile, error) {
+// LoadFiles loads only the named files (per-file reload after a write).
+func LoadFiles(storageDir string, names ...string) (map[string]JSONFile, error) {
+	if err := checkStorageDir(storageDir); err != nil {
+		return nil, err
+	}
+	out := make(map[string]JSONFile, len(names))
+	for _, name := range names {
+		jf, err := loadOne(filepath.Join(storageDir, name), name)
+		if err != nil {
+			return nil, fmt.Errorf("load %s failed: %w", name, err)
+		}
+		out[name] = *jf
+	}
+	return out, nil
+}
+
+func checkStorageDir(storageDir string) error {
+	if storageDir == "" {
+		return fmt.Errorf("STORAGE_DIR is required")
+	}
+	info, err := os.Stat(storageDir)
+	if err != nil {
+		return fmt.Errorf("STORAGE_DIR not accessible: %w", err)
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("STORAGE_DIR is not a directory: %s", storageDir)
+	}
+	return nil
+}
+
+// loadOne reads and parses path. name is the storage filename whose PII
+// fields are decrypted (see pii.go); "" leaves them as stored.
+func loadOne(path, name string) (*JSONFile, error) {
 	b, err := os.ReadFile(path)
 	if err != nil {
 		return nil, err
 	}
+	jf, err := parseJSONFile(b)
+	if err != nil {
+		return nil, err
+	}
+	if name != "" {
+		if err := decryptPII(name, jf); err != nil {
+			return nil, err
+		}
+	}
+	return jf, nil
+}
 
+// parseJSONFile applies the load rules: valid JSON with meta and items objects.
+func parseJSONFile(b []byte) (*JSONFile, error) {
 	var jf JSONFile
 	if err := json.Unmarshal(b, &jf); err != nil {
 		return nil, fmt.Errorf("invalid json: %w", err)
@@ -84,11 +178,46 @@ func loadOne(path string) (*JSONFile, error) {
 	return &jf, nil
 }
 
-func tryLoadOptional(path string) error {
-	_, err := os.Stat(path)
+// tryLoadOptional loads an optional file; a missing file is (nil, nil).
+func tryLoadOptional(storageDir, name string) (*JSONFile, error) {
+	path := filepath.Join(storageDir, name)
+	if _, err := os.Stat(path); err != nil {
+		return nil, nil
+	}
+	return loadOne(path, name)
+}
+
+// LoadOptionalFile reads an optional file from disk; a missing file is empty.
+// Callers hold the file's lock until the updated file is written.
+func LoadOptionalFile(storageDir, name string) (JSONFile, error) {
+	if err := checkStorageDir(storageDir); err != nil {
+		return JSONFile{}, err
+	}
+	jf, err := tryLoadOptional(storageDir, name)
 	if err != nil {
-		return nil
+		return JSONFile{}, fmt.Errorf("load %s failed: %w", name, err)
+	}
+	if jf == nil {
+		return JSONFile{Meta: map[string]any{"version": 1}, Items: map[string]json.RawMessage{}}, nil
+	}
+	return *jf, nil
+}
+
+// MetaVersion reads meta.version; files written before versioning count as 1.
+func MetaVersion(meta map[string]any) (int, error) {
+	v, ok := meta["version"]
+	if !ok || v == nil {
+		return 1, nil
+	}
+	switch t := v.(type) {
+	case float64:
+		if t >= 1 && t == float64(int(t)) {
+			return int(t), nil
+		}
+	case int:
+		if t >= 1 {
+			return t, nil
+		}
 	}
-	_, err = loadOne(path)
-	return err
+	return 0, fmt.Errorf("invalid meta.version %v", v)
 }
@@ -0,0 +1,63 @@
+package storage
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestReloadCoreSupportFiles(t *testing.T) {
+	dir := t.TempDir()
+	writeCore(t, dir, "v")
+	const name = "support/tickets.json"
+
+	lr, err := ReloadCore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := lr.Loaded[name]; ok || len(lr.Skipped) != 0 {
+		t.Fatalf("missing support file: loaded=%v skipped=%v", ok, lr.Skipped)
+	}
+
+	for _, n := range []string{name, SequencesFile} {
+		if err := WriteJSONFileAtomic(dir, n, testFile("t")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if lr, err = ReloadCore(dir); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := lr.Loaded[name]; !ok {
+		t.Fatal("support file not loaded")
+	}
+	if _, ok := lr.Loaded[SequencesFile]; ok {
+		t.Fatal("sequences loaded into the core snapshot")
+	}
+
+	// A broken support file is skipped; the core still loads.
+	if err := os.WriteFile(filepath.Join(dir, name), []byte("{"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if lr, err = ReloadCore(dir); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := lr.Loaded[name]; ok || lr.Skipped[name] == nil {
+		t.Fatalf("broken support file: skipped=%v", lr.Skipped)
+	}
+	if _, err := LoadOptionalFile(dir, name); err == nil {
+		t.Fatal("LoadOptionalFile read a broken file")
+	}
+}
+
+func TestBackupFilesListedOnce(t *testing.T) {
+	seen := map[string]bool{}
+	for _, name := range BackupFiles() {
+		if seen[name] {
+			t.Fatalf("%s listed twice", name)
+		}
+		seen[name] = true
+	}
+	if !seen["support/tickets.json"] || !seen[SequencesFile] {
+		t.Fatalf("backup files: %v", BackupFiles())
+	}
+}
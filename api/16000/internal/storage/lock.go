@@ -0,0 +1,192 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Cross-process locking.
+//
+// Every process that touches a storage directory (server, CLI commands, ops
+// scripts using flock(1)) coordinates through advisory locks on files under
+// `<dir>/.locks/`:
+//
+//   - `storage.lock` is the directory lock. Whole-directory operations
+//     (journal recovery, migrations, CLI restore/repair) hold it exclusively;
+//     everything else holds it shared.
+//   - `<file>.lock` is the per-file lock held exclusively around a
+//     read-modify-write of that file (FileLock takes the directory lock
+//     shared first).
+//
+// Locks are not reentrant: a process holding the directory lock exclusively
+// must not take a FileLock of the same directory. Loader functions never lock
+// on their own; callers hold the appropriate lock.
+//
+// On platforms without flock the locks only serialise within the process.
+//
+// A FileLock whose lock files cannot be opened or locked still serialises
+// within the process, but the file counts as unlocked while it is held:
+// WriteJSONFileAtomic and Tx.Commit refuse it with ErrLockNotHeld rather
+// than write without the cross-process lock.
+
+// ErrLockNotHeld refuses a write to a file whose FileLock is held without
+// its cross-process lock.
+var ErrLockNotHeld = errors.New("storage file lock not held")
+
+// unlockedFiles holds the files (dir/name, cleaned) whose FileLock is held
+// but failed to take the cross-process lock.
+var unlockedFiles sync.Map
+
+func checkLocked(storageDir string, filenames ...string) error {
+	for _, name := range filenames {
+		if _, ok := unlockedFiles.Load(filepath.Join(storageDir, name)); ok {
+			return fmt.Errorf("%s: %w", name, ErrLockNotHeld)
+		}
+	}
+	return nil
+}
+
+// LockDirName is the directory, inside a storage directory, holding the lock files.
+const LockDirName = ".locks"
+
+const dirLockName = "storage.lock"
+
+func lockFilePath(storageDir, name string) string {
+	return filepath.Join(storageDir, LockDirName, name)
+}
+
+// fileLockName maps a storage filename (which may contain "/") to a flat lock file name.
+func fileLockName(filename string) string {
+	return url.PathEscape(filename) + ".lock"
+}
+
+func openLockFile(path string) (*os.File, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+	return os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+}
+
+// DirLock is a held directory lock.
+type DirLock struct {
+	f *os.File
+}
+
+// LockDir blocks until it holds the directory lock of storageDir, exclusive
+// or shared.
+func LockDir(storageDir string, exclusive bool) (*DirLock, error) {
+	if err := checkStorageDir(storageDir); err != nil {
+		return nil, err
+	}
+	f, err := openLockFile(lockFilePath(storageDir, dirLockName))
+	if err != nil {
+		return nil, err
+	}
+	if err := flock(f, exclusive); err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	return &DirLock{f: f}, nil
+}
+
+// Unlock releases the lock.
+func (d *DirLock) Unlock() error {
+	if err := funlock(d.f); err != nil {
+		_ = d.f.Close()
+		return err
+	}
+	return d.f.Close()
+}
+
+// Locks hands out one FileLock per filename of a storage directory. The same
+// name always returns the same lock, whether or not it is a known file.
+type Locks struct {
+	dir string
+
+	// OnError, when set, is told about lock files that could not be opened or
+	// locked. The lock then only serialises within the process, and writes
+	// to the file fail with ErrLockNotHeld until it is released.
+	OnError func(filename string, err error)
+
+	mu    sync.Mutex
+	locks map[string]*FileLock
+}
+
+func NewLocks(storageDir string) *Locks {
+	return &Locks{dir: storageDir, locks: map[string]*FileLock{}}
+}
+
+// For returns the lock of filename.
+func (ls *Locks) For(filename string) *FileLock {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+	if l, ok := ls.locks[filename]; ok {
+		return l
+	}
+	l := &FileLock{ls: ls, name: filename}
+	ls.locks[filename] = l
+	return l
+}
+
+// FileLock is a sync.Locker held across processes: an in-process mutex, then
+// the directory lock (shared) and the file's lock (exclusive).
+type FileLock struct {
+	ls   *Locks
+	name string
+
+	mu   sync.Mutex
+	dirf *os.File
+	f    *os.File
+	held bool
+}
+
+func (l *FileLock) Lock() {
+	l.mu.Lock()
+	if err := l.acquire(); err != nil {
+		unlockedFiles.Store(filepath.Join(l.ls.dir, l.name), true)
+		if l.ls.OnError != nil {
+			l.ls.OnError(l.name, err)
+		}
+	}
+}
+
+func (l *FileLock) Unlock() {
+	if l.held {
+		_ = funlock(l.f)
+		_ = funlock(l.dirf)
+		l.held = false
+	} else {
+		unlockedFiles.Delete(filepath.Join(l.ls.dir, l.name))
+	}
+	l.mu.Unlock()
+}
+
+func (l *FileLock) acquire() error {
+	if l.dirf == nil {
+		f, err := openLockFile(lockFilePath(l.ls.dir, dirLockName))
+		if err != nil {
+			return err
+		}
+		l.dirf = f
+	}
+	if l.f == nil {
+		f, err := openLockFile(lockFilePath(l.ls.dir, fileLockName(l.name)))
+		if err != nil {
+			return err
+		}
+		l.f = f
+	}
+	if err := flock(l.dirf, false); err != nil {
+		return err
+	}
+	if err := flock(l.f, true); err != nil {
+		_ = funlock(l.dirf)
+		return err
+	}
+	l.held = true
+	return nil
+}
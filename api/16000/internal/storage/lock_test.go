@@ -0,0 +1,104 @@
+package storage
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// Two Locks on the same directory stand in for two processes: they hold
+// separate lock file descriptors.
+func TestFileLockAcrossHolders(t *testing.T) {
+	if !osLocks {
+		t.Skip("no OS file locks on this platform")
+	}
+	dir := t.TempDir()
+	a, b := NewLocks(dir), NewLocks(dir)
+
+	if a.For("unknown.json") != a.For("unknown.json") {
+		t.Fatal("lock for the same name is not stable")
+	}
+
+	l := a.For("bookings.json")
+	l.Lock()
+	got := make(chan struct{})
+	go func() {
+		b.For("bookings.json").Lock()
+		close(got)
+		b.For("bookings.json").Unlock()
+	}()
+	select {
+	case <-got:
+		t.Fatal("second holder got a held file lock")
+	case <-time.After(100 * time.Millisecond):
+	}
+	l.Unlock()
+	<-got
+
+	// Other files only share the directory lock.
+	l.Lock()
+	b.For("zones.json").Lock()
+	b.For("zones.json").Unlock()
+	l.Unlock()
+
+	// An exclusive directory lock waits for every file lock.
+	l.Lock()
+	locked := make(chan *DirLock)
+	go func() {
+		d, err := LockDir(dir, true)
+		if err != nil {
+			t.Error(err)
+		}
+		locked <- d
+	}()
+	select {
+	case <-locked:
+		t.Fatal("directory locked while a file lock is held")
+	case <-time.After(100 * time.Millisecond):
+	}
+	l.Unlock()
+	if d := <-locked; d != nil {
+		_ = d.Unlock()
+	}
+}
+
+// A FileLock that cannot take its lock files must not let the write through.
+func TestFileLockFailureRefusesWrites(t *testing.T) {
+	dir := t.TempDir()
+	// A plain file where the lock directory should be: nothing can be locked.
+	if err := os.WriteFile(filepath.Join(dir, LockDirName), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	ls := NewLocks(dir)
+	var failed []string
+	ls.OnError = func(name string, err error) { failed = append(failed, name) }
+
+	l := ls.For("a.json")
+	l.Lock()
+	if len(failed) != 1 || failed[0] != "a.json" {
+		t.Fatalf("OnError calls: %v", failed)
+	}
+	if err := WriteJSONFileAtomic(dir, "a.json", testFile("x")); !errors.Is(err, ErrLockNotHeld) {
+		t.Fatalf("write under a failed lock: %v", err)
+	}
+	tx := BeginTx(dir)
+	tx.Stage("b.json", testFile("x"))
+	tx.Stage("a.json", testFile("x"))
+	if err := tx.Commit(); !errors.Is(err, ErrLockNotHeld) {
+		t.Fatalf("commit under a failed lock: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "b.json")); !os.IsNotExist(err) {
+		t.Fatal("refused transaction wrote a file")
+	}
+	// Files whose lock is not involved are unaffected.
+	if err := WriteJSONFileAtomic(dir, "c.json", testFile("x")); err != nil {
+		t.Fatal(err)
+	}
+	l.Unlock()
+
+	if err := WriteJSONFileAtomic(dir, "a.json", testFile("x")); err != nil {
+		t.Fatalf("write after release: %v", err)
+	}
+}
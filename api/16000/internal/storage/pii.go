@@ -0,0 +1,338 @@
+package storage
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync/atomic"
+)
+
+// Field-level encryption of customer PII.
+//
+// The fields listed in PIIFields are encrypted when a file is written and
+// decrypted when it is loaded, so handlers see plaintext while the files,
+// their backups, journals and snapshots only hold ciphertext.
+//
+// Envelope scheme: every value gets a fresh data key (AES-256-GCM, bound to
+// file, record id and field path); the data key is wrapped with a key of the
+// Keyring. Stored form:
+//
+//	enc:v1:<key id>:<wrapped data key>:<ciphertext>   (base64, no padding)
+//
+// New values use the active (first) key; older keys stay in the keyring to
+// read existing values until RekeyPII rewrites them. Plaintext values are
+// still accepted on load and get encrypted by the next write.
+
+// PIIFields are the encrypted fields per storage file ("a.b" = nested field).
+var PIIFields = map[string][]string{
+	"kpr_applications.json": {"customer.nik", "customer.address", "customer.phone", "customer.email"},
+	"bookings.json":         {"customer_phone", "customer_email"},
+}
+
+const piiPrefix = "enc:v1:"
+
+var (
+	ErrPIIKey     = errors.New("pii key unavailable")
+	ErrPIIKeySpec = errors.New("invalid pii key spec")
+
+	keyIDPattern = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+)
+
+// Keyring holds the key-encryption keys by id. The first key is active.
+type Keyring struct {
+	active string
+	ids    []string
+	keys   map[string]cipher.AEAD
+}
+
+// ParseKeyring reads `id:base64key` entries (32-byte keys) separated by
+// commas or newlines; blank lines and `#` comments are ignored.
+func ParseKeyring(spec string) (*Keyring, error) {
+	k := &Keyring{keys: map[string]cipher.AEAD{}}
+	fields := strings.FieldsFunc(spec, func(r rune) bool { return r == ',' || r == '\n' })
+	for _, f := range fields {
+		f = strings.TrimSpace(f)
+		if f == "" || strings.HasPrefix(f, "#") {
+			continue
+		}
+		id, b64, ok := strings.Cut(f, ":")
+		if !ok || !keyIDPattern.MatchString(id) {
+			return nil, fmt.Errorf("%w: entry %q is not id:base64key", ErrPIIKeySpec, id)
+		}
+		if _, dup := k.keys[id]; dup {
+			return nil, fmt.Errorf("%w: duplicate key id %q", ErrPIIKeySpec, id)
+		}
+		key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(b64))
+		if err != nil || len(key) != 32 {
+			return nil, fmt.Errorf("%w: key %q must be 32 bytes, base64", ErrPIIKeySpec, id)
+		}
+		aead, err := newGCM(key)
+		if err != nil {
+			return nil, err
+		}
+		k.keys[id] = aead
+		k.ids = append(k.ids, id)
+	}
+	if len(k.ids) == 0 {
+		return nil, fmt.Errorf("%w: no keys", ErrPIIKeySpec)
+	}
+	k.active = k.ids[0]
+	return k, nil
+}
+
+// ActiveID is the id of the key new values are encrypted with.
+func (k *Keyring) ActiveID() string { return k.active }
+
+// IDs lists every key id, active first.
+func (k *Keyring) IDs() []string { return append([]string(nil), k.ids...) }
+
+var piiKeyring atomic.Pointer[Keyring]
+
+// SetKeyring installs the process keyring (nil disables encryption of new
+// writes; encrypted values then fail to load). Call it before loading.
+func SetKeyring(k *Keyring) { piiKeyring.Store(k) }
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func randomBytes(n int) ([]byte, error) {
+	b := make([]byte, n)
+	_, err := rand.Read(b)
+	return b, err
+}
+
+// IsEncryptedValue reports whether a stored string is a PII envelope.
+func IsEncryptedValue(v string) bool { return strings.HasPrefix(v, piiPrefix) }
+
+func piiAAD(name, id, path string) []byte {
+	return []byte(name + "\x00" + id + "\x00" + path)
+}
+
+func sealPII(k *Keyring, plain string, aad []byte) (string, error) {
+	dek, err := randomBytes(32)
+	if err != nil {
+		return "", err
+	}
+	data, err := newGCM(dek)
+	if err != nil {
+		return "", err
+	}
+	nonce, err := randomBytes(data.NonceSize())
+	if err != nil {
+		return "", err
+	}
+	ct := data.Seal(nonce, nonce, []byte(plain), aad)
+
+	kek := k.keys[k.active]
+	wnonce, err := randomBytes(kek.NonceSize())
+	if err != nil {
+		return "", err
+	}
+	wrapped := kek.Seal(wnonce, wnonce, dek, []byte(k.active))
+
+	enc := base64.RawStdEncoding
+	return piiPrefix + k.active + ":" + enc.EncodeToString(wrapped) + ":" + enc.EncodeToString(ct), nil
+}
+
+func openPII(k *Keyring, sealed string, aad []byte) (string, error) {
+	parts := strings.Split(strings.TrimPrefix(sealed, piiPrefix), ":")
+	if len(parts) != 3 {
+		return "", errors.New("malformed encrypted value")
+	}
+	kid := parts[0]
+	if k == nil {
+		return "", fmt.Errorf("%w: no keys configured (value uses key %q)", ErrPIIKey, kid)
+	}
+	kek, ok := k.keys[kid]
+	if !ok {
+		return "", fmt.Errorf("%w: unknown key id %q", ErrPIIKey, kid)
+	}
+	enc := base64.RawStdEncoding
+	wrapped, err1 := enc.DecodeString(parts[1])
+	ct, err2 := enc.DecodeString(parts[2])
+	if err1 != nil || err2 != nil || len(wrapped) < kek.NonceSize() {
+		return "", errors.New("malformed encrypted value")
+	}
+	dek, err := kek.Open(nil, wrapped[:kek.NonceSize()], wrapped[kek.NonceSize():], []byte(kid))
+	if err != nil {
+		return "", fmt.Errorf("unwrap data key (key %q): %w", kid, err)
+	}
+	data, err := newGCM(dek)
+	if err != nil {
+		return "", err
+	}
+	if len(ct) < data.NonceSize() {
+		return "", errors.New("malformed encrypted value")
+	}
+	plain, err := data.Open(nil, ct[:data.NonceSize()], ct[data.NonceSize():], aad)
+	if err != nil {
+		return "", fmt.Errorf("decrypt: %w", err)
+	}
+	return string(plain), nil
+}
+
+// piiField returns the parent object and key of a dotted path in rec.
+func piiField(rec map[string]any, path string) (map[string]any, string, bool) {
+	keys := strings.Split(path, ".")
+	obj := rec
+	for _, k := range keys[:len(keys)-1] {
+		next, ok := obj[k].(map[string]any)
+		if !ok {
+			return nil, "", false
+		}
+		obj = next
+	}
+	return obj, keys[len(keys)-1], true
+}
+
+// transformPII applies fn to every non-empty PII string of the items of
+// name. Items fn leaves unchanged keep their bytes. It returns a new items map.
+func transformPII(name string, items map[string]json.RawMessage, fn func(id, path, v string) (string, error)) (map[string]json.RawMessage, error) {
+	paths := PIIFields[name]
+	if len(paths) == 0 {
+		return items, nil
+	}
+	out := make(map[string]json.RawMessage, len(items))
+	for id, raw := range items {
+		out[id] = raw
+		rec, ok := decodeObject(raw)
+		if !ok {
+			continue
+		}
+		changed := false
+		for _, path := range paths {
+			obj, key, ok := piiField(rec, path)
+			if !ok {
+				continue
+			}
+			v, ok := obj[key].(string)
+			if !ok || v == "" {
+				continue
+			}
+			nv, err := fn(id, path, v)
+			if err != nil {
+				return nil, fmt.Errorf("item %s: %s: %w", id, path, err)
+			}
+			if nv != v {
+				obj[key] = nv
+				changed = true
+			}
+		}
+		if !changed {
+			continue
+		}
+		b, err := json.Marshal(rec)
+		if err != nil {
+			return nil, err
+		}
+		out[id] = b
+	}
+	return out, nil
+}
+
+// encryptPII returns jf with its plaintext PII encrypted under the active
+// key. jf itself is not modified. Without a keyring jf is returned as is.
+func encryptPII(name string, jf JSONFile) (JSONFile, error) {
+	k := piiKeyring.Load()
+	if k == nil || len(PIIFields[name]) == 0 {
+		return jf, nil
+	}
+	items, err := transformPII(name, jf.Items, func(id, path, v string) (string, error) {
+		if IsEncryptedValue(v) {
+			return v, nil
+		}
+		return sealPII(k, v, piiAAD(name, id, path))
+	})
+	if err != nil {
+		return jf, err
+	}
+	return JSONFile{Meta: jf.Meta, Items: items}, nil
+}
+
+// decryptPII decrypts the PII of a loaded file in place.
+func decryptPII(name string, jf *JSONFile) error {
+	if len(PIIFields[name]) == 0 {
+		return nil
+	}
+	k := piiKeyring.Load()
+	items, err := transformPII(name, jf.Items, func(id, path, v string) (string, error) {
+		if !IsEncryptedValue(v) {
+			return v, nil
+		}
+		return openPII(k, v, piiAAD(name, id, path))
+	})
+	if err != nil {
+		return err
+	}
+	jf.Items = items
+	return nil
+}
+
+// PIIKeyUsage counts the stored PII values of storageDir per key id
+// ("plaintext" for values not encrypted yet). Values are not decrypted.
+func PIIKeyUsage(storageDir string) (map[string]int, error) {
+	usage := map[string]int{}
+	for _, name := range piiFileNames() {
+		jf, err := loadOne(filepath.Join(storageDir, name), "")
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		_, err = transformPII(name, jf.Items, func(_, _, v string) (string, error) {
+			if !IsEncryptedValue(v) {
+				usage["plaintext"]++
+			} else {
+				kid, _, _ := strings.Cut(strings.TrimPrefix(v, piiPrefix), ":")
+				usage[kid]++
+			}
+			return v, nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return usage, nil
+}
+
+// RekeyPII rewrites every file with PII fields in one transaction so all
+// values are encrypted under the active key. Revisions do not change.
+// Callers hold the directory lock exclusively.
+func RekeyPII(storageDir string) ([]string, error) {
+	if piiKeyring.Load() == nil {
+		return nil, fmt.Errorf("%w: no keys configured", ErrPIIKey)
+	}
+	names := piiFileNames()
+	loaded, err := LoadFiles(storageDir, names...)
+	if err != nil {
+		return nil, err
+	}
+	tx := BeginTx(storageDir)
+	for _, name := range names {
+		tx.Stage(name, loaded[name])
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return names, nil
+}
+
+func piiFileNames() []string {
+	out := make([]string, 0, len(PIIFields))
+	for name := range PIIFields {
+		out = append(out, name)
+	}
+	sort.Strings(out)
+	return out
+}
@@ -0,0 +1,92 @@
+package storage
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func testKey(id string, b byte) string {
+	return id + ":" + base64.StdEncoding.EncodeToString([]byte(strings.Repeat(string(b), 32)))
+}
+
+func useKeys(t *testing.T, spec string) {
+	t.Helper()
+	k, err := ParseKeyring(spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	SetKeyring(k)
+	t.Cleanup(func() { SetKeyring(nil) })
+}
+
+func TestPIIEncryptionAndRekey(t *testing.T) {
+	dir := t.TempDir()
+	const name = "kpr_applications.json"
+	useKeys(t, testKey("k1", 'a'))
+
+	jf := JSONFile{Meta: map[string]any{}, Items: map[string]json.RawMessage{
+		"a": json.RawMessage(`{"id":"a","customer":{"name":"Budi","nik":"3174000000000001","email":""}}`),
+		"b": json.RawMessage(`{"id":"b","customer":{"name":"Sari","nik":"3174000000000002"}}`),
+	}}
+	if err := WriteJSONFileAtomic(dir, name, jf); err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteJSONFileAtomic(dir, "bookings.json", testFile("x")); err != nil {
+		t.Fatal(err)
+	}
+	raw, _ := os.ReadFile(filepath.Join(dir, name))
+	if strings.Contains(string(raw), "3174000000000001") || !strings.Contains(string(raw), "Budi") {
+		t.Fatalf("stored file: %s", raw)
+	}
+	loaded, err := LoadFiles(dir, name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(loaded[name].Items["a"]), `"nik":"3174000000000001"`) {
+		t.Fatalf("not decrypted: %s", loaded[name].Items["a"])
+	}
+
+	// New active key; the old one still reads until rekey.
+	useKeys(t, testKey("k2", 'b')+","+testKey("k1", 'a'))
+	if _, err := RekeyPII(dir); err != nil {
+		t.Fatal(err)
+	}
+	usage, err := PIIKeyUsage(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if usage["k2"] != 2 || usage["k1"] != 0 {
+		t.Fatalf("usage after rekey: %v", usage)
+	}
+	useKeys(t, testKey("k2", 'b'))
+	if _, err := LoadFiles(dir, name); err != nil {
+		t.Fatal(err)
+	}
+
+	// Ciphertext is bound to its record: swapping values fails to load.
+	raw, _ = os.ReadFile(filepath.Join(dir, name))
+	var file struct {
+		Meta  map[string]any            `json:"meta"`
+		Items map[string]map[string]any `json:"items"`
+	}
+	if err := json.Unmarshal(raw, &file); err != nil {
+		t.Fatal(err)
+	}
+	a, b := file.Items["a"]["customer"].(map[string]any), file.Items["b"]["customer"].(map[string]any)
+	a["nik"], b["nik"] = b["nik"], a["nik"]
+	swapped, _ := json.Marshal(file)
+	_ = os.WriteFile(filepath.Join(dir, name), swapped, 0o644)
+	if _, err := LoadFiles(dir, name); err == nil {
+		t.Fatal("swapped ciphertext loaded")
+	}
+
+	SetKeyring(nil)
+	if _, err := LoadFiles(dir, name); !errors.Is(err, ErrPIIKey) {
+		t.Fatalf("load without keys: %v", err)
+	}
+}
@@ -0,0 +1,143 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+// RevField is the per-item revision counter. It is owned by the storage
+// layer: every write compares each item with the file on disk and bumps the
+// revision of items whose content changed, so callers never maintain it.
+const RevField = "rev"
+
+// ItemRev returns the revision of a raw item (0 for items written before
+// revisions existed or that do not decode).
+func ItemRev(raw json.RawMessage) int {
+	var probe struct {
+		Rev json.Number `json:"rev"`
+	}
+	if err := json.Unmarshal(raw, &probe); err != nil {
+		return 0
+	}
+	n, err := probe.Rev.Int64()
+	if err != nil || n < 0 {
+		return 0
+	}
+	return int(n)
+}
+
+// stampFile stamps jf against the current content of full on disk.
+// A missing or unreadable current file counts as empty.
+func stampFile(full, name string, jf JSONFile) error {
+	var prevItems map[string]json.RawMessage
+	if prev, err := loadOne(full, name); err == nil {
+		prevItems = prev.Items
+	}
+	return stampRevisions(prevItems, jf.Items)
+}
+
+// stampRevisions updates next in place: unchanged items keep their previous
+// bytes, changed or new items get max(previous rev, own rev) + 1.
+func stampRevisions(prev, next map[string]json.RawMessage) error {
+	for id, raw := range next {
+		old, existed := prev[id]
+		if existed && string(old) == string(raw) {
+			continue
+		}
+
+		cur, ok := decodeObject(raw)
+		if !ok {
+			// Not an object: leave it for the loader to reject.
+			continue
+		}
+
+		rev := ItemRev(raw)
+		if existed {
+			if before, ok := decodeObject(old); ok && sameContent(before, cur) {
+				next[id] = old
+				continue
+			}
+			if r := ItemRev(old); r > rev {
+				rev = r
+			}
+		}
+
+		cur[RevField] = rev + 1
+		b, err := json.Marshal(cur)
+		if err != nil {
+			return err
+		}
+		next[id] = b
+	}
+	return nil
+}
+
+// decodeObject decodes a JSON object keeping numbers exact.
+func decodeObject(raw json.RawMessage) (map[string]any, bool) {
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	var m map[string]any
+	if err := dec.Decode(&m); err != nil || m == nil {
+		return nil, false
+	}
+	return m, true
+}
+
+// sameContent compares two decoded items ignoring their revisions.
+func sameContent(a, b map[string]any) bool {
+	return objectsEqual(a, b, RevField)
+}
+
+// objectsEqual compares decoded JSON objects, skipping key skip (if not empty).
+func objectsEqual(a, b map[string]any, skip string) bool {
+	n := 0
+	for k, av := range a {
+		if k == skip {
+			continue
+		}
+		bv, ok := b[k]
+		if !ok || !jsonEqual(av, bv) {
+			return false
+		}
+		n++
+	}
+	for k := range b {
+		if k != skip {
+			n--
+		}
+	}
+	return n == 0
+}
+
+// jsonEqual compares decoded JSON values; numbers compare by value so 1 and 1.0 match.
+func jsonEqual(a, b any) bool {
+	switch av := a.(type) {
+	case json.Number:
+		bv, ok := b.(json.Number)
+		if !ok {
+			return false
+		}
+		if av == bv {
+			return true
+		}
+		af, err1 := av.Float64()
+		bf, err2 := bv.Float64()
+		return err1 == nil && err2 == nil && af == bf
+	case map[string]any:
+		bv, ok := b.(map[string]any)
+		return ok && objectsEqual(av, bv, "")
+	case []any:
+		bv, ok := b.([]any)
+		if !ok || len(av) != len(bv) {
+			return false
+		}
+		for i := range av {
+			if !jsonEqual(av[i], bv[i]) {
+				return false
+			}
+		}
+		return true
+	default:
+		return a == b
+	}
+}
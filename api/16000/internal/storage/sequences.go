@@ -0,0 +1,79 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// SequencesFile holds persistent counters (document numbers), one item per
+// key: {"id": key, "last": n}. Counters never move backwards: restoring a
+// backup or importing a snapshot keeps the higher of the stored and the
+// incoming value, so a number handed out once is never handed out again.
+const SequencesFile = "sequences.json"
+
+type sequence struct {
+	ID   string `json:"id"`
+	Rev  int64  `json:"rev,omitempty"`
+	Last int64  `json:"last"`
+}
+
+// LoadSequences reads SequencesFile from disk; a missing file is empty.
+// Callers hold the file's lock until the updated file is written.
+func LoadSequences(storageDir string) (JSONFile, error) {
+	return LoadOptionalFile(storageDir, SequencesFile)
+}
+
+// NextSequence increments the counter key in jf and returns the new value.
+// A counter that does not decode is an error, never a restart at 1.
+func NextSequence(jf *JSONFile, key string) (int64, error) {
+	s, err := sequenceOf(key, jf.Items[key])
+	if err != nil {
+		return 0, err
+	}
+	s.ID = key
+	s.Last++
+	b, _ := json.Marshal(s)
+	jf.Items[key] = b
+	return s.Last, nil
+}
+
+func sequenceOf(key string, raw json.RawMessage) (sequence, error) {
+	var s sequence
+	if raw != nil {
+		if err := json.Unmarshal(raw, &s); err != nil {
+			return sequence{}, fmt.Errorf("%s: counter %q: %w", SequencesFile, key, err)
+		}
+	}
+	return s, nil
+}
+
+// mergeSequences returns next with every counter raised to at least its
+// value in cur (counters only present in cur are kept). A counter of either
+// side that does not decode is an error.
+func mergeSequences(cur, next JSONFile) (JSONFile, error) {
+	out := JSONFile{Meta: next.Meta, Items: make(map[string]json.RawMessage, len(next.Items))}
+	for k, v := range next.Items {
+		out.Items[k] = v
+	}
+	for k, raw := range cur.Items {
+		c, err := sequenceOf(k, raw)
+		if err != nil {
+			return JSONFile{}, err
+		}
+		n, ok := out.Items[k]
+		if !ok {
+			out.Items[k] = raw
+			continue
+		}
+		s, err := sequenceOf(k, n)
+		if err != nil {
+			return JSONFile{}, err
+		}
+		if s.Last < c.Last {
+			s.ID, s.Last = k, c.Last
+			b, _ := json.Marshal(s)
+			out.Items[k] = b
+		}
+	}
+	return out, nil
+}
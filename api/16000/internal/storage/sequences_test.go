@@ -0,0 +1,41 @@
+package storage
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestNextSequence(t *testing.T) {
+	jf := JSONFile{Meta: map[string]any{"version": 1}, Items: map[string]json.RawMessage{}}
+	for want := int64(1); want <= 3; want++ {
+		if n, err := NextSequence(&jf, "BK/s1/2026"); err != nil || n != want {
+			t.Fatalf("next = %d, %v; want %d", n, err, want)
+		}
+	}
+}
+
+// A damaged counter fails instead of restarting at 1.
+func TestDamagedSequence(t *testing.T) {
+	damaged := json.RawMessage(`{"id":"BK/s1/2026","last":"41"}`)
+	jf := JSONFile{Meta: map[string]any{"version": 1}, Items: map[string]json.RawMessage{"BK/s1/2026": damaged}}
+	if n, err := NextSequence(&jf, "BK/s1/2026"); err == nil {
+		t.Fatalf("next = %d from a damaged counter", n)
+	}
+	if string(jf.Items["BK/s1/2026"]) != string(damaged) {
+		t.Fatalf("damaged counter overwritten: %s", jf.Items["BK/s1/2026"])
+	}
+
+	// Nor does a restore merge over it.
+	dir := t.TempDir()
+	if err := WriteJSONFileAtomic(dir, SequencesFile, jf); err != nil {
+		t.Fatal(err)
+	}
+	old := JSONFile{Meta: map[string]any{"version": 1}, Items: map[string]json.RawMessage{}}
+	NextSequence(&old, "BK/s1/2026")
+	b, _ := marshalJSONFile(old)
+	bak := writeBackup(t, dir, SequencesFile, time.Date(2020, 10, 1, 12, 0, 0, 0, time.Local), string(b))
+	if err := RestoreBackup(dir, SequencesFile, bak); err == nil {
+		t.Fatal("restore merged over a damaged counter")
+	}
+}
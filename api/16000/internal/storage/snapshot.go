@@ -0,0 +1,389 @@
+package storage
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Snapshots are whole-directory archives (tar.gz) for staging refreshes and
+// disaster recovery. An archive holds `manifest.json` followed by every core
+// file, every present optional file and every storage file under `support/`.
+// Backups, journals and lock files are not part of it.
+
+const (
+	SnapshotManifestName = "manifest.json"
+	snapshotFormat       = 1
+	snapshotSupportDir   = "support"
+
+	// maxSnapshotBytes caps the uncompressed size ReadSnapshot accepts.
+	maxSnapshotBytes = 1 << 30
+)
+
+var ErrSnapshotInvalid = errors.New("snapshot invalid")
+
+type SnapshotManifest struct {
+	Format    int            `json:"format"`
+	CreatedAt string         `json:"created_at"`
+	Files     []SnapshotFile `json:"files"`
+}
+
+// SnapshotFile describes one archived file. Version is its meta.version.
+type SnapshotFile struct {
+	Name    string `json:"name"`
+	Size    int64  `json:"size"`
+	SHA256  string `json:"sha256"`
+	Version int    `json:"version"`
+	Items   int    `json:"items"`
+}
+
+// Snapshot is a validated archive read by ReadSnapshot.
+type Snapshot struct {
+	Manifest SnapshotManifest
+	Files    map[string]JSONFile
+}
+
+// SnapshotFileDiff counts the records an import would add, remove and change
+// in one file (revisions ignored).
+type SnapshotFileDiff struct {
+	File    string `json:"file"`
+	Added   int    `json:"added"`
+	Removed int    `json:"removed"`
+	Changed int    `json:"changed"`
+	Same    int    `json:"unchanged"`
+}
+
+// SnapshotFiles lists the files an export of storageDir covers: the core
+// files, the optional files present and the `*.json` storage files under
+// support/, sorted.
+func SnapshotFiles(storageDir string) ([]string, error) {
+	out := append([]string(nil), CoreFiles...)
+	for _, name := range OptionalFiles {
+		if _, err := os.Stat(filepath.Join(storageDir, name)); err == nil {
+			out = append(out, name)
+		}
+	}
+	root := filepath.Join(storageDir, snapshotSupportDir)
+	err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if d.IsDir() || !d.Type().IsRegular() || !strings.HasSuffix(d.Name(), ".json") {
+			return nil
+		}
+		rel, err := filepath.Rel(storageDir, p)
+		if err != nil {
+			return err
+		}
+		out = append(out, filepath.ToSlash(rel))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(out)
+	return out, nil
+}
+
+// ExportSnapshot writes an archive of storageDir to w. Callers hold the
+// directory lock so the files are read as one consistent state.
+func ExportSnapshot(storageDir string, w io.Writer) (*SnapshotManifest, error) {
+	if err := checkStorageDir(storageDir); err != nil {
+		return nil, err
+	}
+	names, err := SnapshotFiles(storageDir)
+	if err != nil {
+		return nil, err
+	}
+
+	m := &SnapshotManifest{
+		Format:    snapshotFormat,
+		CreatedAt: time.Now().UTC().Format(time.RFC3339),
+		Files:     make([]SnapshotFile, 0, len(names)),
+	}
+	contents := make([][]byte, 0, len(names))
+	for _, name := range names {
+		b, err := os.ReadFile(filepath.Join(storageDir, filepath.FromSlash(name)))
+		if err != nil {
+			return nil, fmt.Errorf("export %s: %w", name, err)
+		}
+		f, err := describeSnapshotFile(name, b)
+		if err != nil {
+			return nil, fmt.Errorf("export %s: %w", name, err)
+		}
+		m.Files = append(m.Files, f)
+		contents = append(contents, b)
+	}
+
+	mb, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	now := time.Now()
+	put := func(name string, b []byte) error {
+		hdr := &tar.Header{Name: name, Mode: 0o644, Size: int64(len(b)), ModTime: now, Typeflag: tar.TypeReg}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		_, err := tw.Write(b)
+		return err
+	}
+	if err := put(SnapshotManifestName, append(mb, '\n')); err != nil {
+		return nil, err
+	}
+	for i, name := range names {
+		if err := put(name, contents[i]); err != nil {
+			return nil, err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+	if err := gz.Close(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func describeSnapshotFile(name string, b []byte) (SnapshotFile, error) {
+	jf, err := parseJSONFile(b)
+	if err != nil {
+		return SnapshotFile{}, err
+	}
+	v, err := MetaVersion(jf.Meta)
+	if err != nil {
+		return SnapshotFile{}, err
+	}
+	sum := sha256.Sum256(b)
+	return SnapshotFile{
+		Name:    name,
+		Size:    int64(len(b)),
+		SHA256:  hex.EncodeToString(sum[:]),
+		Version: v,
+		Items:   len(jf.Items),
+	}, nil
+}
+
+// ReadSnapshot reads and validates an archive: every entry must be listed in
+// the manifest with a matching checksum, every core file must be present and
+// every file must pass the same checks as LoadCore. Nothing is written.
+func ReadSnapshot(r io.Reader) (*Snapshot, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrSnapshotInvalid, err)
+	}
+	defer gz.Close()
+
+	invalid := func(format string, args ...any) error {
+		return fmt.Errorf("%w: %s", ErrSnapshotInvalid, fmt.Sprintf(format, args...))
+	}
+
+	tr := tar.NewReader(io.LimitReader(gz, maxSnapshotBytes))
+	raw := map[string][]byte{}
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, invalid("%v", err)
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			return nil, invalid("%s: not a regular file", hdr.Name)
+		}
+		if !validSnapshotName(hdr.Name) {
+			return nil, invalid("unexpected entry %q", hdr.Name)
+		}
+		if _, dup := raw[hdr.Name]; dup {
+			return nil, invalid("duplicate entry %q", hdr.Name)
+		}
+		b, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, invalid("%s: %v", hdr.Name, err)
+		}
+		raw[hdr.Name] = b
+	}
+	// Read to the end so the gzip checksum is verified.
+	if _, err := io.Copy(io.Discard, gz); err != nil {
+		return nil, invalid("%v", err)
+	}
+
+	mb, ok := raw[SnapshotManifestName]
+	if !ok {
+		return nil, invalid("missing %s", SnapshotManifestName)
+	}
+	delete(raw, SnapshotManifestName)
+
+	s := &Snapshot{Files: map[string]JSONFile{}}
+	if err := json.Unmarshal(mb, &s.Manifest); err != nil {
+		return nil, invalid("manifest: %v", err)
+	}
+	if s.Manifest.Format != snapshotFormat {
+		return nil, invalid("unsupported format %d", s.Manifest.Format)
+	}
+
+	for _, want := range s.Manifest.Files {
+		b, ok := raw[want.Name]
+		if !ok {
+			return nil, invalid("%s: listed in manifest but missing", want.Name)
+		}
+		got, err := describeSnapshotFile(want.Name, b)
+		if err != nil {
+			return nil, invalid("%s: %v", want.Name, err)
+		}
+		if got != want {
+			return nil, invalid("%s: does not match manifest (checksum, size, version or item count)", want.Name)
+		}
+		jf, _ := parseJSONFile(b)
+		if err := decryptPII(want.Name, jf); err != nil {
+			return nil, invalid("%s: %v", want.Name, err)
+		}
+		s.Files[want.Name] = *jf
+	}
+	for name := range raw {
+		if _, ok := s.Files[name]; !ok {
+			return nil, invalid("%s: not listed in manifest", name)
+		}
+	}
+	for _, name := range CoreFiles {
+		if _, ok := s.Files[name]; !ok {
+			return nil, invalid("missing core file %s", name)
+		}
+	}
+	return s, nil
+}
+
+// validSnapshotName accepts the manifest, core files and support/*.json.
+func validSnapshotName(name string) bool {
+	if name == SnapshotManifestName {
+		return true
+	}
+	if path.Clean(name) != name || path.IsAbs(name) || strings.HasPrefix(name, "..") {
+		return false
+	}
+	for _, f := range BackupFiles() {
+		if name == f {
+			return true
+		}
+	}
+	return strings.HasPrefix(name, snapshotSupportDir+"/") && strings.HasSuffix(name, ".json")
+}
+
+// Names returns the archived filenames, sorted.
+func (s *Snapshot) Names() []string {
+	out := make([]string, 0, len(s.Files))
+	for name := range s.Files {
+		out = append(out, name)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// Diff compares the snapshot with storageDir. Files on disk that the import
+// would delete count every record as removed. Unreadable files count as empty.
+func (s *Snapshot) Diff(storageDir string) ([]SnapshotFileDiff, error) {
+	current, err := SnapshotFiles(storageDir)
+	if err != nil {
+		return nil, err
+	}
+	names := s.Names()
+	for _, name := range current {
+		if _, ok := s.Files[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	out := make([]SnapshotFileDiff, 0, len(names))
+	for _, name := range names {
+		var cur map[string]json.RawMessage
+		if jf, err := loadOne(filepath.Join(storageDir, filepath.FromSlash(name)), name); err == nil {
+			cur = jf.Items
+		}
+		next := s.Files[name].Items
+
+		d := SnapshotFileDiff{File: name}
+		for id, raw := range next {
+			old, ok := cur[id]
+			switch {
+			case !ok:
+				d.Added++
+			case itemsEqual(old, raw):
+				d.Same++
+			default:
+				d.Changed++
+			}
+		}
+		for id := range cur {
+			if _, ok := next[id]; !ok {
+				d.Removed++
+			}
+		}
+		out = append(out, d)
+	}
+	return out, nil
+}
+
+// itemsEqual compares two raw items ignoring their revisions.
+func itemsEqual(a, b json.RawMessage) bool {
+	if string(a) == string(b) {
+		return true
+	}
+	am, ok1 := decodeObject(a)
+	bm, ok2 := decodeObject(b)
+	return ok1 && ok2 && sameContent(am, bm)
+}
+
+// Apply replaces the contents of storageDir with the snapshot in one
+// transaction: archived files are written (revisions keep increasing) and
+// support files missing from the archive are deleted. Sequences only move
+// forward (see SequencesFile). Every replaced file is backed up first.
+// Callers hold the directory lock exclusively.
+func (s *Snapshot) Apply(storageDir string) error {
+	if err := checkStorageDir(storageDir); err != nil {
+		return err
+	}
+	current, err := SnapshotFiles(storageDir)
+	if err != nil {
+		return err
+	}
+
+	tx := BeginTx(storageDir)
+	for _, name := range s.Names() {
+		jf := s.Files[name]
+		if name == SequencesFile {
+			cur, err := LoadSequences(storageDir)
+			if err != nil {
+				return err
+			}
+			if jf, err = mergeSequences(cur, jf); err != nil {
+				return err
+			}
+		}
+		tx.Stage(filepath.FromSlash(name), jf)
+	}
+	for _, name := range current {
+		if _, ok := s.Files[name]; !ok && name != SequencesFile {
+			tx.Remove(filepath.FromSlash(name))
+		}
+	}
+	return tx.Commit()
+}
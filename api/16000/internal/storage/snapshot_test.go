@@ -0,0 +1,124 @@
+package storage
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeCore(t *testing.T, dir, v string) {
+	t.Helper()
+	for _, name := range CoreFiles {
+		if err := WriteJSONFileAtomic(dir, name, testFile(v)); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestSnapshotRoundTrip(t *testing.T) {
+	src, dst := t.TempDir(), t.TempDir()
+	writeCore(t, src, "new")
+	if err := WriteJSONFileAtomic(src, "support/tickets.json", testFile("t")); err != nil {
+		t.Fatal(err)
+	}
+	writeCore(t, dst, "old")
+	for dir, last := range map[string]int64{src: 2, dst: 5} {
+		seq, _ := LoadSequences(dir)
+		for i := int64(0); i < last; i++ {
+			NextSequence(&seq, "BK/s1/2026")
+		}
+		if err := WriteJSONFileAtomic(dir, SequencesFile, seq); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := WriteJSONFileAtomic(dst, "support/extra.json", testFile("x")); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	m, err := ExportSnapshot(src, &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m.Files) != len(CoreFiles)+2 {
+		t.Fatalf("manifest lists %d files", len(m.Files))
+	}
+
+	snap, err := ReadSnapshot(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	diff, err := snap.Diff(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	byFile := map[string]SnapshotFileDiff{}
+	for _, d := range diff {
+		byFile[d.File] = d
+	}
+	if d := byFile["zones.json"]; d.Changed != 1 {
+		t.Fatalf("zones.json: %+v", d)
+	}
+	if d := byFile["support/tickets.json"]; d.Added != 1 {
+		t.Fatalf("support/tickets.json: %+v", d)
+	}
+	if d := byFile["support/extra.json"]; d.Removed != 1 {
+		t.Fatalf("support/extra.json: %+v", d)
+	}
+
+	if err := snap.Apply(dst); err != nil {
+		t.Fatal(err)
+	}
+	if got := readV(t, dst, "zones.json"); got != "new" {
+		t.Fatalf("zones.json not imported: %q", got)
+	}
+	if _, err := os.Stat(filepath.Join(dst, "support", "extra.json")); !os.IsNotExist(err) {
+		t.Fatalf("support/extra.json not removed: %v", err)
+	}
+	// Sequences never move backwards.
+	seq, _ := LoadSequences(dst)
+	if n, err := NextSequence(&seq, "BK/s1/2026"); err != nil || n != 6 {
+		t.Fatalf("sequence after import: next = %d, %v", n, err)
+	}
+
+	// A file that does not match the manifest is rejected.
+	if _, err := ReadSnapshot(bytes.NewReader(repack(t, buf.Bytes(), "zones.json", `{"meta":{},"items":{}}`))); !errors.Is(err, ErrSnapshotInvalid) {
+		t.Fatalf("tampered archive: %v", err)
+	}
+}
+
+// repack rewrites one entry of an archive.
+func repack(t *testing.T, archive []byte, name, content string) []byte {
+	t.Helper()
+	gr, err := gzip.NewReader(bytes.NewReader(archive))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	gw := gzip.NewWriter(&out)
+	tr, tw := tar.NewReader(gr), tar.NewWriter(gw)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, _ := io.ReadAll(tr)
+		if hdr.Name == name {
+			b = []byte(content)
+		}
+		hdr.Size = int64(len(b))
+		_ = tw.WriteHeader(hdr)
+		_, _ = tw.Write(b)
+	}
+	_ = tw.Close()
+	_ = gw.Close()
+	return out.Bytes()
+}
@@ -0,0 +1,378 @@
+package storage
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Multi-file transactions.
+//
+// A Tx stages several JSONFiles and commits them as one unit:
+//
+//  1. journal `txn_<id>.journal` is written with state "prepared"
+//  2. every file is written (fsync) to `<file>.txn.<id>` next to its target
+//  3. targets are backed up (same `.bak.<ts>` scheme as WriteJSONFileAtomic)
+//  4. the journal is rewritten with state "committed"  <- commit point
+//  5. staged files are renamed over their targets, removed files are deleted
+//  6. the journal is removed
+//
+// RecoverTransactions (run by LoadCore at startup) replays committed journals
+// (step 5 is idempotent) and rolls back prepared ones by deleting staged files,
+// so disk never keeps half of a transaction.
+//
+// Commit retries step 5 a few times while the caller still holds the file
+// locks. If it still fails, the dir is marked as needing recovery: every
+// later write to it is refused with ErrNeedsRecovery, so the replay at the
+// next LoadCore cannot overwrite newer data with the old staged files.
+
+const (
+	txStatePrepared  = "prepared"
+	txStateCommitted = "committed"
+
+	txJournalPrefix = "txn_"
+	txJournalSuffix = ".journal"
+)
+
+var (
+	// ErrTxNotApplied is returned by Commit for a transaction that passed its
+	// commit point but whose files could not all be moved into place. Some of
+	// them may be: callers reload every staged file.
+	ErrTxNotApplied = errors.New("transaction committed but not applied")
+	// ErrNeedsRecovery refuses writes to a storage dir that holds an
+	// unapplied transaction, until RecoverTransactions runs.
+	ErrNeedsRecovery = errors.New("storage needs transaction recovery")
+)
+
+// Apply retries inside Commit; vars so tests can fail a rename.
+var (
+	txApplyAttempts   = 3
+	txApplyRetryDelay = 50 * time.Millisecond
+	txRename          = os.Rename
+)
+
+// unappliedTx holds the storage dirs (cleaned) with an unapplied transaction.
+var unappliedTx sync.Map
+
+// NeedsRecovery reports whether storageDir holds a committed transaction that
+// could not be applied; writes to it are refused until RecoverTransactions.
+func NeedsRecovery(storageDir string) bool {
+	_, ok := unappliedTx.Load(filepath.Clean(storageDir))
+	return ok
+}
+
+func checkWritable(storageDir string) error {
+	if id, ok := unappliedTx.Load(filepath.Clean(storageDir)); ok {
+		return fmt.Errorf("%w (transaction %s)", ErrNeedsRecovery, id)
+	}
+	return nil
+}
+
+type txJournal struct {
+	ID        string   `json:"id"`
+	State     string   `json:"state"`
+	CreatedAt string   `json:"created_at"`
+	Files     []string `json:"files"`
+	Removed   []string `json:"removed,omitempty"`
+}
+
+type txFile struct {
+	name string
+	jf   JSONFile
+}
+
+// Tx is a set of files written together. It is not safe for concurrent use;
+// callers hold the per-file locks of every staged file until Commit returns.
+type Tx struct {
+	dir     string
+	id      string
+	files   []txFile
+	removed []string
+}
+
+// TxRecovery reports what RecoverTransactions did with one journal.
+type TxRecovery struct {
+	ID     string   `json:"id"`
+	Action string   `json:"action"` // "replayed" | "rolled_back"
+	Files  []string `json:"files"`
+}
+
+// BeginTx starts a transaction against storageDir.
+func BeginTx(storageDir string) *Tx {
+	return &Tx{dir: storageDir, id: newTxID()}
+}
+
+// ID returns the transaction id used in journal and staged file names.
+func (tx *Tx) ID() string { return tx.id }
+
+// Files returns the staged filenames in staging order.
+func (tx *Tx) Files() []string {
+	out := make([]string, 0, len(tx.files))
+	for _, f := range tx.files {
+		out = append(out, f.name)
+	}
+	return out
+}
+
+// Stage queues filename (relative to the storage dir) to be written on Commit.
+// Staging the same file twice keeps the last content.
+func (tx *Tx) Stage(filename string, jf JSONFile) {
+	for i := range tx.files {
+		if tx.files[i].name == filename {
+			tx.files[i].jf = jf
+			return
+		}
+	}
+	tx.files = append(tx.files, txFile{name: filename, jf: jf})
+}
+
+// Remove deletes filename as part of the transaction (backed up like an
+// overwrite). Removing a missing file is not an error.
+func (tx *Tx) Remove(filename string) {
+	for _, name := range tx.removed {
+		if name == filename {
+			return
+		}
+	}
+	tx.removed = append(tx.removed, filename)
+}
+
+// Commit writes every staged file or none of them.
+//
+// An error before the commit point leaves disk untouched. An error after it
+// wraps ErrTxNotApplied: the journal stays in place, the dir refuses writes
+// and the next LoadCore finishes the transaction.
+func (tx *Tx) Commit() error {
+	if tx.dir == "" {
+		return fmt.Errorf("storage dir is empty")
+	}
+	if err := checkWritable(tx.dir); err != nil {
+		return err
+	}
+	if len(tx.files) == 0 && len(tx.removed) == 0 {
+		return nil
+	}
+	if err := checkLocked(tx.dir, append(tx.Files(), tx.removed...)...); err != nil {
+		return err
+	}
+	for _, name := range tx.removed {
+		if err := checkTxFilename(name); err != nil {
+			return err
+		}
+	}
+
+	names := make([]string, 0, len(tx.files))
+	payloads := make([][]byte, 0, len(tx.files))
+	for _, f := range tx.files {
+		if err := checkTxFilename(f.name); err != nil {
+			return err
+		}
+		full := filepath.Join(tx.dir, f.name)
+		if err := os.MkdirAll(filepath.Dir(full), 0o755); err != nil {
+			return fmt.Errorf("mkdir: %w", err)
+		}
+		if err := stampFile(full, f.name, f.jf); err != nil {
+			return fmt.Errorf("%s: %w", f.name, err)
+		}
+		enc, err := encryptPII(f.name, f.jf)
+		if err != nil {
+			return fmt.Errorf("%s: %w", f.name, err)
+		}
+		b, err := marshalJSONFile(enc)
+		if err != nil {
+			return fmt.Errorf("%s: %w", f.name, err)
+		}
+		names = append(names, f.name)
+		payloads = append(payloads, b)
+	}
+
+	j := txJournal{
+		ID:        tx.id,
+		State:     txStatePrepared,
+		CreatedAt: time.Now().UTC().Format(time.RFC3339),
+		Files:     names,
+		Removed:   tx.removed,
+	}
+	if err := writeJournal(tx.dir, j); err != nil {
+		return err
+	}
+
+	for i, name := range names {
+		if err := writeFileSync(stagedPath(tx.dir, name, tx.id), payloads[i]); err != nil {
+			tx.rollback(names)
+			return fmt.Errorf("stage %s: %w", name, err)
+		}
+	}
+	if err := fsyncDirs(tx.dir, names); err != nil {
+		tx.rollback(names)
+		return fmt.Errorf("fsync dir: %w", err)
+	}
+	for _, name := range append(append([]string(nil), names...), tx.removed...) {
+		if err := backupFile(filepath.Join(tx.dir, name)); err != nil {
+			tx.rollback(names)
+			return fmt.Errorf("%s: %w", name, err)
+		}
+	}
+
+	j.State = txStateCommitted
+	if err := writeJournal(tx.dir, j); err != nil {
+		tx.rollback(names)
+		return err
+	}
+
+	var err error
+	for attempt := 1; attempt <= txApplyAttempts; attempt++ {
+		if err = applyTx(tx.dir, j); err == nil {
+			return nil
+		}
+		if attempt < txApplyAttempts {
+			time.Sleep(txApplyRetryDelay)
+		}
+	}
+	unappliedTx.Store(filepath.Clean(tx.dir), tx.id)
+	return fmt.Errorf("transaction %s: %w (recovered on next load): %w", tx.id, ErrTxNotApplied, err)
+}
+
+func (tx *Tx) rollback(names []string) {
+	for _, name := range names {
+		_ = os.Remove(stagedPath(tx.dir, name, tx.id))
+	}
+	_ = os.Remove(journalPath(tx.dir, tx.id))
+	_ = fsyncDir(tx.dir)
+}
+
+// applyTx moves committed staged files into place, deletes removed files and
+// drops the journal. A staged file that is already gone was moved by an
+// earlier attempt.
+func applyTx(dir string, j txJournal) error {
+	for _, name := range j.Files {
+		staged := stagedPath(dir, name, j.ID)
+		if _, err := os.Stat(staged); err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return err
+		}
+		if err := txRename(staged, filepath.Join(dir, name)); err != nil {
+			return fmt.Errorf("rename %s: %w", name, err)
+		}
+	}
+	for _, name := range j.Removed {
+		if err := os.Remove(filepath.Join(dir, name)); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("remove %s: %w", name, err)
+		}
+	}
+	if err := fsyncDirs(dir, append(append([]string(nil), j.Files...), j.Removed...)); err != nil {
+		return fmt.Errorf("fsync dir: %w", err)
+	}
+	if err := os.Remove(journalPath(dir, j.ID)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("remove journal: %w", err)
+	}
+	return fsyncDir(dir)
+}
+
+// RecoverTransactions finishes or undoes transactions interrupted by a crash.
+// It must only run while no transaction is in flight (i.e. at startup).
+func RecoverTransactions(storageDir string) ([]TxRecovery, error) {
+	paths, err := filepath.Glob(filepath.Join(storageDir, txJournalPrefix+"*"+txJournalSuffix))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+
+	out := make([]TxRecovery, 0, len(paths))
+	for _, p := range paths {
+		b, err := os.ReadFile(p)
+		if err != nil {
+			return out, fmt.Errorf("read journal %s: %w", filepath.Base(p), err)
+		}
+		var j txJournal
+		if err := json.Unmarshal(b, &j); err != nil {
+			return out, fmt.Errorf("invalid journal %s: %w", filepath.Base(p), err)
+		}
+		for _, name := range append(append([]string(nil), j.Files...), j.Removed...) {
+			if err := checkTxFilename(name); err != nil {
+				return out, fmt.Errorf("journal %s: %w", filepath.Base(p), err)
+			}
+		}
+
+		rec := TxRecovery{ID: j.ID, Files: j.Files}
+		if j.State == txStateCommitted {
+			if err := applyTx(storageDir, j); err != nil {
+				return out, fmt.Errorf("replay %s: %w", j.ID, err)
+			}
+			rec.Action = "replayed"
+		} else {
+			(&Tx{dir: storageDir, id: j.ID}).rollback(j.Files)
+			rec.Action = "rolled_back"
+		}
+		out = append(out, rec)
+	}
+	unappliedTx.Delete(filepath.Clean(storageDir))
+	return out, nil
+}
+
+func writeJournal(dir string, j txJournal) error {
+	b, err := json.Marshal(j)
+	if err != nil {
+		return fmt.Errorf("marshal journal: %w", err)
+	}
+	full := journalPath(dir, j.ID)
+	tmp := full + ".tmp"
+	if err := writeFileSync(tmp, append(b, '\n')); err != nil {
+		return fmt.Errorf("journal: %w", err)
+	}
+	if err := os.Rename(tmp, full); err != nil {
+		return fmt.Errorf("journal rename: %w", err)
+	}
+	if err := fsyncDir(dir); err != nil {
+		return fmt.Errorf("journal fsync dir: %w", err)
+	}
+	return nil
+}
+
+func journalPath(dir, id string) string {
+	return filepath.Join(dir, txJournalPrefix+id+txJournalSuffix)
+}
+
+func stagedPath(dir, name, id string) string {
+	return filepath.Join(dir, name) + ".txn." + id
+}
+
+func checkTxFilename(name string) error {
+	clean := filepath.Clean(name)
+	if name == "" || filepath.IsAbs(name) || clean != name || strings.HasPrefix(clean, "..") {
+		return fmt.Errorf("invalid transaction filename %q", name)
+	}
+	return nil
+}
+
+// fsyncDirs syncs every distinct directory holding one of names.
+func fsyncDirs(dir string, names []string) error {
+	seen := map[string]bool{}
+	for _, name := range names {
+		d := filepath.Dir(filepath.Join(dir, name))
+		if seen[d] {
+			continue
+		}
+		seen[d] = true
+		if err := fsyncDir(d); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func newTxID() string {
+	var b [4]byte
+	_, _ = rand.Read(b[:])
+	return time.Now().UTC().Format("20060102T150405.000000000") + "_" + hex.EncodeToString(b[:])
+}
@@ -0,0 +1,183 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func testFile(v string) JSONFile {
+	return JSONFile{
+		Meta:  map[string]any{"version": 1},
+		Items: map[string]json.RawMessage{"a": json.RawMessage(`{"v":"` + v + `"}`)},
+	}
+}
+
+func readV(t *testing.T, dir, name string) string {
+	t.Helper()
+	jf, err := loadOne(filepath.Join(dir, name), name)
+	if err != nil {
+		t.Fatalf("load %s: %v", name, err)
+	}
+	var m map[string]string
+	_ = json.Unmarshal(jf.Items["a"], &m)
+	return m["v"]
+}
+
+func TestTxCommit(t *testing.T) {
+	dir := t.TempDir()
+	if err := WriteJSONFileAtomic(dir, "a.json", testFile("old")); err != nil {
+		t.Fatal(err)
+	}
+
+	tx := BeginTx(dir)
+	tx.Stage("a.json", testFile("new"))
+	tx.Stage("b.json", testFile("new"))
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if readV(t, dir, "a.json") != "new" || readV(t, dir, "b.json") != "new" {
+		t.Fatal("staged files not applied")
+	}
+	if _, err := os.Stat(journalPath(dir, tx.ID())); !os.IsNotExist(err) {
+		t.Fatal("journal left behind")
+	}
+}
+
+func TestRecoverTransactions(t *testing.T) {
+	dir := t.TempDir()
+	for _, n := range []string{"a.json", "b.json"} {
+		if err := WriteJSONFileAtomic(dir, n, testFile("old")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Crash after the commit point with only a.json moved into place.
+	committed := txJournal{ID: "c1", State: txStateCommitted, Files: []string{"a.json", "b.json"}}
+	if err := writeJournal(dir, committed); err != nil {
+		t.Fatal(err)
+	}
+	b, _ := marshalJSONFile(testFile("new"))
+	_ = writeFileSync(filepath.Join(dir, "a.json"), b)
+	_ = writeFileSync(stagedPath(dir, "b.json", "c1"), b)
+
+	// Crash before the commit point.
+	prepared := txJournal{ID: "p1", State: txStatePrepared, Files: []string{"a.json"}}
+	if err := writeJournal(dir, prepared); err != nil {
+		t.Fatal(err)
+	}
+	bad, _ := marshalJSONFile(testFile("uncommitted"))
+	_ = writeFileSync(stagedPath(dir, "a.json", "p1"), bad)
+
+	recs, err := RecoverTransactions(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(recs) != 2 || recs[0].Action != "replayed" || recs[1].Action != "rolled_back" {
+		t.Fatalf("unexpected recovery: %+v", recs)
+	}
+	if readV(t, dir, "a.json") != "new" || readV(t, dir, "b.json") != "new" {
+		t.Fatal("committed transaction not replayed")
+	}
+	if _, err := os.Stat(stagedPath(dir, "a.json", "p1")); !os.IsNotExist(err) {
+		t.Fatal("prepared transaction not rolled back")
+	}
+	if left, _ := filepath.Glob(filepath.Join(dir, "txn_*")); len(left) != 0 {
+		t.Fatalf("journals left behind: %v", left)
+	}
+}
+
+func TestTxCommitRenameFails(t *testing.T) {
+	dir := t.TempDir()
+	for _, n := range []string{"a.json", "b.json"} {
+		if err := WriteJSONFileAtomic(dir, n, testFile("old")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	failing := 0 // renames of b.json left to fail
+	txRename = func(from, to string) error {
+		if filepath.Base(to) == "b.json" && failing > 0 {
+			failing--
+			return errors.New("injected rename failure")
+		}
+		return os.Rename(from, to)
+	}
+	delay := txApplyRetryDelay
+	txApplyRetryDelay = 0
+	defer func() { txRename, txApplyRetryDelay = os.Rename, delay }()
+
+	// A transient failure is retried inside Commit.
+	failing = 1
+	tx := BeginTx(dir)
+	tx.Stage("a.json", testFile("one"))
+	tx.Stage("b.json", testFile("one"))
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("retried apply: %v", err)
+	}
+	if readV(t, dir, "b.json") != "one" {
+		t.Fatal("retried rename not applied")
+	}
+
+	// A persistent one leaves a.json applied, b.json staged, and the dir
+	// refusing writes until recovery.
+	failing = txApplyAttempts
+	tx = BeginTx(dir)
+	tx.Stage("a.json", testFile("two"))
+	tx.Stage("b.json", testFile("two"))
+	if err := tx.Commit(); !errors.Is(err, ErrTxNotApplied) {
+		t.Fatalf("Commit = %v, want ErrTxNotApplied", err)
+	}
+	if readV(t, dir, "a.json") != "two" || readV(t, dir, "b.json") != "one" {
+		t.Fatal("unexpected partial apply")
+	}
+	if !NeedsRecovery(dir) {
+		t.Fatal("dir not marked for recovery")
+	}
+	if err := WriteJSONFileAtomic(dir, "a.json", testFile("later")); !errors.Is(err, ErrNeedsRecovery) {
+		t.Fatalf("write after unapplied tx = %v", err)
+	}
+	later := BeginTx(dir)
+	later.Stage("a.json", testFile("later"))
+	if err := later.Commit(); !errors.Is(err, ErrNeedsRecovery) {
+		t.Fatalf("commit after unapplied tx = %v", err)
+	}
+
+	if _, err := RecoverTransactions(dir); err != nil {
+		t.Fatal(err)
+	}
+	if readV(t, dir, "a.json") != "two" || readV(t, dir, "b.json") != "two" || NeedsRecovery(dir) {
+		t.Fatal("recovery did not finish the transaction")
+	}
+	if err := WriteJSONFileAtomic(dir, "a.json", testFile("later")); err != nil {
+		t.Fatalf("write after recovery: %v", err)
+	}
+}
+
+func TestRevisionsStamped(t *testing.T) {
+	dir := t.TempDir()
+	jf := JSONFile{Meta: map[string]any{"version": 1}, Items: map[string]json.RawMessage{
+		"a": json.RawMessage(`{"v":"x"}`),
+		"b": json.RawMessage(`{"v":"y","n":1}`),
+	}}
+	if err := WriteJSONFileAtomic(dir, "r.json", jf); err != nil {
+		t.Fatal(err)
+	}
+
+	// a changes; b is rewritten with the same content in another shape.
+	next := JSONFile{Meta: jf.Meta, Items: map[string]json.RawMessage{
+		"a": json.RawMessage(`{"v":"z","rev":1}`),
+		"b": json.RawMessage(`{"n":1.0,"v":"y"}`),
+	}}
+	if err := WriteJSONFileAtomic(dir, "r.json", next); err != nil {
+		t.Fatal(err)
+	}
+	got, err := loadOne(filepath.Join(dir, "r.json"), "r.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ItemRev(got.Items["a"]) != 2 || ItemRev(got.Items["b"]) != 1 {
+		t.Fatalf("revs: a=%s b=%s", got.Items["a"], got.Items["b"])
+	}
+}
@@ -10,37 +10,81 @@ import (
 )
 
 // WriteJSONFileAtomic writes a JSONFile to `dir/filename` atomically, with backup.
+// Item revisions are stamped against the current file (see revisions.go) and
+// PII fields are encrypted when a keyring is set (see pii.go).
 func WriteJSONFileAtomic(dir, filename string, jf JSONFile) error {
 	if dir == "" {
 		return fmt.Errorf("storage dir is empty")
 	}
+	if err := checkWritable(dir); err != nil {
+		return err
+	}
+	if err := checkLocked(dir, filename); err != nil {
+		return err
+	}
 	full := filepath.Join(dir, filename)
 
 	if err := os.MkdirAll(filepath.Dir(full), 0o755); err != nil {
 		return fmt.Errorf("mkdir: %w", err)
 	}
 
-	// Backup if file exists
-	if _, err := os.Stat(full); err == nil {
-		ts := time.Now().Format("20060102_150405")
-		bak := full + ".bak." + ts
-		if err := copyFile(full, bak); err != nil {
-			return fmt.Errorf("backup failed: %w", err)
-		}
+	if err := stampFile(full, filename, jf); err != nil {
+		return err
+	}
+	jf, err := encryptPII(filename, jf)
+	if err != nil {
+		return err
+	}
+	if err := backupFile(full); err != nil {
+		return err
 	}
 
-	b, err := json.Marshal(jf)
+	b, err := marshalJSONFile(jf)
 	if err != nil {
-		return fmt.Errorf("marshal: %w", err)
+		return err
 	}
-	b = append(b, '\n')
 
 	tmp := full + ".tmp"
-	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o644)
+	if err := writeFileSync(tmp, b); err != nil {
+		return err
+	}
+
+	if err := os.Rename(tmp, full); err != nil {
+		return fmt.Errorf("rename: %w", err)
+	}
+
+	if err := fsyncDir(filepath.Dir(full)); err != nil {
+		return fmt.Errorf("fsync dir: %w", err)
+	}
+	return nil
+}
+
+// backupFile copies an existing file to `<file>.bak.YYYYMMDD_HHMMSS`. A missing file is not an error.
+func backupFile(full string) error {
+	if _, err := os.Stat(full); err != nil {
+		return nil
+	}
+	ts := time.Now().Format("20060102_150405")
+	if err := copyFile(full, full+".bak."+ts); err != nil {
+		return fmt.Errorf("backup failed: %w", err)
+	}
+	return nil
+}
+
+func marshalJSONFile(jf JSONFile) ([]byte, error) {
+	b, err := json.Marshal(jf)
 	if err != nil {
-		return fmt.Errorf("open tmp: %w", err)
+		return nil, fmt.Errorf("marshal: %w", err)
 	}
+	return append(b, '\n'), nil
+}
 
+// writeFileSync writes b to path and fsyncs it before returning.
+func writeFileSync(path string, b []byte) error {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("open tmp: %w", err)
+	}
 	if _, err := f.Write(b); err != nil {
 		_ = f.Close()
 		return fmt.Errorf("write tmp: %w", err)
@@ -52,14 +96,6 @@ func WriteJSONFileAtomic(dir, filename string, jf JSONFile) error {
 	if err := f.Close(); err != nil {
 		return fmt.Errorf("close tmp: %w", err)
 	}
-
-	if err := os.Rename(tmp, full); err != nil {
-		return fmt.Errorf("rename: %w", err)
-	}
-
-	if err := fsyncDir(filepath.Dir(full)); err != nil {
-		return fmt.Errorf("fsync dir: %w", err)
-	}
 	return nil
 }
 